@@ -4,21 +4,30 @@ import (
 	"cryptopump/exchange"
 	"cryptopump/functions"
 	"cryptopump/markets"
-	"cryptopump/mysql"
 	"cryptopump/plotter"
+	"cryptopump/risk"
+	"cryptopump/stream"
+	"cryptopump/telegram"
 	"cryptopump/threads"
 	"cryptopump/types"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"math"
 	"sync"
 	"time"
 
-	"github.com/adshao/go-binance/v2"
-
+	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
 )
 
+const listenKeyKeepAlive = 30 * time.Minute /* Listen keys expire after 60 minutes without keep alive */
+
+/* Reason each session is holding an order because of the order book, logged once */
+var holds = struct {
+	sync.Mutex
+	m map[*types.Session]string
+}{m: make(map[*types.Session]string)}
+
 /* Modify profit based on sell transaction count  */
 func calculateProfit(
 	configData *types.Config,
@@ -54,7 +63,7 @@ func UpdatePendingOrders(
 	var orderID int64
 	var orderStatus *types.Order
 
-	if orderID, _, err = mysql.GetOrderTransactionPending(sessionData); err != nil {
+	if orderID, _, err = sessionData.Storage.GetOrderTransactionPending(sessionData); err != nil {
 
 		/* Cleanly exit ThreadID */
 		threads.ExitThreadID(sessionData)
@@ -73,7 +82,8 @@ func UpdatePendingOrders(
 		}
 
 		/* Update order status */
-		if err := mysql.UpdateOrder(
+		if err := exchange.UpdateOrder(
+			configData,
 			sessionData,
 			int64(orderStatus.OrderID),
 			orderStatus.CumulativeQuoteQuantity,
@@ -104,9 +114,10 @@ func is24hsHighPrice(
 This function help to avoid issue when a sale happen in the same seccond as the Buy transaction. Duration must be provided in seconds */
 func isOrderInTimeRangeToSell(
 	order types.Order,
+	sessionData *types.Session,
 	timeRange time.Duration) bool {
 
-	timeNow := time.Now()
+	timeNow := functions.Now(sessionData)
 	timeTransaction := time.Unix(order.TransactTime/1000, 0)
 
 	return timeNow.Sub(timeTransaction).Seconds() > float64(timeRange)
@@ -120,7 +131,7 @@ func isBuyUpmarket(
 	sessionData *types.Session) (bool, float64) {
 
 	var err error
-	var lastOrderTransactionPrice float64
+	var lastOrderTransactionPrice decimal.Decimal
 	var lastOrderTransactionSide string
 	var threadTransactiontUpmarketPriceCount int
 	var order types.Order
@@ -146,7 +157,7 @@ func isBuyUpmarket(
 
 	}
 
-	if lastOrderTransactionPrice, err = mysql.GetLastOrderTransactionPrice(
+	if lastOrderTransactionPrice, err = sessionData.Storage.GetLastOrderTransactionPrice(
 		sessionData,
 		"SELL"); err != nil {
 
@@ -155,7 +166,7 @@ func isBuyUpmarket(
 	}
 
 	/* Test if event price is lower than last Sell price plus threshold up */
-	if marketData.Price < lastOrderTransactionPrice*(1+configData.BuyRepeatThresholdUp) {
+	if decimal.NewFromFloat(marketData.Price).LessThan(lastOrderTransactionPrice.Mul(decimal.NewFromFloat(1 + configData.BuyRepeatThresholdUp))) {
 
 		return false, 0
 
@@ -163,7 +174,7 @@ func isBuyUpmarket(
 
 	/* Retrieve the last transaction side and if it's a BUY exit.
 	This avoid double BUY on the UP side */
-	if lastOrderTransactionSide, err = mysql.GetLastOrderTransactionSide(sessionData); err != nil {
+	if lastOrderTransactionSide, err = sessionData.Storage.GetLastOrderTransactionSide(sessionData); err != nil {
 
 		return false, 0
 
@@ -184,20 +195,22 @@ func isBuyUpmarket(
 		order.ExecutedQuantity,
 		order.CumulativeQuoteQuantity,
 		order.TransactTime,
-		err = mysql.GetThreadLastTransaction(sessionData); err != nil {
+		err = sessionData.Storage.GetThreadLastTransaction(sessionData); err != nil {
 
 		return false, 0
 
 	}
 
 	/* See comment above */
-	if marketData.Price > order.Price &&
-		marketData.Price < (order.Price*(1+(configData.ProfitMin/2))) {
+	price := decimal.NewFromFloat(marketData.Price)
+
+	if price.GreaterThan(order.Price) &&
+		price.LessThan(order.Price.Mul(decimal.NewFromFloat(1+(configData.ProfitMin/2)))) {
 
 		return false, 0
 
-	} else if marketData.Price < order.Price &&
-		marketData.Price > (order.Price*(1-(configData.ProfitMin/2))) {
+	} else if price.LessThan(order.Price) &&
+		price.GreaterThan(order.Price.Mul(decimal.NewFromFloat(1-(configData.ProfitMin/2)))) {
 
 		return false, 0
 
@@ -205,7 +218,7 @@ func isBuyUpmarket(
 
 	/* 		This function retrieve the number of thread transactions with price bigger than current price times buy_repeat_threshold_up.
 	   		It servers the purpose of ensuring the algorithm does not buy above the biggest buy. If more more than 1 transaction will not execute buy. */
-	if threadTransactiontUpmarketPriceCount, err = mysql.GetThreadTransactiontUpmarketPriceCount(
+	if threadTransactiontUpmarketPriceCount, err = sessionData.Storage.GetThreadTransactiontUpmarketPriceCount(
 		sessionData,
 		(marketData.Price * (1 + configData.BuyRepeatThresholdUp))); err != nil {
 
@@ -255,7 +268,7 @@ func isBuyDownmarket(
 	sessionData *types.Session) (bool, float64) {
 
 	var err error
-	var lastOrderTransactionPrice float64
+	var lastOrderTransactionPrice decimal.Decimal
 	var side1, side2 string
 
 	/* If BUY Down amount is 0 do not buy */
@@ -279,9 +292,16 @@ func isBuyDownmarket(
 
 	}
 
+	/* Validate Bollinger %B lower than buy_bollinger_percentb */
+	if !isBuyBollinger(configData, marketData) {
+
+		return false, 0
+
+	}
+
 	/* Ensure funds are not deployed less than buy_repeat_threshold_down from each other */
 	buyRepeatThresholdDown := configData.BuyRepeatThresholdDown
-	if lastOrderTransactionPrice, err = mysql.GetLastOrderTransactionPrice(
+	if lastOrderTransactionPrice, err = sessionData.Storage.GetLastOrderTransactionPrice(
 		sessionData,
 		"BUY"); err != nil {
 
@@ -290,14 +310,14 @@ func isBuyDownmarket(
 	}
 
 	/* Test with with buy_repeat_threshold_down to reduce sql queries */
-	if marketData.Price > (lastOrderTransactionPrice * (1 - buyRepeatThresholdDown)) {
+	if decimal.NewFromFloat(marketData.Price).GreaterThan(lastOrderTransactionPrice.Mul(decimal.NewFromFloat(1 - buyRepeatThresholdDown))) {
 
 		return false, 0
 
 	}
 
 	/* Change percentage if last and 2nd orders are BUY */
-	if side1, side2, err = mysql.GetOrderTransactionSideLastTwo(sessionData); err != nil {
+	if side1, side2, err = sessionData.Storage.GetOrderTransactionSideLastTwo(sessionData); err != nil {
 
 		return false, 0
 
@@ -311,7 +331,7 @@ func isBuyDownmarket(
 	}
 
 	/* Test with new buy_repeat_threshold_down */
-	if marketData.Price > (lastOrderTransactionPrice * (1 - buyRepeatThresholdDown)) {
+	if decimal.NewFromFloat(marketData.Price).GreaterThan(lastOrderTransactionPrice.Mul(decimal.NewFromFloat(1 - buyRepeatThresholdDown))) {
 
 		return false, 0
 
@@ -337,7 +357,9 @@ func isBuyInitial(
 
 	/* Validate RSI7 lower than buy_rsi7_entry */
 	/* Validate RSI3 not negative */
-	if marketData.Rsi7 < configData.BuyRsi7Entry && marketData.Rsi3 > 0 {
+	/* Validate Bollinger %B lower than buy_bollinger_percentb */
+	if marketData.Rsi7 < configData.BuyRsi7Entry && marketData.Rsi3 > 0 &&
+		isBuyBollinger(configData, marketData) {
 
 		/* Do not log if DryRun mode set to true */
 		if !configData.DryRun {
@@ -361,43 +383,255 @@ func isBuyInitial(
 
 }
 
-/* Stop goroutine channels */
-func stopChannels(
-	channel chan struct{},
-	wg *sync.WaitGroup,
+/* Validate Bollinger %B lower than buy_bollinger_percentb when buy_bollinger is enabled.
+Buying is held until the bands are available. */
+func isBuyBollinger(
 	configData *types.Config,
-	sessionData *types.Session) {
+	marketData *types.Market) bool {
+
+	if !configData.BuyBollinger {
 
-	sessionData.StopWs = true /* Set goroutine channels to stop */
-	channel <- struct{}{}     /* Stop channel that caused initial error */
+		return true
+
+	}
+
+	return marketData.BollingerUpper > marketData.BollingerLower &&
+		marketData.BollingerPercentB < configData.BuyBollingerPercentB
 
 }
 
-// WsUserDataServe Websocket routine to retrieve realtime user data
-func WsUserDataServe(
+/* Validate the BUY conditions on kline interval indicators, such as 1h rsi14 < 50.
+Buying is held until the indicators of every interval referenced are warmed up, and refused while the conditions are invalid. */
+func isBuyConditions(
 	configData *types.Config,
-	sessionData *types.Session,
-	wg *sync.WaitGroup) {
+	marketData *types.Market) bool {
+
+	if configData.BuyConditionError != nil {
+
+		return false
+
+	}
+
+	for _, condition := range configData.BuyConditions {
+
+		value, ok := markets.GetIndicator(marketData, condition.Interval, condition.Indicator)
+
+		if !ok {
+
+			return false
+
+		}
+
+		switch condition.Operator {
+		case "<":
+
+			ok = value < condition.Value
+
+		case "<=":
+
+			ok = value <= condition.Value
+
+		case ">":
+
+			ok = value > condition.Value
+
+		case ">=":
+
+			ok = value >= condition.Value
+
+		default:
+
+			return false
+
+		}
+
+		if !ok {
+
+			return false
+
+		}
+
+	}
+
+	return true
+
+}
+
+/* Stop-Loss and Trailing-Stop. The high-water mark of thread transactions is persisted to survive restarts.
+ForceSell is set for the sale to execute as a market order. */
+func isSellStop(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session) (bool, types.Order) {
 
-	var doneC chan struct{}
-	var stopC chan struct{}
 	var err error
+	var order types.Order
 
-	/* Retrieve listen key for user stream service */
-	sessionData.ListenKey, _ = exchange.GetUserStreamServiceListenKey(configData, sessionData)
+	if configData.StopLoss > 0 {
 
-	wsHandler := &types.WsHandler{}
-	wsHandler.BinanceWsUserDataServe = func(message []byte) {
+		/* Retrieve highest price order the market price has fallen stop_loss below */
+		if order.OrderID,
+			order.Price,
+			order.ExecutedQuantity,
+			order.CumulativeQuoteQuantity,
+			order.TransactTime,
+			err = sessionData.Storage.GetThreadTransactionByStopLoss(
+			marketData,
+			sessionData,
+			configData.StopLoss); err == nil && order.OrderID != 0 {
 
-		/* Stop Ws channel */
-		if sessionData.StopWs {
+			functions.Logger(&types.LogEntry{
+				Config:   configData,
+				Market:   marketData,
+				Session:  sessionData,
+				Order:    &order,
+				Message:  "STOPLOSS",
+				LogLevel: log.InfoLevel,
+			})
 
-			defer wg.Done()
-			stopC <- struct{}{}
-			return
+			sessionData.ForceSell = true
+
+			return true, order
+
+		}
+
+	}
+
+	if configData.TrailingStop > 0 {
+
+		/* Raise high-water mark for thread transactions */
+		if err = sessionData.Storage.UpdateThreadTransactionHighPrice(
+			marketData,
+			sessionData); err != nil {
+
+			return false, order
+
+		}
+
+		/* Retrieve lowest price order the market price has fallen trailing_stop below the high-water mark */
+		if order.OrderID,
+			order.Price,
+			order.ExecutedQuantity,
+			order.CumulativeQuoteQuantity,
+			order.TransactTime,
+			err = sessionData.Storage.GetThreadTransactionByTrailingStop(
+			marketData,
+			sessionData,
+			configData.TrailingStop); err == nil && order.OrderID != 0 {
+
+			functions.Logger(&types.LogEntry{
+				Config:   configData,
+				Market:   marketData,
+				Session:  sessionData,
+				Order:    &order,
+				Message:  "TRAIL",
+				LogLevel: log.InfoLevel,
+			})
+
+			sessionData.ForceSell = true
+
+			return true, order
+
+		}
+
+	}
+
+	return false, order
+
+}
+
+// ProcessExecutionReport Apply executionReport fills, partial fills, cancels and rejects to the orders table and wake order waits
+func ProcessExecutionReport(
+	configData *types.Config,
+	sessionData *types.Session,
+	executionReport *types.ExecutionReport) {
+
+	if executionReport.Symbol != sessionData.Symbol {
+
+		return
+
+	}
+
+	order := &types.Order{
+		ClientOrderID:           executionReport.ClientOrderID,
+		CumulativeQuoteQuantity: functions.StrToDecimal(executionReport.CumulativeQuoteQty),
+		ExecutedQuantity:        functions.StrToDecimal(executionReport.CumulativeQty),
+		OrderID:                 executionReport.OrderID,
+		Price:                   functions.StrToDecimal(executionReport.Price),
+		Side:                    executionReport.Side,
+		Status:                  executionReport.Status,
+		Symbol:                  executionReport.Symbol,
+		TransactTime:            executionReport.TransactTime,
+	}
+
+	/* Average fill price, or the order price while nothing is filled */
+	if order.ExecutedQuantity.IsPositive() {
+
+		order.Price = order.CumulativeQuoteQuantity.Div(order.ExecutedQuantity)
+
+	}
+
+	/* Capture the commission of each trade, converted to the quote currency at the trade price. Trade ID is -1 for events without a trade.
+	Commissions are recorded while the order is waiting to be filled, as orders filled in the order response are saved with the commission of their fills. */
+	if executionReport.ExecutionType == "TRADE" &&
+		executionReport.TradeID > 0 {
+
+		commissionAmount := functions.StrToDecimal(executionReport.ComissionAmount)
+
+		_ = sessionData.Storage.UpdateOrderCommission(
+			sessionData,
+			int64(order.OrderID),
+			int64(executionReport.TradeID),
+			commissionAmount,
+			executionReport.ComissionAsset,
+			exchange.GetCommission(
+				configData,
+				sessionData,
+				commissionAmount,
+				executionReport.ComissionAsset,
+				functions.StrToDecimal(executionReport.LastExecutedPrice)))
+
+	}
+
+	switch executionReport.ExecutionType {
+	case "TRADE", "CANCELED", "REJECTED", "EXPIRED":
 
+		/* Update order status, quantities and price */
+		_ = exchange.UpdateOrder(
+			configData,
+			sessionData,
+			int64(order.OrderID),
+			order.CumulativeQuoteQuantity,
+			order.ExecutedQuantity,
+			order.Price,
+			order.Status)
+
+	}
+
+	/* Wake order waits without blocking the user data stream */
+	if sessionData.OrderUpdate != nil {
+
+		select {
+		case sessionData.OrderUpdate <- order:
+		default:
 		}
 
+	}
+
+}
+
+// WsUserDataServe Websocket routine to retrieve realtime user data.
+// A new listen key is retrieved on every connection, and the listen key is kept alive by the stream, reconnecting when it expired.
+func WsUserDataServe(
+	configData *types.Config,
+	sessionData *types.Session,
+	wg *sync.WaitGroup) {
+
+	defer wg.Done()
+
+	wsHandler := &types.WsHandler{}
+	wsHandler.WsUserDataServe = func(message []byte) {
+
 		var executionReport = &types.ExecutionReport{}
 		var outboundAccountPosition = &types.OutboundAccountPosition{}
 
@@ -415,6 +649,11 @@ func WsUserDataServe(
 
 		} else if executionReport.EventType == "executionReport" {
 
+			ProcessExecutionReport(
+				configData,
+				sessionData,
+				executionReport)
+
 			return
 
 		}
@@ -433,13 +672,22 @@ func WsUserDataServe(
 
 		} else if outboundAccountPosition.EventType == "outboundAccountPosition" {
 
+			/* Track the balance of the symbol quote asset from the symbols registry */
+			symbolFiat := sessionData.SymbolFiat
+
+			if info, err := exchange.GetSymbol(configData, sessionData, sessionData.Symbol); err == nil {
+
+				symbolFiat = info.QuoteAsset
+
+			}
+
 			for key := range outboundAccountPosition.Balances {
 
-				if outboundAccountPosition.Balances[key].Asset == sessionData.SymbolFiat {
+				if outboundAccountPosition.Balances[key].Asset == symbolFiat {
 
 					sessionData.SymbolFiatFunds = functions.StrToFloat64(outboundAccountPosition.Balances[key].Free)
 
-					_ = mysql.UpdateSession(
+					_ = sessionData.Storage.UpdateSession(
 						configData,
 						sessionData)
 
@@ -453,71 +701,76 @@ func WsUserDataServe(
 
 	}
 
-	errHandler := func(err error) {
+	stream.Serve(configData, sessionData, &stream.Stream{
+		Name: "userData",
+		Connect: func(event func(), errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
 
-		functions.Logger(&types.LogEntry{
-			Config:   configData,
-			Market:   nil,
-			Session:  sessionData,
-			Order:    &types.Order{},
-			Message:  functions.GetFunctionName() + " - " + err.Error(),
-			LogLevel: log.DebugLevel,
-		})
+			/* Retrieve listen key for user stream service */
+			if sessionData.ListenKey, err = exchange.GetUserStreamServiceListenKey(configData, sessionData); err != nil {
 
-		switch {
-		case strings.Contains(err.Error(), "1006"):
-			/* -1006 UNEXPECTED_RESP An unexpected response was received from the message bus. Execution status unknown. */
-			/* Error Codes for Binance https://github.com/binance/binance-spot-api-docs/blob/master/errors.md */
+				return nil, nil, err
 
-			return
-
-		}
-
-		stopChannels(stopC, wg, configData, sessionData)
+			}
 
-		/* Retrieve NEW WsUserDataServe listen key for user stream service when there's an error */
-		sessionData.ListenKey, _ = exchange.GetUserStreamServiceListenKey(configData, sessionData)
+			return exchange.WsUserDataServe(configData, sessionData, &types.WsHandler{
+				WsUserDataServe: func(message []byte) {
 
-	}
+					event()
+					wsHandler.WsUserDataServe(message)
 
-	doneC, stopC, err = exchange.WsUserDataServe(configData, sessionData, wsHandler, errHandler)
+				},
+			}, errHandler)
 
-	if err != nil {
+		},
+		KeepAlive: func() error {
 
-		fmt.Println(err)
+			return exchange.KeepAliveUserStreamServiceListenKey(configData, sessionData)
 
-	}
-
-	<-doneC
+		},
+		KeepAliveInterval: listenKeyKeepAlive,
+	})
 
 }
 
-// WsKline The Kline/Candlestick Stream push updates to the current klines/candlestick every second.
+// WsKline The Kline/Candlestick Stream push updates to the current klines/candlestick of the interval every second.
 func WsKline(
 	configData *types.Config,
 	marketData *types.Market,
 	sessionData *types.Session,
+	interval string,
 	wg *sync.WaitGroup) {
 
-	var doneC chan struct{}
-	var stopC chan struct{}
-	var err error
+	defer wg.Done()
 
 	wsHandler := &types.WsHandler{}
-	wsHandler.BinanceWsKline = func(event *binance.WsKlineEvent) {
+	wsHandler.WsKline = func(kline types.WsKline) {
+
+		/* Klines of other intervals only update their timeframe */
+		if interval != "1m" {
+
+			if kline.IsFinal {
+
+				/* Load Final kline for technical analysis */
+				markets.LoadKlineData(
+					configData,
+					sessionData,
+					marketData,
+					kline)
+
+				/* Save Final kline to the database */
+				markets.SaveKline(
+					sessionData,
+					kline)
 
-		/* Stop Ws channel */
-		if sessionData.StopWs {
+			}
 
-			defer wg.Done()
-			stopC <- struct{}{}
 			return
 
 		}
 
 		/* Analyse Volume kline direction and create marketData.Direction. 0 = SELL / 1+ BUY */
-		activeSellVolume := (functions.StrToFloat64(event.Kline.Volume) - functions.StrToFloat64(event.Kline.ActiveBuyVolume))
-		if activeSellVolume > functions.StrToFloat64(event.Kline.ActiveBuyVolume) {
+		activeSellVolume := (functions.StrToFloat64(kline.Volume) - functions.StrToFloat64(kline.ActiveBuyVolume))
+		if activeSellVolume > functions.StrToFloat64(kline.ActiveBuyVolume) {
 
 			marketData.Direction = 0
 
@@ -527,56 +780,61 @@ func WsKline(
 
 		}
 
-		if event.Kline.IsFinal {
+		if kline.IsFinal {
 
 			/* Load Final kline for technical analysis */
 			markets.LoadKlineData(
 				configData,
 				sessionData,
 				marketData,
-				exchange.BinanceMapWsKline(event.Kline))
+				kline)
 
 			/* Load Final kline for e-chart plotting */
 			plotter.LoadKlineData(
 				sessionData,
-				exchange.BinanceMapWsKline(event.Kline))
+				kline)
+
+			/* Save Final kline to the database */
+			markets.SaveKline(
+				sessionData,
+				kline)
 
 		}
 
 	}
 
-	errHandler := func(err error) {
+	stream.Serve(configData, sessionData, &stream.Stream{
+		Name: "kline " + interval,
+		Connect: func(event func(), errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
 
-		functions.Logger(&types.LogEntry{
-			Config:   configData,
-			Market:   nil,
-			Session:  sessionData,
-			Order:    &types.Order{},
-			Message:  functions.GetFunctionName() + " - " + err.Error(),
-			LogLevel: log.DebugLevel,
-		})
+			return exchange.WsKlineServe(configData, sessionData, interval, &types.WsHandler{
+				WsKline: func(kline types.WsKline) {
 
-		switch {
-		case strings.Contains(err.Error(), "unexpected EOF"):
-			/* -unexpected EOF An unexpected response was received from the message bus. Execution status unknown. */
+					event()
+					wsHandler.WsKline(kline)
 
-			return
-
-		}
+				},
+			}, errHandler)
 
-		stopChannels(stopC, wg, configData, sessionData)
+		},
+		Resume: func() {
 
-	}
-
-	doneC, stopC, err = exchange.WsKlineServe(configData, sessionData, wsHandler, errHandler)
-
-	if err != nil {
+			/* Retrieve the klines missed while reconnecting and load them for e-chart plotting */
+			for _, kline := range markets.Backfill(
+				configData,
+				marketData,
+				sessionData,
+				interval) {
 
-		fmt.Println(err)
+				plotter.LoadKlineData(
+					sessionData,
+					kline)
 
-	}
+			}
 
-	<-doneC
+		},
+		Stale: time.Duration(configData.WsStaleTimeout) * time.Second,
+	})
 
 }
 
@@ -587,21 +845,10 @@ func WsBookTicker(
 	sessionData *types.Session,
 	wg *sync.WaitGroup) {
 
-	var doneC chan struct{}
-	var stopC chan struct{}
-	var err error
+	defer wg.Done()
 
 	wsHandler := &types.WsHandler{}
-	wsHandler.BinanceWsBookTicker = func(event *binance.WsBookTickerEvent) {
-
-		/* Stop Ws channel */
-		if sessionData.StopWs {
-
-			defer wg.Done()
-			stopC <- struct{}{}
-			return
-
-		}
+	wsHandler.WsBookTicker = func(event *types.WsBookTicker) {
 
 		/* If there are 0 ThreadID transactions and configData.Exit is True the ThreadID is gracefully
 		finalized, and the ThreadID is unlocked. */
@@ -623,111 +870,356 @@ func WsBookTicker(
 
 			marketData.Price = functions.StrToFloat64(event.BestAskPrice)
 
-			if is, buyQuantityFiat := BuyDecisionTree(
+			Trade(
 				configData,
 				marketData,
-				sessionData); is {
+				sessionData)
 
-				exchange.BuyTicker(
-					buyQuantityFiat,
-					configData,
-					marketData,
-					sessionData)
+		}
 
-				/* Update ThreadCount after BUY */
-				sessionData.ThreadCount, err = mysql.GetThreadTransactionCount(sessionData)
+		/* Reload config data every 10 seconds */
+		if time.Now().Second()%10 == 0 {
 
-			} else if is, order := SellDecisionTree(
-				configData,
-				marketData,
-				sessionData); is {
+			configData = functions.GetConfigData(sessionData)
 
-				exchange.SellTicker(
-					order,
-					configData,
-					marketData,
-					sessionData)
+		}
 
-				/* Update ThreadCount after SELL */
-				sessionData.ThreadCount, _ = mysql.GetThreadTransactionCount(sessionData)
+	}
 
-				/* Update Number of Sale Transactions per hour */
-				sessionData.SellTransactionCount, err = mysql.GetOrderTransactionCount(sessionData, "SELL")
+	stream.Serve(configData, sessionData, &stream.Stream{
+		Name: "bookTicker",
+		Connect: func(event func(), errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
 
-			}
+			return exchange.WsBookTickerServe(configData, sessionData, &types.WsHandler{
+				WsBookTicker: func(ticker *types.WsBookTicker) {
+
+					event()
+					wsHandler.WsBookTicker(ticker)
+
+				},
+			}, errHandler)
+
+		},
+		Stale: time.Duration(configData.WsStaleTimeout) * time.Second,
+	})
+
+}
+
+// WsDepth Pushes the top levels of the order book every 100ms, maintained as the local order book
+func WsDepth(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session,
+	wg *sync.WaitGroup) {
+
+	defer wg.Done()
+
+	stream.Serve(configData, sessionData, &stream.Stream{
+		Name: "depth",
+		Connect: func(event func(), errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
+
+			return exchange.WsDepthServe(configData, sessionData, &types.WsHandler{
+				WsDepth: func(depth *types.WsDepth) {
+
+					event()
+
+					markets.LoadDepth(
+						sessionData,
+						marketData,
+						depth)
+
+				},
+			}, errHandler)
+
+		},
+		Stale: time.Duration(configData.WsStaleTimeout) * time.Second,
+	})
+
+}
+
+// Trade Run the BUY and SELL decision trees for the market price and execute the resulting order
+func Trade(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session) {
+
+	if is, buyQuantityFiat := BuyDecisionTree(
+		configData,
+		marketData,
+		sessionData); is {
+
+		/* Validate the spread and expected fill price, downsizing the BUY to the order book depth */
+		if is, buyQuantityFiat = isBuyExecutable(
+			configData,
+			marketData,
+			sessionData,
+			buyQuantityFiat); !is {
+
+			return
 
 		}
 
-		/* Reload config data every 10 seconds */
-		if time.Now().Second()%10 == 0 {
+		exchange.BuyTicker(
+			buyQuantityFiat,
+			configData,
+			marketData,
+			sessionData)
 
-			configData = functions.GetConfigData(sessionData)
+		/* Update ThreadCount after BUY */
+		sessionData.ThreadCount, _ = sessionData.Storage.GetThreadTransactionCount(sessionData)
+
+	} else if is, order := SellDecisionTree(
+		configData,
+		marketData,
+		sessionData); is {
+
+		/* Validate the spread and expected fill price of market SELL */
+		if !isSellExecutable(
+			configData,
+			marketData,
+			sessionData,
+			order) {
+
+			return
 
 		}
 
+		exchange.SellTicker(
+			order,
+			configData,
+			marketData,
+			sessionData)
+
+		/* Update ThreadCount after SELL */
+		sessionData.ThreadCount, _ = sessionData.Storage.GetThreadTransactionCount(sessionData)
+
+		/* Update Number of Sale Transactions per hour */
+		sessionData.SellTransactionCount, _ = sessionData.Storage.GetOrderTransactionCount(sessionData, "SELL")
+
 	}
 
-	errHandler := func(err error) {
+}
 
-		functions.Logger(&types.LogEntry{
-			Config:   configData,
-			Market:   nil,
-			Session:  sessionData,
-			Order:    &types.Order{},
-			Message:  functions.GetFunctionName() + " - " + err.Error(),
-			LogLevel: log.DebugLevel,
-		})
+/* Validate the spread and the expected fill price of a market BUY of buyQuantityFiat against the local order book.
+The BUY is held while the spread exceeds MaxSpread, and downsized to the fiat quantity filled within MaxSlippage. */
+func isBuyExecutable(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session,
+	buyQuantityFiat float64) (bool, float64) {
 
-		switch {
-		case strings.Contains(err.Error(), "1006"):
-			/* -1006 UNEXPECTED_RESP An unexpected response was received from the message bus. Execution status unknown. */
-			/* Error Codes for Binance https://github.com/binance/binance-spot-api-docs/blob/master/errors.md */
+	book := markets.GetBook(sessionData, marketData)
 
-			return
+	/* Order book not available, such as in backtests */
+	if book == nil {
 
-		case strings.Contains(err.Error(), "1008"):
-			/* websocket: close 1008 (policy violation): Pong timeout */
-			/* 1008 indicates that an endpoint is terminating the connection
-			because it has received a message that violates its policy.  This
-			is a generic status code that can be returned when there is no
-			other more suitable status code (e.g., 1003 or 1009) or if there
-			is a need to hide specific details about the policy. */
+		return true, buyQuantityFiat
 
-			exchange.GetClient(configData, sessionData) /* Reconnect exchange client */
+	}
 
-		case strings.Contains(err.Error(), "unexpected EOF"):
-			/* -unexpected EOF An unexpected response was received from the message bus. Execution status unknown. */
+	if spread := markets.Spread(book); configData.MaxSpread > 0 && spread > configData.MaxSpread {
 
-			return
+		logHeld(configData, marketData, sessionData, "SPREAD - BUY held", fmt.Sprintf("spread %.4f exceeds %.4f", spread, configData.MaxSpread))
+		return false, 0
+
+	}
+
+	logHeld(configData, marketData, sessionData, "", "")
+
+	if configData.MaxSlippage == 0 {
+
+		return true, buyQuantityFiat
+
+	}
+
+	limit := book.Asks[0].Price * (1 + configData.MaxSlippage)
+
+	if price, ok := markets.EstimateBuy(book, buyQuantityFiat); ok && price <= limit {
+
+		return true, buyQuantityFiat
+
+	}
+
+	fiat := math.Min(markets.BuyFiatWithin(book, limit), buyQuantityFiat)
+
+	if fiat <= 0 {
+
+		logHeld(configData, marketData, sessionData, "SLIPPAGE - BUY held", fmt.Sprintf("no depth within %.4f", configData.MaxSlippage))
+		return false, 0
+
+	}
+
+	functions.Logger(&types.LogEntry{
+		Config:   configData,
+		Market:   marketData,
+		Session:  sessionData,
+		Order:    &types.Order{},
+		Message:  fmt.Sprintf("SLIPPAGE - BUY downsized from %.2f to %.2f within %.4f", buyQuantityFiat, fiat, configData.MaxSlippage),
+		LogLevel: log.InfoLevel,
+	})
+
+	return true, fiat
+
+}
+
+/* Validate the spread and the expected fill price of a market SELL against the local order book.
+Limit SELL are placed at the market price and are not validated. Market SELL are held while the spread exceeds MaxSpread or the expected fill price slips beyond MaxSlippage. */
+func isSellExecutable(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session,
+	order types.Order) bool {
 
-		case strings.Contains(err.Error(), "connection reset by peer"):
+	book := markets.GetBook(sessionData, marketData)
 
-			exchange.GetClient(configData, sessionData) /* Reconnect exchange client */
+	if !sessionData.ForceSell || book == nil {
+
+		return true
+
+	}
+
+	held, reason := "", ""
+
+	if spread := markets.Spread(book); configData.MaxSpread > 0 && spread > configData.MaxSpread {
+
+		held, reason = "SPREAD - SELL held", fmt.Sprintf("spread %.4f exceeds %.4f", spread, configData.MaxSpread)
+
+	} else if configData.MaxSlippage > 0 {
+
+		if price, ok := markets.EstimateSell(book, order.ExecutedQuantity.InexactFloat64()); !ok || price < book.Bids[0].Price*(1-configData.MaxSlippage) {
+
+			held, reason = "SLIPPAGE - SELL held", fmt.Sprintf("expected fill price %.4f slips beyond %.4f", price, configData.MaxSlippage)
 
 		}
 
-		stopChannels(stopC, wg, configData, sessionData)
+	}
+
+	logHeld(configData, marketData, sessionData, held, reason)
+
+	if held == "" {
+
+		return true
 
 	}
 
-	doneC, stopC, err = exchange.WsBookTickerServe(configData, sessionData, wsHandler, errHandler)
+	/* Market SELL is decided again on the next price update */
+	sessionData.ForceSell = false
+
+	return false
+
+}
+
+/* Log an order held by the order book once, until the order is executable or held for another reason. Held is empty when executable. */
+func logHeld(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session,
+	held string,
+	reason string) {
+
+	holds.Lock()
+	defer holds.Unlock()
+
+	if holds.m[sessionData] == held {
+
+		return
+
+	}
 
-	if err != nil {
+	if held == "" {
 
-		fmt.Println(err)
+		delete(holds.m, sessionData)
+		return
 
 	}
 
-	<-doneC
+	holds.m[sessionData] = held
+
+	functions.Logger(&types.LogEntry{
+		Config:   configData,
+		Market:   marketData,
+		Session:  sessionData,
+		Order:    &types.Order{},
+		Message:  held + ", " + reason,
+		LogLevel: log.InfoLevel,
+	})
 
 }
 
-// BuyDecisionTree BUY decision routine
+// BuyDecisionTree BUY decision routine. BUY is refused while a risk limit is breached.
 func BuyDecisionTree(
 	configData *types.Config,
 	marketData *types.Market,
 	sessionData *types.Session) (bool, float64) {
 
+	is, buyQuantityFiat := buyDecisionTree(
+		configData,
+		marketData,
+		sessionData)
+
+	if !is {
+
+		return false, 0
+
+	}
+
+	allowed, breach := risk.Check(
+		configData,
+		marketData,
+		sessionData,
+		buyQuantityFiat)
+
+	notifyRisk(configData, sessionData, breach)
+
+	if !allowed {
+
+		return false, 0
+
+	}
+
+	return true, buyQuantityFiat
+
+}
+
+// UpdateRisk Update the risk circuit breaker, so daily loss and drawdown are tracked while not buying
+func UpdateRisk(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session) {
+
+	notifyRisk(configData, sessionData, risk.Update(configData, marketData, sessionData))
+
+}
+
+/* Notify a risk limit breached to Telegram */
+func notifyRisk(
+	configData *types.Config,
+	sessionData *types.Session,
+	breach risk.Breach) {
+
+	switch {
+	case breach.Message == "":
+
+		return
+
+	case breach.Paused:
+
+		telegram.Notify(configData, sessionData, "Risk - "+breach.Message+"\nBuying paused on all threads, /resetrisk to resume")
+
+	default:
+
+		telegram.Notify(configData, sessionData, "Risk - "+breach.Message+"\nBuying blocked until the exposure is back within the limit, resuming without reset")
+
+	}
+
+}
+
+/* BUY decision routine before risk limits */
+func buyDecisionTree(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session) (bool, float64) {
+
 	/* Protect against the exchange sending zeroed ticker pricing (seen in few occasions with Binance TestNet)*/
 	if marketData.Price == 0 {
 
@@ -753,6 +1245,13 @@ func BuyDecisionTree(
 
 	}
 
+	/* Hold BUY until the indicators are warmed up, as they are meaningless with few klines */
+	if !marketData.Ready {
+
+		return false, 0
+
+	}
+
 	/* If configData.Exit is True stop BUY. */
 	if configData.Exit {
 
@@ -761,7 +1260,7 @@ func BuyDecisionTree(
 	}
 
 	/* Validate marketData not older than 100 seconds */
-	if time.Since(marketData.TimeStamp).Seconds() > 100 {
+	if functions.Now(sessionData).Sub(marketData.TimeStamp).Seconds() > 100 {
 
 		return false, 0
 
@@ -769,7 +1268,7 @@ func BuyDecisionTree(
 
 	/* 	If last buy is less than configData.BuyWait seconds return false
 	   	This function protects against sequential buys when there's too much volatility */
-	if time.Duration(time.Since(sessionData.LastBuyTransactTime).Seconds()) < time.Duration(configData.BuyWait) {
+	if time.Duration(functions.Now(sessionData).Sub(sessionData.LastBuyTransactTime).Seconds()) < time.Duration(configData.BuyWait) {
 
 		return false, 0
 
@@ -785,6 +1284,15 @@ func BuyDecisionTree(
 
 	}
 
+	/* Check the BUY conditions on kline interval indicators */
+	if !isBuyConditions(
+		configData,
+		marketData) {
+
+		return false, 0
+
+	}
+
 	/* Check for subsequent BUY */
 	if sessionData.ThreadCount > 0 {
 
@@ -858,14 +1366,14 @@ func SellDecisionTree(
 			order.ExecutedQuantity,
 			order.CumulativeQuoteQuantity,
 			order.TransactTime,
-			_ = mysql.GetThreadLastTransaction(sessionData)
+			_ = sessionData.Storage.GetThreadLastTransaction(sessionData)
 
 		return true, order
 
 	}
 
 	/* Validate marketData is not older than 100 seconds */
-	if time.Since(marketData.TimeStamp).Seconds() > 100 {
+	if functions.Now(sessionData).Sub(marketData.TimeStamp).Seconds() > 100 {
 
 		return false, order
 
@@ -873,7 +1381,7 @@ func SellDecisionTree(
 
 	/* 	If last canceled transaction (LastSellCanceledTime) is less than (configData.SellWaitAfterCancel) seconds return false
 	   	This function protects against sequential seeling with same pricing */
-	if time.Duration(time.Since(sessionData.LastSellCanceledTime).Seconds()) < time.Duration(configData.SellWaitAfterCancel) {
+	if time.Duration(functions.Now(sessionData).Sub(sessionData.LastSellCanceledTime).Seconds()) < time.Duration(configData.SellWaitAfterCancel) {
 
 		return false, order
 
@@ -892,9 +1400,9 @@ func SellDecisionTree(
 				order.ExecutedQuantity,
 				order.CumulativeQuoteQuantity,
 				order.TransactTime,
-				_ = mysql.GetThreadLastTransaction(sessionData)
+				_ = sessionData.Storage.GetThreadLastTransaction(sessionData)
 
-			if marketData.Price < (order.Price * (1 - configData.BuyRepeatThresholdDown)) {
+			if decimal.NewFromFloat(marketData.Price).LessThan(order.Price.Mul(decimal.NewFromFloat(1 - configData.BuyRepeatThresholdDown))) {
 
 				return true, order
 
@@ -903,13 +1411,20 @@ func SellDecisionTree(
 		}
 	}
 
+	/* Stop-Loss and Trailing-Stop sell at market */
+	if is, stopOrder := isSellStop(configData, marketData, sessionData); is {
+
+		return true, stopOrder
+
+	}
+
 	/* Retrieve lowest price order from Thread database */
 	if order.OrderID,
 		order.Price,
 		order.ExecutedQuantity,
 		order.CumulativeQuoteQuantity,
 		order.TransactTime,
-		err = mysql.GetThreadTransactionByPrice(
+		err = sessionData.Storage.GetThreadTransactionByPrice(
 		marketData,
 		sessionData); err != nil {
 
@@ -927,7 +1442,7 @@ func SellDecisionTree(
 	/* Verify that an order is in a sellable time range
 	This function help to avoid issue when a sale happen in the same second as the Buy transaction.
 	Duration must be provided in seconds */
-	if !isOrderInTimeRangeToSell(order, 60) {
+	if !isOrderInTimeRangeToSell(order, sessionData, 60) {
 
 		return false, order
 
@@ -935,8 +1450,8 @@ func SellDecisionTree(
 
 	/* Current price is higher than BUY price + profits */
 	/* Modify profit based on sell transaction count  */
-	if (marketData.Price*(1+configData.ExchangeComission)) >=
-		(order.Price*(1+calculateProfit(configData, sessionData))) &&
+	if decimal.NewFromFloat(marketData.Price*(1+configData.ExchangeComission)).GreaterThanOrEqual(
+		order.Price.Mul(decimal.NewFromFloat(1+calculateProfit(configData, sessionData)))) &&
 		order.OrderID != 0 {
 
 		/* Hold sale if RSI3 above defined threshold.
@@ -947,6 +1462,15 @@ func SellDecisionTree(
 
 		}
 
+		/* Hold sale if Bollinger %B above defined threshold.
+		With a threshold of 1 the holding extends while ticker price rides the upper band */
+		if configData.SellHoldOnBollinger > 0 &&
+			marketData.BollingerPercentB > configData.SellHoldOnBollinger {
+
+			return false, order
+
+		}
+
 		return true, order
 
 	}
@@ -0,0 +1,759 @@
+package main
+
+import (
+	"crypto/subtle"
+	"cryptopump/exchange"
+	"cryptopump/functions"
+	"cryptopump/ledger"
+	"cryptopump/risk"
+	"cryptopump/stream"
+	"cryptopump/types"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+)
+
+const apiPrefix = "/api/v1/" /* Path of the versioned JSON API */
+
+/* Configuration keys updated with PUT /api/v1/config, with the type of their values: int, float, bool or string */
+var apiConfigKeys = map[string]string{
+	"bollinger_window":                             "int",
+	"bollinger_deviation":                          "float",
+	"buy_24hs_highprice_entry":                     "float",
+	"buy_direction_down":                           "int",
+	"buy_direction_up":                             "int",
+	"buy_quantity_fiat_up":                         "float",
+	"buy_quantity_fiat_down":                       "float",
+	"buy_quantity_fiat_init":                       "float",
+	"buy_rsi7_entry":                               "float",
+	"buy_condition":                                "string",
+	"buy_wait":                                     "int",
+	"buy_bollinger":                                "bool",
+	"buy_bollinger_percentb":                       "float",
+	"buy_repeat_threshold_down":                    "float",
+	"buy_repeat_threshold_down_second":             "float",
+	"buy_repeat_threshold_down_second_start_count": "int",
+	"buy_repeat_threshold_up":                      "float",
+	"exchange_comission":                           "float",
+	"ws_stale_timeout":                             "int",
+	"max_spread":                                   "float",
+	"max_slippage":                                 "float",
+	"profit_min":                                   "float",
+	"sellwaitbeforecancel":                         "int",
+	"sellwaitaftercancel":                          "int",
+	"selltocover":                                  "bool",
+	"sellholdonrsi3":                               "float",
+	"sellholdonbollinger":                          "float",
+	"risk_max_threads":                             "int",
+	"risk_max_fiat_symbol":                         "float",
+	"risk_max_fiat_total":                          "float",
+	"risk_max_daily_loss":                          "float",
+	"risk_max_drawdown":                            "float",
+	"stop_loss":                                    "float",
+	"trailing_stop":                                "float",
+	"symbol":                                       "string",
+	"symbol_fiat":                                  "string",
+	"symbol_fiat_stash":                            "float",
+	"time_enforce":                                 "bool",
+	"time_start":                                   "string",
+	"time_stop":                                    "string",
+	"debug":                                        "bool",
+	"exit":                                         "bool",
+	"dryrun_fiat_funds":                            "float",
+	"newsession":                                   "bool",
+}
+
+/* Configuration keys read-only over the API, as they select the exchange and the account traded */
+var apiConfigReadOnly = map[string]bool{
+	"dryrun":       true,
+	"exchangename": true,
+	"testnet":      true,
+}
+
+type apiStream struct {
+	Name       string `json:"name"`
+	Connected  bool   `json:"connected"`
+	Reconnects int    `json:"reconnects"`
+	LastEvent  int64  `json:"lastEvent"` /* Seconds since the last event received */
+	LastError  string `json:"lastError"`
+}
+
+type apiStatus struct {
+	ThreadID             string          `json:"threadId"`
+	Running              bool            `json:"running"`
+	Symbol               string          `json:"symbol"`
+	SymbolFiat           string          `json:"symbolFiat"`
+	SymbolFiatFunds      float64         `json:"symbolFiatFunds"`
+	Profit               float64         `json:"profit"`       /* Realized profit of all threads */
+	ProfitThreadID       float64         `json:"profitThread"` /* Realized profit of the thread */
+	Unrealized           decimal.Decimal `json:"unrealized"`   /* Profit of the open lots of the thread at the market price */
+	ThreadCount          int             `json:"threadCount"`
+	ThreadAmount         float64         `json:"threadAmount"`
+	SellTransactionCount float64         `json:"sellTransactionCount"`
+	Risk                 string          `json:"risk"` /* Limit breached pausing BUY, empty when buying is allowed */
+	Busy                 bool            `json:"busy"`
+	Streams              []apiStream     `json:"streams"`
+}
+
+type apiMarket struct {
+	Symbol             string    `json:"symbol"`
+	Price              float64   `json:"price"`
+	High               float64   `json:"high"` /* High price for 1 period */
+	Low                float64   `json:"low"`  /* Low price for 1 period */
+	Rsi3               float64   `json:"rsi3"`
+	Rsi7               float64   `json:"rsi7"`
+	Rsi14              float64   `json:"rsi14"`
+	MACD               float64   `json:"macd"`
+	Direction          int       `json:"direction"`
+	Imbalance          float64   `json:"imbalance"`
+	BollingerUpper     float64   `json:"bollingerUpper"`
+	BollingerMiddle    float64   `json:"bollingerMiddle"`
+	BollingerLower     float64   `json:"bollingerLower"`
+	BollingerPercentB  float64   `json:"bollingerPercentB"`
+	BollingerBandwidth float64   `json:"bollingerBandwidth"`
+	Ready              bool      `json:"ready"`
+	TimeStamp          time.Time `json:"timeStamp"`
+}
+
+type apiOrder struct {
+	OrderID int             `json:"orderId"`
+	Quote   decimal.Decimal `json:"quote"`
+	Price   decimal.Decimal `json:"price"`
+	Target  decimal.Decimal `json:"target"` /* Price to SELL at the minimum profit */
+}
+
+type apiThread struct {
+	ThreadID        string  `json:"threadId"`
+	Symbol          string  `json:"symbol"`
+	SymbolFiat      string  `json:"symbolFiat"`
+	SymbolFiatFunds float64 `json:"symbolFiatFunds"`
+	Busy            bool    `json:"busy"`
+}
+
+/* Serve the JSON API. Endpoints act on the thread selected by the thread query parameter, or on the session shown by the web UI. */
+func (fh *myHandler) api(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff") /* Add X-Content-Type-Options header */
+
+	/* Requests trading or changing the configuration are authorized, GET requests only read */
+	if r.Method != "GET" && !apiAuthorized(w, r) {
+
+		return
+
+	}
+
+	sessionData, marketData, ok := fh.apiSession(w, r)
+
+	if !ok {
+
+		return
+
+	}
+
+	configData := functions.GetConfigData(sessionData)
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+
+	switch {
+	case path == "status" || path == "market" || path == "orders" || path == "threads":
+
+		if r.Method != "GET" {
+
+			apiMethodNotAllowed(w, "GET")
+			return
+
+		}
+
+	case path == "config":
+
+		if r.Method != "PUT" {
+
+			apiMethodNotAllowed(w, "PUT")
+			return
+
+		}
+
+	case strings.HasPrefix(path, "actions/"):
+
+		if r.Method != "POST" {
+
+			apiMethodNotAllowed(w, "POST")
+			return
+
+		}
+
+	}
+
+	switch path {
+	case "status":
+
+		apiWrite(w, http.StatusOK, fh.apiStatus(configData, sessionData, marketData))
+
+	case "market":
+
+		apiWrite(w, http.StatusOK, apiMarket{
+			Symbol:             sessionData.Symbol,
+			Price:              marketData.Price,
+			High:               marketData.PriceChangeStatsHighPrice,
+			Low:                marketData.PriceChangeStatsLowPrice,
+			Rsi3:               marketData.Rsi3,
+			Rsi7:               marketData.Rsi7,
+			Rsi14:              marketData.Rsi14,
+			MACD:               marketData.MACD,
+			Direction:          marketData.Direction,
+			Imbalance:          marketData.Imbalance,
+			BollingerUpper:     marketData.BollingerUpper,
+			BollingerMiddle:    marketData.BollingerMiddle,
+			BollingerLower:     marketData.BollingerLower,
+			BollingerPercentB:  marketData.BollingerPercentB,
+			BollingerBandwidth: marketData.BollingerBandwidth,
+			Ready:              marketData.Ready,
+			TimeStamp:          marketData.TimeStamp,
+		})
+
+	case "orders":
+
+		orders, err := sessionData.Storage.GetThreadTransactionByThreadID(sessionData)
+
+		if err != nil {
+
+			apiError(w, configData, sessionData, http.StatusInternalServerError, err)
+			return
+
+		}
+
+		response := []apiOrder{}
+
+		for _, order := range orders {
+
+			response = append(response, apiOrder{
+				OrderID: order.OrderID,
+				Quote:   order.CumulativeQuoteQuantity,
+				Price:   order.Price,
+				Target:  order.Price.Mul(decimal.NewFromFloat(1 + configData.ProfitMin)),
+			})
+
+		}
+
+		apiWrite(w, http.StatusOK, response)
+
+	case "threads":
+
+		response := []apiThread{}
+
+		for _, thread := range fh.supervisor.Threads() {
+
+			response = append(response, apiThread{
+				ThreadID:        thread.Session.ThreadID,
+				Symbol:          thread.Session.Symbol,
+				SymbolFiat:      thread.Session.SymbolFiat,
+				SymbolFiatFunds: thread.Session.SymbolFiatFunds,
+				Busy:            thread.Session.Busy,
+			})
+
+		}
+
+		apiWrite(w, http.StatusOK, response)
+
+	case "config":
+
+		values, errs := apiConfigValues(w, r, configData, sessionData)
+
+		if len(errs) > 0 {
+
+			apiWrite(w, http.StatusUnprocessableEntity, map[string]interface{}{"errors": errs})
+			return
+
+		}
+
+		if values == nil {
+
+			return
+
+		}
+
+		if err := functions.UpdateConfigData(values, sessionData); err != nil {
+
+			apiError(w, configData, sessionData, http.StatusInternalServerError, err)
+			return
+
+		}
+
+		apiWrite(w, http.StatusOK, map[string]interface{}{"threadId": sessionData.ThreadID, "config": values})
+
+	case "actions/buy":
+
+		if !fh.apiRunning(sessionData) {
+
+			apiError(w, configData, sessionData, http.StatusConflict, errors.New("thread not running"))
+			return
+
+		}
+
+		sessionData.ForceBuy = true
+
+		apiWrite(w, http.StatusAccepted, map[string]string{"threadId": sessionData.ThreadID, "action": "buy"})
+
+	case "actions/sell":
+
+		if !fh.apiRunning(sessionData) {
+
+			apiError(w, configData, sessionData, http.StatusConflict, errors.New("thread not running"))
+			return
+
+		}
+
+		sessionData.ForceSell = true
+
+		apiWrite(w, http.StatusAccepted, map[string]string{"threadId": sessionData.ThreadID, "action": "sell"})
+
+	case "actions/start":
+
+		fh.supervisor.Start(
+			configData,
+			sessionData,
+			marketData)
+
+		time.Sleep(2 * time.Second) /* Sleep time to wait for ThreadID to start */
+
+		apiWrite(w, http.StatusAccepted, map[string]string{"threadId": sessionData.ThreadID, "action": "start"})
+
+	case "actions/stop":
+
+		if !fh.apiRunning(sessionData) {
+
+			apiError(w, configData, sessionData, http.StatusConflict, errors.New("thread not running"))
+			return
+
+		}
+
+		fh.supervisor.Stop(sessionData) /* Cleanly exit ThreadID */
+
+		/* The web UI shows a new session once the thread is stopped */
+		if sessionData == fh.sessionData {
+
+			fh.sessionData = newSession(sessionData.Storage)
+			fh.marketData = newMarket()
+
+		}
+
+		apiWrite(w, http.StatusAccepted, map[string]string{"threadId": sessionData.ThreadID, "action": "stop"})
+
+	default:
+
+		apiError(w, configData, sessionData, http.StatusNotFound, errors.New("no endpoint "+r.URL.Path))
+
+	}
+
+}
+
+/* Authorize a request from the origin of the API, with the API_TOKEN environment variable as bearer token, or from the local host when API_TOKEN is not set. An error response is written when refused. */
+func apiAuthorized(
+	w http.ResponseWriter,
+	r *http.Request) bool {
+
+	/* Refuse requests sent by browsers from pages of other sites */
+	if origin := r.Header.Get("Origin"); origin != "" {
+
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+
+			apiWrite(w, http.StatusForbidden, map[string]string{"error": "cross-origin request refused"})
+			return false
+
+		}
+
+	}
+
+	if token := os.Getenv("API_TOKEN"); token != "" {
+
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			apiWrite(w, http.StatusUnauthorized, map[string]string{"error": "API token required"})
+			return false
+
+		}
+
+		return true
+
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil && net.ParseIP(host).IsLoopback() {
+
+		return true
+
+	}
+
+	apiWrite(w, http.StatusForbidden, map[string]string{"error": "set API_TOKEN to use the API from other hosts"})
+
+	return false
+
+}
+
+/* Retrieve the session and market data of the thread selected by the thread query parameter, or of the session shown by the web UI */
+func (fh *myHandler) apiSession(
+	w http.ResponseWriter,
+	r *http.Request) (sessionData *types.Session, marketData *types.Market, ok bool) {
+
+	threadID := r.URL.Query().Get("thread")
+
+	if threadID == "" {
+
+		return fh.sessionData, fh.marketData, true
+
+	}
+
+	if thread := fh.supervisor.GetThread(threadID); thread != nil {
+
+		return thread.Session, thread.Market, true
+
+	}
+
+	apiWrite(w, http.StatusNotFound, map[string]string{"error": "thread " + threadID + " not running"})
+
+	return nil, nil, false
+
+}
+
+/* Check if the session is running as a thread of the supervisor */
+func (fh *myHandler) apiRunning(
+	sessionData *types.Session) bool {
+
+	for _, thread := range fh.supervisor.Threads() {
+
+		if thread.Session == sessionData {
+
+			return true
+
+		}
+
+	}
+
+	return false
+
+}
+
+/* Collect the status of the session */
+func (fh *myHandler) apiStatus(
+	configData *types.Config,
+	sessionData *types.Session,
+	marketData *types.Market) apiStatus {
+
+	status := apiStatus{
+		ThreadID:             sessionData.ThreadID,
+		Running:              fh.apiRunning(sessionData),
+		Symbol:               sessionData.Symbol,
+		SymbolFiat:           sessionData.SymbolFiat,
+		SymbolFiatFunds:      sessionData.SymbolFiatFunds,
+		SellTransactionCount: sessionData.SellTransactionCount,
+		Risk:                 risk.Status(sessionData),
+		Busy:                 sessionData.Busy,
+		Streams:              []apiStream{},
+	}
+
+	if profit, err := sessionData.Storage.GetProfit(sessionData); err == nil {
+		status.Profit = profit
+	}
+	if profitThreadID, err := sessionData.Storage.GetProfitByThreadID(sessionData); err == nil {
+		status.ProfitThreadID = profitThreadID
+	}
+	if unrealized, err := ledger.Unrealized(configData, sessionData, marketData.Price); err == nil {
+		status.Unrealized = unrealized
+	}
+	if threadCount, err := sessionData.Storage.GetThreadCount(sessionData); err == nil {
+		status.ThreadCount = threadCount
+	}
+	if threadAmount, err := sessionData.Storage.GetThreadAmount(sessionData); err == nil {
+		status.ThreadAmount = threadAmount
+	}
+
+	for _, s := range stream.Status(sessionData) {
+
+		tmp := apiStream{
+			Name:       s.Name,
+			Connected:  s.Connected,
+			Reconnects: s.Reconnects,
+			LastError:  s.LastError,
+		}
+
+		if !s.LastEvent.IsZero() {
+
+			tmp.LastEvent = int64(time.Since(s.LastEvent).Seconds())
+
+		}
+
+		status.Streams = append(status.Streams, tmp)
+
+	}
+
+	return status
+
+}
+
+/* Decode and validate the values of a PUT /api/v1/config JSON object by configuration key. Values are nil when an error response was written. */
+func apiConfigValues(
+	w http.ResponseWriter,
+	r *http.Request,
+	configData *types.Config,
+	sessionData *types.Session) (values map[string]string, errs map[string]string) {
+
+	var body map[string]interface{}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&body); err != nil || body == nil {
+
+		if err == nil {
+
+			err = errors.New("configuration must be a JSON object")
+
+		}
+
+		apiError(w, configData, sessionData, http.StatusBadRequest, err)
+		return nil, nil
+
+	}
+
+	values = map[string]string{}
+	errs = map[string]string{}
+
+	for key, value := range body {
+
+		if text, err := apiConfigValue(key, value); err != nil {
+
+			errs[key] = err.Error()
+
+		} else {
+
+			values[key] = text
+
+		}
+
+	}
+
+	if condition, ok := values["buy_condition"]; ok {
+
+		/* Reject BUY conditions with unknown kline intervals, indicators, operators or configuration keys */
+		if err := functions.ValidateCondition(condition); err != nil {
+
+			errs["buy_condition"] = err.Error()
+
+		}
+
+	}
+
+	for _, key := range []string{"time_start", "time_stop"} {
+
+		if value, ok := values[key]; ok {
+
+			if _, err := time.Parse(time.Kitchen, value); err != nil {
+
+				errs[key] = "must be a time such as 4:00AM"
+
+			}
+
+		}
+
+	}
+
+	_, symbolOK := values["symbol"]
+	_, symbolFiatOK := values["symbol_fiat"]
+
+	if (symbolOK || symbolFiatOK) && errs["symbol"] == "" && errs["symbol_fiat"] == "" {
+
+		symbol, symbolFiat := configData.Symbol, configData.SymbolFiat
+
+		if symbolOK {
+
+			symbol = values["symbol"]
+
+		}
+
+		if symbolFiatOK {
+
+			symbolFiat = values["symbol_fiat"]
+
+		}
+
+		/* Reject symbols unknown to the exchange, not trading, or not quoted in the fiat currency */
+		if _, err := exchange.ValidateSymbol(configData, sessionData, symbol, symbolFiat); err != nil {
+
+			if symbolOK {
+
+				errs["symbol"] = err.Error()
+
+			} else {
+
+				errs["symbol_fiat"] = err.Error()
+
+			}
+
+		}
+
+	}
+
+	return values, errs
+
+}
+
+/* Convert a JSON configuration value to the text saved in the config file, validating its type and range */
+func apiConfigValue(
+	key string,
+	value interface{}) (text string, err error) {
+
+	if apiConfigReadOnly[key] {
+
+		return "", errors.New("read-only, set in the configuration file")
+
+	}
+
+	kind, ok := apiConfigKeys[key]
+
+	if !ok {
+
+		return "", errors.New("unknown configuration key")
+
+	}
+
+	switch kind {
+	case "bool":
+
+		if b, ok := value.(bool); ok {
+
+			return strconv.FormatBool(b), nil
+
+		}
+
+		return "", errors.New("must be true or false")
+
+	case "string":
+
+		if s, ok := value.(string); ok {
+
+			if strings.TrimSpace(s) == "" && key != "buy_condition" {
+
+				return "", errors.New("must not be empty")
+
+			}
+
+			return s, nil
+
+		}
+
+		return "", errors.New("must be a string")
+
+	}
+
+	number, ok := value.(json.Number)
+
+	if !ok {
+
+		return "", errors.New("must be a number")
+
+	}
+
+	if kind == "int" {
+
+		i, err := strconv.Atoi(number.String())
+
+		if err != nil {
+
+			return "", errors.New("must be an integer")
+
+		}
+
+		if i < 0 || (i == 0 && key == "bollinger_window") {
+
+			return "", errors.New("must be greater than 0")
+
+		}
+
+		return number.String(), nil
+
+	}
+
+	f, err := number.Float64()
+
+	if err != nil {
+
+		return "", errors.New("must be a number")
+
+	}
+
+	if f < 0 {
+
+		return "", errors.New("must not be negative")
+
+	}
+
+	return number.String(), nil
+
+}
+
+/* Write the response as JSON */
+func apiWrite(
+	w http.ResponseWriter,
+	status int,
+	response interface{}) {
+
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  nil,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+	}
+
+}
+
+/* Write an error response as JSON */
+func apiError(
+	w http.ResponseWriter,
+	configData *types.Config,
+	sessionData *types.Session,
+	status int,
+	err error) {
+
+	if status >= http.StatusInternalServerError {
+
+		functions.Logger(&types.LogEntry{
+			Config:   configData,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+	}
+
+	apiWrite(w, status, map[string]string{"error": err.Error()})
+
+}
+
+/* Write a method not allowed response, listing the method of the endpoint */
+func apiMethodNotAllowed(
+	w http.ResponseWriter,
+	method string) {
+
+	w.Header().Set("Allow", method)
+	apiWrite(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed, use " + method})
+
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestAPIAuthorized(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		token         string /* API_TOKEN environment variable */
+		remoteAddr    string
+		origin        string
+		authorization string
+		status        int /* Status of the response written when refused, 0 when authorized */
+	}{
+		{
+			name:       "local host without token",
+			remoteAddr: "127.0.0.1:50000",
+		},
+		{
+			name:       "local host from the dashboard",
+			remoteAddr: "[::1]:50000",
+			origin:     "http://localhost:8080",
+		},
+		{
+			name:       "other host without token",
+			remoteAddr: "192.0.2.1:50000",
+			status:     http.StatusForbidden,
+		},
+		{
+			name:       "page of another site",
+			remoteAddr: "127.0.0.1:50000",
+			origin:     "http://example.com",
+			status:     http.StatusForbidden,
+		},
+		{
+			name:       "token missing",
+			token:      "secret",
+			remoteAddr: "127.0.0.1:50000",
+			status:     http.StatusUnauthorized,
+		},
+		{
+			name:          "token wrong",
+			token:         "secret",
+			remoteAddr:    "192.0.2.1:50000",
+			authorization: "Bearer other",
+			status:        http.StatusUnauthorized,
+		},
+		{
+			name:          "token from other host",
+			token:         "secret",
+			remoteAddr:    "192.0.2.1:50000",
+			authorization: "Bearer secret",
+		},
+		{
+			name:          "token from page of another site",
+			token:         "secret",
+			remoteAddr:    "192.0.2.1:50000",
+			origin:        "http://example.com",
+			authorization: "Bearer secret",
+			status:        http.StatusForbidden,
+		},
+	}
+
+	defer os.Unsetenv("API_TOKEN")
+
+	for _, test := range tests {
+
+		os.Setenv("API_TOKEN", test.token)
+
+		r := httptest.NewRequest("POST", "http://localhost:8080/api/v1/actions/buy", nil)
+		r.RemoteAddr = test.remoteAddr
+
+		if test.origin != "" {
+
+			r.Header.Set("Origin", test.origin)
+
+		}
+
+		if test.authorization != "" {
+
+			r.Header.Set("Authorization", test.authorization)
+
+		}
+
+		w := httptest.NewRecorder()
+
+		if authorized := apiAuthorized(w, r); authorized != (test.status == 0) {
+
+			t.Errorf("%s: apiAuthorized() = %v, want %v", test.name, authorized, test.status == 0)
+
+		}
+
+		if test.status != 0 && w.Code != test.status {
+
+			t.Errorf("%s: status %d, want %d", test.name, w.Code, test.status)
+
+		}
+
+	}
+
+}
+
+func TestAPIConfigValue(t *testing.T) {
+
+	tests := []struct {
+		key   string
+		value interface{}
+		want  string
+		err   bool
+	}{
+		{"profit_min", json.Number("0.002"), "0.002", false},
+		{"profit_min", json.Number("-1"), "", true},
+		{"buy_wait", json.Number("1.5"), "", true},
+		{"buy_bollinger", true, "true", false},
+		{"buy_bollinger", "true", "", true},
+		{"symbol", "BTCUSDT", "BTCUSDT", false},
+		{"unknown", "value", "", true},
+		{"dryrun", false, "", true},
+		{"testnet", true, "", true},
+		{"exchangename", "binance", "", true},
+	}
+
+	for _, test := range tests {
+
+		got, err := apiConfigValue(test.key, test.value)
+
+		if (err != nil) != test.err || got != test.want {
+
+			t.Errorf("apiConfigValue(%q, %v) = %q, %v, want %q, error %v", test.key, test.value, got, err, test.want, test.err)
+
+		}
+
+	}
+
+}
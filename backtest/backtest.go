@@ -0,0 +1,567 @@
+package backtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"cryptopump/algorithms"
+	"cryptopump/exchange"
+	"cryptopump/functions"
+	"cryptopump/markets"
+	"cryptopump/memory"
+	"cryptopump/types"
+
+	"github.com/sdcoffey/techan"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	klineUpdates   = 30              /* Price updates replayed for each kline */
+	updateInterval = 2 * time.Second /* Time between price updates */
+	seriesLength   = 200             /* Candles kept for technical analysis, or the warm-up klines when longer */
+)
+
+// Report summarize the result of a backtest
+type Report struct {
+	Start       time.Time /* Time of the first replayed kline */
+	End         time.Time /* Time of the last replayed kline */
+	Klines      int       /* Number of klines replayed */
+	Trades      []Trade   /* Filled orders */
+	Buys        int       /* Number of filled BUY orders */
+	Sells       int       /* Number of filled SELL orders */
+	Profit      float64   /* Realized profit of closed thread transactions, net of their fees */
+	Fees        float64   /* Exchange comission paid for all filled orders */
+	NetProfit   float64   /* Realized profit after all fees paid */
+	MaxThreads  int       /* Maximum number of open thread transactions */
+	OpenThreads int       /* Thread transactions still open at the end */
+	OpenAmount  float64   /* Fiat amount held in open thread transactions */
+	MaxDrawdown float64   /* Maximum decline of funds and open positions at market price from a previous peak, as a fraction */
+	InitFunds   float64   /* Fiat funds at start */
+	FinalFunds  float64   /* Fiat funds at the end */
+}
+
+// Trade define a filled order
+type Trade struct {
+	Time          time.Time
+	OrderID       int
+	Side          string
+	Price         float64
+	Quantity      float64
+	QuoteQuantity float64
+	Fee           float64
+}
+
+/* engine replays klines as the kline and book ticker websockets would deliver them */
+type engine struct {
+	configData  *types.Config
+	marketData  *types.Market
+	sessionData *types.Session
+	storage     *memory.Storage
+	exchange    *exchange.SimulatedExchange
+	venue       *replay
+	clock       *clock
+	kline       int                        /* Index of the kline being replayed */
+	timeframes  map[string][]types.WsKline /* Klines of intervals other than 1m merged from the replayed klines */
+	length      int                        /* Candles kept for technical analysis */
+	update      int                        /* Last price update replayed for the kline */
+	maxThreads  int
+	peak        float64 /* Highest funds and open positions at market price */
+	maxDrawdown float64
+}
+
+// Filters define the exchange filters of the replayed symbol
+type Filters struct {
+	StepSize    float64 /* Lot size step */
+	TickSize    float64 /* Price tick, dividing every replayed price */
+	MinNotional float64 /* Minimum order value in fiat */
+}
+
+// Run Replay klines through the BUY and SELL decision trees with simulated fills.
+// Initial funds are defined by DryRunFiatFunds and filters are the exchange filters of the symbol.
+func Run(
+	configData *types.Config,
+	klines []types.WsKline,
+	filters Filters) (report *Report, err error) {
+
+	/* Klines loaded before trading starts, the same number sessions retrieve on start */
+	warmup := markets.WarmupKlines(configData)
+
+	if len(klines) <= warmup {
+
+		return nil, errors.New("Backtest - Not enough klines")
+
+	}
+
+	if configData.DryRunFiatFunds <= 0 {
+
+		return nil, errors.New("Backtest - DryRun fiat funds must be defined")
+
+	}
+
+	if err = checkFilters(klines, filters); err != nil {
+
+		return nil, err
+
+	}
+
+	e := &engine{
+		configData: configData,
+		marketData: &types.Market{
+			Series: techan.NewTimeSeries(),
+		},
+		storage: memory.New(),
+		venue: &replay{
+			klines:  klines,
+			filters: filters,
+		},
+		kline:      warmup,
+		timeframes: make(map[string][]types.WsKline),
+		length:     seriesLength,
+	}
+
+	if warmup > e.length {
+
+		e.length = warmup
+
+	}
+
+	e.clock = &clock{
+		now:     klineTime(klines[warmup-1]).Add(time.Minute),
+		elapsed: e.replay,
+	}
+
+	e.exchange = exchange.NewSimulatedExchange(e.venue, configData)
+
+	/* Apply execution reports to orders, recording the commission of LIMIT orders filled while resting */
+	e.exchange.UserDataServe(&types.WsHandler{
+		WsUserDataServe: func(message []byte) {
+
+			executionReport := &types.ExecutionReport{}
+
+			if err := json.Unmarshal(message, executionReport); err == nil &&
+				executionReport.EventType == "executionReport" {
+
+				algorithms.ProcessExecutionReport(configData, e.sessionData, executionReport)
+
+			}
+
+		},
+	})
+
+	e.sessionData = &types.Session{
+		ThreadID:        functions.GetThreadID(),
+		ThreadIDSession: functions.GetThreadID(),
+		Symbol:          configData.Symbol,
+		SymbolFiat:      configData.SymbolFiat,
+		Storage:         e.storage,
+		Exchange:        e.exchange,
+		Clock:           e.clock,
+		Backtest:        true,
+	}
+
+	if err = e.storage.SaveSession(configData, e.sessionData); err != nil {
+
+		return nil, err
+
+	}
+
+	exchange.GetLotSize(configData, e.sessionData)
+
+	if e.sessionData.SymbolFiatFunds, err = exchange.GetSymbolFunds(configData, e.sessionData); err != nil {
+
+		return nil, err
+
+	}
+
+	report = &Report{
+		Start:     klineTime(klines[warmup]),
+		End:       klineTime(klines[len(klines)-1]),
+		Klines:    len(klines) - warmup,
+		InitFunds: e.sessionData.SymbolFiatFunds,
+	}
+
+	/* Load warm-up klines the same way the session does on start */
+	for key := 0; key < warmup; key++ {
+
+		e.venue.push(key)
+		e.merge(klines[key])
+
+	}
+
+	markets.LoadKlineDataPast(configData, e.marketData, e.sessionData)
+	markets.LoadTimeframes(configData, e.marketData, e.sessionData)
+
+	/* Replay all klines, trading on each price update */
+	for e.kline < len(klines) {
+
+		e.next(true)
+
+	}
+
+	e.report(report)
+
+	return report, nil
+
+}
+
+/* Replay price updates up to time without trading, as the ticker handler is busy while orders are waited on */
+func (e *engine) replay(
+	until time.Time) {
+
+	for e.kline < len(e.venue.klines) &&
+		!e.updateTime().After(until) {
+
+		e.next(false)
+
+	}
+
+}
+
+/* Time of the next price update */
+func (e *engine) updateTime() time.Time {
+
+	return klineTime(e.venue.klines[e.kline]).Add(time.Duration(e.update+1) * updateInterval)
+
+}
+
+/* Replay the next price update */
+func (e *engine) next(
+	trade bool) {
+
+	index := e.kline
+	update := e.update + 1
+	kline := e.venue.klines[index]
+
+	if t := e.updateTime(); t.After(e.clock.now) {
+
+		e.clock.now = t
+
+	}
+
+	/* Move to the next update before replaying, as trading may advance the clock further */
+	if update == klineUpdates {
+
+		e.kline++
+		e.update = 0
+
+	} else {
+
+		e.update = update
+
+	}
+
+	/* Analyse Volume kline direction and create marketData.Direction. 0 = SELL / 1+ BUY */
+	activeSellVolume := (functions.StrToFloat64(kline.Volume) - functions.StrToFloat64(kline.ActiveBuyVolume))
+	if activeSellVolume > functions.StrToFloat64(kline.ActiveBuyVolume) {
+
+		e.marketData.Direction = 0
+
+	} else {
+
+		e.marketData.Direction++
+
+	}
+
+	if update == klineUpdates {
+
+		e.venue.push(index)
+
+		/* Load Final kline for technical analysis */
+		markets.LoadKlineData(
+			e.configData,
+			e.sessionData,
+			e.marketData,
+			kline)
+
+		if len(e.marketData.Series.Candles) > e.length {
+
+			e.marketData.Series.Candles = e.marketData.Series.Candles[len(e.marketData.Series.Candles)-e.length:]
+
+		}
+
+		/* Load klines of other intervals once their last 1m kline is replayed, as their kline streams would */
+		for interval, final := range e.merge(kline) {
+
+			markets.LoadKlineData(
+				e.configData,
+				e.sessionData,
+				e.marketData,
+				final)
+
+			if timeframe, ok := e.marketData.Timeframes[interval]; ok && len(timeframe.Series.Candles) > e.length {
+
+				timeframe.Series.Candles = timeframe.Series.Candles[len(timeframe.Series.Candles)-e.length:]
+
+			}
+
+		}
+
+		/* Update Number of Sale Transactions per hour, as the session scheduler does */
+		e.sessionData.SellTransactionCount, _ = e.storage.GetOrderTransactionCount(e.sessionData, "SELL")
+
+		e.drawdown(functions.StrToFloat64(kline.Close))
+
+	}
+
+	price := pricePath(kline, update)
+
+	e.exchange.UpdateBookTicker(e.sessionData, &types.WsBookTicker{
+		Symbol:       e.sessionData.Symbol,
+		BestBidPrice: functions.Float64ToStr(price, 8),
+		BestAskPrice: functions.Float64ToStr(price, 8),
+	})
+
+	if !trade {
+
+		return
+
+	}
+
+	e.marketData.Price = price
+
+	algorithms.Trade(
+		e.configData,
+		e.marketData,
+		e.sessionData)
+
+	e.sessionData.SymbolFiatFunds, _ = exchange.GetSymbolFunds(e.configData, e.sessionData)
+
+	if e.sessionData.ThreadCount > e.maxThreads {
+
+		e.maxThreads = e.sessionData.ThreadCount
+
+	}
+
+}
+
+/* Merge a final 1m kline into the klines of the intervals other than 1m. Returns the klines made final by it. */
+func (e *engine) merge(
+	kline types.WsKline) (final map[string]types.WsKline) {
+
+	final = make(map[string]types.WsKline)
+
+	for _, interval := range e.configData.Timeframes {
+
+		klines := merge(e.timeframes[interval], kline, interval)
+
+		/* Only the kline being merged is kept */
+		e.timeframes[interval] = klines[len(klines)-1:]
+
+		if last := klines[len(klines)-1]; last.IsFinal {
+
+			final[interval] = last
+
+		}
+
+	}
+
+	return final
+
+}
+
+/* Track the decline of funds and open positions at market price from the previous peak */
+func (e *engine) drawdown(
+	price float64) {
+
+	funds, _ := exchange.GetSymbolFunds(e.configData, e.sessionData)
+	quantity, _ := e.storage.GetThreadQuantity(e.sessionData)
+	equity := funds + quantity*price
+
+	if equity > e.peak {
+
+		e.peak = equity
+
+	}
+
+	if e.peak > 0 && (e.peak-equity)/e.peak > e.maxDrawdown {
+
+		e.maxDrawdown = (e.peak - equity) / e.peak
+
+	}
+
+}
+
+/* Summarize filled orders, profit and open thread transactions */
+func (e *engine) report(
+	report *Report) {
+
+	for _, order := range e.storage.GetOrders(e.sessionData) {
+
+		if order.Status != "FILLED" {
+
+			continue
+
+		}
+
+		trade := Trade{
+			Time:          time.Unix(0, order.TransactTime*int64(time.Millisecond)).UTC(),
+			OrderID:       order.OrderID,
+			Side:          order.Side,
+			Quantity:      order.ExecutedQuantity.InexactFloat64(),
+			QuoteQuantity: order.CumulativeQuoteQuantity.InexactFloat64(),
+			Fee:           order.Commission.InexactFloat64(),
+		}
+
+		if order.ExecutedQuantity.IsPositive() {
+
+			trade.Price = order.CumulativeQuoteQuantity.Div(order.ExecutedQuantity).InexactFloat64()
+
+		}
+
+		switch order.Side {
+		case "BUY":
+			report.Buys++
+		case "SELL":
+			report.Sells++
+		}
+
+		report.Fees += trade.Fee
+		report.Trades = append(report.Trades, trade)
+
+	}
+
+	report.Profit, _ = e.storage.GetProfitByThreadID(e.sessionData)
+	report.NetProfit = report.Profit
+
+	/* Fees of thread transactions still open are not included in the realized profit */
+	open, _ := e.storage.GetThreadTransactionByThreadID(e.sessionData)
+
+	for _, order := range open {
+
+		for _, trade := range report.Trades {
+
+			if trade.OrderID == order.OrderID {
+
+				report.NetProfit -= trade.Fee
+
+			}
+
+		}
+
+	}
+	report.MaxThreads = e.maxThreads
+	report.MaxDrawdown = e.maxDrawdown
+	report.OpenThreads, _ = e.storage.GetThreadTransactionCount(e.sessionData)
+	report.OpenAmount, _ = e.storage.GetThreadAmount(e.sessionData)
+	report.FinalFunds = e.sessionData.SymbolFiatFunds
+
+}
+
+// Print Write the backtest report
+func Print(
+	w io.Writer,
+	report *Report) {
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintln(tw, "Time\tOrderID\tSide\tPrice\tQuantity\tQuote\tFee\t")
+
+	for _, trade := range report.Trades {
+
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%.8f\t%.8f\t%.2f\t%.4f\t\n",
+			trade.Time.Format("2006-01-02 15:04:05"),
+			trade.OrderID,
+			trade.Side,
+			trade.Price,
+			trade.Quantity,
+			trade.QuoteQuantity,
+			trade.Fee)
+
+	}
+
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Period:        %s - %s (%d klines)\n", report.Start.Format("2006-01-02 15:04"), report.End.Format("2006-01-02 15:04"), report.Klines)
+	fmt.Fprintf(w, "Trades:        %d (%d BUY / %d SELL)\n", len(report.Trades), report.Buys, report.Sells)
+	fmt.Fprintf(w, "Profit:        %.2f\n", report.Profit)
+	fmt.Fprintf(w, "Fees:          %.2f\n", report.Fees)
+	fmt.Fprintf(w, "Net Profit:    %.2f\n", report.NetProfit)
+	fmt.Fprintf(w, "Max Drawdown:  %.2f%%\n", report.MaxDrawdown*100)
+	fmt.Fprintf(w, "Max Threads:   %d\n", report.MaxThreads)
+	fmt.Fprintf(w, "Open Threads:  %d (%.2f)\n", report.OpenThreads, report.OpenAmount)
+	fmt.Fprintf(w, "Funds:         %.2f -> %.2f\n", report.InitFunds, report.FinalFunds)
+
+}
+
+/* Validate the exchange filters, refusing a tick size coarser than the replayed prices */
+func checkFilters(
+	klines []types.WsKline,
+	filters Filters) error {
+
+	if filters.StepSize <= 0 || filters.TickSize <= 0 || filters.MinNotional < 0 {
+
+		return errors.New("Backtest - Step size and tick size must be positive and min notional not negative")
+
+	}
+
+	tickSize := decimal.NewFromFloat(filters.TickSize)
+
+	for _, kline := range klines {
+
+		for _, value := range []string{kline.Open, kline.High, kline.Low, kline.Close} {
+
+			price, err := decimal.NewFromString(value)
+
+			if err != nil {
+
+				return fmt.Errorf("Backtest - Kline price %q at %s: %v", value, klineTime(kline).Format(time.RFC3339), err)
+
+			}
+
+			if !price.Mod(tickSize).IsZero() {
+
+				return fmt.Errorf("Backtest - Tick size %s is coarser than the kline price %s at %s", tickSize, value, klineTime(kline).Format(time.RFC3339))
+
+			}
+
+		}
+
+	}
+
+	return nil
+
+}
+
+/* Kline open time */
+func klineTime(
+	kline types.WsKline) time.Time {
+
+	return time.Unix(0, kline.StartTime*int64(time.Millisecond)).UTC()
+
+}
+
+/* Interpolate the price for an update, moving from open to the extreme closest to it, then to the other extreme and to close */
+func pricePath(
+	kline types.WsKline,
+	update int) float64 {
+
+	open := functions.StrToFloat64(kline.Open)
+	high := functions.StrToFloat64(kline.High)
+	low := functions.StrToFloat64(kline.Low)
+	close := functions.StrToFloat64(kline.Close)
+
+	path := []float64{open, low, high, close}
+
+	if high-open < open-low {
+
+		path = []float64{open, high, low, close}
+
+	}
+
+	position := float64(update) / klineUpdates * 3
+	segment := int(position)
+
+	if segment >= 3 {
+
+		return close
+
+	}
+
+	return path[segment] + (path[segment+1]-path[segment])*(position-float64(segment))
+
+}
@@ -0,0 +1,516 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cryptopump/functions"
+	"cryptopump/types"
+)
+
+/* clock replays historical time. Sleeping replays the market updates elapsed meanwhile, as the exchange keeps moving while an order is waited on. */
+type clock struct {
+	now     time.Time
+	elapsed func(until time.Time) /* Replay market updates up to the time */
+}
+
+/* Current replay time */
+func (c *clock) Now() time.Time {
+
+	return c.now
+
+}
+
+/* Advance replay time */
+func (c *clock) Sleep(
+	d time.Duration) {
+
+	until := c.now.Add(d)
+
+	c.elapsed(until)
+
+	if until.After(c.now) {
+
+		c.now = until
+
+	}
+
+}
+
+/* replay implements the market data operations of types.Exchange from historical klines. Orders are handled by the simulated exchange wrapping it. */
+type replay struct {
+	klines  []types.WsKline /* Historical klines */
+	filters Filters         /* Exchange filters for the symbol */
+	highs   []int           /* Monotonic queue of kline indexes for the 24hs high price */
+	lows    []int           /* Monotonic queue of kline indexes for the 24hs low price */
+}
+
+/* Add final kline to the 24hs rolling statistics */
+func (r *replay) push(
+	index int) {
+
+	high := functions.StrToFloat64(r.klines[index].High)
+	low := functions.StrToFloat64(r.klines[index].Low)
+
+	for len(r.highs) > 0 && functions.StrToFloat64(r.klines[r.highs[len(r.highs)-1]].High) <= high {
+		r.highs = r.highs[:len(r.highs)-1]
+	}
+
+	for len(r.lows) > 0 && functions.StrToFloat64(r.klines[r.lows[len(r.lows)-1]].Low) >= low {
+		r.lows = r.lows[:len(r.lows)-1]
+	}
+
+	r.highs = append(r.highs, index)
+	r.lows = append(r.lows, index)
+
+	/* Remove klines older than 24hs */
+	start := r.klines[index].StartTime - (24 * time.Hour).Milliseconds()
+
+	for r.klines[r.highs[0]].StartTime <= start {
+		r.highs = r.highs[1:]
+	}
+
+	for r.klines[r.lows[0]].StartTime <= start {
+		r.lows = r.lows[1:]
+	}
+
+}
+
+/* Retrieve Order Status */
+func (r *replay) GetOrder(
+	sessionData *types.Session,
+	orderID int64) (order *types.Order, err error) {
+
+	return nil, errors.New("Backtest - Orders are not supported by replay")
+
+}
+
+/* Create order to BUY */
+func (r *replay) BuyOrder(
+	sessionData *types.Session,
+	quantity string) (order *types.Order, err error) {
+
+	return nil, errors.New("Backtest - Orders are not supported by replay")
+
+}
+
+/* Create order to SELL */
+func (r *replay) SellOrder(
+	marketData *types.Market,
+	sessionData *types.Session,
+	quantity string) (order *types.Order, err error) {
+
+	return nil, errors.New("Backtest - Orders are not supported by replay")
+
+}
+
+/* CANCEL an order */
+func (r *replay) CancelOrder(
+	sessionData *types.Session,
+	orderID int64) (order *types.Order, err error) {
+
+	return nil, errors.New("Backtest - Orders are not supported by replay")
+
+}
+
+/* Retrieve open orders */
+func (r *replay) GetOpenOrders(
+	sessionData *types.Session) (orders []*types.Order, err error) {
+
+	return nil, errors.New("Backtest - Orders are not supported by replay")
+
+}
+
+/* Retrieve recent orders */
+func (r *replay) GetRecentOrders(
+	sessionData *types.Session,
+	limit int) (orders []*types.Order, err error) {
+
+	return nil, errors.New("Backtest - Orders are not supported by replay")
+
+}
+
+/* Retrieve exchange information for the replayed symbol */
+func (r *replay) GetSymbols(
+	sessionData *types.Session) (symbols []*types.ExchangeInfo, err error) {
+
+	return []*types.ExchangeInfo{{
+		Symbol:         sessionData.Symbol,
+		Status:         "TRADING",
+		BaseAsset:      strings.TrimSuffix(sessionData.Symbol, sessionData.SymbolFiat),
+		QuoteAsset:     sessionData.SymbolFiat,
+		MaxQuantity:    "9000000",
+		MinQuantity:    functions.Float64ToStr(r.filters.StepSize, 8),
+		StepSize:       functions.Float64ToStr(r.filters.StepSize, 8),
+		TickSize:       functions.Float64ToStr(r.filters.TickSize, 8),
+		MinNotional:    functions.Float64ToStr(r.filters.MinNotional, 8),
+		BasePrecision:  8,
+		QuotePrecision: 8,
+	}}, nil
+
+}
+
+/* Retrieve funds available */
+func (r *replay) GetSymbolFunds(
+	sessionData *types.Session) (balance float64, err error) {
+
+	return 0, errors.New("Backtest - Funds are defined by DryRun fiat funds")
+
+}
+
+/* Retrieve the final klines replayed from startTime or up to endTime when not zero, aggregated for kline intervals other than 1m */
+func (r *replay) GetKlines(
+	sessionData *types.Session,
+	interval string,
+	startTime int64,
+	endTime int64,
+	limit int) (klines []*types.Kline, err error) {
+
+	if functions.IntervalDuration(interval) == 0 {
+
+		return nil, errors.New("Backtest - Kline interval not supported " + interval)
+
+	}
+
+	if len(r.highs) == 0 {
+
+		return nil, nil
+
+	}
+
+	var aggregated []types.WsKline
+
+	for _, kline := range r.klines[:r.highs[len(r.highs)-1]+1] {
+
+		if interval == "1m" {
+
+			aggregated = append(aggregated, kline)
+
+		} else {
+
+			aggregated = merge(aggregated, kline, interval)
+
+		}
+
+	}
+
+	/* Keep the klines in the time range */
+	for len(aggregated) > 0 && aggregated[0].StartTime < startTime {
+		aggregated = aggregated[1:]
+	}
+
+	for len(aggregated) > 0 && endTime > 0 && aggregated[len(aggregated)-1].StartTime > endTime {
+		aggregated = aggregated[:len(aggregated)-1]
+	}
+
+	if len(aggregated) > limit {
+
+		if startTime > 0 {
+
+			aggregated = aggregated[:limit]
+
+		} else {
+
+			aggregated = aggregated[len(aggregated)-limit:]
+
+		}
+
+	}
+
+	for _, kline := range aggregated {
+
+		klines = append(klines, &types.Kline{
+			OpenTime:             kline.StartTime,
+			Open:                 kline.Open,
+			High:                 kline.High,
+			Low:                  kline.Low,
+			Close:                kline.Close,
+			Volume:               kline.Volume,
+			CloseTime:            kline.EndTime,
+			QuoteVolume:          kline.QuoteVolume,
+			ActiveBuyVolume:      kline.ActiveBuyVolume,
+			ActiveBuyQuoteVolume: kline.ActiveBuyQuoteVolume,
+		})
+
+	}
+
+	return klines, nil
+
+}
+
+/* Retrieve 24hs Rolling Price Statistics up to the last final kline */
+func (r *replay) GetPriceChangeStats(
+	sessionData *types.Session) (priceChangeStats []*types.PriceChangeStats, err error) {
+
+	if len(r.highs) == 0 {
+
+		return nil, errors.New("Backtest - No klines replayed")
+
+	}
+
+	return []*types.PriceChangeStats{{
+		HighPrice: r.klines[r.highs[0]].High,
+		LowPrice:  r.klines[r.lows[0]].Low,
+	}}, nil
+
+}
+
+/* Retrieve the close price of the last final kline for the symbol */
+func (r *replay) GetPrice(
+	sessionData *types.Session,
+	symbol string) (price float64, err error) {
+
+	if symbol != sessionData.Symbol || len(r.highs) == 0 {
+
+		return 0, errors.New("Backtest - Price not available for " + symbol)
+
+	}
+
+	return functions.StrToFloat64(r.klines[r.highs[len(r.highs)-1]].Close), nil
+
+}
+
+/* Synchronize time */
+func (r *replay) NewSetServerTimeService(
+	sessionData *types.Session) (err error) {
+
+	return nil
+
+}
+
+/* Websockets are not used by the replay, market updates are pushed by the backtest engine */
+func (r *replay) WsBookTickerServe(
+	sessionData *types.Session,
+	wsHandler *types.WsHandler,
+	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
+
+	return nil, nil, errors.New("Backtest - Websockets are not supported by replay")
+
+}
+
+/* Websockets are not used by the replay, and klines carry no order book depth */
+func (r *replay) WsDepthServe(
+	sessionData *types.Session,
+	wsHandler *types.WsHandler,
+	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
+
+	return nil, nil, errors.New("Backtest - Websockets are not supported by replay")
+
+}
+
+/* Websockets are not used by the replay, market updates are pushed by the backtest engine */
+func (r *replay) WsKlineServe(
+	sessionData *types.Session,
+	interval string,
+	wsHandler *types.WsHandler,
+	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
+
+	return nil, nil, errors.New("Backtest - Websockets are not supported by replay")
+
+}
+
+/* Retrieve listen key for user stream service */
+func (r *replay) GetUserStreamServiceListenKey(
+	sessionData *types.Session) (listenKey string, err error) {
+
+	return "", nil
+
+}
+
+/* Keep user stream service alive */
+func (r *replay) KeepAliveUserStreamServiceListenKey(
+	sessionData *types.Session) (err error) {
+
+	return nil
+
+}
+
+/* Websockets are not used by the replay */
+func (r *replay) WsUserDataServe(
+	sessionData *types.Session,
+	wsHandler *types.WsHandler,
+	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
+
+	return nil, nil, errors.New("Backtest - Websockets are not supported by replay")
+
+}
+
+/* Merge a 1m kline into the last kline of the interval, final once its last 1m kline is merged */
+func merge(
+	klines []types.WsKline,
+	kline types.WsKline,
+	interval string) []types.WsKline {
+
+	duration := functions.IntervalDuration(interval).Milliseconds()
+	start := kline.StartTime - kline.StartTime%duration
+
+	if len(klines) == 0 || klines[len(klines)-1].StartTime != start {
+
+		klines = append(klines, types.WsKline{
+			StartTime: start,
+			EndTime:   start + duration - 1,
+			Interval:  interval,
+			Open:      kline.Open,
+			High:      kline.High,
+			Low:       kline.Low,
+			Volume:    "0",
+		})
+
+	}
+
+	last := &klines[len(klines)-1]
+
+	if functions.StrToFloat64(kline.High) > functions.StrToFloat64(last.High) {
+
+		last.High = kline.High
+
+	}
+
+	if functions.StrToFloat64(kline.Low) < functions.StrToFloat64(last.Low) {
+
+		last.Low = kline.Low
+
+	}
+
+	last.Close = kline.Close
+	last.Volume = functions.Float64ToStr(functions.StrToFloat64(last.Volume)+functions.StrToFloat64(kline.Volume), 8)
+	last.IsFinal = kline.StartTime+time.Minute.Milliseconds() >= start+duration
+
+	return klines
+
+}
+
+// LoadKlines Load 1m klines from Binance kline CSV files (https://data.binance.vision).
+// Files are merged and sorted by open time, and duplicated klines are dropped.
+func LoadKlines(
+	filenames ...string) (klines []types.WsKline, err error) {
+
+	for _, filename := range filenames {
+
+		var file *os.File
+
+		if file, err = os.Open(filename); err != nil {
+
+			return nil, err
+
+		}
+
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+
+		for {
+
+			var record []string
+
+			if record, err = reader.Read(); err == io.EOF {
+
+				break
+
+			} else if err != nil {
+
+				file.Close()
+				return nil, err
+
+			}
+
+			if len(record) < 11 {
+
+				file.Close()
+				return nil, errors.New("Backtest - Invalid kline record in " + filename)
+
+			}
+
+			startTime, err := strconv.ParseInt(record[0], 10, 64)
+
+			/* Skip header line */
+			if err != nil {
+
+				continue
+
+			}
+
+			endTime, _ := strconv.ParseInt(record[6], 10, 64)
+
+			/* Binance publishes timestamps in microseconds since 2025 */
+			if startTime > 1e14 {
+
+				startTime /= 1000
+				endTime /= 1000
+
+			}
+
+			klines = append(klines, types.WsKline{
+				StartTime:            startTime,
+				EndTime:              endTime,
+				Interval:             "1m",
+				Open:                 record[1],
+				High:                 record[2],
+				Low:                  record[3],
+				Close:                record[4],
+				Volume:               record[5],
+				QuoteVolume:          record[7],
+				ActiveBuyVolume:      record[9],
+				ActiveBuyQuoteVolume: record[10],
+				IsFinal:              true,
+			})
+
+		}
+
+		file.Close()
+
+	}
+
+	sort.SliceStable(klines, func(i, j int) bool {
+		return klines[i].StartTime < klines[j].StartTime
+	})
+
+	/* Drop duplicated klines from overlapping files */
+	unique := klines[:0]
+
+	for key := range klines {
+
+		if key == 0 || klines[key].StartTime != klines[key-1].StartTime {
+
+			unique = append(unique, klines[key])
+
+		}
+
+	}
+
+	return unique, nil
+
+}
+
+// LoadStoredKlines Load the 1m klines saved to the storage for the symbol, opened from startTime to endTime
+func LoadStoredKlines(
+	storage types.Storage,
+	symbol string,
+	startTime int64,
+	endTime int64) (klines []types.WsKline, err error) {
+
+	sessionData := &types.Session{
+		Symbol:  symbol,
+		Storage: storage,
+	}
+
+	if klines, err = storage.GetKlines(sessionData, "1m", startTime, endTime); err != nil {
+
+		return nil, err
+
+	}
+
+	if len(klines) == 0 {
+
+		return nil, errors.New("Backtest - No klines saved for " + symbol)
+
+	}
+
+	return klines, nil
+
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"cryptopump/functions"
 	"cryptopump/types"
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/adshao/go-binance/v2"
@@ -16,12 +18,13 @@ func binanceMapOrder(from *binance.Order) (to *types.Order) {
 	to = &types.Order{}
 	to.ClientOrderID = from.ClientOrderID
 	to.OrderID = int(from.OrderID)
-	to.CumulativeQuoteQuantity = functions.StrToFloat64(from.CummulativeQuoteQuantity)
-	to.ExecutedQuantity = functions.StrToFloat64(from.ExecutedQuantity)
-	to.Price = functions.StrToFloat64(from.Price)
+	to.CumulativeQuoteQuantity = functions.StrToDecimal(from.CummulativeQuoteQuantity)
+	to.ExecutedQuantity = functions.StrToDecimal(from.ExecutedQuantity)
+	to.Price = functions.StrToDecimal(from.Price)
 	to.Side = string(from.Side)
 	to.Status = string(from.Status)
 	to.Symbol = from.Symbol
+	to.TransactTime = from.Time
 
 	return to
 
@@ -33,14 +36,22 @@ func binanceMapCreateOrderResponse(from *binance.CreateOrderResponse) (to *types
 	to = &types.Order{}
 	to.ClientOrderID = from.ClientOrderID
 	to.OrderID = int(from.OrderID)
-	to.CumulativeQuoteQuantity = functions.StrToFloat64(from.CummulativeQuoteQuantity)
-	to.ExecutedQuantity = functions.StrToFloat64(from.ExecutedQuantity)
-	to.Price = functions.StrToFloat64(from.Price)
+	to.CumulativeQuoteQuantity = functions.StrToDecimal(from.CummulativeQuoteQuantity)
+	to.ExecutedQuantity = functions.StrToDecimal(from.ExecutedQuantity)
+	to.Price = functions.StrToDecimal(from.Price)
 	to.Side = string(from.Side)
 	to.Status = string(from.Status)
 	to.Symbol = from.Symbol
 	to.TransactTime = from.TransactTime
 
+	/* Sum the commission paid for the fills */
+	for _, fill := range from.Fills {
+
+		to.CommissionAmount = to.CommissionAmount.Add(functions.StrToDecimal(fill.Commission))
+		to.CommissionAsset = fill.CommissionAsset
+
+	}
+
 	return to
 
 }
@@ -51,9 +62,9 @@ func binanceMapCancelOrderResponse(from *binance.CancelOrderResponse) (to *types
 	to = &types.Order{}
 	to.ClientOrderID = from.ClientOrderID
 	to.OrderID = int(from.OrderID)
-	to.CumulativeQuoteQuantity = functions.StrToFloat64(from.CummulativeQuoteQuantity)
-	to.ExecutedQuantity = functions.StrToFloat64(from.ExecutedQuantity)
-	to.Price = functions.StrToFloat64(from.Price)
+	to.CumulativeQuoteQuantity = functions.StrToDecimal(from.CummulativeQuoteQuantity)
+	to.ExecutedQuantity = functions.StrToDecimal(from.ExecutedQuantity)
+	to.Price = functions.StrToDecimal(from.Price)
 	to.Side = string(from.Side)
 	to.Status = string(from.Status)
 	to.Symbol = from.Symbol
@@ -77,6 +88,10 @@ func binanceMapKline(from []*binance.Kline) (to []*types.Kline) {
 		tmp.Open = from[key].Open
 		tmp.OpenTime = from[key].OpenTime
 		tmp.Volume = from[key].Volume
+		tmp.CloseTime = from[key].CloseTime
+		tmp.QuoteVolume = from[key].QuoteAssetVolume
+		tmp.ActiveBuyVolume = from[key].TakerBuyBaseAssetVolume
+		tmp.ActiveBuyQuoteVolume = from[key].TakerBuyQuoteAssetVolume
 
 		to = append(to, tmp)
 
@@ -86,12 +101,12 @@ func binanceMapKline(from []*binance.Kline) (to []*types.Kline) {
 
 }
 
-// BinanceMapWsKline Map binance.WsKline types to WsKline type
-func BinanceMapWsKline(from binance.WsKline) (to types.WsKline) {
+/* Map binance.WsKline types to WsKline type */
+func binanceMapWsKline(from binance.WsKline) (to types.WsKline) {
 
 	to = types.WsKline{}
 	to.ActiveBuyQuoteVolume = from.ActiveBuyQuoteVolume
-	to.ActiveBuyVolume = from.ActiveBuyQuoteVolume
+	to.ActiveBuyVolume = from.ActiveBuyVolume
 	to.Close = from.Close
 	to.EndTime = from.EndTime
 	to.FirstTradeID = from.FirstTradeID
@@ -111,6 +126,44 @@ func BinanceMapWsKline(from binance.WsKline) (to types.WsKline) {
 
 }
 
+/* Map binance.WsBookTickerEvent types to WsBookTicker type */
+func binanceMapWsBookTicker(from *binance.WsBookTickerEvent) (to *types.WsBookTicker) {
+
+	to = &types.WsBookTicker{}
+	to.UpdateID = from.UpdateID
+	to.Symbol = from.Symbol
+	to.BestBidPrice = from.BestBidPrice
+	to.BestBidQty = from.BestBidQty
+	to.BestAskPrice = from.BestAskPrice
+	to.BestAskQty = from.BestAskQty
+
+	return to
+
+}
+
+/* Map binance.WsPartialDepthEvent types to WsDepth type */
+func binanceMapWsDepth(from *binance.WsPartialDepthEvent) (to *types.WsDepth) {
+
+	to = &types.WsDepth{}
+	to.LastUpdateID = from.LastUpdateID
+	to.Symbol = from.Symbol
+
+	for key := range from.Bids {
+
+		to.Bids = append(to.Bids, types.DepthLevel{Price: from.Bids[key].Price, Quantity: from.Bids[key].Quantity})
+
+	}
+
+	for key := range from.Asks {
+
+		to.Asks = append(to.Asks, types.DepthLevel{Price: from.Asks[key].Price, Quantity: from.Asks[key].Quantity})
+
+	}
+
+	return to
+
+}
+
 /* Map binance.PriceChangeStats types to Kline type */
 func binanceMapPriceChangeStats(from []*binance.PriceChangeStats) (to []*types.PriceChangeStats) {
 
@@ -130,67 +183,145 @@ func binanceMapPriceChangeStats(from []*binance.PriceChangeStats) (to []*types.P
 
 }
 
-/* Map binance.ExchangeInfo types to Order type */
-func binanceMapExchangeInfo(sessionData *types.Session, from *binance.ExchangeInfo) (to *types.ExchangeInfo) {
-
-	to = &types.ExchangeInfo{}
+/* Map binance.ExchangeInfo types to ExchangeInfo type for every symbol */
+func binanceMapExchangeInfo(from *binance.ExchangeInfo) (symbols []*types.ExchangeInfo) {
 
 	for key := range from.Symbols {
 
-		if from.Symbols[key].Symbol == sessionData.Symbol {
+		to := &types.ExchangeInfo{
+			Symbol:     from.Symbols[key].Symbol,
+			Status:     from.Symbols[key].Status,
+			BaseAsset:  from.Symbols[key].BaseAsset,
+			QuoteAsset: from.Symbols[key].QuoteAsset,
+		}
+
+		if filter := from.Symbols[key].LotSizeFilter(); filter != nil {
+
+			to.MaxQuantity = filter.MaxQuantity
+			to.MinQuantity = filter.MinQuantity
+			to.StepSize = filter.StepSize
+
+		}
+
+		if filter := from.Symbols[key].PriceFilter(); filter != nil {
+
+			to.TickSize = filter.TickSize
 
-			to.MaxQuantity = from.Symbols[key].LotSizeFilter().MaxQuantity
-			to.MinQuantity = from.Symbols[key].LotSizeFilter().MinQuantity
-			to.StepSize = from.Symbols[key].LotSizeFilter().StepSize
+		}
+
+		if filter := from.Symbols[key].MinNotionalFilter(); filter != nil {
+
+			to.MinNotional = filter.MinNotional
 
 		}
 
+		/* MIN_NOTIONAL is replaced by the NOTIONAL filter on newer symbols */
+		for _, filter := range from.Symbols[key].Filters {
+
+			if filter["filterType"] == "NOTIONAL" {
+
+				if minNotional, ok := filter["minNotional"].(string); ok {
+
+					to.MinNotional = minNotional
+
+				}
+
+			}
+
+		}
+
+		to.BasePrecision = from.Symbols[key].BaseAssetPrecision
+		to.QuotePrecision = from.Symbols[key].QuotePrecision
+
+		symbols = append(symbols, to)
+
 	}
 
-	return to
+	return symbols
+
+}
+
+const (
+	binanceAPIURL        = "https://api.binance.com"        /* REST endpoint */
+	binanceAPITestnetURL = "https://testnet.binance.vision" /* REST endpoint of the exchange test network */
+)
 
+/* binanceExchange implements types.Exchange for Binance */
+type binanceExchange struct {
+	client *binance.Client
 }
 
-/* Get Binance client */
-func binanceGetClient(
-	configData *types.Config) *binance.Client {
+/* Network of the Binance websockets. The library selects websocket endpoints for the whole process, so the first client created sets the network. */
+var binanceNetwork = struct {
+	sync.Mutex
+	set     bool
+	testnet bool
+}{}
+
+func init() {
+
+	Register("binance", newBinanceExchange)
+
+}
+
+/* Get Binance client. REST requests use the endpoint of the client, while testnet and mainnet websockets cannot be mixed in the process. */
+func newBinanceExchange(
+	configData *types.Config) (types.Exchange, error) {
+
+	binanceNetwork.Lock()
+	defer binanceNetwork.Unlock()
+
+	if binanceNetwork.set && binanceNetwork.testnet != configData.TestNet {
+
+		return nil, errors.New("Binance testnet and mainnet configurations cannot run in the same process")
+
+	}
+
+	binanceNetwork.set = true
+	binanceNetwork.testnet = configData.TestNet
 
 	binance.WebsocketKeepalive = false
 	binance.WebsocketTimeout = time.Second * 30
+	binance.UseTestnet = configData.TestNet /* Websocket endpoints */
 
 	/* Exchange test network, used with launch.json */
 	if configData.TestNet {
 
-		binance.UseTestnet = true
-		return binance.NewClient(configData.ApikeyTestNet, configData.SecretkeyTestNet)
+		client := binance.NewClient(configData.ApikeyTestNet, configData.SecretkeyTestNet)
+		client.BaseURL = binanceAPITestnetURL
+
+		return &binanceExchange{client: client}, nil
 
 	}
 
-	return binance.NewClient(configData.Apikey, configData.Secretkey)
+	client := binance.NewClient(configData.Apikey, configData.Secretkey)
+	client.BaseURL = binanceAPIURL
+
+	return &binanceExchange{client: client}, nil
 
 }
 
-/* Retrieve exchange information */
-func binanceGetInfo(
-	sessionData *types.Session) (info *types.ExchangeInfo, err error) {
+/* Retrieve exchange information for every symbol */
+func (e *binanceExchange) GetSymbols(
+	sessionData *types.Session) (symbols []*types.ExchangeInfo, err error) {
 
 	var tmp *binance.ExchangeInfo
 
-	if tmp, err = sessionData.Clients.Binance.NewExchangeInfoService().Do(context.Background()); err != nil {
+	if tmp, err = e.client.NewExchangeInfoService().Do(context.Background()); err != nil {
 
 		return nil, err
 
 	}
 
-	return binanceMapExchangeInfo(sessionData, tmp), err
+	return binanceMapExchangeInfo(tmp), err
 
 }
 
 /* Retrieve listen key for user stream service */
-func binanceGetUserStreamServiceListenKey(
+func (e *binanceExchange) GetUserStreamServiceListenKey(
 	sessionData *types.Session) (listenKey string, err error) {
 
-	if listenKey, err = sessionData.Clients.Binance.NewStartUserStreamService().Do(context.Background()); err != nil {
+	if listenKey, err = e.client.NewStartUserStreamService().Do(context.Background()); err != nil {
 
 		return "", err
 
@@ -201,10 +332,10 @@ func binanceGetUserStreamServiceListenKey(
 }
 
 /* Keep user stream service alive */
-func binanceKeepAliveUserStreamServiceListenKey(
+func (e *binanceExchange) KeepAliveUserStreamServiceListenKey(
 	sessionData *types.Session) (err error) {
 
-	if err = sessionData.Clients.Binance.NewKeepaliveUserStreamService().ListenKey(sessionData.ListenKey).Do(context.Background()); err != nil {
+	if err = e.client.NewKeepaliveUserStreamService().ListenKey(sessionData.ListenKey).Do(context.Background()); err != nil {
 
 		return err
 
@@ -215,10 +346,10 @@ func binanceKeepAliveUserStreamServiceListenKey(
 }
 
 /* Synchronize time */
-func binanceNewSetServerTimeService(
+func (e *binanceExchange) NewSetServerTimeService(
 	sessionData *types.Session) (err error) {
 
-	if _, err = sessionData.Clients.Binance.NewSetServerTimeService().Do(context.Background()); err != nil {
+	if _, err = e.client.NewSetServerTimeService().Do(context.Background()); err != nil {
 
 		return err
 
@@ -229,12 +360,12 @@ func binanceNewSetServerTimeService(
 }
 
 /* Retrieve funds available */
-func binanceGetSymbolFunds(
+func (e *binanceExchange) GetSymbolFunds(
 	sessionData *types.Session) (balance float64, err error) {
 
 	var account *binance.Account
 
-	if account, err = sessionData.Clients.Binance.NewGetAccountService().Do(context.Background()); err != nil {
+	if account, err = e.client.NewGetAccountService().Do(context.Background()); err != nil {
 
 		functions.Logger(&types.LogEntry{
 			Config:   nil,
@@ -263,28 +394,48 @@ func binanceGetSymbolFunds(
 
 }
 
-/* Minutely crypto currency open/close prices, high/low, trades and others */
-func binanceGetKlines(
-	sessionData *types.Session) (klines []*binance.Kline, err error) {
+/* Crypto currency open/close prices, high/low, trades and others for the kline interval, from startTime or up to endTime when not zero */
+func (e *binanceExchange) GetKlines(
+	sessionData *types.Session,
+	interval string,
+	startTime int64,
+	endTime int64,
+	limit int) (klines []*types.Kline, err error) {
+
+	var tmp []*binance.Kline
+
+	service := e.client.NewKlinesService().Symbol(sessionData.Symbol).
+		Interval(interval).Limit(limit)
 
-	if klines, err = sessionData.Clients.Binance.NewKlinesService().Symbol(sessionData.Symbol).
-		Interval("1m").Limit(14).Do(context.Background()); err != nil {
+	if startTime > 0 {
+
+		service.StartTime(startTime)
+
+	}
+
+	if endTime > 0 {
+
+		service.EndTime(endTime)
+
+	}
+
+	if tmp, err = service.Do(context.Background()); err != nil {
 
 		return nil, err
 
 	}
 
-	return klines, err
+	return binanceMapKline(tmp), err
 
 }
 
 /* 24hr ticker price change statistics */
-func binanceGetPriceChangeStats(
+func (e *binanceExchange) GetPriceChangeStats(
 	sessionData *types.Session) (PriceChangeStats []*types.PriceChangeStats, err error) {
 
 	var tmp []*binance.PriceChangeStats
 
-	if tmp, err = sessionData.Clients.Binance.NewListPriceChangeStatsService().Symbol(sessionData.Symbol).Do(context.Background()); err != nil {
+	if tmp, err = e.client.NewListPriceChangeStatsService().Symbol(sessionData.Symbol).Do(context.Background()); err != nil {
 
 		return nil, err
 
@@ -294,14 +445,37 @@ func binanceGetPriceChangeStats(
 
 }
 
+/* Retrieve the latest price for a symbol */
+func (e *binanceExchange) GetPrice(
+	sessionData *types.Session,
+	symbol string) (price float64, err error) {
+
+	var tmp []*binance.SymbolPrice
+
+	if tmp, err = e.client.NewListPricesService().Symbol(symbol).Do(context.Background()); err != nil {
+
+		return 0, err
+
+	}
+
+	if len(tmp) == 0 {
+
+		return 0, errors.New("Price not found for " + symbol)
+
+	}
+
+	return functions.StrToFloat64(tmp[0].Price), err
+
+}
+
 /* Retrieve Order Status */
-func binanceGetOrder(
+func (e *binanceExchange) GetOrder(
 	sessionData *types.Session,
 	orderID int64) (order *types.Order, err error) {
 
 	var tmp *binance.Order
 
-	if tmp, err = sessionData.Clients.Binance.NewGetOrderService().Symbol(sessionData.Symbol).OrderID(orderID).Do(context.Background()); err != nil {
+	if tmp, err = e.client.NewGetOrderService().Symbol(sessionData.Symbol).OrderID(orderID).Do(context.Background()); err != nil {
 
 		return nil, err
 
@@ -311,14 +485,59 @@ func binanceGetOrder(
 
 }
 
+/* Retrieve open orders for the symbol */
+func (e *binanceExchange) GetOpenOrders(
+	sessionData *types.Session) (orders []*types.Order, err error) {
+
+	var tmp []*binance.Order
+
+	if tmp, err = e.client.NewListOpenOrdersService().Symbol(sessionData.Symbol).Do(context.Background()); err != nil {
+
+		return nil, err
+
+	}
+
+	for key := range tmp {
+
+		orders = append(orders, binanceMapOrder(tmp[key]))
+
+	}
+
+	return orders, err
+
+}
+
+/* Retrieve the most recent orders for the symbol, open or not */
+func (e *binanceExchange) GetRecentOrders(
+	sessionData *types.Session,
+	limit int) (orders []*types.Order, err error) {
+
+	var tmp []*binance.Order
+
+	if tmp, err = e.client.NewListOrdersService().Symbol(sessionData.Symbol).Limit(limit).Do(context.Background()); err != nil {
+
+		return nil, err
+
+	}
+
+	for key := range tmp {
+
+		orders = append(orders, binanceMapOrder(tmp[key]))
+
+	}
+
+	return orders, err
+
+}
+
 /* CANCEL an order */
-func binanceCancelOrder(
+func (e *binanceExchange) CancelOrder(
 	sessionData *types.Session,
 	orderID int64) (cancelOrderResponse *types.Order, err error) {
 
 	var tmp *binance.CancelOrderResponse
 
-	if tmp, err = sessionData.Clients.Binance.NewCancelOrderService().Symbol(sessionData.Symbol).OrderID(orderID).Do(context.Background()); err != nil {
+	if tmp, err = e.client.NewCancelOrderService().Symbol(sessionData.Symbol).OrderID(orderID).Do(context.Background()); err != nil {
 
 		return nil, err
 
@@ -329,14 +548,14 @@ func binanceCancelOrder(
 }
 
 /* Create order to BUY */
-func binanceBuyOrder(
+func (e *binanceExchange) BuyOrder(
 	sessionData *types.Session,
 	quantity string) (order *types.Order, err error) {
 
 	var tmp *binance.CreateOrderResponse
 
 	/* Execute OrderTypeMarket */
-	if tmp, err = sessionData.Clients.Binance.NewCreateOrderService().Symbol(sessionData.Symbol).
+	if tmp, err = e.client.NewCreateOrderService().Symbol(sessionData.Symbol).
 		Side(binance.SideTypeBuy).Type(binance.OrderTypeMarket).
 		Quantity(quantity).Do(context.Background()); err != nil {
 
@@ -358,42 +577,67 @@ func binanceBuyOrder(
 }
 
 /* WsBookTickerServe serve websocket that pushes updates to the best bid or ask price or quantity in real-time for a specified symbol. */
-func binanceWsBookTickerServe(
+func (e *binanceExchange) WsBookTickerServe(
+	sessionData *types.Session,
+	wsHandler *types.WsHandler,
+	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
+
+	doneC, stopC, err = binance.WsBookTickerServe(sessionData.Symbol, func(event *binance.WsBookTickerEvent) {
+
+		wsHandler.WsBookTicker(binanceMapWsBookTicker(event))
+
+	}, errHandler)
+
+	return doneC, stopC, err
+
+}
+
+/* WsDepthServe serve websocket partial depth handler with the top 20 levels every 100ms */
+func (e *binanceExchange) WsDepthServe(
 	sessionData *types.Session,
 	wsHandler *types.WsHandler,
 	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
 
-	doneC, stopC, err = binance.WsBookTickerServe(sessionData.Symbol, wsHandler.BinanceWsBookTicker, errHandler)
+	doneC, stopC, err = binance.WsPartialDepthServe100Ms(sessionData.Symbol, "20", func(event *binance.WsPartialDepthEvent) {
+
+		wsHandler.WsDepth(binanceMapWsDepth(event))
+
+	}, errHandler)
 
 	return doneC, stopC, err
 
 }
 
 /* WsKlineServe serve websocket kline handler */
-func binanceWsKlineServe(
+func (e *binanceExchange) WsKlineServe(
 	sessionData *types.Session,
+	interval string,
 	wsHandler *types.WsHandler,
 	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
 
-	doneC, stopC, err = binance.WsKlineServe(sessionData.Symbol, "1m", wsHandler.BinanceWsKline, errHandler)
+	doneC, stopC, err = binance.WsKlineServe(sessionData.Symbol, interval, func(event *binance.WsKlineEvent) {
+
+		wsHandler.WsKline(binanceMapWsKline(event.Kline))
+
+	}, errHandler)
 
 	return doneC, stopC, err
 
 }
 
 /* WsUserDataServe serve user data handler with listen key */
-func binanceWsUserDataServe(
+func (e *binanceExchange) WsUserDataServe(
 	sessionData *types.Session,
 	wsHandler *types.WsHandler,
 	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
 
-	doneC, stopC, err = binance.WsUserDataServe(sessionData.ListenKey, wsHandler.BinanceWsUserDataServe, errHandler)
+	doneC, stopC, err = binance.WsUserDataServe(sessionData.ListenKey, wsHandler.WsUserDataServe, errHandler)
 
 	return doneC, stopC, err
 }
 
 /* Create order to SELL */
-func binanceSellOrder(
+func (e *binanceExchange) SellOrder(
 	marketData *types.Market,
 	sessionData *types.Session,
 	quantity string) (order *types.Order, err error) {
@@ -403,7 +647,7 @@ func binanceSellOrder(
 	if !sessionData.ForceSell {
 
 		/* Execute OrderTypeLimit */
-		if tmp, err = sessionData.Clients.Binance.NewCreateOrderService().Symbol(sessionData.Symbol).Side(binance.SideTypeSell).Type(binance.OrderTypeLimit).Quantity(quantity).Price(functions.Float64ToStr(marketData.Price, 2)).TimeInForce(binance.TimeInForceTypeGTC).Do(context.Background()); err != nil {
+		if tmp, err = e.client.NewCreateOrderService().Symbol(sessionData.Symbol).Side(binance.SideTypeSell).Type(binance.OrderTypeLimit).Quantity(quantity).Price(formatPrice(sessionData, marketData.Price)).TimeInForce(binance.TimeInForceTypeGTC).Do(context.Background()); err != nil {
 
 			return nil, err
 
@@ -416,7 +660,7 @@ func binanceSellOrder(
 		sessionData.ForceSell = false
 
 		/* Execute OrderTypeMarket */
-		if tmp, err = sessionData.Clients.Binance.NewCreateOrderService().Symbol(sessionData.Symbol).Side(binance.SideTypeSell).Type(binance.OrderTypeMarket).Quantity(quantity).Do(context.Background()); err != nil {
+		if tmp, err = e.client.NewCreateOrderService().Symbol(sessionData.Symbol).Side(binance.SideTypeSell).Type(binance.OrderTypeMarket).Quantity(quantity).Do(context.Background()); err != nil {
 
 			return nil, err
 
@@ -4,25 +4,73 @@ import (
 	"errors"
 	"math"
 	"strings"
+	"sync"
 	"time"
 
 	"cryptopump/functions"
-	"cryptopump/mysql"
+	"cryptopump/ledger"
 	"cryptopump/threads"
 	"cryptopump/types"
 
+	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
 )
 
-// GetClient Define the exchange to be used
+const klinesPageLimit = 1000 /* Maximum klines retrieved by one REST request */
+
+// Factory create an exchange adapter from the configuration
+type Factory func(configData *types.Config) (types.Exchange, error)
+
+/* Exchange adapters available, indexed by lower case exchange name */
+var factories = map[string]Factory{}
+
+/* Exchange clients shared by the threads of the process, indexed by exchange name, network and API key */
+var clients = struct {
+	sync.Mutex
+	venues map[string]types.Exchange
+}{venues: map[string]types.Exchange{}}
+
+// Register make an exchange adapter available under the provided name.
+// Adapters are expected to call Register from their init function.
+func Register(
+	name string,
+	factory Factory) {
+
+	factories[strings.ToLower(name)] = factory
+
+}
+
+// GetClient Define the exchange to be used. Threads with the same exchange credentials share the exchange client.
 func GetClient(
 	configData *types.Config,
 	sessionData *types.Session) (err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
+	if _, ok := factories[strings.ToLower(configData.ExchangeName)]; ok {
+
+		venue, err := getVenue(configData)
+
+		if err != nil {
+
+			return err
+
+		}
+
+		/* DryRun mode trade against a simulated exchange, keeping its virtual funds and orders on reconnect */
+		if configData.DryRun {
+
+			if simulated, ok := sessionData.Exchange.(*SimulatedExchange); ok {
+
+				simulated.venue = venue
+				return nil
+
+			}
+
+			sessionData.Exchange = NewSimulatedExchange(venue, configData)
+			return nil
+
+		}
 
-		sessionData.Clients.Binance = binanceGetClient(configData)
+		sessionData.Exchange = venue
 		return nil
 
 	}
@@ -31,20 +79,46 @@ func GetClient(
 
 }
 
+/* Retrieve the shared exchange client for the configuration, creating it on first use */
+func getVenue(
+	configData *types.Config) (venue types.Exchange, err error) {
+
+	key := strings.ToLower(configData.ExchangeName) + "/" + configData.Apikey
+
+	if configData.TestNet {
+
+		key = strings.ToLower(configData.ExchangeName) + "/testnet/" + configData.ApikeyTestNet
+
+	}
+
+	clients.Lock()
+	defer clients.Unlock()
+
+	if venue, ok := clients.venues[key]; ok {
+
+		return venue, nil
+
+	}
+
+	if venue, err = factories[strings.ToLower(configData.ExchangeName)](configData); err != nil {
+
+		return nil, err
+
+	}
+
+	clients.venues[key] = venue
+
+	return venue, nil
+
+}
+
 // GetOrder Retrieve Order Status
 func GetOrder(
 	configData *types.Config,
 	sessionData *types.Session,
 	orderID int64) (order *types.Order, err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
-
-		return binanceGetOrder(sessionData, orderID)
-
-	}
-
-	return
+	return sessionData.Exchange.GetOrder(sessionData, orderID)
 
 }
 
@@ -54,14 +128,7 @@ func BuyOrder(
 	sessionData *types.Session,
 	quantity string) (order *types.Order, err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
-
-		return binanceBuyOrder(sessionData, quantity)
-
-	}
-
-	return
+	return sessionData.Exchange.BuyOrder(sessionData, quantity)
 
 }
 
@@ -72,14 +139,7 @@ func SellOrder(
 	sessionData *types.Session,
 	quantity string) (order *types.Order, err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
-
-		return binanceSellOrder(marketData, sessionData, quantity)
-
-	}
-
-	return
+	return sessionData.Exchange.SellOrder(marketData, sessionData, quantity)
 
 }
 
@@ -89,34 +149,91 @@ func CancelOrder(
 	sessionData *types.Session,
 	orderID int64) (order *types.Order, err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
+	return sessionData.Exchange.CancelOrder(sessionData, orderID)
 
-		return binanceCancelOrder(sessionData, orderID)
+}
 
-	}
+// GetOpenOrders Retrieve open orders for the symbol
+func GetOpenOrders(
+	configData *types.Config,
+	sessionData *types.Session) (orders []*types.Order, err error) {
 
-	return
+	return sessionData.Exchange.GetOpenOrders(sessionData)
 
 }
 
-// GetInfo Retrieve exchange information
-func GetInfo(
+// GetRecentOrders Retrieve the most recent orders for the symbol, open or not
+func GetRecentOrders(
 	configData *types.Config,
-	sessionData *types.Session) (info *types.ExchangeInfo, err error) {
+	sessionData *types.Session,
+	limit int) (orders []*types.Order, err error) {
+
+	return sessionData.Exchange.GetRecentOrders(sessionData, limit)
+
+}
+
+// GetPrice Retrieve the latest price for a symbol
+func GetPrice(
+	configData *types.Config,
+	sessionData *types.Session,
+	symbol string) (price float64, err error) {
+
+	return sessionData.Exchange.GetPrice(sessionData, symbol)
+
+}
+
+// GetCommission Convert a commission to the quote currency of the session symbol.
+// Commissions paid in the base asset are converted at the fill price, and other assets (e.g. BNB) at their market price.
+func GetCommission(
+	configData *types.Config,
+	sessionData *types.Session,
+	amount decimal.Decimal,
+	asset string,
+	price decimal.Decimal) (commission decimal.Decimal) {
+
+	if amount.IsZero() || asset == sessionData.SymbolFiat {
+
+		return amount
+
+	}
+
+	if info, err := GetSymbol(configData, sessionData, sessionData.Symbol); err == nil && asset == info.BaseAsset {
+
+		return amount.Mul(price)
+
+	}
+
+	assetPrice, err := GetPrice(configData, sessionData, asset+sessionData.SymbolFiat)
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
+	if err != nil {
 
-		return binanceGetInfo(sessionData)
+		functions.Logger(&types.LogEntry{
+			Config:   configData,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return decimal.Zero
 
 	}
 
-	return
+	return amount.Mul(decimal.NewFromFloat(assetPrice))
+
+}
+
+// GetInfo Retrieve exchange information for the session symbol from the symbols registry
+func GetInfo(
+	configData *types.Config,
+	sessionData *types.Session) (info *types.ExchangeInfo, err error) {
+
+	return GetSymbol(configData, sessionData, sessionData.Symbol)
 
 }
 
-// GetLotSize Retrieve Lot Size specs
+// GetLotSize Retrieve Lot Size, price and notional specs
 func GetLotSize(
 	configData *types.Config,
 	sessionData *types.Session) {
@@ -126,6 +243,10 @@ func GetLotSize(
 		sessionData.MaxQuantity = functions.StrToFloat64(info.MaxQuantity)
 		sessionData.MinQuantity = functions.StrToFloat64(info.MinQuantity)
 		sessionData.StepSize = functions.StrToFloat64(info.StepSize)
+		sessionData.TickSize = functions.StrToFloat64(info.TickSize)
+		sessionData.MinNotional = functions.StrToFloat64(info.MinNotional)
+		sessionData.BasePrecision = info.BasePrecision
+		sessionData.QuotePrecision = info.QuotePrecision
 
 		return
 
@@ -138,36 +259,87 @@ func GetSymbolFunds(
 	configData *types.Config,
 	sessionData *types.Session) (balance float64, err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
+	return sessionData.Exchange.GetSymbolFunds(sessionData)
+
+}
+
+// GetKlines Retrieve the latest KLines for the kline interval via REST API.
+// Klines are retrieved in pages going back in time when more than one request is needed.
+func GetKlines(
+	configData *types.Config,
+	sessionData *types.Session,
+	interval string,
+	limit int) (klines []*types.Kline, err error) {
+
+	var endTime int64
+
+	for len(klines) < limit {
+
+		var page []*types.Kline
+
+		size := limit - len(klines)
 
-		return binanceGetSymbolFunds(sessionData)
+		if size > klinesPageLimit {
+
+			size = klinesPageLimit
+
+		}
+
+		if page, err = sessionData.Exchange.GetKlines(sessionData, interval, 0, endTime, size); err != nil {
+
+			return nil, err
+
+		}
+
+		klines = append(page, klines...)
+
+		/* No older klines available */
+		if len(page) < size {
+
+			break
+
+		}
+
+		endTime = page[0].OpenTime - 1
 
 	}
 
-	return
+	return klines, nil
 
 }
 
-// GetKlines Retrieve KLines via REST API
-func GetKlines(
+// GetKlinesSince Retrieve the KLines for the kline interval opened since startTime via REST API.
+// Klines are retrieved in pages going forward in time until the latest kline.
+func GetKlinesSince(
 	configData *types.Config,
-	sessionData *types.Session) (klines []*types.Kline, err error) {
+	sessionData *types.Session,
+	interval string,
+	startTime int64) (klines []*types.Kline, err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
+	for {
 
-		tmp, err := binanceGetKlines(sessionData)
+		var page []*types.Kline
+
+		if page, err = sessionData.Exchange.GetKlines(sessionData, interval, startTime, 0, klinesPageLimit); err != nil {
+
+			return nil, err
 
-		if err == nil {
-			return binanceMapKline(tmp), err
 		}
 
-		return nil, err
+		klines = append(klines, page...)
+
+		/* No newer klines available */
+		if len(page) < klinesPageLimit {
+
+			break
+
+		}
+
+		startTime = page[len(page)-1].OpenTime + 1
 
 	}
 
-	return
+	return klines, nil
 
 }
 
@@ -177,23 +349,146 @@ func GetPriceChangeStats(
 	sessionData *types.Session,
 	marketData *types.Market) (priceChangeStats []*types.PriceChangeStats, err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
+	return sessionData.Exchange.GetPriceChangeStats(sessionData)
+
+}
+
+/* Calculate the correct quantity to SELL according to the exchange lotSizeStep, deducting the BUY commission paid in the base asset */
+func getSellQuantity(
+	order types.Order,
+	configData *types.Config,
+	sessionData *types.Session) (quantity decimal.Decimal) {
+
+	if info, err := GetSymbol(configData, sessionData, sessionData.Symbol); err == nil {
 
-		return binanceGetPriceChangeStats(sessionData)
+		if buyOrder, err := sessionData.Storage.GetOrderByOrderID(sessionData, int64(order.OrderID)); err == nil &&
+			buyOrder.CommissionAmount.IsPositive() &&
+			buyOrder.CommissionAsset == info.BaseAsset {
+
+			/* The quantity held after the commission can't be rounded up */
+			return roundStep(sessionData, order.ExecutedQuantity.Sub(buyOrder.CommissionAmount), true)
+
+		}
 
 	}
 
-	return
+	return roundStep(sessionData, order.ExecutedQuantity, false)
 
 }
 
-/* Calculate the correct quantity to SELL according to the exchange lotSizeStep */
-func getSellQuantity(
-	order types.Order,
-	sessionData *types.Session) (quantity float64) {
+/* Round a quantity to a multiple of the exchange lotSizeStep, down when floor is set. The quantity is unchanged when the lotSizeStep is unknown. */
+func roundStep(
+	sessionData *types.Session,
+	quantity decimal.Decimal,
+	floor bool) decimal.Decimal {
+
+	if sessionData.StepSize <= 0 {
+
+		return quantity
+
+	}
+
+	step := decimal.NewFromFloat(sessionData.StepSize)
+
+	if floor {
+
+		return quantity.Div(step).Floor().Mul(step)
+
+	}
+
+	return quantity.Div(step).Round(0).Mul(step)
+
+}
+
+/* Format a quantity with the decimals of the exchange lotSizeStep, or the base asset precision when unknown */
+func formatQuantity(
+	sessionData *types.Session,
+	quantity decimal.Decimal) string {
+
+	precision := 8
+
+	if sessionData.StepSize > 0 {
+
+		precision = functions.Precision(sessionData.StepSize)
+
+	} else if sessionData.BasePrecision > 0 {
+
+		precision = sessionData.BasePrecision
+
+	}
+
+	return quantity.StringFixed(int32(precision))
+
+}
 
-	return math.Round(order.ExecutedQuantity/sessionData.StepSize) * sessionData.StepSize
+/* Round a price to the exchange tickSize and format it with its decimals, or the quote asset precision when unknown */
+func formatPrice(
+	sessionData *types.Session,
+	price float64) string {
+
+	precision := 8
+
+	if sessionData.TickSize > 0 {
+
+		price = math.Round(price/sessionData.TickSize) * sessionData.TickSize
+		precision = functions.Precision(sessionData.TickSize)
+
+	} else if sessionData.QuotePrecision > 0 {
+
+		precision = sessionData.QuotePrecision
+
+	}
+
+	return functions.Float64ToStr(price, precision)
+
+}
+
+/* Check the order value reaches the exchange minimum notional, logging orders refused */
+func isMinNotional(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session,
+	side string,
+	quantity decimal.Decimal,
+	price float64) bool {
+
+	notional := quantity.Mul(decimal.NewFromFloat(price))
+
+	if notional.GreaterThanOrEqual(decimal.NewFromFloat(sessionData.MinNotional)) {
+
+		return true
+
+	}
+
+	functions.Logger(&types.LogEntry{
+		Config:  configData,
+		Market:  marketData,
+		Session: sessionData,
+		Order: &types.Order{
+			Side:                    side,
+			Price:                   decimal.NewFromFloat(price),
+			ExecutedQuantity:        quantity,
+			CumulativeQuoteQuantity: notional,
+		},
+		Message:  "MIN NOTIONAL",
+		LogLevel: log.InfoLevel,
+	})
+
+	return false
+
+}
+
+/* Calculate the average fill price of an order, zero when nothing was filled */
+func averagePrice(
+	order *types.Order) decimal.Decimal {
+
+	if order.ExecutedQuantity.IsZero() {
+
+		return decimal.Zero
+
+	}
+
+	return order.CumulativeQuoteQuantity.Div(order.ExecutedQuantity)
 
 }
 
@@ -201,9 +496,9 @@ func getSellQuantity(
 func getBuyQuantity(
 	marketData *types.Market,
 	sessionData *types.Session,
-	fiatQuantity float64) (quantity float64) {
+	fiatQuantity float64) (quantity decimal.Decimal) {
 
-	return math.Round((fiatQuantity/marketData.Price)/sessionData.StepSize) * sessionData.StepSize
+	return roundStep(sessionData, decimal.NewFromFloat(fiatQuantity).Div(decimal.NewFromFloat(marketData.Price)), false)
 
 }
 
@@ -212,14 +507,7 @@ func GetUserStreamServiceListenKey(
 	configData *types.Config,
 	sessionData *types.Session) (listenKey string, err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
-
-		return binanceGetUserStreamServiceListenKey(sessionData)
-
-	}
-
-	return
+	return sessionData.Exchange.GetUserStreamServiceListenKey(sessionData)
 
 }
 
@@ -228,14 +516,7 @@ func KeepAliveUserStreamServiceListenKey(
 	configData *types.Config,
 	sessionData *types.Session) (err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
-
-		return binanceKeepAliveUserStreamServiceListenKey(sessionData)
-
-	}
-
-	return
+	return sessionData.Exchange.KeepAliveUserStreamServiceListenKey(sessionData)
 
 }
 
@@ -244,14 +525,7 @@ func NewSetServerTimeService(
 	configData *types.Config,
 	sessionData *types.Session) (err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
-
-		return binanceNewSetServerTimeService(sessionData)
-
-	}
-
-	return
+	return sessionData.Exchange.NewSetServerTimeService(sessionData)
 
 }
 
@@ -262,32 +536,30 @@ func WsBookTickerServe(
 	wsHandler *types.WsHandler,
 	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
+	return sessionData.Exchange.WsBookTickerServe(sessionData, wsHandler, errHandler)
 
-		return binanceWsBookTickerServe(sessionData, wsHandler, errHandler)
+}
 
-	}
+// WsDepthServe serve websocket that pushes the top levels of the order book in real-time for a specified symbol.
+func WsDepthServe(
+	configData *types.Config,
+	sessionData *types.Session,
+	wsHandler *types.WsHandler,
+	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
 
-	return
+	return sessionData.Exchange.WsDepthServe(sessionData, wsHandler, errHandler)
 
 }
 
-// WsKlineServe serve websocket kline handler
+// WsKlineServe serve websocket kline handler for the kline interval
 func WsKlineServe(
 	configData *types.Config,
 	sessionData *types.Session,
+	interval string,
 	wsHandler *types.WsHandler,
 	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
-
-		return binanceWsKlineServe(sessionData, wsHandler, errHandler)
-
-	}
-
-	return
+	return sessionData.Exchange.WsKlineServe(sessionData, interval, wsHandler, errHandler)
 
 }
 
@@ -298,14 +570,7 @@ func WsUserDataServe(
 	wsHandler *types.WsHandler,
 	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
 
-	switch strings.ToLower(configData.ExchangeName) {
-	case "binance":
-
-		return binanceWsUserDataServe(sessionData, wsHandler, errHandler)
-
-	}
-
-	return
+	return sessionData.Exchange.WsUserDataServe(sessionData, wsHandler, errHandler)
 
 }
 
@@ -317,8 +582,9 @@ func BuyTicker(
 	sessionData *types.Session) {
 
 	var orderStatus *types.Order
-	var orderPrice float64
-	var orderExecutedQuantity float64
+	var orderPrice decimal.Decimal
+	var orderExecutedQuantity decimal.Decimal
+	var orderCumulativeQuoteQuantity decimal.Decimal
 	var isCanceled bool
 
 	/* Enter and defer exiting busy mode */
@@ -327,8 +593,11 @@ func BuyTicker(
 		sessionData.Busy = false
 	}()
 
-	/* Exit if DryRun mode set to true */
-	if configData.DryRun {
+	/* Get the correct quantity according to lotSizeMin and lotSizeStep */
+	buyQuantity := getBuyQuantity(marketData, sessionData, quantity)
+
+	/* Refuse orders below the exchange minimum notional */
+	if !isMinNotional(configData, marketData, sessionData, "BUY", buyQuantity, marketData.Price) {
 
 		return
 
@@ -337,7 +606,7 @@ func BuyTicker(
 	orderResponse, err := BuyOrder(
 		configData,
 		sessionData,
-		functions.Float64ToStr(getBuyQuantity(marketData, sessionData, quantity), 4)) /* Get the correct quantity according to lotSizeMin and lotSizeStep */
+		formatQuantity(sessionData, buyQuantity))
 
 	/* Test orderResponse for  errors */
 	if (orderResponse == nil && err != nil) ||
@@ -347,15 +616,22 @@ func BuyTicker(
 
 	}
 
-	/* Check if result is nil and set as zero */
-	if orderPrice = orderResponse.CumulativeQuoteQuantity / orderResponse.ExecutedQuantity; math.IsNaN(orderPrice) {
-		orderPrice = 0
-	}
+	/* Average fill price, zero when nothing was filled */
+	orderPrice = averagePrice(orderResponse)
 
 	orderExecutedQuantity = orderResponse.ExecutedQuantity
+	orderCumulativeQuoteQuantity = orderResponse.CumulativeQuoteQuantity
+
+	/* Convert the commission paid for the order response fills to the quote currency */
+	orderResponse.Commission = GetCommission(
+		configData,
+		sessionData,
+		orderResponse.CommissionAmount,
+		orderResponse.CommissionAsset,
+		orderPrice)
 
 	/* Save order to database */
-	if err := mysql.SaveOrder(
+	if err := sessionData.Storage.SaveOrder(
 		sessionData,
 		orderResponse.ClientOrderID,
 		orderResponse.CumulativeQuoteQuantity,
@@ -365,7 +641,10 @@ func BuyTicker(
 		string(orderResponse.Side),
 		string(orderResponse.Status),
 		orderResponse.Symbol,
-		orderResponse.TransactTime); err != nil {
+		orderResponse.TransactTime,
+		orderResponse.CommissionAmount,
+		orderResponse.CommissionAsset,
+		orderResponse.Commission); err != nil {
 
 		/* Cleanly exit ThreadID */
 		threads.ExitThreadID(sessionData)
@@ -373,7 +652,7 @@ func BuyTicker(
 	}
 
 	/* This session variable stores the time of the last buy */
-	sessionData.LastBuyTransactTime = time.Now()
+	sessionData.LastBuyTransactTime = functions.Now(sessionData)
 
 S:
 	switch orderResponse.Status {
@@ -395,23 +674,40 @@ S:
 
 			}
 
-			time.Sleep(3000 * time.Millisecond)
+			/* Wait for the order to be updated by the user data stream, retrieving its status from the exchange when the wait times out */
+			if update := waitOrderUpdate(
+				sessionData,
+				int64(orderResponse.OrderID),
+				3000*time.Millisecond); update != nil {
+
+				orderStatus = update
+
+			} else {
+
+				orderStatus, err = GetOrder(
+					configData,
+					sessionData,
+					int64(orderResponse.OrderID))
+
+			}
 
 		}
 
 		switch orderStatus.Status {
 		case "FILLED", "PARTIALLY_FILLED":
 
-			orderPrice = orderStatus.CumulativeQuoteQuantity / orderStatus.ExecutedQuantity
+			orderPrice = averagePrice(orderStatus)
 
 			orderExecutedQuantity = orderStatus.ExecutedQuantity
+			orderCumulativeQuoteQuantity = orderStatus.CumulativeQuoteQuantity
 
 			/* Update order status and price & Save Thread Transaction */
-			if err := mysql.UpdateOrder(
+			if err := UpdateOrder(
+				configData,
 				sessionData,
 				int64(orderResponse.OrderID),
-				orderResponse.CumulativeQuoteQuantity,
-				orderResponse.ExecutedQuantity,
+				orderCumulativeQuoteQuantity,
+				orderExecutedQuantity,
 				orderPrice,
 				string(orderStatus.Status)); err != nil {
 
@@ -420,7 +716,7 @@ S:
 
 			}
 
-		case "CANCELED":
+		case "CANCELED", "EXPIRED":
 
 			isCanceled = true
 
@@ -433,10 +729,10 @@ S:
 	if !isCanceled {
 
 		/* Save Thread Transaction */
-		if err := mysql.SaveThreadTransaction(
+		if err := sessionData.Storage.SaveThreadTransaction(
 			sessionData,
 			int64(orderResponse.OrderID),
-			orderResponse.CumulativeQuoteQuantity,
+			orderCumulativeQuoteQuantity,
 			orderPrice,
 			orderExecutedQuantity); err != nil {
 
@@ -445,13 +741,32 @@ S:
 
 		}
 
+		/* Record the lot in the ledger, with the commission recorded for the order */
+		lot := types.Order{
+			OrderID:                 orderResponse.OrderID,
+			Symbol:                  orderResponse.Symbol,
+			ExecutedQuantity:        orderExecutedQuantity,
+			CumulativeQuoteQuantity: orderCumulativeQuoteQuantity,
+			Commission:              orderResponse.Commission,
+			TransactTime:            orderResponse.TransactTime,
+		}
+
+		if stored, err := sessionData.Storage.GetOrderByOrderID(sessionData, int64(orderResponse.OrderID)); err == nil && stored.Status != "" {
+
+			lot.Commission = stored.Commission
+
+		}
+
+		_ = ledger.Open(configData, sessionData, &lot)
+
 		functions.Logger(&types.LogEntry{
 			Config:  configData,
 			Market:  marketData,
 			Session: sessionData,
 			Order: &types.Order{
-				OrderID: int(orderResponse.OrderID),
-				Price:   orderPrice,
+				OrderID:    int(orderResponse.OrderID),
+				Price:      orderPrice,
+				Commission: orderResponse.Commission,
 			},
 			Message:  "BUY",
 			LogLevel: log.InfoLevel,
@@ -496,8 +811,11 @@ func SellTicker(
 		sessionData.Busy = false
 	}()
 
-	/* Exit if DryRun mode set to true */
-	if configData.DryRun {
+	/* Get correct quantity to sell according to the lotSizeStep */
+	sellQuantity := getSellQuantity(order, configData, sessionData)
+
+	/* Refuse orders below the exchange minimum notional */
+	if !isMinNotional(configData, marketData, sessionData, "SELL", sellQuantity, functions.StrToFloat64(formatPrice(sessionData, marketData.Price))) {
 
 		return
 
@@ -507,7 +825,7 @@ func SellTicker(
 		configData,
 		marketData,
 		sessionData,
-		functions.Float64ToStr(getSellQuantity(order, sessionData), 6) /* Get correct quantity to sell according to the lotSizeStep */)
+		formatQuantity(sessionData, sellQuantity))
 
 	/* Test orderResponse for  errors */
 	if (orderResponse == nil && err != nil) ||
@@ -517,18 +835,37 @@ func SellTicker(
 
 	}
 
+	/* Convert the commission paid for the order response fills to the quote currency, at the average fill price */
+	orderPrice := decimal.NewFromFloat(marketData.Price)
+
+	if orderResponse.ExecutedQuantity.IsPositive() {
+
+		orderPrice = averagePrice(orderResponse)
+
+	}
+
+	orderResponse.Commission = GetCommission(
+		configData,
+		sessionData,
+		orderResponse.CommissionAmount,
+		orderResponse.CommissionAsset,
+		orderPrice)
+
 	/* Save order to database */
-	if err := mysql.SaveOrder(
+	if err := sessionData.Storage.SaveOrder(
 		sessionData,
 		orderResponse.ClientOrderID,
 		orderResponse.CumulativeQuoteQuantity,
 		orderResponse.ExecutedQuantity,
 		int64(orderResponse.OrderID),
-		marketData.Price,
+		decimal.NewFromFloat(marketData.Price),
 		string(orderResponse.Side),
 		string(orderResponse.Status),
 		orderResponse.Symbol,
-		orderResponse.TransactTime); err != nil {
+		orderResponse.TransactTime,
+		orderResponse.CommissionAmount,
+		orderResponse.CommissionAsset,
+		orderResponse.Commission); err != nil {
 
 		/* Cleanly exit ThreadID */
 		threads.ExitThreadID(sessionData)
@@ -545,15 +882,29 @@ S:
 
 	case "PARTIALLY_FILLED", "NEW":
 
-		time.Sleep(2000 * time.Millisecond)
+		/* Wait for the order to be filled, retrieving its status from the exchange unless the user data stream reports it FILLED, CANCELED or EXPIRED */
+		if orderStatus = waitOrderUpdate(
+			sessionData,
+			int64(orderResponse.OrderID),
+			2000*time.Millisecond); orderStatus == nil {
+
+			orderStatus, err = GetOrder(
+				configData,
+				sessionData,
+				int64(orderResponse.OrderID))
+
+		}
+
+		if err == nil && isOrderCanceled(orderStatus) {
+
+			isCanceled = true
+
+		}
 
 	F:
-		for orderStatus, err = GetOrder(
-			configData,
-			sessionData,
-			int64(orderResponse.OrderID)); orderStatus == nil ||
+		for orderStatus == nil ||
 			orderStatus.Status == "NEW" ||
-			orderStatus.Status == "PARTIALLY_FILLED"; {
+			orderStatus.Status == "PARTIALLY_FILLED" {
 
 			if err != nil {
 
@@ -626,7 +977,7 @@ S:
 					isCanceled = true
 
 					/* This session variable stores the time of the cancelled sell */
-					sessionData.LastSellCanceledTime = time.Now()
+					sessionData.LastSellCanceledTime = functions.Now(sessionData)
 
 					if orderStatus, err = GetOrder(
 						configData,
@@ -648,7 +999,7 @@ S:
 						Session: sessionData,
 						Order: &types.Order{
 							OrderID: int(orderResponse.OrderID),
-							Price:   marketData.Price,
+							Price:   decimal.NewFromFloat(marketData.Price),
 						},
 						Message:  "FAILED TO CANCEL ORDER",
 						LogLevel: log.InfoLevel,
@@ -660,20 +1011,41 @@ S:
 
 			}
 
-			/* Wait time between iterations (i++). There are ten iterations and the total waiting time define the amount od time before an order is canceled. configData.SellWaitBeforeCancel is divided by then converted into seconds. */
-			time.Sleep(
+			/* Wait time between iterations (i++). There are ten iterations and the total waiting time define the amount od time before an order is canceled. configData.SellWaitBeforeCancel is divided by then converted into seconds.
+			The wait ends early when the user data stream reports the order FILLED, CANCELED or EXPIRED, otherwise the order status is retrieved from the exchange. */
+			if update := waitOrderUpdate(
+				sessionData,
+				int64(orderResponse.OrderID),
 				time.Duration(
-					configData.SellWaitBeforeCancel/10) * time.Second)
+					configData.SellWaitBeforeCancel/10)*time.Second); update != nil {
+
+				orderStatus = update
+
+			} else {
+
+				orderStatus, err = GetOrder(
+					configData,
+					sessionData,
+					int64(orderResponse.OrderID))
+
+			}
+
+			if err == nil && isOrderCanceled(orderStatus) {
+
+				isCanceled = true
+
+			}
 
 		}
 
 		/* Update order status and price */
-		if err := mysql.UpdateOrder(
+		if err := UpdateOrder(
+			configData,
 			sessionData,
 			int64(orderResponse.OrderID),
 			orderStatus.CumulativeQuoteQuantity,
 			orderStatus.ExecutedQuantity,
-			marketData.Price,
+			decimal.NewFromFloat(marketData.Price),
 			string(orderStatus.Status)); err != nil {
 
 			/* Cleanly exit ThreadID */
@@ -685,8 +1057,23 @@ S:
 
 	if !isCanceled {
 
+		/* Quantities and commission recorded for the order, from the order response or the user data stream */
+		sold := *orderResponse
+
+		if stored, err := sessionData.Storage.GetOrderByOrderID(sessionData, int64(orderResponse.OrderID)); err == nil {
+
+			orderResponse.Commission = stored.Commission
+
+			if stored.Status != "" {
+
+				sold = stored
+
+			}
+
+		}
+
 		/* Remove Thread transaction from database */
-		if err := mysql.DeleteThreadTransactionByOrderID(
+		if err := sessionData.Storage.DeleteThreadTransactionByOrderID(
 			sessionData,
 			order.OrderID); err != nil {
 
@@ -695,13 +1082,17 @@ S:
 
 		}
 
+		/* Record the lots closed in the ledger */
+		_, _ = ledger.Close(configData, sessionData, order.OrderID, &sold)
+
 		functions.Logger(&types.LogEntry{
 			Config:  configData,
 			Market:  marketData,
 			Session: sessionData,
 			Order: &types.Order{
 				OrderID:       int(orderResponse.OrderID),
-				Price:         marketData.Price,
+				Price:         decimal.NewFromFloat(marketData.Price),
+				Commission:    orderResponse.Commission,
 				OrderIDSource: order.OrderID,
 			},
 			Message:  "SELL",
@@ -716,7 +1107,7 @@ S:
 			Session: sessionData,
 			Order: &types.Order{
 				OrderID:       int(orderResponse.OrderID),
-				Price:         marketData.Price,
+				Price:         decimal.NewFromFloat(marketData.Price),
 				OrderIDSource: order.OrderID,
 			},
 			Message:  "CANCELED",
@@ -726,3 +1117,62 @@ S:
 	}
 
 }
+
+/* Order ended on the exchange without being filled */
+func isOrderCanceled(order *types.Order) bool {
+
+	switch order.Status {
+	case "CANCELED", "EXPIRED", "REJECTED":
+
+		return true
+
+	}
+
+	return false
+
+}
+
+/* Wait up to duration for the user data stream to report an order FILLED, CANCELED, EXPIRED or REJECTED. Sleep when order updates are not streamed. */
+func waitOrderUpdate(
+	sessionData *types.Session,
+	orderID int64,
+	duration time.Duration) (order *types.Order) {
+
+	if sessionData.OrderUpdate == nil {
+
+		functions.Sleep(sessionData, duration)
+		return nil
+
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	for {
+
+		select {
+		case order = <-sessionData.OrderUpdate:
+
+			/* Discard updates from other orders */
+			if int64(order.OrderID) != orderID {
+
+				continue
+
+			}
+
+			switch order.Status {
+			case "FILLED", "CANCELED", "EXPIRED", "REJECTED":
+
+				return order
+
+			}
+
+		case <-timer.C:
+
+			return nil
+
+		}
+
+	}
+
+}
@@ -0,0 +1,226 @@
+package exchange
+
+import (
+	"testing"
+	"time"
+
+	"cryptopump/memory"
+	"cryptopump/types"
+
+	"github.com/shopspring/decimal"
+)
+
+/* Exchange filling a SELL order after the statuses polled, without streaming order updates */
+type sellExchange struct {
+	types.Exchange
+	statuses []string
+	polls    int
+	canceled bool
+}
+
+func (e *sellExchange) GetSymbols(
+	sessionData *types.Session) (symbols []*types.ExchangeInfo, err error) {
+
+	return []*types.ExchangeInfo{{Symbol: "BTCUSDT", BaseAsset: "BTC", QuoteAsset: "USDT"}}, nil
+
+}
+
+func (e *sellExchange) SellOrder(
+	marketData *types.Market,
+	sessionData *types.Session,
+	quantity string) (order *types.Order, err error) {
+
+	return &types.Order{OrderID: 10, Side: "SELL", Status: "NEW", Symbol: sessionData.Symbol, TransactTime: 2000}, nil
+
+}
+
+func (e *sellExchange) GetOrder(
+	sessionData *types.Session,
+	orderID int64) (order *types.Order, err error) {
+
+	order = &types.Order{OrderID: int(orderID), Side: "SELL", Status: e.statuses[e.polls], Symbol: sessionData.Symbol}
+
+	if order.Status == "FILLED" {
+
+		order.ExecutedQuantity = decimal.NewFromInt(1)
+		order.CumulativeQuoteQuantity = decimal.NewFromInt(110)
+
+	}
+
+	if e.polls < len(e.statuses)-1 {
+
+		e.polls++
+
+	}
+
+	return order, nil
+
+}
+
+func (e *sellExchange) CancelOrder(
+	sessionData *types.Session,
+	orderID int64) (order *types.Order, err error) {
+
+	e.canceled = true
+
+	return &types.Order{OrderID: int(orderID), Side: "SELL", Status: "CANCELED", Symbol: sessionData.Symbol}, nil
+
+}
+
+/* Clock advancing on sleep */
+type stepClock struct {
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+
+	return c.now
+
+}
+
+func (c *stepClock) Sleep(
+	d time.Duration) {
+
+	c.now = c.now.Add(d)
+
+}
+
+func TestSellTickerPolling(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		statuses []string /* Order statuses retrieved from the exchange */
+		sold     bool
+	}{
+		{
+			name:     "filled while waiting",
+			statuses: []string{"NEW", "NEW", "FILLED"},
+			sold:     true,
+		},
+		{
+			name:     "expired while waiting",
+			statuses: []string{"NEW", "EXPIRED"},
+		},
+	}
+
+	for _, test := range tests {
+
+		venue := &sellExchange{statuses: test.statuses}
+
+		sessionData := &types.Session{
+			ThreadID:   "test",
+			Symbol:     "BTCUSDT",
+			SymbolFiat: "USDT",
+			Backtest:   true,
+			Storage:    memory.New(),
+			Exchange:   venue,
+			Clock:      &stepClock{now: time.Unix(0, 0)},
+		}
+
+		_ = sessionData.Storage.SaveOrder(sessionData, "test", decimal.NewFromInt(100), decimal.NewFromInt(1), 1, decimal.NewFromInt(100), "BUY", "FILLED", sessionData.Symbol, 1000, decimal.Zero, "", decimal.Zero)
+		_ = sessionData.Storage.SaveThreadTransaction(sessionData, 1, decimal.NewFromInt(100), decimal.NewFromInt(100), decimal.NewFromInt(1))
+
+		SellTicker(
+			types.Order{OrderID: 1, ExecutedQuantity: decimal.NewFromInt(1)},
+			&types.Config{SellWaitBeforeCancel: 100},
+			&types.Market{Price: 110},
+			sessionData)
+
+		count, _ := sessionData.Storage.GetThreadTransactionCount(sessionData)
+		order, _ := sessionData.Storage.GetOrderByOrderID(sessionData, 10)
+
+		if venue.canceled {
+
+			t.Errorf("%s: SELL order canceled, want its status retrieved before the cancel deadline", test.name)
+
+		}
+
+		if order.Status != test.statuses[len(test.statuses)-1] {
+
+			t.Errorf("%s: SELL order status %q, want %q", test.name, order.Status, test.statuses[len(test.statuses)-1])
+
+		}
+
+		if test.sold && count != 0 || !test.sold && count != 1 {
+
+			t.Errorf("%s: %d thread transactions, want the thread transaction removed only when sold", test.name, count)
+
+		}
+
+	}
+
+}
+
+func TestRoundStep(t *testing.T) {
+
+	tests := []struct {
+		stepSize float64
+		quantity string
+		floor    bool
+		want     string
+	}{
+		{0, "1.23456789", true, "1.23456789"},
+		{-0.001, "1.23456789", false, "1.23456789"},
+		{0.001, "1.2345", true, "1.234"},
+		{0.001, "1.2345", false, "1.235"},
+		{0.001, "1.2344", false, "1.234"},
+		{0.001, "1.234", true, "1.234"},
+		{0.001, "0.0009", true, "0"},
+		{0.000001, "0.12345678", true, "0.123456"},
+		{0.1, "0.3", true, "0.3"},
+		{0.1, "0.29999999", true, "0.2"},
+		{1, "12.5", true, "12"},
+		{1, "12.5", false, "13"},
+		{10, "125", true, "120"},
+	}
+
+	for _, test := range tests {
+
+		sessionData := &types.Session{StepSize: test.stepSize}
+
+		got := roundStep(sessionData, decimal.RequireFromString(test.quantity), test.floor)
+
+		if !got.Equal(decimal.RequireFromString(test.want)) {
+
+			t.Errorf("roundStep(%v, %s, %v) = %s, want %s", test.stepSize, test.quantity, test.floor, got, test.want)
+
+		}
+
+	}
+
+}
+
+func TestFormatPrice(t *testing.T) {
+
+	tests := []struct {
+		tickSize       float64
+		quotePrecision int
+		price          float64
+		want           string
+	}{
+		{0.01, 0, 123.456, "123.46"},
+		{0.01, 0, 123.454, "123.45"},
+		{0.01, 8, 0.1 + 0.2, "0.30"},
+		{0.01, 0, 100, "100.00"},
+		{0.00001, 0, 0.000123456, "0.00012"},
+		{0.5, 0, 10.3, "10.5"},
+		{0.5, 0, 10.2, "10.0"},
+		{1, 0, 41234.56, "41235"},
+		{10, 0, 41234.56, "41230"},
+		{0, 2, 123.456, "123.46"},
+		{0, 0, 0.123456789, "0.12345679"},
+	}
+
+	for _, test := range tests {
+
+		sessionData := &types.Session{TickSize: test.tickSize, QuotePrecision: test.quotePrecision}
+
+		if got := formatPrice(sessionData, test.price); got != test.want {
+
+			t.Errorf("formatPrice(%v, %d, %v) = %s, want %s", test.tickSize, test.quotePrecision, test.price, got, test.want)
+
+		}
+
+	}
+
+}
@@ -0,0 +1,426 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+
+	"cryptopump/functions"
+	"cryptopump/types"
+
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+)
+
+const reconcileLimit = 500 /* Recent orders retrieved from the exchange for reconciliation */
+
+/* Order status transitions allowed from each status. An order keeps its status while quantities are updated. */
+var orderTransitions = map[string][]string{
+	"NEW":              {"NEW", "PARTIALLY_FILLED", "FILLED", "CANCELED", "EXPIRED", "REJECTED"},
+	"PARTIALLY_FILLED": {"PARTIALLY_FILLED", "FILLED", "CANCELED", "EXPIRED"},
+	"FILLED":           {"FILLED"},
+	"CANCELED":         {"CANCELED"},
+	"EXPIRED":          {"EXPIRED"},
+	"REJECTED":         {"REJECTED"},
+}
+
+// Reconciliation summarize the differences found between the exchange and the orders and thread tables
+type Reconciliation struct {
+	Orders         int /* Orders compared with the exchange */
+	Updated        int /* Orders updated to the exchange status and quantities */
+	Rejected       int /* Orders with an invalid status transition */
+	OrphanDatabase int /* Open orders unknown to the exchange */
+	OrphanExchange int /* Exchange orders missing from the orders table */
+	OrphanThreads  int /* Thread transactions with a BUY order unknown to the exchange */
+	UpdatedThreads int /* Thread transactions updated to the exchange quantity and amount filled */
+	DeletedThreads int /* Thread transactions removed as their BUY order was never filled */
+}
+
+/* Summarize the reconciliation */
+func (r *Reconciliation) String() string {
+
+	return fmt.Sprintf("Orders: %d\nUpdated: %d\nRejected: %d\nOrphan Database: %d\nOrphan Exchange: %d\nOrphan Threads: %d\nUpdated Threads: %d\nDeleted Threads: %d",
+		r.Orders,
+		r.Updated,
+		r.Rejected,
+		r.OrphanDatabase,
+		r.OrphanExchange,
+		r.OrphanThreads,
+		r.UpdatedThreads,
+		r.DeletedThreads)
+
+}
+
+/* Validate an order status transition */
+func isOrderTransition(
+	from string,
+	to string) bool {
+
+	for _, status := range orderTransitions[from] {
+
+		if status == to {
+
+			return true
+
+		}
+
+	}
+
+	return false
+
+}
+
+/* Check if an order is waiting to be filled */
+func isOrderOpen(
+	status string) bool {
+
+	return status == "NEW" || status == "PARTIALLY_FILLED"
+
+}
+
+// UpdateOrder Update order status, quantities and price. Invalid status transitions are rejected and logged.
+func UpdateOrder(
+	configData *types.Config,
+	sessionData *types.Session,
+	orderID int64,
+	cumulativeQuoteQuantity decimal.Decimal,
+	executedQuantity decimal.Decimal,
+	price decimal.Decimal,
+	status string) (err error) {
+
+	var order types.Order
+
+	if order, err = sessionData.Storage.GetOrderByOrderID(sessionData, orderID); err != nil {
+
+		return err
+
+	}
+
+	/* Order not saved yet */
+	if order.Status == "" {
+
+		return nil
+
+	}
+
+	if !isOrderTransition(order.Status, status) {
+
+		functions.Logger(&types.LogEntry{
+			Config:  configData,
+			Market:  nil,
+			Session: sessionData,
+			Order: &types.Order{
+				OrderID: int(orderID),
+			},
+			Message:  functions.GetFunctionName() + " - Invalid order status transition " + order.Status + " -> " + status,
+			LogLevel: log.DebugLevel,
+		})
+
+		return nil
+
+	}
+
+	return sessionData.Storage.UpdateOrder(
+		sessionData,
+		orderID,
+		cumulativeQuoteQuantity,
+		executedQuantity,
+		price,
+		status)
+
+}
+
+// Reconcile Compare the open and recent exchange orders for the symbol with the orders and thread tables.
+// Order statuses and quantities are fixed, thread transactions of BUY orders never filled are removed, and orphans on either side are logged.
+func Reconcile(
+	configData *types.Config,
+	sessionData *types.Session) (reconciliation *Reconciliation, err error) {
+
+	var openOrders []*types.Order
+	var recentOrders []*types.Order
+	var storageOrders []types.Order
+	var threadOrders []types.Order
+	var since int64
+
+	if sessionData.Exchange == nil {
+
+		return nil, errors.New("Reconcile - session not connected to the exchange")
+
+	}
+
+	reconciliation = &Reconciliation{}
+
+	if recentOrders, err = GetRecentOrders(configData, sessionData, reconcileLimit); err == nil {
+
+		openOrders, err = GetOpenOrders(configData, sessionData)
+
+	}
+
+	if err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   configData,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return nil, err
+
+	}
+
+	/* Exchange orders indexed by order ID. Open orders are retrieved last and take precedence. */
+	exchangeOrders := make(map[int]*types.Order)
+
+	for _, order := range append(recentOrders, openOrders...) {
+
+		exchangeOrders[order.OrderID] = order
+
+	}
+
+	/* Orders are compared for the period covered by the exchange orders, or the whole history when all orders were retrieved.
+	Open orders older than the period are retrieved one by one. */
+	if len(recentOrders) >= reconcileLimit {
+
+		since = recentOrders[0].TransactTime
+
+		for _, order := range exchangeOrders {
+
+			if order.TransactTime < since {
+
+				since = order.TransactTime
+
+			}
+
+		}
+
+	}
+
+	if storageOrders, err = sessionData.Storage.GetOrdersBySymbol(sessionData, 0); err != nil {
+
+		return nil, err
+
+	}
+
+	stored := make(map[int]bool)
+
+	for _, order := range storageOrders {
+
+		stored[order.OrderID] = true
+
+		exchangeOrder, ok := exchangeOrders[order.OrderID]
+
+		if !ok && order.TransactTime < since {
+
+			if !isOrderOpen(order.Status) {
+
+				continue
+
+			}
+
+			var e error
+
+			if exchangeOrder, e = GetOrder(configData, sessionData, int64(order.OrderID)); e == nil {
+
+				ok = true
+
+			}
+
+		}
+
+		reconciliation.Orders++
+
+		if !ok {
+
+			if isOrderOpen(order.Status) {
+
+				reconciliation.OrphanDatabase++
+				logReconcile(configData, sessionData, &order, "ORPHAN DATABASE")
+
+			}
+
+			continue
+
+		}
+
+		if exchangeOrder.Status == order.Status &&
+			exchangeOrder.ExecutedQuantity.Equal(order.ExecutedQuantity) &&
+			exchangeOrder.CumulativeQuoteQuantity.Equal(order.CumulativeQuoteQuantity) {
+
+			continue
+
+		}
+
+		if !isOrderTransition(order.Status, exchangeOrder.Status) {
+
+			reconciliation.Rejected++
+
+			functions.Logger(&types.LogEntry{
+				Config:  configData,
+				Market:  nil,
+				Session: sessionData,
+				Order: &types.Order{
+					OrderID: order.OrderID,
+				},
+				Message:  functions.GetFunctionName() + " - Invalid order status transition " + order.Status + " -> " + exchangeOrder.Status,
+				LogLevel: log.DebugLevel,
+			})
+
+			continue
+
+		}
+
+		/* Average fill price, or the stored price while nothing is filled */
+		price := order.Price
+
+		if exchangeOrder.ExecutedQuantity.IsPositive() {
+
+			price = averagePrice(exchangeOrder)
+
+		}
+
+		if err = sessionData.Storage.UpdateOrder(
+			sessionData,
+			int64(order.OrderID),
+			exchangeOrder.CumulativeQuoteQuantity,
+			exchangeOrder.ExecutedQuantity,
+			price,
+			exchangeOrder.Status); err != nil {
+
+			return nil, err
+
+		}
+
+		reconciliation.Updated++
+		logReconcile(configData, sessionData, exchangeOrder, "RECONCILE")
+
+	}
+
+	/* Exchange orders missing from the orders table */
+	for _, order := range exchangeOrders {
+
+		if !stored[order.OrderID] {
+
+			reconciliation.OrphanExchange++
+			logReconcile(configData, sessionData, order, "ORPHAN EXCHANGE")
+
+		}
+
+	}
+
+	if threadOrders, err = sessionData.Storage.GetThreadTransactionByThreadID(sessionData); err != nil {
+
+		return nil, err
+
+	}
+
+	for key := range threadOrders {
+
+		exchangeOrder, ok := exchangeOrders[threadOrders[key].OrderID]
+
+		if !ok {
+
+			var e error
+
+			if exchangeOrder, e = GetOrder(configData, sessionData, int64(threadOrders[key].OrderID)); e != nil {
+
+				reconciliation.OrphanThreads++
+				logReconcile(configData, sessionData, &threadOrders[key], "ORPHAN THREAD")
+
+				continue
+
+			}
+
+		}
+
+		/* Thread transactions are only kept for BUY orders filled, in full or in part */
+		if !exchangeOrder.ExecutedQuantity.IsPositive() {
+
+			switch exchangeOrder.Status {
+			case "CANCELED", "EXPIRED", "REJECTED":
+
+				if err = sessionData.Storage.DeleteThreadTransactionByOrderID(sessionData, threadOrders[key].OrderID); err != nil {
+
+					return nil, err
+
+				}
+
+				reconciliation.DeletedThreads++
+				logReconcile(configData, sessionData, exchangeOrder, "RECONCILE")
+
+			}
+
+			continue
+
+		}
+
+		/* Thread transactions hold the quantity and amount filled on the exchange */
+		price, executedQuantity, cumulativeQuoteQuantity, err := sessionData.Storage.GetThreadTransactionByOrderID(sessionData, threadOrders[key].OrderID)
+
+		if err != nil {
+
+			return nil, err
+
+		}
+
+		if executedQuantity.Equal(exchangeOrder.ExecutedQuantity) &&
+			cumulativeQuoteQuantity.Equal(exchangeOrder.CumulativeQuoteQuantity) {
+
+			continue
+
+		}
+
+		/* Average fill price, or the thread price when the exchange amount is unknown */
+		if exchangeOrder.CumulativeQuoteQuantity.IsPositive() {
+
+			price = averagePrice(exchangeOrder)
+
+		}
+
+		if err = sessionData.Storage.UpdateThreadTransaction(
+			sessionData,
+			threadOrders[key].OrderID,
+			exchangeOrder.CumulativeQuoteQuantity,
+			price,
+			exchangeOrder.ExecutedQuantity); err != nil {
+
+			return nil, err
+
+		}
+
+		reconciliation.UpdatedThreads++
+		logReconcile(configData, sessionData, exchangeOrder, "RECONCILE")
+
+	}
+
+	functions.Logger(&types.LogEntry{
+		Config:   configData,
+		Market:   nil,
+		Session:  sessionData,
+		Order:    &types.Order{},
+		Message:  "Reconciled " + fmt.Sprint(reconciliation.Orders) + " orders",
+		LogLevel: log.InfoLevel,
+	})
+
+	return reconciliation, nil
+
+}
+
+/* Log an order fixed or flagged by reconciliation */
+func logReconcile(
+	configData *types.Config,
+	sessionData *types.Session,
+	order *types.Order,
+	message string) {
+
+	functions.Logger(&types.LogEntry{
+		Config:   configData,
+		Market:   nil,
+		Session:  sessionData,
+		Order:    order,
+		Message:  message,
+		LogLevel: log.InfoLevel,
+	})
+
+}
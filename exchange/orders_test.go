@@ -0,0 +1,217 @@
+package exchange
+
+import (
+	"errors"
+	"testing"
+
+	"cryptopump/memory"
+	"cryptopump/types"
+
+	"github.com/shopspring/decimal"
+)
+
+/* Exchange returning the orders of a reconciliation test */
+type reconcileExchange struct {
+	types.Exchange
+	orders []*types.Order
+}
+
+func (e *reconcileExchange) GetRecentOrders(
+	sessionData *types.Session,
+	limit int) (orders []*types.Order, err error) {
+
+	return e.orders, nil
+
+}
+
+func (e *reconcileExchange) GetOpenOrders(
+	sessionData *types.Session) (orders []*types.Order, err error) {
+
+	for _, order := range e.orders {
+
+		if isOrderOpen(order.Status) {
+
+			orders = append(orders, order)
+
+		}
+
+	}
+
+	return orders, nil
+
+}
+
+func (e *reconcileExchange) GetOrder(
+	sessionData *types.Session,
+	orderID int64) (order *types.Order, err error) {
+
+	for _, order := range e.orders {
+
+		if int64(order.OrderID) == orderID {
+
+			return order, nil
+
+		}
+
+	}
+
+	return nil, errors.New("Order does not exist")
+
+}
+
+func TestIsOrderTransition(t *testing.T) {
+
+	tests := []struct {
+		from string
+		to   string
+		want bool
+	}{
+		{"NEW", "NEW", true},
+		{"NEW", "PARTIALLY_FILLED", true},
+		{"NEW", "FILLED", true},
+		{"NEW", "CANCELED", true},
+		{"NEW", "EXPIRED", true},
+		{"NEW", "REJECTED", true},
+		{"PARTIALLY_FILLED", "PARTIALLY_FILLED", true},
+		{"PARTIALLY_FILLED", "FILLED", true},
+		{"PARTIALLY_FILLED", "CANCELED", true},
+		{"PARTIALLY_FILLED", "EXPIRED", true},
+		{"PARTIALLY_FILLED", "NEW", false},
+		{"PARTIALLY_FILLED", "REJECTED", false},
+		{"FILLED", "FILLED", true},
+		{"FILLED", "NEW", false},
+		{"FILLED", "PARTIALLY_FILLED", false},
+		{"FILLED", "CANCELED", false},
+		{"CANCELED", "CANCELED", true},
+		{"CANCELED", "FILLED", false},
+		{"EXPIRED", "NEW", false},
+		{"REJECTED", "FILLED", false},
+		{"", "NEW", false},
+		{"NEW", "", false},
+		{"NEW", "UNKNOWN", false},
+	}
+
+	for _, test := range tests {
+
+		if got := isOrderTransition(test.from, test.to); got != test.want {
+
+			t.Errorf("isOrderTransition(%q, %q) = %v, want %v", test.from, test.to, got, test.want)
+
+		}
+
+	}
+
+}
+
+func TestReconcileThreadTransaction(t *testing.T) {
+
+	d := decimal.RequireFromString
+
+	tests := []struct {
+		name     string
+		stored   types.Order /* Order and thread transaction saved */
+		exchange types.Order /* Order on the exchange */
+		deleted  bool
+		want     types.Order /* Thread transaction after reconciliation */
+		updated  int
+	}{
+		{
+			name:     "partially filled BUY with the thread quantity wrong",
+			stored:   types.Order{Status: "NEW", ExecutedQuantity: d("0"), CumulativeQuoteQuantity: d("0"), Price: d("100")},
+			exchange: types.Order{Status: "PARTIALLY_FILLED", ExecutedQuantity: d("0.4"), CumulativeQuoteQuantity: d("40.2")},
+			want:     types.Order{ExecutedQuantity: d("0.4"), CumulativeQuoteQuantity: d("40.2"), Price: d("100.5")},
+			updated:  1,
+		},
+		{
+			name:     "filled BUY with the thread amount wrong",
+			stored:   types.Order{Status: "FILLED", ExecutedQuantity: d("1"), CumulativeQuoteQuantity: d("100"), Price: d("100")},
+			exchange: types.Order{Status: "FILLED", ExecutedQuantity: d("1"), CumulativeQuoteQuantity: d("100.25")},
+			want:     types.Order{ExecutedQuantity: d("1"), CumulativeQuoteQuantity: d("100.25"), Price: d("100.25")},
+			updated:  1,
+		},
+		{
+			name:     "filled BUY matching the thread",
+			stored:   types.Order{Status: "FILLED", ExecutedQuantity: d("1"), CumulativeQuoteQuantity: d("100"), Price: d("100")},
+			exchange: types.Order{Status: "FILLED", ExecutedQuantity: d("1.000"), CumulativeQuoteQuantity: d("100.00")},
+			want:     types.Order{ExecutedQuantity: d("1"), CumulativeQuoteQuantity: d("100"), Price: d("100")},
+		},
+		{
+			name:     "canceled BUY partially filled",
+			stored:   types.Order{Status: "PARTIALLY_FILLED", ExecutedQuantity: d("0.5"), CumulativeQuoteQuantity: d("50"), Price: d("100")},
+			exchange: types.Order{Status: "CANCELED", ExecutedQuantity: d("0.6"), CumulativeQuoteQuantity: d("60")},
+			want:     types.Order{ExecutedQuantity: d("0.6"), CumulativeQuoteQuantity: d("60"), Price: d("100")},
+			updated:  1,
+		},
+		{
+			name:     "canceled BUY never filled",
+			stored:   types.Order{Status: "NEW", ExecutedQuantity: d("0"), CumulativeQuoteQuantity: d("0"), Price: d("100")},
+			exchange: types.Order{Status: "CANCELED", ExecutedQuantity: d("0"), CumulativeQuoteQuantity: d("0")},
+			deleted:  true,
+		},
+	}
+
+	for _, test := range tests {
+
+		sessionData := &types.Session{
+			ThreadID: "test",
+			Symbol:   "BTCUSDT",
+			Backtest: true,
+			Storage:  memory.New(),
+		}
+
+		exchangeOrder := test.exchange
+		exchangeOrder.OrderID = 1
+		exchangeOrder.Side = "BUY"
+		exchangeOrder.Symbol = sessionData.Symbol
+		exchangeOrder.TransactTime = 1000
+
+		sessionData.Exchange = &reconcileExchange{orders: []*types.Order{&exchangeOrder}}
+
+		_ = sessionData.Storage.SaveOrder(sessionData, "test", test.stored.CumulativeQuoteQuantity, test.stored.ExecutedQuantity, 1, test.stored.Price, "BUY", test.stored.Status, sessionData.Symbol, 1000, decimal.Zero, "", decimal.Zero)
+		_ = sessionData.Storage.SaveThreadTransaction(sessionData, 1, test.stored.CumulativeQuoteQuantity, test.stored.Price, test.stored.ExecutedQuantity)
+
+		reconciliation, err := Reconcile(&types.Config{}, sessionData)
+
+		if err != nil {
+
+			t.Errorf("%s: Reconcile() error = %v", test.name, err)
+			continue
+
+		}
+
+		count, _ := sessionData.Storage.GetThreadTransactionCount(sessionData)
+
+		if test.deleted {
+
+			if count != 0 || reconciliation.DeletedThreads != 1 {
+
+				t.Errorf("%s: %d thread transactions, %d deleted, want the thread transaction deleted", test.name, count, reconciliation.DeletedThreads)
+
+			}
+
+			continue
+
+		}
+
+		price, executedQuantity, cumulativeQuoteQuantity, _ := sessionData.Storage.GetThreadTransactionByOrderID(sessionData, 1)
+
+		if count != 1 ||
+			!executedQuantity.Equal(test.want.ExecutedQuantity) ||
+			!cumulativeQuoteQuantity.Equal(test.want.CumulativeQuoteQuantity) ||
+			!price.Equal(test.want.Price) {
+
+			t.Errorf("%s: thread transaction quantity %s amount %s price %s, want quantity %s amount %s price %s",
+				test.name, executedQuantity, cumulativeQuoteQuantity, price,
+				test.want.ExecutedQuantity, test.want.CumulativeQuoteQuantity, test.want.Price)
+
+		}
+
+		if reconciliation.UpdatedThreads != test.updated {
+
+			t.Errorf("%s: %d thread transactions updated, want %d", test.name, reconciliation.UpdatedThreads, test.updated)
+
+		}
+
+	}
+
+}
@@ -0,0 +1,683 @@
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cryptopump/functions"
+	"cryptopump/types"
+
+	"github.com/shopspring/decimal"
+)
+
+// SimulatedExchange implements a paper-trading exchange. Market data is served by the
+// venue exchange adapter, while orders are filled locally against the book ticker
+// bid and ask prices and charged the configured exchange comission.
+type SimulatedExchange struct {
+	venue       types.Exchange            /* Exchange adapter providing market data */
+	comission   float64                   /* Comission charged on each fill */
+	funds       float64                   /* Virtual fiat funds */
+	fundsLoaded bool                      /* Virtual fiat funds initialized */
+	initFunds   float64                   /* Initial virtual fiat funds defined in configuration */
+	bid         float64                   /* Best bid price from book ticker */
+	ask         float64                   /* Best ask price from book ticker */
+	orderID     int64                     /* Last order ID issued */
+	orders      map[int64]*simulatedOrder /* Orders placed, indexed by order ID */
+	userData    func(message []byte)      /* User data handler receiving executionReport and outboundAccountPosition */
+	mutex       sync.Mutex
+}
+
+/* simulatedOrder keep an order with its requested quantity until filled */
+type simulatedOrder struct {
+	order    types.Order
+	quantity decimal.Decimal
+}
+
+// NewSimulatedExchange Create a simulated exchange on top of the venue exchange adapter
+func NewSimulatedExchange(
+	venue types.Exchange,
+	configData *types.Config) *SimulatedExchange {
+
+	return &SimulatedExchange{
+		venue:     venue,
+		comission: configData.ExchangeComission,
+		initFunds: configData.DryRunFiatFunds,
+		orderID:   time.Now().UnixNano() / int64(time.Microsecond), /* Unique order IDs across restarts */
+		orders:    make(map[int64]*simulatedOrder),
+	}
+
+}
+
+/* Retrieve Order Status */
+func (e *SimulatedExchange) GetOrder(
+	sessionData *types.Session,
+	orderID int64) (order *types.Order, err error) {
+
+	e.matchOrders(sessionData)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if tmp, ok := e.orders[orderID]; ok {
+
+		order = &types.Order{}
+		*order = tmp.order
+
+		return order, nil
+
+	}
+
+	return nil, errors.New("<APIError> code=-2013, msg=Order does not exist.")
+
+}
+
+/* Create order to BUY. Market orders are filled at the best ask price. */
+func (e *SimulatedExchange) BuyOrder(
+	sessionData *types.Session,
+	quantity string) (order *types.Order, err error) {
+
+	e.loadFunds(sessionData)
+
+	e.mutex.Lock()
+
+	if e.ask == 0 {
+
+		e.mutex.Unlock()
+		return nil, errors.New("Simulated exchange - No book ticker price available")
+
+	}
+
+	executedQuantity := functions.StrToDecimal(quantity)
+	quoteQuantity := executedQuantity.Mul(decimal.NewFromFloat(e.ask))
+
+	if quoteQuantity.Mul(decimal.NewFromFloat(1+e.comission)).InexactFloat64() > e.funds {
+
+		e.mutex.Unlock()
+		return nil, errors.New("<APIError> code=-2010, msg=Account has insufficient balance for requested action.")
+
+	}
+
+	tmp := e.newOrder(sessionData, "BUY", decimal.Zero, executedQuantity)
+	e.fill(tmp, decimal.NewFromFloat(e.ask))
+	order = &types.Order{}
+	*order = tmp.order
+
+	e.mutex.Unlock()
+
+	e.pushExecutionReport(sessionData, order, "MARKET", averagePrice(order))
+
+	return order, nil
+
+}
+
+/*
+	Create order to SELL. Limit orders rest until the best bid reaches the order price,
+
+while a ForceSell is executed as a market order at the best bid price.
+*/
+func (e *SimulatedExchange) SellOrder(
+	marketData *types.Market,
+	sessionData *types.Session,
+	quantity string) (order *types.Order, err error) {
+
+	var orderType = "LIMIT"
+
+	e.loadFunds(sessionData)
+
+	e.mutex.Lock()
+
+	if e.bid == 0 {
+
+		e.mutex.Unlock()
+		return nil, errors.New("Simulated exchange - No book ticker price available")
+
+	}
+
+	price := functions.StrToDecimal(formatPrice(sessionData, marketData.Price))
+	bid := decimal.NewFromFloat(e.bid)
+
+	if sessionData.ForceSell {
+
+		sessionData.ForceSell = false
+		orderType = "MARKET"
+		price = decimal.Zero
+
+	}
+
+	tmp := e.newOrder(sessionData, "SELL", price, functions.StrToDecimal(quantity))
+
+	if orderType == "MARKET" || bid.GreaterThanOrEqual(price) {
+
+		e.fill(tmp, bid)
+
+	}
+
+	order = &types.Order{}
+	*order = tmp.order
+
+	e.mutex.Unlock()
+
+	if order.Status == "FILLED" {
+
+		e.pushExecutionReport(sessionData, order, orderType, averagePrice(order))
+
+	}
+
+	return order, nil
+
+}
+
+/* CANCEL an order */
+func (e *SimulatedExchange) CancelOrder(
+	sessionData *types.Session,
+	orderID int64) (order *types.Order, err error) {
+
+	e.matchOrders(sessionData)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	tmp, ok := e.orders[orderID]
+
+	if !ok || tmp.order.Status != "NEW" {
+
+		return nil, errors.New("<APIError> code=-2011, msg=Unknown order sent.")
+
+	}
+
+	tmp.order.Status = "CANCELED"
+	order = &types.Order{}
+	*order = tmp.order
+
+	return order, nil
+
+}
+
+/* Retrieve open orders for the symbol */
+func (e *SimulatedExchange) GetOpenOrders(
+	sessionData *types.Session) (orders []*types.Order, err error) {
+
+	e.matchOrders(sessionData)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, tmp := range e.sortedOrders(sessionData) {
+
+		if tmp.order.Status == "NEW" {
+
+			order := tmp.order
+			orders = append(orders, &order)
+
+		}
+
+	}
+
+	return orders, nil
+
+}
+
+/* Retrieve the most recent orders for the symbol, open or not */
+func (e *SimulatedExchange) GetRecentOrders(
+	sessionData *types.Session,
+	limit int) (orders []*types.Order, err error) {
+
+	e.matchOrders(sessionData)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	tmp := e.sortedOrders(sessionData)
+
+	if len(tmp) > limit {
+
+		tmp = tmp[len(tmp)-limit:]
+
+	}
+
+	for key := range tmp {
+
+		order := tmp[key].order
+		orders = append(orders, &order)
+
+	}
+
+	return orders, nil
+
+}
+
+/* Retrieve the latest price for a symbol from the venue */
+func (e *SimulatedExchange) GetPrice(
+	sessionData *types.Session,
+	symbol string) (price float64, err error) {
+
+	return e.venue.GetPrice(sessionData, symbol)
+
+}
+
+/* Retrieve exchange information for every symbol */
+func (e *SimulatedExchange) GetSymbols(
+	sessionData *types.Session) (symbols []*types.ExchangeInfo, err error) {
+
+	return e.venue.GetSymbols(sessionData)
+
+}
+
+/* Retrieve virtual funds available */
+func (e *SimulatedExchange) GetSymbolFunds(
+	sessionData *types.Session) (balance float64, err error) {
+
+	e.loadFunds(sessionData)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.funds, nil
+
+}
+
+/* Retrieve KLines via REST API */
+func (e *SimulatedExchange) GetKlines(
+	sessionData *types.Session,
+	interval string,
+	startTime int64,
+	endTime int64,
+	limit int) (klines []*types.Kline, err error) {
+
+	return e.venue.GetKlines(sessionData, interval, startTime, endTime, limit)
+
+}
+
+/* Retrieve 24hs Rolling Price Statistics */
+func (e *SimulatedExchange) GetPriceChangeStats(
+	sessionData *types.Session) (priceChangeStats []*types.PriceChangeStats, err error) {
+
+	return e.venue.GetPriceChangeStats(sessionData)
+
+}
+
+/* Retrieve listen key for user stream service */
+func (e *SimulatedExchange) GetUserStreamServiceListenKey(
+	sessionData *types.Session) (listenKey string, err error) {
+
+	return "simulated", nil
+
+}
+
+/* Keep user stream service alive */
+func (e *SimulatedExchange) KeepAliveUserStreamServiceListenKey(
+	sessionData *types.Session) (err error) {
+
+	return nil
+
+}
+
+/* Synchronize time */
+func (e *SimulatedExchange) NewSetServerTimeService(
+	sessionData *types.Session) (err error) {
+
+	return e.venue.NewSetServerTimeService(sessionData)
+
+}
+
+/* WsBookTickerServe serve the venue book ticker, recording best bid and ask prices and filling resting orders */
+func (e *SimulatedExchange) WsBookTickerServe(
+	sessionData *types.Session,
+	wsHandler *types.WsHandler,
+	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
+
+	var busy int32 /* Ticker handler running */
+
+	return e.venue.WsBookTickerServe(sessionData, &types.WsHandler{
+		WsBookTicker: func(event *types.WsBookTicker) {
+
+			e.UpdateBookTicker(sessionData, event)
+
+			/* The ticker handler blocks while an order is placed and waited on. Prices keep filling
+			resting orders meanwhile, and events are only delivered to the handler when it is idle. */
+			if !atomic.CompareAndSwapInt32(&busy, 0, 1) {
+
+				return
+
+			}
+
+			go func() {
+
+				defer atomic.StoreInt32(&busy, 0)
+				wsHandler.WsBookTicker(event)
+
+			}()
+
+		},
+	}, errHandler)
+
+}
+
+// UpdateBookTicker Record best bid and ask prices and fill resting orders reached by the bid
+func (e *SimulatedExchange) UpdateBookTicker(
+	sessionData *types.Session,
+	event *types.WsBookTicker) {
+
+	e.mutex.Lock()
+	e.bid = functions.StrToFloat64(event.BestBidPrice)
+	e.ask = functions.StrToFloat64(event.BestAskPrice)
+	e.mutex.Unlock()
+
+	e.matchOrders(sessionData)
+
+}
+
+/* WsDepthServe serve the venue websocket partial depth handler */
+func (e *SimulatedExchange) WsDepthServe(
+	sessionData *types.Session,
+	wsHandler *types.WsHandler,
+	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
+
+	return e.venue.WsDepthServe(sessionData, wsHandler, errHandler)
+
+}
+
+/* WsKlineServe serve the venue websocket kline handler */
+func (e *SimulatedExchange) WsKlineServe(
+	sessionData *types.Session,
+	interval string,
+	wsHandler *types.WsHandler,
+	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
+
+	return e.venue.WsKlineServe(sessionData, interval, wsHandler, errHandler)
+
+}
+
+/*
+	WsUserDataServe serve simulated user data events. Account positions are pushed every
+
+minute, and executionReport events are pushed when orders are filled.
+*/
+func (e *SimulatedExchange) WsUserDataServe(
+	sessionData *types.Session,
+	wsHandler *types.WsHandler,
+	errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error) {
+
+	doneC = make(chan struct{})
+	stopC = make(chan struct{})
+
+	e.mutex.Lock()
+	e.userData = wsHandler.WsUserDataServe
+	e.mutex.Unlock()
+
+	/* Wait for stop signal and detach user data handler */
+	go func() {
+
+		<-stopC
+
+		e.mutex.Lock()
+		e.userData = nil
+		e.mutex.Unlock()
+
+		close(doneC)
+
+	}()
+
+	/* Push account position periodically */
+	go func() {
+
+		ticker := time.NewTicker(60 * time.Second)
+		defer ticker.Stop()
+
+		for {
+
+			select {
+			case <-doneC:
+
+				return
+
+			case <-ticker.C:
+
+				e.pushAccountPosition(sessionData)
+
+			}
+
+		}
+
+	}()
+
+	return doneC, stopC, nil
+
+}
+
+// UserDataServe Deliver user data events to the handler without a websocket, as orders are filled.
+// Backtests use it to replay the user data stream in the order events happen.
+func (e *SimulatedExchange) UserDataServe(
+	wsHandler *types.WsHandler) {
+
+	e.mutex.Lock()
+	e.userData = wsHandler.WsUserDataServe
+	e.mutex.Unlock()
+
+}
+
+/* Initialize virtual funds from configuration, or from the venue balance when not defined */
+func (e *SimulatedExchange) loadFunds(
+	sessionData *types.Session) {
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.fundsLoaded {
+
+		return
+
+	}
+
+	e.funds = e.initFunds
+
+	if e.funds == 0 {
+
+		if balance, err := e.venue.GetSymbolFunds(sessionData); err == nil {
+
+			e.funds = balance
+
+		}
+
+	}
+
+	e.fundsLoaded = true
+
+}
+
+/* Create a NEW order. Must be called with mutex locked. */
+func (e *SimulatedExchange) newOrder(
+	sessionData *types.Session,
+	side string,
+	price decimal.Decimal,
+	quantity decimal.Decimal) (order *simulatedOrder) {
+
+	e.orderID++
+
+	order = &simulatedOrder{
+		order: types.Order{
+			ClientOrderID: functions.GetThreadID(),
+			OrderID:       int(e.orderID),
+			Price:         price,
+			Side:          side,
+			Status:        "NEW",
+			Symbol:        sessionData.Symbol,
+			TransactTime:  functions.Now(sessionData).UnixNano() / int64(time.Millisecond),
+		},
+		quantity: quantity,
+	}
+
+	/* Comission is charged in the quote currency */
+	order.order.CommissionAsset = sessionData.SymbolFiat
+
+	e.orders[e.orderID] = order
+
+	return order
+
+}
+
+/* Orders for the symbol sorted by order ID. Must be called with mutex locked. */
+func (e *SimulatedExchange) sortedOrders(
+	sessionData *types.Session) (orders []*simulatedOrder) {
+
+	for _, order := range e.orders {
+
+		if order.order.Symbol == sessionData.Symbol {
+
+			orders = append(orders, order)
+
+		}
+
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].order.OrderID < orders[j].order.OrderID
+	})
+
+	return orders
+
+}
+
+/* Fill an order at the provided price and update virtual funds. Must be called with mutex locked. */
+func (e *SimulatedExchange) fill(
+	order *simulatedOrder,
+	price decimal.Decimal) {
+
+	order.order.ExecutedQuantity = order.quantity
+	order.order.CumulativeQuoteQuantity = order.quantity.Mul(price)
+	order.order.Status = "FILLED"
+	order.order.CommissionAmount = order.order.CumulativeQuoteQuantity.Mul(decimal.NewFromFloat(e.comission))
+
+	switch order.order.Side {
+	case "BUY":
+
+		e.funds -= order.order.CumulativeQuoteQuantity.Add(order.order.CommissionAmount).InexactFloat64()
+
+	case "SELL":
+
+		e.funds += order.order.CumulativeQuoteQuantity.Sub(order.order.CommissionAmount).InexactFloat64()
+
+	}
+
+}
+
+/* Fill resting LIMIT SELL orders the best bid price has reached */
+func (e *SimulatedExchange) matchOrders(
+	sessionData *types.Session) {
+
+	var filled []types.Order
+
+	e.mutex.Lock()
+
+	for _, order := range e.orders {
+
+		if order.order.Status == "NEW" &&
+			order.order.Side == "SELL" &&
+			e.bid > 0 &&
+			decimal.NewFromFloat(e.bid).GreaterThanOrEqual(order.order.Price) {
+
+			e.fill(order, order.order.Price)
+			filled = append(filled, order.order)
+
+		}
+
+	}
+
+	e.mutex.Unlock()
+
+	for key := range filled {
+
+		e.pushExecutionReport(sessionData, &filled[key], "LIMIT", filled[key].Price)
+
+	}
+
+}
+
+/* Push executionReport and outboundAccountPosition events to the user data handler */
+func (e *SimulatedExchange) pushExecutionReport(
+	sessionData *types.Session,
+	order *types.Order,
+	orderType string,
+	price decimal.Decimal) {
+
+	now := functions.Now(sessionData).UnixNano() / int64(time.Millisecond)
+
+	if message, err := json.Marshal(types.ExecutionReport{
+		EventType:            "executionReport",
+		EventTime:            now,
+		Symbol:               order.Symbol,
+		ClientOrderID:        order.ClientOrderID,
+		Side:                 order.Side,
+		OrderType:            orderType,
+		TimeInForce:          "GTC",
+		Quantity:             order.ExecutedQuantity.StringFixed(8),
+		Price:                order.Price.StringFixed(8),
+		ExecutionType:        "TRADE",
+		Status:               order.Status,
+		OrderID:              order.OrderID,
+		LastExecutedQuantity: order.ExecutedQuantity.StringFixed(8),
+		CumulativeQty:        order.ExecutedQuantity.StringFixed(8),
+		LastExecutedPrice:    price.StringFixed(8),
+		ComissionAmount:      order.CommissionAmount.StringFixed(8),
+		ComissionAsset:       order.CommissionAsset,
+		TransactTime:         now,
+		TradeID:              order.OrderID, /* Orders are filled in a single trade */
+		OrderCreationTime:    order.TransactTime,
+		CumulativeQuoteQty:   order.CumulativeQuoteQuantity.StringFixed(8),
+		LastQuoteQty:         order.CumulativeQuoteQuantity.StringFixed(8),
+	}); err == nil {
+
+		e.push(message)
+
+	}
+
+	e.pushAccountPosition(sessionData)
+
+}
+
+/* Push outboundAccountPosition event with virtual funds to the user data handler */
+func (e *SimulatedExchange) pushAccountPosition(
+	sessionData *types.Session) {
+
+	now := functions.Now(sessionData).UnixNano() / int64(time.Millisecond)
+
+	e.mutex.Lock()
+	funds := e.funds
+	e.mutex.Unlock()
+
+	if message, err := json.Marshal(types.OutboundAccountPosition{
+		EventType:  "outboundAccountPosition",
+		EventTime:  now,
+		LastUpdate: now,
+		Balances: []types.Balances{{
+			Asset:  sessionData.SymbolFiat,
+			Free:   functions.Float64ToStr(funds, 8),
+			Locked: "0",
+		}},
+	}); err == nil {
+
+		e.push(message)
+
+	}
+
+}
+
+/* Deliver message to the user data handler, if attached */
+func (e *SimulatedExchange) push(
+	message []byte) {
+
+	e.mutex.Lock()
+	userData := e.userData
+	e.mutex.Unlock()
+
+	if userData != nil {
+
+		userData(message)
+
+	}
+
+}
@@ -0,0 +1,140 @@
+package exchange
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"cryptopump/functions"
+	"cryptopump/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+/* Symbols registry loaded from exchange information, indexed by symbol. The registry is shared by the sessions using the exchange. */
+var symbols = struct {
+	sync.RWMutex
+	info map[string]*types.ExchangeInfo
+}{info: map[string]*types.ExchangeInfo{}}
+
+// LoadSymbols Load the symbols registry with the base and quote assets, status and filters of every exchange symbol.
+// The shared exchange client of the configuration is used when the session is not connected, to validate configurations before starting.
+func LoadSymbols(
+	configData *types.Config,
+	sessionData *types.Session) (err error) {
+
+	var info []*types.ExchangeInfo
+
+	venue := sessionData.Exchange
+
+	if venue == nil {
+
+		if _, ok := factories[strings.ToLower(configData.ExchangeName)]; !ok {
+
+			return errors.New("Invalid Exchange Name")
+
+		}
+
+		if venue, err = getVenue(configData); err != nil {
+
+			return err
+
+		}
+
+	}
+
+	if info, err = venue.GetSymbols(sessionData); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   configData,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return err
+
+	}
+
+	registry := make(map[string]*types.ExchangeInfo, len(info))
+
+	for _, symbol := range info {
+
+		registry[symbol.Symbol] = symbol
+
+	}
+
+	symbols.Lock()
+	symbols.info = registry
+	symbols.Unlock()
+
+	return nil
+
+}
+
+// GetSymbol Retrieve symbol information from the symbols registry, loading the registry on first use
+func GetSymbol(
+	configData *types.Config,
+	sessionData *types.Session,
+	symbol string) (info *types.ExchangeInfo, err error) {
+
+	symbols.RLock()
+	info, ok := symbols.info[symbol]
+	loaded := len(symbols.info) > 0
+	symbols.RUnlock()
+
+	if !ok && !loaded {
+
+		if err = LoadSymbols(configData, sessionData); err != nil {
+
+			return nil, err
+
+		}
+
+		symbols.RLock()
+		info, ok = symbols.info[symbol]
+		symbols.RUnlock()
+
+	}
+
+	if !ok {
+
+		return nil, errors.New("Symbol " + symbol + " not found")
+
+	}
+
+	return info, nil
+
+}
+
+// ValidateSymbol Check the symbol exists and is trading on the exchange.
+// The quote asset is also checked when symbolFiat is provided.
+func ValidateSymbol(
+	configData *types.Config,
+	sessionData *types.Session,
+	symbol string,
+	symbolFiat string) (info *types.ExchangeInfo, err error) {
+
+	if info, err = GetSymbol(configData, sessionData, symbol); err != nil {
+
+		return nil, err
+
+	}
+
+	if info.Status != "TRADING" {
+
+		return nil, errors.New("Symbol " + symbol + " is not trading (" + info.Status + ")")
+
+	}
+
+	if symbolFiat != "" && info.QuoteAsset != symbolFiat {
+
+		return nil, errors.New("Symbol " + symbol + " is quoted in " + info.QuoteAsset + ", not " + symbolFiat)
+
+	}
+
+	return info, nil
+
+}
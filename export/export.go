@@ -0,0 +1,382 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"cryptopump/types"
+
+	"github.com/shopspring/decimal"
+)
+
+// Formats supported by Write: generic csv and json, and the import formats of Koinly and CoinTracking
+var Formats = []string{"csv", "json", "koinly", "cointracking"}
+
+/* Row of the generic csv and json formats */
+type row struct {
+	Time          string              `json:"time"`
+	OrderID       int                 `json:"orderId"`
+	ThreadID      string              `json:"threadId"`
+	Symbol        string              `json:"symbol"`
+	Side          string              `json:"side"`
+	Base          string              `json:"base"`
+	Quote         string              `json:"quote"`
+	Quantity      decimal.Decimal     `json:"quantity"`
+	Price         decimal.Decimal     `json:"price"`
+	QuoteQuantity decimal.Decimal     `json:"quoteQuantity"` /* Quote value */
+	Fee           decimal.Decimal     `json:"fee"`
+	FeeAsset      string              `json:"feeAsset"`
+	FeeQuote      decimal.Decimal     `json:"feeQuote"`     /* Fee converted to the quote currency at fill time */
+	CostBasis     decimal.NullDecimal `json:"costBasis"`    /* Cost of the lots closed by a SELL */
+	RealizedGain  decimal.NullDecimal `json:"realizedGain"` /* Realized by a SELL, net of fees */
+	transactTime  time.Time
+}
+
+// Write Write the trades in the format. Realized gain is written for the disposals recorded in the ledger.
+// Base and quote assets are taken from the exchange information of the symbols, failing when a symbol is unknown.
+func Write(
+	w io.Writer,
+	format string,
+	exchangeName string,
+	trades []types.Trade,
+	symbols map[string]*types.ExchangeInfo) (err error) {
+
+	rows := make([]row, 0, len(trades))
+
+	for _, trade := range trades {
+
+		r, err := newRow(trade, symbols)
+
+		if err != nil {
+
+			return err
+
+		}
+
+		rows = append(rows, r)
+
+	}
+
+	switch format {
+	case "csv":
+
+		return writeCSV(w, rows)
+
+	case "json":
+
+		return writeJSON(w, rows)
+
+	case "koinly":
+
+		return writeKoinly(w, rows)
+
+	case "cointracking":
+
+		return writeCoinTracking(w, exchangeName, rows)
+
+	}
+
+	return fmt.Errorf("export format %s not supported, use %s", format, strings.Join(Formats, ", "))
+
+}
+
+// FileName Return the name of the file exported in the format for the days from start to end
+func FileName(
+	format string,
+	start string,
+	end string) string {
+
+	extension := "csv"
+
+	if format == "json" {
+
+		extension = "json"
+
+	}
+
+	name := "cryptopump"
+
+	if format != "csv" && format != "json" {
+
+		name += "-" + format
+
+	}
+
+	if start != "" {
+
+		name += "-" + start
+
+	}
+
+	if end != "" {
+
+		name += "-" + end
+
+	}
+
+	return name + "." + extension
+
+}
+
+// ContentType Return the MIME type of the format
+func ContentType(
+	format string) string {
+
+	if format == "json" {
+
+		return "application/json"
+
+	}
+
+	return "text/csv"
+
+}
+
+/* Write the generic csv format, a row per filled order */
+func writeCSV(
+	w io.Writer,
+	rows []row) (err error) {
+
+	writer := csv.NewWriter(w)
+
+	if err = writer.Write([]string{"Time", "OrderID", "ThreadID", "Symbol", "Side", "Base", "Quote", "Quantity", "Price", "QuoteQuantity", "Fee", "FeeAsset", "FeeQuote", "CostBasis", "RealizedGain"}); err != nil {
+
+		return err
+
+	}
+
+	for _, r := range rows {
+
+		if err = writer.Write([]string{
+			r.Time,
+			strconv.Itoa(r.OrderID),
+			r.ThreadID,
+			r.Symbol,
+			r.Side,
+			r.Base,
+			r.Quote,
+			r.Quantity.String(),
+			r.Price.String(),
+			r.QuoteQuantity.String(),
+			r.Fee.String(),
+			r.FeeAsset,
+			r.FeeQuote.String(),
+			nullString(r.CostBasis),
+			nullString(r.RealizedGain),
+		}); err != nil {
+
+			return err
+
+		}
+
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+
+}
+
+/* Write the generic json format, an array of filled orders */
+func writeJSON(
+	w io.Writer,
+	rows []row) (err error) {
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(rows)
+
+}
+
+/* Write the Koinly universal import format. Realized gain is in the description, as Koinly calculates gains itself. */
+func writeKoinly(
+	w io.Writer,
+	rows []row) (err error) {
+
+	writer := csv.NewWriter(w)
+
+	if err = writer.Write([]string{"Date", "Sent Amount", "Sent Currency", "Received Amount", "Received Currency", "Fee Amount", "Fee Currency", "Net Worth Amount", "Net Worth Currency", "Label", "Description", "TxHash"}); err != nil {
+
+		return err
+
+	}
+
+	for _, r := range rows {
+
+		sent, sentCurrency, received, receivedCurrency := r.QuoteQuantity, r.Quote, r.Quantity, r.Base
+
+		if r.Side == "SELL" {
+
+			sent, sentCurrency, received, receivedCurrency = r.Quantity, r.Base, r.QuoteQuantity, r.Quote
+
+		}
+
+		if err = writer.Write([]string{
+			r.transactTime.Format("2006-01-02 15:04:05") + " UTC",
+			sent.String(),
+			sentCurrency,
+			received.String(),
+			receivedCurrency,
+			feeString(r),
+			r.FeeAsset,
+			r.QuoteQuantity.String(),
+			r.Quote,
+			"",
+			description(r),
+			strconv.Itoa(r.OrderID),
+		}); err != nil {
+
+			return err
+
+		}
+
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+
+}
+
+/* Write the CoinTracking CSV import format, grouping the trades of each ThreadID. Realized gain is in the comment. */
+func writeCoinTracking(
+	w io.Writer,
+	exchangeName string,
+	rows []row) (err error) {
+
+	writer := csv.NewWriter(w)
+
+	if err = writer.Write([]string{"Type", "Buy Amount", "Buy Currency", "Sell Amount", "Sell Currency", "Fee", "Fee Currency", "Exchange", "Trade-Group", "Comment", "Date"}); err != nil {
+
+		return err
+
+	}
+
+	for _, r := range rows {
+
+		buy, buyCurrency, sell, sellCurrency := r.Quantity, r.Base, r.QuoteQuantity, r.Quote
+
+		if r.Side == "SELL" {
+
+			buy, buyCurrency, sell, sellCurrency = r.QuoteQuantity, r.Quote, r.Quantity, r.Base
+
+		}
+
+		if err = writer.Write([]string{
+			"Trade",
+			buy.String(),
+			buyCurrency,
+			sell.String(),
+			sellCurrency,
+			feeString(r),
+			r.FeeAsset,
+			exchangeName,
+			r.ThreadID,
+			description(r),
+			r.transactTime.Format("2006-01-02 15:04:05"),
+		}); err != nil {
+
+			return err
+
+		}
+
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+
+}
+
+/* Convert a trade to a row of the generic formats, with the base and quote assets of the symbol exchange information */
+func newRow(
+	trade types.Trade,
+	symbols map[string]*types.ExchangeInfo) (r row, err error) {
+
+	info, ok := symbols[trade.Symbol]
+
+	if !ok || info.BaseAsset == "" || info.QuoteAsset == "" {
+
+		return row{}, fmt.Errorf("export - base and quote assets of %s unknown, order %d not exported", trade.Symbol, trade.OrderID)
+
+	}
+
+	transactTime := time.Unix(0, trade.TransactTime*int64(time.Millisecond)).UTC()
+
+	r = row{
+		Time:          transactTime.Format(time.RFC3339),
+		OrderID:       trade.OrderID,
+		ThreadID:      trade.ThreadID,
+		Symbol:        trade.Symbol,
+		Side:          trade.Side,
+		Base:          info.BaseAsset,
+		Quote:         info.QuoteAsset,
+		Quantity:      trade.Quantity,
+		QuoteQuantity: trade.QuoteQuantity,
+		Fee:           trade.CommissionAmount,
+		FeeAsset:      trade.CommissionAsset,
+		FeeQuote:      trade.Commission,
+		CostBasis:     trade.Cost,
+		RealizedGain:  trade.Profit,
+		transactTime:  transactTime,
+	}
+
+	if trade.Quantity.IsPositive() {
+
+		r.Price = trade.QuoteQuantity.Div(trade.Quantity)
+
+	}
+
+	return r, nil
+
+}
+
+/* Return the fee, empty when no fee was recorded */
+func feeString(
+	r row) string {
+
+	if r.FeeAsset == "" && r.Fee.IsZero() {
+
+		return ""
+
+	}
+
+	return r.Fee.String()
+
+}
+
+/* Describe the order, with the realized gain of a SELL recorded in the ledger */
+func description(
+	r row) string {
+
+	text := "CryptoPump " + r.ThreadID + " " + r.Side + " order " + strconv.Itoa(r.OrderID)
+
+	if r.RealizedGain.Valid {
+
+		text += ", realized gain " + r.RealizedGain.Decimal.StringFixed(2) + " " + r.Quote
+
+	}
+
+	return text
+
+}
+
+/* Return a nullable decimal, empty when NULL */
+func nullString(
+	value decimal.NullDecimal) string {
+
+	if !value.Valid {
+
+		return ""
+
+	}
+
+	return value.Decimal.String()
+
+}
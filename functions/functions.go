@@ -17,6 +17,7 @@ import (
 	"cryptopump/types"
 
 	"github.com/rs/xid"
+	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
@@ -36,6 +37,21 @@ func StrToFloat64(value string) (r float64) {
 	return r
 }
 
+// StrToDecimal function
+/* This public function convert an exchange string to an exact decimal */
+func StrToDecimal(value string) (r decimal.Decimal) {
+
+	var err error
+
+	if r, err = decimal.NewFromString(value); err != nil {
+
+		log.Fatal(err)
+
+	}
+
+	return r
+}
+
 // Float64ToStr function
 /* This public function convert float64 to string with variable precision */
 func Float64ToStr(value float64, prec int) string {
@@ -44,6 +60,21 @@ func Float64ToStr(value float64, prec int) string {
 
 }
 
+// Precision Return the number of decimals of an exchange step or tick size (e.g. 0.00100000 returns 3)
+func Precision(size float64) int {
+
+	value := strconv.FormatFloat(size, 'f', -1, 64)
+
+	if index := strings.IndexByte(value, '.'); index >= 0 {
+
+		return len(value) - index - 1
+
+	}
+
+	return 0
+
+}
+
 // IntToFloat64 convert Int to Float64
 func IntToFloat64(value int) float64 {
 
@@ -73,6 +104,13 @@ func Logger(LogEntry *types.LogEntry) {
 	var filename string
 	var file *os.File
 
+	/* Backtest sessions replay historical data and are not logged */
+	if LogEntry.Session != nil && LogEntry.Session.Backtest {
+
+		return
+
+	}
+
 	/* Log as JSON instead of the default ASCII formatter */
 	log.SetFormatter(&log.TextFormatter{
 		DisableColors:   false,
@@ -84,7 +122,8 @@ func Logger(LogEntry *types.LogEntry) {
 	log.SetLevel(LogEntry.LogLevel) /* Define the log level for the entry */
 
 	switch {
-	case LogEntry.LogLevel == log.InfoLevel:
+	case LogEntry.LogLevel == log.InfoLevel,
+		LogEntry.LogLevel == log.WarnLevel:
 
 		filename = "cryptopump.log"
 
@@ -115,8 +154,11 @@ func Logger(LogEntry *types.LogEntry) {
 				"rsi7":      fmt.Sprintf("%.2f", LogEntry.Market.Rsi7),
 				"rsi14":     fmt.Sprintf("%.2f", LogEntry.Market.Rsi14),
 				"MACD":      fmt.Sprintf("%.2f", LogEntry.Market.MACD),
+				"percentB":  fmt.Sprintf("%.2f", LogEntry.Market.BollingerPercentB),
+				"bandwidth": fmt.Sprintf("%.4f", LogEntry.Market.BollingerBandwidth),
 				"high":      LogEntry.Market.PriceChangeStatsHighPrice,
 				"direction": LogEntry.Market.Direction,
+				"imbalance": fmt.Sprintf("%.2f", LogEntry.Market.Imbalance),
 			}).Info(LogEntry.Message)
 
 		case "BUY":
@@ -124,7 +166,8 @@ func Logger(LogEntry *types.LogEntry) {
 			log.WithFields(log.Fields{
 				"threadID":   LogEntry.Session.ThreadID,
 				"orderID":    LogEntry.Order.OrderID,
-				"orderPrice": fmt.Sprintf("%.4f", LogEntry.Order.Price),
+				"orderPrice": LogEntry.Order.Price.StringFixed(4),
+				"commission": LogEntry.Order.Commission.StringFixed(4),
 			}).Info(LogEntry.Message)
 
 		case "SELL":
@@ -133,7 +176,37 @@ func Logger(LogEntry *types.LogEntry) {
 				"threadID":      LogEntry.Session.ThreadID,
 				"OrderIDSource": LogEntry.Order.OrderIDSource,
 				"orderID":       LogEntry.Order.OrderID,
-				"orderPrice":    fmt.Sprintf("%.4f", LogEntry.Order.Price),
+				"orderPrice":    LogEntry.Order.Price.StringFixed(4),
+				"commission":    LogEntry.Order.Commission.StringFixed(4),
+			}).Info(LogEntry.Message)
+
+		case "STOPLOSS", "TRAIL":
+
+			log.WithFields(log.Fields{
+				"threadID":    LogEntry.Session.ThreadID,
+				"orderID":     LogEntry.Order.OrderID,
+				"orderPrice":  LogEntry.Order.Price.StringFixed(4),
+				"marketPrice": fmt.Sprintf("%.4f", LogEntry.Market.Price),
+			}).Info(LogEntry.Message)
+
+		case "MIN NOTIONAL":
+
+			log.WithFields(log.Fields{
+				"threadID":    LogEntry.Session.ThreadID,
+				"side":        LogEntry.Order.Side,
+				"quantity":    LogEntry.Order.ExecutedQuantity,
+				"orderPrice":  LogEntry.Order.Price,
+				"notional":    LogEntry.Order.CumulativeQuoteQuantity,
+				"minNotional": LogEntry.Session.MinNotional,
+			}).Info(LogEntry.Message)
+
+		case "RECONCILE", "ORPHAN DATABASE", "ORPHAN EXCHANGE", "ORPHAN THREAD":
+
+			log.WithFields(log.Fields{
+				"threadID":    LogEntry.Session.ThreadID,
+				"orderID":     LogEntry.Order.OrderID,
+				"side":        LogEntry.Order.Side,
+				"orderStatus": LogEntry.Order.Status,
 			}).Info(LogEntry.Message)
 
 		case "CANCELED":
@@ -156,6 +229,12 @@ func Logger(LogEntry *types.LogEntry) {
 
 		}
 
+	case LogEntry.LogLevel == log.WarnLevel:
+
+		log.WithFields(log.Fields{
+			"threadID": LogEntry.Session.ThreadID,
+		}).Warn(LogEntry.Message)
+
 	case LogEntry.LogLevel == log.DebugLevel:
 
 		log.WithFields(log.Fields{
@@ -167,19 +246,6 @@ func Logger(LogEntry *types.LogEntry) {
 
 }
 
-// MustGetenv is a helper function for getting environment variables.
-// Displays a warning if the environment variable is not set.
-func MustGetenv(k string) string {
-
-	v := os.Getenv(k)
-	if v == "" {
-		log.Fatalf("Warning: %s environment variable not set.\n", k)
-	}
-
-	return strings.ToLower(v)
-
-}
-
 // GetIP gets a requests IP address by reading off the forwarded-for
 // header (for proxies) and falls back to use the remote address.
 func GetIP(r *http.Request) string {
@@ -221,6 +287,36 @@ func stringToTime(str string) (r time.Time) {
 
 }
 
+// Now Return the current time from the session clock, or system time when the session has none
+func Now(
+	sessionData *types.Session) time.Time {
+
+	if sessionData != nil && sessionData.Clock != nil {
+
+		return sessionData.Clock.Now()
+
+	}
+
+	return time.Now()
+
+}
+
+// Sleep Pause for the duration using the session clock, or system time when the session has none
+func Sleep(
+	sessionData *types.Session,
+	d time.Duration) {
+
+	if sessionData != nil && sessionData.Clock != nil {
+
+		sessionData.Clock.Sleep(d)
+		return
+
+	}
+
+	time.Sleep(d)
+
+}
+
 // IsInTimeRange Check if time is in a specific range
 func IsInTimeRange(startTimeString string, endTimeString string) bool {
 
@@ -409,42 +505,22 @@ func DeleteConfigFile(sessionData *types.Session) {
 func GetConfigData(
 	sessionData *types.Session) *types.Config {
 
-	configData := loadConfigData(sessionData)
+	configData := loadConfigData(viper.GetViper(), sessionData)
 
 	if sessionData.ThreadID != "" {
 
 		filename := sessionData.ThreadID + ".yml"
 		writePath := "./config/"
 
-		if _, err := os.Stat(writePath + filename); err == nil {
-
-			/* Test for existing ThreadID config file and load configuration */
-			viper.SetConfigFile(writePath + filename)
+		/* Create new ThreadID config file from the running configuration */
+		if _, err := os.Stat(writePath + filename); os.IsNotExist(err) {
 
-			if err := viper.ReadInConfig(); err != nil {
-
-				Logger(&types.LogEntry{
-					Config:   nil,
-					Market:   nil,
-					Session:  nil,
-					Order:    &types.Order{},
-					Message:  GetFunctionName() + " - " + err.Error(),
-					LogLevel: log.DebugLevel,
-				})
-
-			}
-
-			configData = loadConfigData(sessionData)
-
-		} else if os.IsNotExist(err) {
-
-			/* Create new ThreadID config file and load configuration */
 			if err := viper.WriteConfigAs(writePath + filename); err != nil {
 
 				Logger(&types.LogEntry{
 					Config:   nil,
 					Market:   nil,
-					Session:  nil,
+					Session:  sessionData,
 					Order:    &types.Order{},
 					Message:  GetFunctionName() + " - " + err.Error(),
 					LogLevel: log.DebugLevel,
@@ -452,22 +528,26 @@ func GetConfigData(
 
 			}
 
-			viper.SetConfigFile(writePath + filename)
+		}
 
-			if err := viper.ReadInConfig(); err != nil {
+		/* Load ThreadID config file with its own viper instance, as each thread in the process has its own configuration */
+		v := viper.New()
+		v.SetConfigFile(writePath + filename)
 
-				Logger(&types.LogEntry{
-					Config:   nil,
-					Market:   nil,
-					Session:  nil,
-					Order:    &types.Order{},
-					Message:  GetFunctionName() + " - " + err.Error(),
-					LogLevel: log.DebugLevel,
-				})
+		if err := v.ReadInConfig(); err != nil {
 
-			}
+			Logger(&types.LogEntry{
+				Config:   nil,
+				Market:   nil,
+				Session:  sessionData,
+				Order:    &types.Order{},
+				Message:  GetFunctionName() + " - " + err.Error(),
+				LogLevel: log.DebugLevel,
+			})
+
+		} else {
 
-			configData = loadConfigData(sessionData)
+			configData = loadConfigData(v, sessionData)
 
 		}
 
@@ -547,7 +627,7 @@ func LoadConfigTemplate(
 
 	}
 
-	configData := loadConfigData(sessionData)
+	configData := loadConfigData(viper.GetViper(), sessionData)
 
 	/* Set origina template as current config */
 	viper.SetConfigFile(filenameOld)
@@ -568,102 +648,313 @@ func LoadConfigTemplate(
 
 }
 
+// LoadConfigFile Load configuration from a yml file without changing the running configuration
+func LoadConfigFile(
+	filename string) (configData *types.Config, err error) {
+
+	v := viper.New()
+	v.SetConfigFile(filename)
+
+	if err = v.ReadInConfig(); err != nil {
+
+		return nil, err
+
+	}
+
+	if configData = LoadConfig(v); configData.BuyConditionError != nil {
+
+		return nil, fmt.Errorf("%s: %v", filename, configData.BuyConditionError)
+
+	}
+
+	return configData, nil
+
+}
+
+// LoadConfig Load configuration from a viper instance without changing the running configuration
+func LoadConfig(
+	v *viper.Viper) *types.Config {
+
+	return loadConfigData(v, &types.Session{})
+
+}
+
 /* This routine load viper configuration data into map[string]interface{} */
 func loadConfigData(
+	v *viper.Viper,
 	sessionData *types.Session) *types.Config {
 
 	configData := &types.Config{
 		ThreadID:                               sessionData.ThreadID, /* For index.html population */
-		Apikey:                                 viper.GetString("config.apiKey"),
-		Secretkey:                              viper.GetString("config.secretKey"),
-		ApikeyTestNet:                          viper.GetString("config.apiKeyTestNet"),    /* API key for exchange test network, used with launch.json */
-		SecretkeyTestNet:                       viper.GetString("config.secretKeyTestNet"), /* Secret key for exchange test network, used with launch.json */
-		Buy24hsHighpriceEntry:                  viper.GetFloat64("config.buy_24hs_highprice_entry"),
-		BuyDirectionDown:                       viper.GetInt("config.buy_direction_down"),
-		BuyDirectionUp:                         viper.GetInt("config.buy_direction_up"),
-		BuyQuantityFiatUp:                      viper.GetFloat64("config.buy_quantity_fiat_up"),
-		BuyQuantityFiatDown:                    viper.GetFloat64("config.buy_quantity_fiat_down"),
-		BuyQuantityFiatInit:                    viper.GetFloat64("config.buy_quantity_fiat_init"),
-		BuyRepeatThresholdDown:                 viper.GetFloat64("config.buy_repeat_threshold_down"),
-		BuyRepeatThresholdDownSecond:           viper.GetFloat64("config.buy_repeat_threshold_down_second"),
-		BuyRepeatThresholdDownSecondStartCount: viper.GetInt("config.buy_repeat_threshold_down_second_start_count"),
-		BuyRepeatThresholdUp:                   viper.GetFloat64("config.buy_repeat_threshold_up"),
-		BuyRsi7Entry:                           viper.GetFloat64("config.buy_rsi7_entry"),
-		BuyWait:                                viper.GetInt("config.buy_wait"),
-		ExchangeComission:                      viper.GetFloat64("config.exchange_comission"),
-		ExchangeName:                           viper.GetString("config.exchangename"),
-		ProfitMin:                              viper.GetFloat64("config.profit_min"),
-		SellWaitBeforeCancel:                   viper.GetInt("config.sellwaitbeforecancel"),
-		SellWaitAfterCancel:                    viper.GetInt("config.sellwaitaftercancel"),
-		SellToCover:                            viper.GetBool("config.selltocover"),
-		SellHoldOnRSI3:                         viper.GetFloat64("config.sellholdonrsi3"),
-		SymbolFiat:                             viper.GetString("config.symbol_fiat"),
-		SymbolFiatStash:                        viper.GetFloat64("config.symbol_fiat_stash"),
-		Symbol:                                 viper.GetString("config.symbol"),
-		TimeEnforce:                            viper.GetBool("config.time_enforce"),
-		TimeStart:                              viper.GetString("config.time_start"),
-		TimeStop:                               viper.GetString("config.time_stop"),
-		TestNet:                                viper.GetBool("config.testnet"),
-		TgBotApikey:                            viper.GetString("config.tgbotapikey"),
-		Debug:                                  viper.GetBool("config.debug"),
-		Exit:                                   viper.GetBool("config.exit"),
-		DryRun:                                 viper.GetBool("config.dryrun"),
-		NewSession:                             viper.GetBool("config.newsession"),
+		Apikey:                                 v.GetString("config.apiKey"),
+		Secretkey:                              v.GetString("config.secretKey"),
+		ApikeyTestNet:                          v.GetString("config.apiKeyTestNet"),    /* API key for exchange test network, used with launch.json */
+		SecretkeyTestNet:                       v.GetString("config.secretKeyTestNet"), /* Secret key for exchange test network, used with launch.json */
+		BollingerWindow:                        v.GetInt("config.bollinger_window"),
+		BollingerDeviation:                     v.GetFloat64("config.bollinger_deviation"),
+		Buy24hsHighpriceEntry:                  v.GetFloat64("config.buy_24hs_highprice_entry"),
+		BuyDirectionDown:                       v.GetInt("config.buy_direction_down"),
+		BuyDirectionUp:                         v.GetInt("config.buy_direction_up"),
+		BuyQuantityFiatUp:                      v.GetFloat64("config.buy_quantity_fiat_up"),
+		BuyQuantityFiatDown:                    v.GetFloat64("config.buy_quantity_fiat_down"),
+		BuyQuantityFiatInit:                    v.GetFloat64("config.buy_quantity_fiat_init"),
+		BuyRepeatThresholdDown:                 v.GetFloat64("config.buy_repeat_threshold_down"),
+		BuyRepeatThresholdDownSecond:           v.GetFloat64("config.buy_repeat_threshold_down_second"),
+		BuyRepeatThresholdDownSecondStartCount: v.GetInt("config.buy_repeat_threshold_down_second_start_count"),
+		BuyRepeatThresholdUp:                   v.GetFloat64("config.buy_repeat_threshold_up"),
+		BuyRsi7Entry:                           v.GetFloat64("config.buy_rsi7_entry"),
+		BuyWait:                                v.GetInt("config.buy_wait"),
+		BuyBollinger:                           v.GetBool("config.buy_bollinger"),
+		BuyBollingerPercentB:                   v.GetFloat64("config.buy_bollinger_percentb"),
+		ExchangeComission:                      v.GetFloat64("config.exchange_comission"),
+		WsStaleTimeout:                         v.GetInt("config.ws_stale_timeout"),
+		MaxSpread:                              v.GetFloat64("config.max_spread"),
+		MaxSlippage:                            v.GetFloat64("config.max_slippage"),
+		ExchangeName:                           v.GetString("config.exchangename"),
+		ProfitMin:                              v.GetFloat64("config.profit_min"),
+		SellWaitBeforeCancel:                   v.GetInt("config.sellwaitbeforecancel"),
+		SellWaitAfterCancel:                    v.GetInt("config.sellwaitaftercancel"),
+		SellToCover:                            v.GetBool("config.selltocover"),
+		SellHoldOnRSI3:                         v.GetFloat64("config.sellholdonrsi3"),
+		SellHoldOnBollinger:                    v.GetFloat64("config.sellholdonbollinger"),
+		RiskMaxThreads:                         v.GetInt("config.risk_max_threads"),
+		RiskMaxFiatSymbol:                      v.GetFloat64("config.risk_max_fiat_symbol"),
+		RiskMaxFiatTotal:                       v.GetFloat64("config.risk_max_fiat_total"),
+		RiskMaxDailyLoss:                       v.GetFloat64("config.risk_max_daily_loss"),
+		RiskMaxDrawdown:                        v.GetFloat64("config.risk_max_drawdown"),
+		StopLoss:                               v.GetFloat64("config.stop_loss"),
+		TrailingStop:                           v.GetFloat64("config.trailing_stop"),
+		SymbolFiat:                             v.GetString("config.symbol_fiat"),
+		SymbolFiatStash:                        v.GetFloat64("config.symbol_fiat_stash"),
+		Symbol:                                 v.GetString("config.symbol"),
+		TimeEnforce:                            v.GetBool("config.time_enforce"),
+		TimeStart:                              v.GetString("config.time_start"),
+		TimeStop:                               v.GetString("config.time_stop"),
+		TestNet:                                v.GetBool("config.testnet"),
+		TgBotApikey:                            v.GetString("config.tgbotapikey"),
+		TgBotChatID:                            v.GetInt64("config.tgbotchatid"),
+		Debug:                                  v.GetBool("config.debug"),
+		Exit:                                   v.GetBool("config.exit"),
+		DryRun:                                 v.GetBool("config.dryrun"),
+		DryRunFiatFunds:                        v.GetFloat64("config.dryrun_fiat_funds"),
+		NewSession:                             v.GetBool("config.newsession"),
 		ConfigTemplateList:                     getConfigTemplateList(sessionData),
 	}
 
-	return configData
+	var err error
 
-}
+	configData.BuyCondition = v.GetString("config.buy_condition")
 
-// SaveConfigData save viper configuration from html
-func SaveConfigData(
-	r *http.Request,
-	sessionData *types.Session) {
+	/* BUY is refused while the conditions are invalid, instead of buying without them */
+	if configData.BuyConditions, err = parseConditions(v, configData.BuyCondition); err != nil {
 
-	viper.Set("config.buy_24hs_highprice_entry", r.PostFormValue("buy24hsHighpriceEntry"))
-	viper.Set("config.buy_direction_down", r.PostFormValue("buyDirectionDown"))
-	viper.Set("config.buy_direction_up", r.PostFormValue("buyDirectionUp"))
-	viper.Set("config.buy_quantity_fiat_up", r.PostFormValue("buyQuantityFiatUp"))
-	viper.Set("config.buy_quantity_fiat_down", r.PostFormValue("buyQuantityFiatDown"))
-	viper.Set("config.buy_quantity_fiat_init", r.PostFormValue("buyQuantityFiatInit"))
-	viper.Set("config.buy_rsi7_entry", r.PostFormValue("buyRsi7Entry"))
-	viper.Set("config.buy_wait", r.PostFormValue("buyWait"))
-	viper.Set("config.buy_repeat_threshold_down", r.PostFormValue("buyRepeatThresholdDown"))
-	viper.Set("config.buy_repeat_threshold_down_second", r.PostFormValue("buyRepeatThresholdDownSecond"))
-	viper.Set("config.buy_repeat_threshold_down_second_start_count", r.PostFormValue("buyRepeatThresholdDownSecondStartCount"))
-	viper.Set("config.buy_repeat_threshold_up", r.PostFormValue("buyRepeatThresholdUp"))
-	viper.Set("config.exchange_comission", r.PostFormValue("exchangeComission"))
-	viper.Set("config.exchangename", r.PostFormValue("exchangename"))
-	viper.Set("config.profit_min", r.PostFormValue("profitMin"))
-	viper.Set("config.sellwaitbeforecancel", r.PostFormValue("sellwaitbeforecancel"))
-	viper.Set("config.sellwaitaftercancel", r.PostFormValue("sellwaitaftercancel"))
-	viper.Set("config.selltocover", r.PostFormValue("selltocover"))
-	viper.Set("config.sellholdonrsi3", r.PostFormValue("sellholdonrsi3"))
-	viper.Set("config.symbol", r.PostFormValue("symbol"))
-	viper.Set("config.symbol_fiat", r.PostFormValue("symbol_fiat"))
-	viper.Set("config.symbol_fiat_stash", r.PostFormValue("symbolFiatStash"))
-	viper.Set("config.time_enforce", r.PostFormValue("timeEnforce"))
-	viper.Set("config.time_start", r.PostFormValue("timeStart"))
-	viper.Set("config.time_stop", r.PostFormValue("timeStop"))
-	viper.Set("config.testnet", r.PostFormValue("testnet"))
-	viper.Set("config.debug", r.PostFormValue("debug"))
-	viper.Set("config.exit", r.PostFormValue("exit"))
-	viper.Set("config.dryrun", r.PostFormValue("dryrun"))
-	viper.Set("config.newsession", r.PostFormValue(("newsession")))
-
-	if err := viper.WriteConfig(); err != nil {
+		configData.BuyConditionError = err
 
 		Logger(&types.LogEntry{
 			Config:   nil,
 			Market:   nil,
-			Session:  nil,
+			Session:  sessionData,
 			Order:    &types.Order{},
-			Message:  GetFunctionName() + " - " + err.Error(),
-			LogLevel: log.DebugLevel,
+			Message:  "BUY CONDITION - " + err.Error() + ", buying is refused until buy_condition is fixed",
+			LogLevel: log.InfoLevel,
 		})
 
-		os.Exit(1)
 	}
 
+	/* Kline intervals other than 1m referenced by the conditions */
+	for _, condition := range configData.BuyConditions {
+
+		if condition.Interval != "1m" && !contains(configData.Timeframes, condition.Interval) {
+
+			configData.Timeframes = append(configData.Timeframes, condition.Interval)
+
+		}
+
+	}
+
+	return configData
+
+}
+
+// IntervalDuration Return the duration of a kline interval, or 0 for intervals not supported (e.g. 1m, 15m, 1h, 1d)
+func IntervalDuration(
+	interval string) time.Duration {
+
+	switch interval {
+	case "1m", "3m", "5m", "15m", "30m":
+
+		return time.Duration(StrToInt(strings.TrimSuffix(interval, "m"))) * time.Minute
+
+	case "1h", "2h", "4h", "6h", "8h", "12h":
+
+		return time.Duration(StrToInt(strings.TrimSuffix(interval, "h"))) * time.Hour
+
+	case "1d":
+
+		return 24 * time.Hour
+
+	}
+
+	return 0
+
+}
+
+// ValidateCondition Validate a BUY condition against the running configuration
+func ValidateCondition(
+	condition string) error {
+
+	_, err := parseConditions(viper.GetViper(), condition)
+
+	return err
+
+}
+
+/* Parse conditions such as "1h rsi14 < 50 and 1m rsi7 < buy_rsi7_entry". Values are numbers or configuration keys. */
+func parseConditions(
+	v *viper.Viper,
+	condition string) (conditions []types.Condition, err error) {
+
+	if strings.TrimSpace(condition) == "" {
+
+		return nil, nil
+
+	}
+
+	for _, clause := range strings.Split(strings.ToLower(condition), " and ") {
+
+		fields := strings.Fields(clause)
+
+		if len(fields) != 4 {
+
+			return nil, fmt.Errorf("Invalid condition %q, expected interval indicator operator value", clause)
+
+		}
+
+		c := types.Condition{
+			Interval:  fields[0],
+			Indicator: fields[1],
+			Operator:  fields[2],
+		}
+
+		if IntervalDuration(c.Interval) == 0 {
+
+			return nil, fmt.Errorf("Invalid interval %q in condition %q", c.Interval, clause)
+
+		}
+
+		switch c.Indicator {
+		case "rsi3", "rsi7", "rsi14", "macd":
+
+		default:
+
+			return nil, fmt.Errorf("Invalid indicator %q in condition %q", c.Indicator, clause)
+
+		}
+
+		switch c.Operator {
+		case "<", "<=", ">", ">=":
+
+		default:
+
+			return nil, fmt.Errorf("Invalid operator %q in condition %q", c.Operator, clause)
+
+		}
+
+		if c.Value, err = strconv.ParseFloat(fields[3], 64); err != nil {
+
+			if !v.IsSet("config." + fields[3]) {
+
+				return nil, fmt.Errorf("Invalid value %q in condition %q", fields[3], clause)
+
+			}
+
+			c.Value = v.GetFloat64("config." + fields[3])
+
+		}
+
+		conditions = append(conditions, c)
+
+	}
+
+	return conditions, nil
+
+}
+
+/* Check if a list contains the value */
+func contains(
+	list []string,
+	value string) bool {
+
+	for _, item := range list {
+
+		if item == value {
+
+			return true
+
+		}
+
+	}
+
+	return false
+
+}
+
+// UpdateConfigData save configuration values indexed by key (e.g. profit_min), leaving the other keys unchanged.
+// Running threads save to their ThreadID config file.
+func UpdateConfigData(
+	values map[string]string,
+	sessionData *types.Session) error {
+
+	v, filename, err := configViper(sessionData)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	for key, value := range values {
+
+		v.Set("config."+key, value)
+
+	}
+
+	return v.WriteConfigAs(filename)
+
+}
+
+/* Load the configuration file saved to, the ThreadID config file for running threads, with its own viper instance. The global instance holds the defaults of new threads and is never set. */
+func configViper(
+	sessionData *types.Session) (v *viper.Viper, filename string, err error) {
+
+	filename = viper.ConfigFileUsed()
+
+	if sessionData.ThreadID != "" {
+
+		filename = "./config/" + sessionData.ThreadID + ".yml"
+
+		/* Create the ThreadID config file from the running configuration */
+		if _, err = os.Stat(filename); os.IsNotExist(err) {
+
+			if err = viper.WriteConfigAs(filename); err != nil {
+
+				return nil, "", err
+
+			}
+
+		}
+
+	}
+
+	v = viper.New()
+	v.SetConfigFile(filename)
+
+	if err = v.ReadInConfig(); err != nil {
+
+		return nil, "", err
+
+	}
+
+	return v, filename, nil
+
 }
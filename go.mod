@@ -7,12 +7,13 @@ require (
 	github.com/go-echarts/go-echarts/v2 v2.2.4
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible
-	github.com/jtaczanowski/go-scheduler v0.1.0
 	github.com/rs/xid v1.3.0
 	github.com/sdcoffey/big v0.7.0
 	github.com/sdcoffey/techan v0.12.0
+	github.com/shopspring/decimal v1.3.1
 	github.com/sirupsen/logrus v1.8.1
 	github.com/skratchdot/open-golang v0.0.0-20200116055534-eef842397966
 	github.com/spf13/viper v1.8.1
 	github.com/technoweenie/multipartstreamer v1.0.1 // indirect
+	modernc.org/sqlite v1.14.2
 )
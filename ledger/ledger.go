@@ -0,0 +1,165 @@
+package ledger
+
+import (
+	"fmt"
+
+	"cryptopump/functions"
+	"cryptopump/types"
+
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+)
+
+// Open Record the lot of a filled BUY order in the ledger
+func Open(
+	configData *types.Config,
+	sessionData *types.Session,
+	order *types.Order) (err error) {
+
+	if !order.ExecutedQuantity.IsPositive() {
+
+		return nil
+
+	}
+
+	return sessionData.Storage.SaveLot(sessionData, types.Lot{
+		OrderID:    order.OrderID,
+		Symbol:     order.Symbol,
+		Quantity:   order.ExecutedQuantity,
+		Cost:       order.CumulativeQuoteQuantity,
+		Commission: order.Commission,
+		OpenTime:   order.TransactTime,
+	})
+
+}
+
+// Close Record the fill of a filled SELL order in the ledger, with the profit realized for the lot it sold.
+// The lot sold is closed in full, including the quantity left by rounding down to the step size, as its thread transaction is removed.
+// Quantity sold beyond the lot is logged and not recorded, leaving the other lots open along with their thread transactions.
+func Close(
+	configData *types.Config,
+	sessionData *types.Session,
+	lotOrderID int,
+	order *types.Order) (fills []types.Fill, err error) {
+
+	if !order.ExecutedQuantity.IsPositive() {
+
+		return nil, nil
+
+	}
+
+	var lots []types.Lot
+	var lot *types.Lot
+
+	if lots, err = sessionData.Storage.GetOpenLots(sessionData); err != nil {
+
+		return nil, err
+
+	}
+
+	for key := range lots {
+
+		if lots[key].OrderID == lotOrderID {
+
+			lot = &lots[key]
+
+			break
+
+		}
+
+	}
+
+	if lot == nil {
+
+		logLedger(configData, sessionData, lotOrderID, order, fmt.Sprintf("LEDGER - lot %d not open, %s sold not recorded", lotOrderID, order.ExecutedQuantity.String()))
+
+		return nil, nil
+
+	}
+
+	sold := decimal.Min(lot.Remaining, order.ExecutedQuantity)
+	share := sold.Div(order.ExecutedQuantity)   /* Share of the SELL order */
+	lotShare := lot.Remaining.Div(lot.Quantity) /* Share of the lot */
+
+	fill := types.Fill{
+		SellOrderID:  order.OrderID,
+		LotOrderID:   lot.OrderID,
+		Symbol:       order.Symbol,
+		Quantity:     lot.Remaining,
+		Proceeds:     order.CumulativeQuoteQuantity.Mul(share),
+		Cost:         lot.Cost.Mul(lotShare),
+		Commission:   lot.Commission.Mul(lotShare).Add(order.Commission.Mul(share)),
+		HoldingTime:  order.TransactTime - lot.OpenTime,
+		TransactTime: order.TransactTime,
+	}
+
+	fill.Profit = fill.Proceeds.Sub(fill.Cost).Sub(fill.Commission)
+
+	if err = sessionData.Storage.SaveFill(sessionData, fill); err != nil {
+
+		return nil, err
+
+	}
+
+	if remaining := order.ExecutedQuantity.Sub(sold); remaining.IsPositive() {
+
+		logLedger(configData, sessionData, lotOrderID, order, fmt.Sprintf("LEDGER - %s sold beyond lot %d not recorded", remaining.String(), lotOrderID))
+
+	}
+
+	return []types.Fill{fill}, nil
+
+}
+
+// Unrealized Calculate the profit of the open lots of the thread marked at price,
+// net of their BUY commissions and of the exchange commission for selling them
+func Unrealized(
+	configData *types.Config,
+	sessionData *types.Session,
+	price float64) (profit decimal.Decimal, err error) {
+
+	var lots []types.Lot
+
+	if lots, err = sessionData.Storage.GetOpenLots(sessionData); err != nil {
+
+		return decimal.Zero, err
+
+	}
+
+	mark := decimal.NewFromFloat(price * (1 - configData.ExchangeComission))
+
+	for _, lot := range lots {
+
+		lotShare := lot.Remaining.Div(lot.Quantity)
+
+		profit = profit.Add(lot.Remaining.Mul(mark)).
+			Sub(lot.Cost.Mul(lotShare)).
+			Sub(lot.Commission.Mul(lotShare))
+
+	}
+
+	return profit, nil
+
+}
+
+/* Log a SELL quantity not recorded in the ledger */
+func logLedger(
+	configData *types.Config,
+	sessionData *types.Session,
+	lotOrderID int,
+	order *types.Order,
+	message string) {
+
+	functions.Logger(&types.LogEntry{
+		Config:  configData,
+		Market:  nil,
+		Session: sessionData,
+		Order: &types.Order{
+			OrderID:       order.OrderID,
+			OrderIDSource: lotOrderID,
+		},
+		Message:  message,
+		LogLevel: log.InfoLevel,
+	})
+
+}
@@ -0,0 +1,206 @@
+package ledger
+
+import (
+	"testing"
+
+	"cryptopump/memory"
+	"cryptopump/types"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestClose(t *testing.T) {
+
+	d := decimal.RequireFromString
+
+	type sell struct {
+		lotOrderID int
+		order      types.Order
+		fills      []types.Fill
+	}
+
+	tests := []struct {
+		name      string
+		lots      []types.Lot
+		sells     []sell
+		remaining map[int]decimal.Decimal /* Remaining quantity of the open lots */
+	}{
+		{
+			name: "lot sold in full with the quantity left by rounding",
+			lots: []types.Lot{
+				{OrderID: 1, Quantity: d("1"), Cost: d("100"), Commission: d("0.1"), OpenTime: 1000},
+				{OrderID: 3, Quantity: d("1.0004"), Cost: d("120.048"), Commission: d("0.12"), OpenTime: 3000},
+			},
+			sells: []sell{
+				{
+					lotOrderID: 3,
+					order:      types.Order{OrderID: 10, ExecutedQuantity: d("1"), CumulativeQuoteQuantity: d("130"), Commission: d("0.13"), TransactTime: 4000},
+					fills: []types.Fill{
+						{LotOrderID: 3, Quantity: d("1.0004"), Proceeds: d("130"), Cost: d("120.048"), Commission: d("0.25"), Profit: d("9.702"), HoldingTime: 1000},
+					},
+				},
+			},
+			remaining: map[int]decimal.Decimal{1: d("1")},
+		},
+		{
+			name: "quantity beyond the lot sold capped to the lot",
+			lots: []types.Lot{
+				{OrderID: 1, Quantity: d("1"), Cost: d("100"), Commission: d("0.1"), OpenTime: 1000},
+				{OrderID: 2, Quantity: d("2"), Cost: d("220"), Commission: d("0.2"), OpenTime: 2000},
+			},
+			sells: []sell{
+				{
+					lotOrderID: 2,
+					order:      types.Order{OrderID: 10, ExecutedQuantity: d("2.5"), CumulativeQuoteQuantity: d("275"), Commission: d("0.275"), TransactTime: 4000},
+					fills: []types.Fill{
+						{LotOrderID: 2, Quantity: d("2"), Proceeds: d("220"), Cost: d("220"), Commission: d("0.42"), Profit: d("-0.42"), HoldingTime: 2000},
+					},
+				},
+			},
+			remaining: map[int]decimal.Decimal{1: d("1")},
+		},
+		{
+			name: "lots sold one after the other, newest first",
+			lots: []types.Lot{
+				{OrderID: 1, Quantity: d("1"), Cost: d("100"), Commission: d("0.1"), OpenTime: 1000},
+				{OrderID: 2, Quantity: d("2"), Cost: d("220"), Commission: d("0.2"), OpenTime: 2000},
+			},
+			sells: []sell{
+				{
+					lotOrderID: 2,
+					order:      types.Order{OrderID: 10, ExecutedQuantity: d("2"), CumulativeQuoteQuantity: d("230"), Commission: d("0.23"), TransactTime: 4000},
+					fills: []types.Fill{
+						{LotOrderID: 2, Quantity: d("2"), Proceeds: d("230"), Cost: d("220"), Commission: d("0.43"), Profit: d("9.57"), HoldingTime: 2000},
+					},
+				},
+				{
+					lotOrderID: 1,
+					order:      types.Order{OrderID: 11, ExecutedQuantity: d("0.999"), CumulativeQuoteQuantity: d("119.88"), Commission: d("0.12"), TransactTime: 5000},
+					fills: []types.Fill{
+						{LotOrderID: 1, Quantity: d("1"), Proceeds: d("119.88"), Cost: d("100"), Commission: d("0.22"), Profit: d("19.66"), HoldingTime: 4000},
+					},
+				},
+			},
+			remaining: map[int]decimal.Decimal{},
+		},
+		{
+			name: "lot not open",
+			lots: []types.Lot{
+				{OrderID: 1, Quantity: d("1"), Cost: d("100"), Commission: d("0.1"), OpenTime: 1000},
+			},
+			sells: []sell{
+				{
+					lotOrderID: 9,
+					order:      types.Order{OrderID: 10, ExecutedQuantity: d("1"), CumulativeQuoteQuantity: d("120"), Commission: d("0.12"), TransactTime: 4000},
+				},
+			},
+			remaining: map[int]decimal.Decimal{1: d("1")},
+		},
+		{
+			name: "SELL order not filled",
+			lots: []types.Lot{
+				{OrderID: 1, Quantity: d("1"), Cost: d("100"), Commission: d("0.1"), OpenTime: 1000},
+			},
+			sells: []sell{
+				{
+					lotOrderID: 1,
+					order:      types.Order{OrderID: 10, ExecutedQuantity: d("0"), CumulativeQuoteQuantity: d("0"), Commission: d("0"), TransactTime: 4000},
+				},
+			},
+			remaining: map[int]decimal.Decimal{1: d("1")},
+		},
+	}
+
+	for _, test := range tests {
+
+		sessionData := &types.Session{
+			ThreadID: "test",
+			Symbol:   "BTCUSDT",
+			Backtest: true,
+			Storage:  memory.New(),
+		}
+
+		for _, lot := range test.lots {
+
+			lot.Symbol = sessionData.Symbol
+
+			if err := sessionData.Storage.SaveLot(sessionData, lot); err != nil {
+
+				t.Fatalf("%s: SaveLot() error = %v", test.name, err)
+
+			}
+
+		}
+
+		for _, sell := range test.sells {
+
+			sell.order.Symbol = sessionData.Symbol
+
+			fills, err := Close(&types.Config{}, sessionData, sell.lotOrderID, &sell.order)
+
+			if err != nil {
+
+				t.Errorf("%s: Close() error = %v", test.name, err)
+				continue
+
+			}
+
+			if len(fills) != len(sell.fills) {
+
+				t.Errorf("%s: SELL %d closed %d lots, want %d", test.name, sell.order.OrderID, len(fills), len(sell.fills))
+				continue
+
+			}
+
+			for key, want := range sell.fills {
+
+				got := fills[key]
+
+				if got.SellOrderID != sell.order.OrderID ||
+					got.LotOrderID != want.LotOrderID ||
+					!got.Quantity.Equal(want.Quantity) ||
+					!got.Proceeds.Equal(want.Proceeds) ||
+					!got.Cost.Equal(want.Cost) ||
+					!got.Commission.Equal(want.Commission) ||
+					!got.Profit.Equal(want.Profit) ||
+					got.HoldingTime != want.HoldingTime {
+
+					t.Errorf("%s: SELL %d fill %d = lot %d quantity %s proceeds %s cost %s commission %s profit %s holding %d, want lot %d quantity %s proceeds %s cost %s commission %s profit %s holding %d",
+						test.name, sell.order.OrderID, key,
+						got.LotOrderID, got.Quantity, got.Proceeds, got.Cost, got.Commission, got.Profit, got.HoldingTime,
+						want.LotOrderID, want.Quantity, want.Proceeds, want.Cost, want.Commission, want.Profit, want.HoldingTime)
+
+				}
+
+			}
+
+		}
+
+		lots, err := sessionData.Storage.GetOpenLots(sessionData)
+
+		if err != nil {
+
+			t.Errorf("%s: GetOpenLots() error = %v", test.name, err)
+			continue
+
+		}
+
+		if len(lots) != len(test.remaining) {
+
+			t.Errorf("%s: %d open lots, want %d", test.name, len(lots), len(test.remaining))
+
+		}
+
+		for _, lot := range lots {
+
+			if want, ok := test.remaining[lot.OrderID]; !ok || !lot.Remaining.Equal(want) {
+
+				t.Errorf("%s: lot %d remaining %s, want %s", test.name, lot.OrderID, lot.Remaining, want)
+
+			}
+
+		}
+
+	}
+
+}
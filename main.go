@@ -2,29 +2,39 @@ package main
 
 import (
 	"cryptopump/algorithms"
+	"cryptopump/backtest"
 	"cryptopump/exchange"
+	"cryptopump/export"
 	"cryptopump/functions"
+	"cryptopump/ledger"
 	"cryptopump/markets"
 	"cryptopump/mysql"
 	"cryptopump/node"
+	"cryptopump/optimizer"
 	"cryptopump/plotter"
+	"cryptopump/risk"
+	"cryptopump/sqlite"
+	"cryptopump/stream"
 	"cryptopump/telegram"
 	"cryptopump/threads"
 	"cryptopump/types"
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"net/http"
 	"os"
-	"os/exec"
+	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/jtaczanowski/go-scheduler"
 	"github.com/sdcoffey/techan"
+	"github.com/shopspring/decimal"
 	"github.com/skratchdot/open-golang/open"
 	"github.com/spf13/viper"
 
@@ -36,6 +46,7 @@ type myHandler struct {
 	sessionData *types.Session
 	marketData  *types.Market
 	configData  *types.Config
+	supervisor  *threads.Supervisor /* Threads running in the process. The page shows the thread of sessionData */
 }
 
 func init() {
@@ -64,7 +75,71 @@ func init() {
 
 func main() {
 
-	sessionData := &types.Session{
+	/* Replay historical klines instead of trading when started with the backtest command */
+	if len(os.Args) > 1 && os.Args[1] == "backtest" {
+
+		runBacktest(os.Args[2:])
+		return
+
+	}
+
+	/* Apply the schema migrations pending and exit when started with the migrate command */
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+
+		runMigrate(os.Args[2:])
+		return
+
+	}
+
+	/* Write the trade history and exit when started with the export command */
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+
+		runExport(os.Args[2:])
+		return
+
+	}
+
+	/* Search configuration parameters over historical klines when started with the optimize command */
+	if len(os.Args) > 1 && os.Args[1] == "optimize" {
+
+		runOptimize(os.Args[2:])
+		return
+
+	}
+
+	/* Initialize DB connection, shared by the threads of the process */
+	storage, err := openStorage()
+
+	if err != nil {
+
+		log.Fatal(err)
+
+	}
+
+	myHandler := &myHandler{
+		sessionData: newSession(storage),
+		marketData:  newMarket(),
+		configData:  &types.Config{},
+		supervisor:  threads.NewSupervisor(storage, execution)}
+
+	port := functions.GetPort() /* Determine port for HTTP service. */
+
+	http.HandleFunc("/", myHandler.handler)
+	http.HandleFunc(apiPrefix, myHandler.api) /* JSON API for the web UI and scripts */
+	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	fmt.Printf("Listening on port %s \n", port)
+
+	open.Run("http://localhost:" + port) /* Open URI using the OS's default browser */
+
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), nil))
+
+}
+
+/* Create an idle session, started by the thread supervisor */
+func newSession(
+	storage types.Storage) *types.Session {
+
+	return &types.Session{
 		ThreadID:             "",
 		ThreadIDSession:      "",
 		ThreadCount:          0,
@@ -80,17 +155,28 @@ func main() {
 		ListenKey:            "",
 		MasterNode:           false,
 		TgBotAPI:             &tgbotapi.BotAPI{},
-		Db:                   &sql.DB{},
-		Clients:              types.Client{},
+		Storage:              storage,
+		Exchange:             nil,
+		Clock:                nil,
+		Backtest:             false,
 		KlineData:            []types.KlineData{},
-		StopWs:               false,
 		Busy:                 false,
 		MinQuantity:          0,
 		MaxQuantity:          0,
 		StepSize:             0,
+		TickSize:             0,
+		MinNotional:          0,
+		BasePrecision:        0,
+		QuotePrecision:       0,
+		OrderUpdate:          make(chan *types.Order, 10),
 	}
 
-	marketData := &types.Market{
+}
+
+/* Create empty market data for a session */
+func newMarket() *types.Market {
+
+	return &types.Market{
 		Rsi3:                      0,
 		Rsi7:                      0,
 		Rsi14:                     0,
@@ -99,30 +185,15 @@ func main() {
 		PriceChangeStatsHighPrice: 0,
 		PriceChangeStatsLowPrice:  0,
 		Direction:                 0,
+		BollingerUpper:            0,
+		BollingerMiddle:           0,
+		BollingerLower:            0,
+		BollingerPercentB:         0,
+		BollingerBandwidth:        0,
 		TimeStamp:                 time.Time{},
 		Series:                    &techan.TimeSeries{},
 	}
 
-	configData := &types.Config{}
-
-	/* Initialize DB connection */
-	sessionData.Db = mysql.DBInit()
-
-	myHandler := &myHandler{
-		sessionData: sessionData,
-		marketData:  marketData,
-		configData:  configData}
-
-	port := functions.GetPort() /* Determine port for HTTP service. */
-
-	http.HandleFunc("/", myHandler.handler)
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
-	fmt.Printf("Listening on port %s \n", port)
-
-	open.Run("http://localhost:" + port) /* Open URI using the OS's default browser */
-
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), nil))
-
 }
 
 func (fh *myHandler) handler(w http.ResponseWriter, r *http.Request) {
@@ -141,7 +212,7 @@ func (fh *myHandler) handler(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
 		case "/":
 
-			loadConfigDataAdditionalComponents(fh.configData, fh.sessionData) /* Load dynamic components in configData */
+			loadConfigDataAdditionalComponents(fh.configData, fh.sessionData, fh.supervisor) /* Load dynamic components in configData */
 
 			functions.ExecuteTemplate(w, fh.configData, fh.sessionData) /* This is the template execution for 'index' */
 
@@ -166,6 +237,40 @@ func (fh *myHandler) handler(w http.ResponseWriter, r *http.Request) {
 
 			}
 
+		case "/export":
+
+			/* Download the trades of the date range and ThreadIDs selected in the export form */
+			query := r.URL.Query()
+			format := query.Get("format")
+
+			w.Header().Set("Content-Type", export.ContentType(format))
+			w.Header().Set("Content-Disposition", "attachment; filename=\""+export.FileName(format, query.Get("start"), query.Get("end"))+"\"")
+
+			if err := exportTrades(
+				w,
+				fh.configData,
+				fh.sessionData.Storage,
+				fh.sessionData,
+				fh.configData.ExchangeName,
+				query.Get("start"),
+				query.Get("end"),
+				query.Get("threads"),
+				format); err != nil {
+
+				functions.Logger(&types.LogEntry{
+					Config:   fh.configData,
+					Market:   nil,
+					Session:  fh.sessionData,
+					Order:    &types.Order{},
+					Message:  functions.GetFunctionName() + " - " + err.Error(),
+					LogLevel: log.DebugLevel,
+				})
+
+				w.Header().Del("Content-Disposition")
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+			}
+
 		}
 
 	case "POST":
@@ -195,26 +300,32 @@ func (fh *myHandler) handler(w http.ResponseWriter, r *http.Request) {
 			switch r.PostFormValue("submitselect") {
 			case "new":
 
-				/* Spawn a new process  */
-				path, err := os.Executable()
-				if err != nil {
-					log.Println(err)
-				}
+				/* Show a new session to be started as a thread of this process, the running threads keep trading */
+				fh.sessionData = newSession(fh.sessionData.Storage)
+				fh.marketData = newMarket()
+
+				http.Redirect(w, r, r.URL.Path, 301) /* Redirect to root 'index' */
+
+			case "thread":
+
+				/* Show the selected thread, or a new session */
+				if thread := fh.supervisor.GetThread(r.PostFormValue("threadList")); thread != nil {
+
+					fh.sessionData = thread.Session
+					fh.marketData = thread.Market
+
+				} else if r.PostFormValue("threadList") == "" {
 
-				cmd := exec.Command(path)
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
+					fh.sessionData = newSession(fh.sessionData.Storage)
+					fh.marketData = newMarket()
 
-				err = cmd.Start()
-				if err != nil {
-					log.Println(err)
 				}
 
-				functions.ExecuteTemplate(w, fh.configData, fh.sessionData) /* This is the template execution for 'index' */
+				http.Redirect(w, r, r.URL.Path, 301) /* Redirect to root 'index' */
 
 			case "start":
 
-				go execution(
+				fh.supervisor.Start(
 					fh.configData,
 					fh.sessionData,
 					fh.marketData)
@@ -224,12 +335,19 @@ func (fh *myHandler) handler(w http.ResponseWriter, r *http.Request) {
 
 			case "stop":
 
-				threads.ExitThreadID(fh.sessionData) /* Cleanly exit ThreadID */
+				fh.supervisor.Stop(fh.sessionData) /* Cleanly exit ThreadID */
+
+				/* Show a new session once the thread is stopped */
+				fh.sessionData = newSession(fh.sessionData.Storage)
+				fh.marketData = newMarket()
+
+				http.Redirect(w, r, r.URL.Path, 301) /* Redirect to root 'index' */
 
-			case "update":
+			case "restart":
 
-				functions.SaveConfigData(r, fh.sessionData) /* Save updated config */
+				fh.supervisor.Restart(fh.sessionData) /* Stop and start ThreadID with its reloaded configuration */
 
+				time.Sleep(2 * time.Second)          /* Sleep time to wait for ThreadID to start */
 				http.Redirect(w, r, r.URL.Path, 301) /* Redirect to root 'index' */
 
 			case "buy":
@@ -244,6 +362,34 @@ func (fh *myHandler) handler(w http.ResponseWriter, r *http.Request) {
 
 				http.Redirect(w, r, r.URL.Path, 301) /* Redirect to root 'index' */
 
+			case "reconcile":
+
+				/* Reconcile orders and thread transactions with the exchange for the thread shown, refused when it is not running */
+				if thread := fh.supervisor.GetThread(r.PostFormValue("threadList")); thread != nil && thread.Session.Exchange != nil {
+
+					go exchange.Reconcile(functions.GetConfigData(thread.Session), thread.Session)
+
+				} else {
+
+					functions.Logger(&types.LogEntry{
+						Config:   fh.configData,
+						Market:   nil,
+						Session:  &types.Session{ThreadID: r.PostFormValue("threadList")},
+						Order:    &types.Order{},
+						Message:  "Reconcile refused - thread is not running",
+						LogLevel: log.InfoLevel,
+					})
+
+				}
+
+				http.Redirect(w, r, r.URL.Path, 301) /* Redirect to root 'index' */
+
+			case "resetrisk":
+
+				risk.Reset(fh.sessionData) /* Resume buying after a risk limit breach */
+
+				http.Redirect(w, r, r.URL.Path, 301) /* Redirect to root 'index' */
+
 			case "configTemplate":
 
 				fh.sessionData.ConfigTemplate = functions.StrToInt(r.PostFormValue("configTemplateList")) /* Retrieve Configuration Template Key selection */
@@ -283,15 +429,44 @@ func execution(
 
 	}
 
-	/* Routine to resume operations */
+	/* Routine to resume operations. Threads restarted by the supervisor resume their own ThreadID. */
 	var threadIDSessionDB string
-	sessionData.ThreadID, threadIDSessionDB, _ = mysql.GetThreadTransactionDistinct(sessionData)
+
+	if sessionData.ThreadID == "" {
+
+		sessionData.ThreadID, threadIDSessionDB, _ = sessionData.Storage.GetThreadTransactionDistinct(sessionData)
+
+	} else if functions.LockThreadID(sessionData.ThreadID) {
+
+		threadIDSessionDB = sessionData.ThreadIDSession
+
+	} else {
+
+		functions.Logger(&types.LogEntry{
+			Config:   configData,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  "ThreadID locked by another process",
+			LogLevel: log.DebugLevel,
+		})
+
+		/* Cleanly exit ThreadID, keeping the lock of the other process */
+		sessionData.ThreadID = ""
+		threads.ExitThreadID(sessionData)
+
+	}
 
 	if sessionData.ThreadID != "" && !configData.NewSession {
 
 		configData = functions.GetConfigData(sessionData)
 
-		sessionData.Symbol, _ = mysql.GetOrderSymbol(sessionData)
+		/* Threads restarted by the supervisor keep their symbol */
+		if sessionData.Symbol == "" {
+
+			sessionData.Symbol, _ = sessionData.Storage.GetOrderSymbol(sessionData)
+
+		}
 
 		if sessionData.Symbol == "" {
 
@@ -309,9 +484,6 @@ func execution(
 
 		}
 
-		/* Select the symbol coin to be used from sessionData.Symbol option */
-		sessionData.SymbolFiat = sessionData.Symbol[3:7]
-
 		functions.Logger(&types.LogEntry{
 			Config:   configData,
 			Market:   marketData,
@@ -329,13 +501,13 @@ func execution(
 		/* Create lock for threadID */
 		if !functions.LockThreadID(sessionData.ThreadID) {
 
-			os.Exit(1)
+			/* Cleanly exit ThreadID */
+			threads.ExitThreadID(sessionData)
 
 		}
 
 		/* Select the symbol coin to be used from Config option */
 		sessionData.Symbol = configData.Symbol
-		sessionData.SymbolFiat = configData.SymbolFiat
 
 		functions.Logger(&types.LogEntry{
 			Config:   configData,
@@ -348,25 +520,55 @@ func execution(
 
 	}
 
+	/* Load the symbols registry and select the fiat currency from the symbol quote asset */
+	var info *types.ExchangeInfo
+
+	if err = exchange.LoadSymbols(configData, sessionData); err == nil {
+
+		info, err = exchange.ValidateSymbol(configData, sessionData, sessionData.Symbol, "")
+
+	}
+
+	if err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   configData,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		/* Cleanly exit ThreadID */
+		threads.ExitThreadID(sessionData)
+
+	}
+
+	sessionData.SymbolFiat = info.QuoteAsset
+
 	/* Print threadID to debug for easy identification of session */
 	fmt.Printf("ThreadID:  %s", sessionData.ThreadID)
 
 	/* Synchronize time with Binance every 5 minutes */
 	_ = exchange.NewSetServerTimeService(configData, sessionData)
-	scheduler.RunTaskAtInterval(
+	threads.RunTaskAtInterval(
+		sessionData,
 		func() { _ = exchange.NewSetServerTimeService(configData, sessionData) },
 		time.Second*300,
 		time.Second*0)
 
 	/* Retrieve config data every 10 seconds. */
-	scheduler.RunTaskAtInterval(
+	threads.RunTaskAtInterval(
+		sessionData,
 		func() { configData = functions.GetConfigData(sessionData) },
 		time.Second*10,
 		time.Second*0)
 
 	/* run function UpdatePendingOrders() every 180 seconds */
 	rand.Seed(time.Now().UnixNano())
-	scheduler.RunTaskAtInterval(
+	threads.RunTaskAtInterval(
+		sessionData,
 		func() { algorithms.UpdatePendingOrders(configData, sessionData) },
 		time.Second*180,
 		time.Second*time.Duration(rand.Intn(180-1+1)+1),
@@ -374,22 +576,25 @@ func execution(
 
 	/* Retrieve initial node role and then every 60 seconds */
 	node.GetRole(configData, sessionData)
-	scheduler.RunTaskAtInterval(
+	threads.RunTaskAtInterval(
+		sessionData,
 		func() { node.GetRole(configData, sessionData) },
 		time.Second*60,
 		time.Second*0)
 
-	/* Keep user stream service alive every 60 seconds */
-	scheduler.RunTaskAtInterval(
-		func() { _ = exchange.KeepAliveUserStreamServiceListenKey(configData, sessionData) },
+	/* Track daily loss and drawdown every 60 seconds */
+	threads.RunTaskAtInterval(
+		sessionData,
+		func() { algorithms.UpdateRisk(configData, marketData, sessionData) },
 		time.Second*60,
-		time.Second*0)
+		time.Second*60)
 
 	/* Update Number of Sale Transactions per hour every 3 minutes.
 	The same function is executed after each sale, and when initiating cycle. */
-	scheduler.RunTaskAtInterval(
+	threads.RunTaskAtInterval(
+		sessionData,
 		func() {
-			sessionData.SellTransactionCount, err = mysql.GetOrderTransactionCount(sessionData, "SELL")
+			sessionData.SellTransactionCount, err = sessionData.Storage.GetOrderTransactionCount(sessionData, "SELL")
 		},
 		time.Second*180,
 		time.Second*0)
@@ -400,7 +605,7 @@ func execution(
 	if sessionData.SymbolFiatFunds, _ = exchange.GetSymbolFunds(
 		configData,
 		sessionData); err == nil {
-		_ = mysql.UpdateSession(
+		_ = sessionData.Storage.UpdateSession(
 			configData,
 			sessionData)
 	}
@@ -408,48 +613,81 @@ func execution(
 	/* Retrieve exchange lot size for ticker and store in sessionData */
 	exchange.GetLotSize(configData, sessionData)
 
-	sum := 0
-	for {
+	/* Reconcile orders and thread transactions with the exchange before trading */
+	_, _ = exchange.Reconcile(configData, sessionData)
 
-		/* Check start/stop times of operation */
-		if configData.TimeEnforce {
+	/* Check start/stop times of operation */
+	if configData.TimeEnforce {
 
-			for !functions.IsInTimeRange(configData.TimeStart, configData.TimeStop) {
+		for !functions.IsInTimeRange(configData.TimeStart, configData.TimeStop) {
 
-				functions.Logger(&types.LogEntry{
-					Config:   configData,
-					Market:   marketData,
-					Session:  sessionData,
-					Order:    &types.Order{},
-					Message:  "Sleeping",
-					LogLevel: log.InfoLevel,
-				})
+			functions.Logger(&types.LogEntry{
+				Config:   configData,
+				Market:   marketData,
+				Session:  sessionData,
+				Order:    &types.Order{},
+				Message:  "Sleeping",
+				LogLevel: log.InfoLevel,
+			})
+
+			/* Sleep until the thread is stopped */
+			select {
+			case <-sessionData.Done:
 
-				time.Sleep(300000 * time.Millisecond)
+				return
+
+			case <-time.After(300000 * time.Millisecond):
 
 			}
 
 		}
 
-		/* Update ThreadCount */
-		sessionData.ThreadCount, _ = mysql.GetThreadTransactionCount(sessionData)
+	}
+
+	/* Update ThreadCount */
+	sessionData.ThreadCount, _ = sessionData.Storage.GetThreadTransactionCount(sessionData)
+
+	/* Update Number of Sale Transactions per hour */
+	sessionData.SellTransactionCount, err = sessionData.Storage.GetOrderTransactionCount(sessionData, "SELL")
+
+	/* This routine is executed when no transaction cycle has initiated (ThreadCount = 0) */
+	if sessionData.ThreadCount == 0 {
+
+		/* Define new Thread ID Session */
+		sessionData.ThreadIDSession = functions.GetThreadID()
+
+		/* Save new session to Session table. */
+		if err := sessionData.Storage.SaveSession(
+			configData,
+			sessionData); err != nil {
+
+			/* Update existing session on Session table */
+			if err := sessionData.Storage.UpdateSession(
+				configData,
+				sessionData); err != nil {
+
+				/* Cleanly exit ThreadID */
+				threads.ExitThreadID(sessionData)
+
+			}
+
+		}
 
-		/* Update Number of Sale Transactions per hour */
-		sessionData.SellTransactionCount, err = mysql.GetOrderTransactionCount(sessionData, "SELL")
+	} else {
 
-		/* This routine is executed when no transaction cycle has initiated (ThreadCount = 0) */
-		if sessionData.ThreadCount == 0 {
+		/* Retrieve existing Thread ID Session if first time */
+		if threadIDSessionDB != "" {
 
-			/* Define new Thread ID Session */
-			sessionData.ThreadIDSession = functions.GetThreadID()
+			sessionData.ThreadIDSession = threadIDSessionDB
+			threadIDSessionDB = ""
 
-			/* Save new session to Session table. */
-			if err := mysql.SaveSession(
+			/* Save new session to Session table then update if fail */
+			if err := sessionData.Storage.SaveSession(
 				configData,
 				sessionData); err != nil {
 
 				/* Update existing session on Session table */
-				if err := mysql.UpdateSession(
+				if err := sessionData.Storage.UpdateSession(
 					configData,
 					sessionData); err != nil {
 
@@ -460,86 +698,101 @@ func execution(
 
 			}
 
-		} else {
-
-			/* Retrieve existing Thread ID Session if first time */
-			if threadIDSessionDB != "" {
-
-				sessionData.ThreadIDSession = threadIDSessionDB
-				threadIDSessionDB = ""
+		}
 
-				/* Save new session to Session table then update if fail */
-				if err := mysql.SaveSession(
-					configData,
-					sessionData); err != nil {
+	}
 
-					/* Update existing session on Session table */
-					if err := mysql.UpdateSession(
-						configData,
-						sessionData); err != nil {
+	/* Retrieve past market data the first time, or the klines missed since the thread was restarted.
+	The websockets backfill the klines missed while reconnecting. */
+	if marketData.PriceChangeStatsHighPrice == 0 {
 
-						/* Cleanly exit ThreadID */
-						threads.ExitThreadID(sessionData)
+		markets.LoadKlineDataPast(
+			configData,
+			marketData,
+			sessionData)
 
-					}
+	} else {
 
-				}
+		for _, kline := range markets.Backfill(
+			configData,
+			marketData,
+			sessionData,
+			"1m") {
 
-			}
+			plotter.LoadKlineData(
+				sessionData,
+				kline)
 
 		}
 
-		/* Conditional used in case this is the first run in the cycle go get past market data */
-		if marketData.PriceChangeStatsHighPrice == 0 {
+		for interval := range marketData.Timeframes {
 
-			markets.LoadKlineDataPast(
+			markets.Backfill(
 				configData,
 				marketData,
-				sessionData)
+				sessionData,
+				interval)
 
 		}
 
-		wg := &sync.WaitGroup{} /* WaitGroup to stop inside Channels */
-		wg.Add(3)               /* WaitGroup to stop inside Channels */
+	}
 
-		/* Start Telegram bot if Master Node and store in sessionData.TgBotAPI */
-		go telegram.CheckUpdates(
-			configData,
-			sessionData,
-			wg)
+	/* Load the kline intervals referenced by the BUY conditions not loaded yet */
+	markets.LoadTimeframes(
+		configData,
+		marketData,
+		sessionData)
 
-		/* Websocket routine to retrieve realtime candle data */
-		go algorithms.WsKline(
-			configData,
-			marketData,
-			sessionData,
-			wg)
+	wg := &sync.WaitGroup{}                /* WaitGroup to wait for the websockets */
+	wg.Add(4 + len(configData.Timeframes)) /* WaitGroup to wait for the websockets */
 
-		/* Websocket routine to retrieve realtime user data */
-		go algorithms.WsUserDataServe(
-			configData,
-			sessionData,
-			wg)
+	/* Start Telegram bot if Master Node and store in sessionData.TgBotAPI */
+	go telegram.CheckUpdates(
+		configData,
+		sessionData,
+		wg)
+
+	/* Websocket routine to retrieve realtime candle data */
+	go algorithms.WsKline(
+		configData,
+		marketData,
+		sessionData,
+		"1m",
+		wg)
 
-		/* Websocket routine to retrieve realtime ticker prices */
-		go algorithms.WsBookTicker(
+	/* Websocket routines to retrieve realtime candle data of the kline intervals referenced by the BUY conditions */
+	for _, interval := range configData.Timeframes {
+
+		go algorithms.WsKline(
 			configData,
 			marketData,
 			sessionData,
+			interval,
 			wg)
 
-		wg.Wait()                  /* Wait for the goroutines to finish */
-		sessionData.StopWs = false /* Reset goroutine channels */
+	}
 
-		/* Reload configuration in case of WsBookTicker broken connection */
-		configData = functions.GetConfigData(sessionData)
+	/* Websocket routine to retrieve realtime user data */
+	go algorithms.WsUserDataServe(
+		configData,
+		sessionData,
+		wg)
 
-		time.Sleep(3000 * time.Millisecond)
+	/* Websocket routine to retrieve realtime ticker prices */
+	go algorithms.WsBookTicker(
+		configData,
+		marketData,
+		sessionData,
+		wg)
 
-		/* repeated forever */
-		sum++
+	/* Websocket routine to maintain the local order book */
+	go algorithms.WsDepth(
+		configData,
+		marketData,
+		sessionData,
+		wg)
 
-	}
+	wg.Wait() /* Wait for the websockets, supervised until the thread is stopped */
 
 }
 
@@ -556,6 +809,12 @@ func loadSessionDataAdditionalComponents(
 		MACD      float64 /* Moving average convergence divergence */
 		Price     float64 /* Market Price */
 		Direction int     /* Market Direction */
+		Imbalance float64 /* Order book imbalance */
+		Upper     float64 /* Bollinger upper band */
+		Middle    float64 /* Bollinger middle band */
+		Lower     float64 /* Bollinger lower band */
+		PercentB  float64 /* Bollinger %B */
+		Bandwidth float64 /* Bollinger bandwidth */
 	}
 
 	type Order struct {
@@ -565,16 +824,26 @@ func loadSessionDataAdditionalComponents(
 		Target  float64
 	}
 
+	type Stream struct {
+		Name       string /* Websocket stream */
+		Connected  bool   /* Websocket connected */
+		Reconnects int    /* Reconnects since the thread started */
+		LastEvent  int64  /* Seconds since the last event received */
+		LastError  string /* Reason of the last reconnect */
+	}
+
 	type Session struct {
 		ThreadID             string  /* Unique session ID for the thread */
 		SellTransactionCount float64 /* Number of SELL transactions in the last 60 minutes*/
 		SymbolFiat           string  /* Fiat currency funds */
 		SymbolFiatFunds      float64 /* Fiat currency funds */
 		ProfitThreadID       float64 /* ThreadID profit */
+		Unrealized           float64 /* ThreadID profit of the open lots at the market price */
 		Profit               float64 /* Total profit */
 		ThreadCount          int     /* Thread count */
 		ThreadAmount         float64 /* Thread cost amount */
 		Orders               []Order
+		Streams              []Stream
 	}
 
 	type Update struct {
@@ -590,40 +859,68 @@ func loadSessionDataAdditionalComponents(
 	sessiondata.Market.MACD = math.Round(marketData.MACD*10000) / 10000
 	sessiondata.Market.Price = math.Round(marketData.Price*1000) / 1000
 	sessiondata.Market.Direction = marketData.Direction
+	sessiondata.Market.Imbalance = math.Round(marketData.Imbalance*100) / 100
+	sessiondata.Market.Upper = math.Round(marketData.BollingerUpper*1000) / 1000
+	sessiondata.Market.Middle = math.Round(marketData.BollingerMiddle*1000) / 1000
+	sessiondata.Market.Lower = math.Round(marketData.BollingerLower*1000) / 1000
+	sessiondata.Market.PercentB = math.Round(marketData.BollingerPercentB*100) / 100
+	sessiondata.Market.Bandwidth = math.Round(marketData.BollingerBandwidth*10000) / 10000
 
 	sessiondata.Session.ThreadID = sessionData.ThreadID
 	sessiondata.Session.SellTransactionCount = sessionData.SellTransactionCount
 	sessiondata.Session.SymbolFiat = sessionData.SymbolFiat
 	sessiondata.Session.SymbolFiatFunds = math.Round(sessionData.SymbolFiatFunds*100) / 100
 
-	if profit, err := mysql.GetProfit(sessionData); err == nil {
+	if profit, err := sessionData.Storage.GetProfit(sessionData); err == nil {
 		sessiondata.Session.Profit = math.Round(profit*100) / 100
 	}
-	if profitThreadID, err := mysql.GetProfitByThreadID(sessionData); err == nil {
+	if profitThreadID, err := sessionData.Storage.GetProfitByThreadID(sessionData); err == nil {
 		sessiondata.Session.ProfitThreadID = math.Round(profitThreadID*100) / 100
 	}
-	if threadCount, err := mysql.GetThreadCount(sessionData); err == nil {
+	if unrealized, err := ledger.Unrealized(configData, sessionData, marketData.Price); err == nil {
+		sessiondata.Session.Unrealized = unrealized.Round(2).InexactFloat64()
+	}
+	if threadCount, err := sessionData.Storage.GetThreadCount(sessionData); err == nil {
 		sessiondata.Session.ThreadCount = threadCount
 	}
-	if threadAmount, err := mysql.GetThreadAmount(sessionData); err == nil {
+	if threadAmount, err := sessionData.Storage.GetThreadAmount(sessionData); err == nil {
 		sessiondata.Session.ThreadAmount = math.Round(threadAmount*100) / 100
 	}
 
-	if orders, err := mysql.GetThreadTransactionByThreadID(sessionData); err == nil {
+	if orders, err := sessionData.Storage.GetThreadTransactionByThreadID(sessionData); err == nil {
 
 		for _, key := range orders {
 
 			tmp := Order{}
 			tmp.OrderID = strconv.Itoa(key.OrderID)
-			tmp.Quote = math.Round(key.CumulativeQuoteQuantity*100) / 100
-			tmp.Price = math.Round(key.Price*10000) / 10000
-			tmp.Target = math.Round((tmp.Price*(1+configData.ProfitMin))*1000) / 1000
+			tmp.Quote = key.CumulativeQuoteQuantity.Round(2).InexactFloat64()
+			tmp.Price = key.Price.Round(4).InexactFloat64()
+			tmp.Target = key.Price.Mul(decimal.NewFromFloat(1 + configData.ProfitMin)).Round(3).InexactFloat64()
 
 			sessiondata.Session.Orders = append(sessiondata.Session.Orders, tmp)
 		}
 
 	}
 
+	for _, status := range stream.Status(sessionData) {
+
+		tmp := Stream{
+			Name:       status.Name,
+			Connected:  status.Connected,
+			Reconnects: status.Reconnects,
+			LastError:  status.LastError,
+		}
+
+		if !status.LastEvent.IsZero() {
+
+			tmp.LastEvent = int64(time.Since(status.LastEvent).Seconds())
+
+		}
+
+		sessiondata.Session.Streams = append(sessiondata.Session.Streams, tmp)
+
+	}
+
 	return json.Marshal(sessiondata)
 
 }
@@ -631,8 +928,510 @@ func loadSessionDataAdditionalComponents(
 /* Load dynamic components into configData for html output */
 func loadConfigDataAdditionalComponents(
 	configData *types.Config,
-	sessionData *types.Session) {
+	sessionData *types.Session,
+	supervisor *threads.Supervisor) {
 
 	configData.HTMLSnippet = plotter.Plot(sessionData)
 
+	/* List the threads running in the process for selection, and a new session */
+	threadList := map[string]string{"": "-"}
+
+	for _, thread := range supervisor.Threads() {
+
+		if thread.Session.ThreadID == "" {
+
+			continue
+
+		}
+
+		threadList[thread.Session.ThreadID] = thread.Session.ThreadID + " " + thread.Session.Symbol
+
+	}
+
+	configData.ThreadList = threadList
+
+}
+
+/* Load 1m klines from CSV files, or from the database for the symbol from the start day to the end day included */
+func loadKlines(
+	klinesFiles string,
+	database bool,
+	symbol string,
+	start string,
+	end string) (klines []types.WsKline, err error) {
+
+	if !database {
+
+		return backtest.LoadKlines(strings.Split(klinesFiles, ",")...)
+
+	}
+
+	startTime, endTime, err := dayRange(start, end)
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	storage, err := openStorage()
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	return backtest.LoadStoredKlines(storage, symbol, startTime, endTime)
+
+}
+
+/* Convert the start day and end day included (YYYY-MM-DD, UTC) to Unix milliseconds. The range is open when a day is empty. */
+func dayRange(
+	start string,
+	end string) (startTime int64, endTime int64, err error) {
+
+	var day time.Time
+
+	endTime = time.Now().UnixNano() / int64(time.Millisecond)
+
+	if start != "" {
+
+		if day, err = time.Parse("2006-01-02", start); err != nil {
+
+			return 0, 0, err
+
+		}
+
+		startTime = day.UnixNano() / int64(time.Millisecond)
+
+	}
+
+	if end != "" {
+
+		if day, err = time.Parse("2006-01-02", end); err != nil {
+
+			return 0, 0, err
+
+		}
+
+		endTime = day.Add(24*time.Hour).UnixNano()/int64(time.Millisecond) - 1
+
+	}
+
+	return startTime, endTime, nil
+
+}
+
+/* Retrieve the trades from the start day to the end day included for the comma separated ThreadIDs, all when empty, and write them with the base and quote assets from exchange information */
+func exportTrades(
+	w io.Writer,
+	configData *types.Config,
+	storage types.Storage,
+	sessionData *types.Session,
+	exchangeName string,
+	start string,
+	end string,
+	threadIDs string,
+	format string) (err error) {
+
+	var ids []string
+	var trades []types.Trade
+
+	startTime, endTime, err := dayRange(start, end)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	for _, threadID := range strings.Split(threadIDs, ",") {
+
+		if threadID = strings.TrimSpace(threadID); threadID != "" {
+
+			ids = append(ids, threadID)
+
+		}
+
+	}
+
+	if trades, err = storage.GetTrades(sessionData, startTime, endTime, ids); err != nil {
+
+		return err
+
+	}
+
+	symbols := make(map[string]*types.ExchangeInfo)
+
+	for _, trade := range trades {
+
+		if _, ok := symbols[trade.Symbol]; ok {
+
+			continue
+
+		}
+
+		if symbols[trade.Symbol], err = exchange.GetSymbol(configData, sessionData, trade.Symbol); err != nil {
+
+			return fmt.Errorf("export - base and quote assets of %s unknown: %v", trade.Symbol, err)
+
+		}
+
+	}
+
+	return export.Write(w, format, exchangeName, trades, symbols)
+
+}
+
+/* Open the storage selected by the DB_ENGINE environment variable, mysql or sqlite, applying the schema migrations pending */
+func openStorage() (types.Storage, error) {
+
+	engine, db, _, _, err := migrateDatabase()
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	if engine == "mysql" {
+
+		return mysql.NewStorage(db), nil
+
+	}
+
+	return sqlite.NewStorage(db), nil
+
+}
+
+/* Open the database selected by the DB_ENGINE environment variable and apply the schema migrations pending, returning the schema version before and after. When DB_ENGINE is not set, MySQL is used if DB_NAME is set and an SQLite file at DB_PATH (cryptopump.db by default) otherwise. */
+func migrateDatabase() (engine string, db *sql.DB, from int, to int, err error) {
+
+	engine = strings.ToLower(os.Getenv("DB_ENGINE"))
+
+	if engine == "" {
+
+		engine = "sqlite"
+
+		if os.Getenv("DB_NAME") != "" {
+
+			engine = "mysql"
+
+		}
+
+	}
+
+	switch engine {
+	case "mysql":
+
+		if db, err = mysql.DBInit(); err != nil {
+
+			return engine, nil, 0, 0, err
+
+		}
+
+		from, to, err = mysql.Migrate(db)
+
+	case "sqlite":
+
+		path := os.Getenv("DB_PATH")
+
+		if path == "" {
+
+			path = "cryptopump.db"
+
+		}
+
+		if db, err = sqlite.DBInit(path); err != nil {
+
+			return engine, nil, 0, 0, err
+
+		}
+
+		from, to, err = sqlite.Migrate(db)
+
+	default:
+
+		return engine, nil, 0, 0, fmt.Errorf("DB_ENGINE %s not supported, use mysql or sqlite", engine)
+
+	}
+
+	if err != nil {
+
+		db.Close()
+		return engine, nil, from, to, fmt.Errorf("%s schema: %v", engine, err)
+
+	}
+
+	return engine, db, from, to, nil
+
+}
+
+/* Apply the schema migrations from command line: cryptopump migrate */
+func runMigrate(args []string) {
+
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Parse(args)
+
+	engine, db, from, to, err := migrateDatabase()
+
+	if err != nil {
+
+		fmt.Println(err)
+		os.Exit(1)
+
+	}
+
+	db.Close()
+
+	if from == to {
+
+		fmt.Printf("%s schema version %d is up to date\n", engine, to)
+		return
+
+	}
+
+	fmt.Printf("%s schema migrated from version %d to %d\n", engine, from, to)
+
+}
+
+/* Write the trade history from command line: cryptopump export -start 2021-01-01 -end 2021-12-31 -threads a1b2c3,d4e5f6 -format koinly -output trades.csv */
+func runExport(args []string) {
+
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	start := flags.String("start", "", "First day of the trades exported (YYYY-MM-DD, UTC)")
+	end := flags.String("end", "", "Last day of the trades exported (YYYY-MM-DD, UTC)")
+	threadIDs := flags.String("threads", "", "Comma separated ThreadIDs, all when empty")
+	format := flags.String("format", "csv", "Format: "+strings.Join(export.Formats, ", "))
+	exchangeName := flags.String("exchange", "BINANCE", "Exchange name written in the cointracking format")
+	output := flags.String("output", "", "Output file, standard output when empty")
+	configFile := flags.String("config", "config/config_default.yml", "Configuration file of the exchange providing the base and quote assets of the symbols")
+	flags.Parse(args)
+
+	configData, err := functions.LoadConfigFile(*configFile)
+
+	if err != nil {
+
+		fmt.Println(err)
+		os.Exit(1)
+
+	}
+
+	storage, err := openStorage()
+
+	if err != nil {
+
+		fmt.Println(err)
+		os.Exit(1)
+
+	}
+
+	w := io.Writer(os.Stdout)
+
+	if *output != "" {
+
+		file, err := os.Create(*output)
+
+		if err != nil {
+
+			fmt.Println(err)
+			os.Exit(1)
+
+		}
+
+		defer file.Close()
+		w = file
+
+	}
+
+	if err = exportTrades(w, configData, storage, &types.Session{}, *exchangeName, *start, *end, *threadIDs, *format); err != nil {
+
+		fmt.Println(err)
+		os.Exit(1)
+
+	}
+
+}
+
+/* Run backtest from command line: cryptopump backtest -config config/config_default.yml -klines BTCUSDT-1m-2021-01.csv */
+func runBacktest(args []string) {
+
+	flags := flag.NewFlagSet("backtest", flag.ExitOnError)
+	configFile := flags.String("config", "config/config_default.yml", "Configuration file")
+	klinesFiles := flags.String("klines", "", "Comma separated 1m kline CSV files")
+	database := flags.Bool("db", false, "Load the 1m klines saved to the database for the configuration symbol instead of CSV files")
+	start := flags.String("start", "", "First day of the klines loaded from the database (YYYY-MM-DD, UTC)")
+	end := flags.String("end", "", "Last day of the klines loaded from the database (YYYY-MM-DD, UTC)")
+	funds := flags.Float64("funds", 0, "Initial fiat funds, DryRun funds from configuration when zero")
+	stepSize := flags.Float64("stepsize", 0.000001, "Lot size step for the symbol")
+	tickSize := flags.Float64("ticksize", 0.01, "Price tick for the symbol, dividing every kline price")
+	minNotional := flags.Float64("minnotional", 0, "Minimum order value for the symbol")
+	flags.Parse(args)
+
+	if *klinesFiles == "" && !*database {
+
+		flags.Usage()
+		os.Exit(2)
+
+	}
+
+	configData, err := functions.LoadConfigFile(*configFile)
+
+	if err != nil {
+
+		fmt.Println(err)
+		os.Exit(1)
+
+	}
+
+	if *funds > 0 {
+
+		configData.DryRunFiatFunds = *funds
+
+	}
+
+	klines, err := loadKlines(*klinesFiles, *database, configData.Symbol, *start, *end)
+
+	if err != nil {
+
+		fmt.Println(err)
+		os.Exit(1)
+
+	}
+
+	report, err := backtest.Run(configData, klines, backtest.Filters{StepSize: *stepSize, TickSize: *tickSize, MinNotional: *minNotional})
+
+	if err != nil {
+
+		fmt.Println(err)
+		os.Exit(1)
+
+	}
+
+	backtest.Print(os.Stdout, report)
+
+}
+
+/* Parameters given to the optimize command, as key=min:max:step or key=value1,value2 */
+type parameterFlags []optimizer.Parameter
+
+func (p *parameterFlags) String() string {
+
+	return fmt.Sprint(*p)
+
+}
+
+func (p *parameterFlags) Set(value string) error {
+
+	parameter, err := optimizer.ParseParameter(value)
+
+	if err != nil {
+
+		return err
+
+	}
+
+	*p = append(*p, parameter)
+
+	return nil
+
+}
+
+/* Run optimize from command line: cryptopump optimize -config config/config_default.yml -klines BTCUSDT-1m-2021-01.csv -param profit_min=0.001:0.009:0.002 -param buy_rsi7_entry=30,40,50 */
+func runOptimize(args []string) {
+
+	var parameters parameterFlags
+
+	flags := flag.NewFlagSet("optimize", flag.ExitOnError)
+	configFile := flags.String("config", "config/config_default.yml", "Base configuration file")
+	klinesFiles := flags.String("klines", "", "Comma separated 1m kline CSV files")
+	database := flags.Bool("db", false, "Load the 1m klines saved to the database for the configuration symbol instead of CSV files")
+	start := flags.String("start", "", "First day of the klines loaded from the database (YYYY-MM-DD, UTC)")
+	end := flags.String("end", "", "Last day of the klines loaded from the database (YYYY-MM-DD, UTC)")
+	funds := flags.Float64("funds", 0, "Initial fiat funds, DryRun funds from configuration when zero")
+	stepSize := flags.Float64("stepsize", 0.000001, "Lot size step for the symbol")
+	tickSize := flags.Float64("ticksize", 0.01, "Price tick for the symbol, dividing every kline price")
+	minNotional := flags.Float64("minnotional", 0, "Minimum order value for the symbol")
+	search := flags.String("search", "grid", "Search method: grid, random or genetic")
+	samples := flags.Int("samples", 50, "Candidates for random search and population for genetic search")
+	generations := flags.Int("generations", 10, "Generations for genetic search")
+	workers := flags.Int("workers", runtime.NumCPU(), "Backtests running in parallel")
+	rank := flags.String("rank", "profit", "Rank results by profit, drawdown or trades")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "Seed for random and genetic search")
+	top := flags.Int("top", 3, "Number of best results written as configuration templates")
+	flags.Var(&parameters, "param", "Parameter searched as key=min:max:step or key=value1,value2 (repeatable)")
+	flags.Parse(args)
+
+	if (*klinesFiles == "" && !*database) || len(parameters) == 0 {
+
+		flags.Usage()
+		os.Exit(2)
+
+	}
+
+	configData, err := functions.LoadConfigFile(*configFile)
+
+	if err != nil {
+
+		fmt.Println(err)
+		os.Exit(1)
+
+	}
+
+	klines, err := loadKlines(*klinesFiles, *database, configData.Symbol, *start, *end)
+
+	if err != nil {
+
+		fmt.Println(err)
+		os.Exit(1)
+
+	}
+
+	options := &optimizer.Options{
+		ConfigFile:  *configFile,
+		Klines:      klines,
+		Filters:     backtest.Filters{StepSize: *stepSize, TickSize: *tickSize, MinNotional: *minNotional},
+		Funds:       *funds,
+		Parameters:  parameters,
+		Search:      *search,
+		Samples:     *samples,
+		Generations: *generations,
+		Workers:     *workers,
+		Rank:        *rank,
+		Seed:        *seed,
+	}
+
+	results, err := optimizer.Run(options)
+
+	if err != nil {
+
+		fmt.Println(err)
+		os.Exit(1)
+
+	}
+
+	optimizer.Print(os.Stdout, options, results)
+
+	if *top > len(results) {
+
+		*top = len(results)
+
+	}
+
+	filenames, err := optimizer.Write(options, results[:*top], "./config")
+
+	for _, filename := range filenames {
+
+		fmt.Println("Configuration template saved to " + filename)
+
+	}
+
+	if err != nil {
+
+		fmt.Println(err)
+		os.Exit(1)
+
+	}
+
 }
@@ -0,0 +1,184 @@
+package markets
+
+import (
+	"cryptopump/functions"
+	"cryptopump/types"
+	"time"
+)
+
+const bookStale = 10 * time.Second /* Order book not updated for the duration is not used for execution */
+
+// LoadDepth Replace the local order book with a partial depth update and calculate the order book imbalance
+func LoadDepth(
+	sessionData *types.Session,
+	marketData *types.Market,
+	depth *types.WsDepth) {
+
+	book := &types.OrderBook{
+		Bids:      loadLevels(depth.Bids),
+		Asks:      loadLevels(depth.Asks),
+		TimeStamp: functions.Now(sessionData),
+	}
+
+	var bidQuantity, askQuantity float64
+
+	for _, level := range book.Bids {
+
+		bidQuantity += level.Quantity
+
+	}
+
+	for _, level := range book.Asks {
+
+		askQuantity += level.Quantity
+
+	}
+
+	if bidQuantity+askQuantity > 0 {
+
+		marketData.Imbalance = (bidQuantity - askQuantity) / (bidQuantity + askQuantity)
+
+	}
+
+	marketData.Book = book
+
+}
+
+// GetBook Retrieve the local order book, or nil when the depth is not streamed or the order book is stale
+func GetBook(
+	sessionData *types.Session,
+	marketData *types.Market) *types.OrderBook {
+
+	book := marketData.Book
+
+	if book == nil ||
+		len(book.Bids) == 0 ||
+		len(book.Asks) == 0 ||
+		functions.Now(sessionData).Sub(book.TimeStamp) > bookStale {
+
+		return nil
+
+	}
+
+	return book
+
+}
+
+// Spread Calculate the bid-ask spread as a percentage of the mid price
+func Spread(
+	book *types.OrderBook) float64 {
+
+	bid, ask := book.Bids[0].Price, book.Asks[0].Price
+
+	return (ask - bid) / ((ask + bid) / 2)
+
+}
+
+// EstimateBuy Estimate the average fill price of a market BUY of the fiat quantity, walking the asks.
+// Returns false when the order book depth doesn't fill the quantity.
+func EstimateBuy(
+	book *types.OrderBook,
+	fiat float64) (price float64, ok bool) {
+
+	var filled, quantity float64
+
+	for _, level := range book.Asks {
+
+		take := level.Price * level.Quantity
+
+		if filled+take >= fiat {
+
+			quantity += (fiat - filled) / level.Price
+			return fiat / quantity, true
+
+		}
+
+		filled += take
+		quantity += level.Quantity
+
+	}
+
+	return 0, false
+
+}
+
+// EstimateSell Estimate the average fill price of a market SELL of the quantity, walking the bids.
+// Returns false when the order book depth doesn't fill the quantity.
+func EstimateSell(
+	book *types.OrderBook,
+	quantity float64) (price float64, ok bool) {
+
+	var filled, amount float64
+
+	for _, level := range book.Bids {
+
+		if filled+level.Quantity >= quantity {
+
+			amount += (quantity - filled) * level.Price
+			return amount / quantity, true
+
+		}
+
+		filled += level.Quantity
+		amount += level.Quantity * level.Price
+
+	}
+
+	return 0, false
+
+}
+
+// BuyFiatWithin Calculate the largest fiat quantity a market BUY fills at an average price up to price, walking the asks
+func BuyFiatWithin(
+	book *types.OrderBook,
+	price float64) (fiat float64) {
+
+	var quantity float64
+
+	for _, level := range book.Asks {
+
+		if level.Price <= price {
+
+			fiat += level.Price * level.Quantity
+			quantity += level.Quantity
+			continue
+
+		}
+
+		/* Quantity of the level keeping the average price at the limit */
+		if take := (price*quantity - fiat) / (level.Price - price); take > 0 {
+
+			if take > level.Quantity {
+
+				take = level.Quantity
+
+			}
+
+			fiat += level.Price * take
+
+		}
+
+		break
+
+	}
+
+	return fiat
+
+}
+
+/* Convert depth levels to order book price levels */
+func loadLevels(
+	depth []types.DepthLevel) (levels []types.PriceLevel) {
+
+	for _, level := range depth {
+
+		levels = append(levels, types.PriceLevel{
+			Price:    functions.StrToFloat64(level.Price),
+			Quantity: functions.StrToFloat64(level.Quantity),
+		})
+
+	}
+
+	return levels
+
+}
@@ -12,32 +12,93 @@ import (
 	"github.com/sdcoffey/techan"
 )
 
+const (
+	rsiWindow       = 14 /* Longest Relative Strength Index window */
+	macdShortWindow = 12 /* MACD short EMA window */
+	macdLongWindow  = 26 /* MACD long EMA window */
+	warmupFactor    = 5  /* Klines per window for the exponential and Wilder averages to converge */
+)
+
+// WarmupKlines Return the number of final klines required for the indicators to be meaningful.
+// Warm-up is sized from the longest indicator window, as RSI and MACD averages depend on all previous klines.
+func WarmupKlines(
+	configData *types.Config) int {
+
+	window := macdLongWindow
+
+	if rsiWindow > window {
+
+		window = rsiWindow
+
+	}
+
+	if configData.BollingerWindow > window {
+
+		window = configData.BollingerWindow
+
+	}
+
+	/* 1m indicators are calculated on the kline preceding the last */
+	return window*warmupFactor + 1
+
+}
+
 /* Technical analysis Calculations */
 func calculate(
 	closePrices techan.Indicator,
 	priceChangeStats []*types.PriceChangeStats,
+	configData *types.Config,
 	sessionData *types.Session,
 	marketData *types.Market) {
 
 	marketData.Rsi3 = calculateRSI(closePrices, marketData.Series, 3)
 	marketData.Rsi7 = calculateRSI(closePrices, marketData.Series, 7)
-	marketData.Rsi14 = calculateRSI(closePrices, marketData.Series, 14)
-	marketData.MACD = calculateMACD(closePrices, marketData.Series, 12, 26)
+	marketData.Rsi14 = calculateRSI(closePrices, marketData.Series, rsiWindow)
+	marketData.MACD = calculateMACD(closePrices, marketData.Series, macdShortWindow, macdLongWindow)
+	marketData.BollingerUpper,
+		marketData.BollingerMiddle,
+		marketData.BollingerLower,
+		marketData.BollingerPercentB,
+		marketData.BollingerBandwidth = calculateBollinger(closePrices, marketData.Series, configData.BollingerWindow, configData.BollingerDeviation)
 	if priceChangeStats != nil {
 		marketData.PriceChangeStatsHighPrice = calculatePriceChangeStatsHighPrice(priceChangeStats)
 		marketData.PriceChangeStatsLowPrice = calculatePriceChangeStatsLowPrice(priceChangeStats)
 	}
-	marketData.TimeStamp = time.Now() /* Time of last retrieved market Data */
+	marketData.TimeStamp = functions.Now(sessionData) /* Time of last retrieved market Data */
+	marketData.Ready = len(marketData.Series.Candles) >= WarmupKlines(configData)
 
 }
 
-// LoadKlineData Retrieve RealTime Kline Data
+// LoadKlineData Retrieve RealTime Kline Data. Klines of intervals other than 1m are loaded to their timeframe.
 func LoadKlineData(
 	configData *types.Config,
 	sessionData *types.Session,
 	marketData *types.Market,
 	kline types.WsKline) {
 
+	if kline.Interval != "" && kline.Interval != "1m" {
+
+		if timeframe, ok := marketData.Timeframes[kline.Interval]; ok {
+
+			loadTimeframeKline(
+				configData,
+				sessionData,
+				timeframe,
+				&types.Kline{
+					OpenTime: kline.StartTime,
+					Open:     kline.Open,
+					High:     kline.High,
+					Low:      kline.Low,
+					Close:    kline.Close,
+					Volume:   kline.Volume,
+				})
+
+		}
+
+		return
+
+	}
+
 	start, _ := strconv.ParseInt(fmt.Sprint(kline.StartTime), 10, 64)
 	period := techan.NewTimePeriod(time.Unix((start/1000), 0).UTC(), time.Minute*1)
 
@@ -57,6 +118,7 @@ func LoadKlineData(
 	calculate(
 		techan.NewClosePriceIndicator(marketData.Series),
 		priceChangeStats,
+		configData,
 		sessionData,
 		marketData)
 
@@ -71,7 +133,8 @@ func LoadKlineDataPast(
 	var err error
 	var klines []*types.Kline
 
-	if klines, err = exchange.GetKlines(configData, sessionData); err != nil {
+	/* The last kline retrieved is still open */
+	if klines, err = exchange.GetKlines(configData, sessionData, "1m", WarmupKlines(configData)+1); err != nil {
 
 		return
 
@@ -79,20 +142,19 @@ func LoadKlineDataPast(
 
 	for _, datum := range klines {
 
-		start, _ := strconv.ParseInt(fmt.Sprint(datum.OpenTime), 10, 64)
-		period := techan.NewTimePeriod(time.Unix((start/1000), 0).UTC(), time.Minute*1)
+		/* Open klines are loaded once final from the kline stream */
+		if isOpen(sessionData, "1m", datum) {
 
-		candle := techan.NewCandle(period)
-		candle.OpenPrice = big.NewFromString(datum.Open)
-		candle.ClosePrice = big.NewFromString(datum.Close)
-		candle.MaxPrice = big.NewFromString(datum.High)
-		candle.MinPrice = big.NewFromString(datum.Low)
-		candle.Volume = big.NewFromString(datum.Volume)
+			continue
 
-		if !marketData.Series.AddCandle(candle) {
+		}
+
+		if !addCandle(marketData.Series, "1m", datum) {
 			return
 		}
 
+		saveKline(sessionData, "1m", datum)
+
 	}
 
 	priceChangeStats, _ := exchange.GetPriceChangeStats(configData, sessionData, marketData)
@@ -100,11 +162,301 @@ func LoadKlineDataPast(
 	calculate(
 		techan.NewClosePriceIndicator(marketData.Series),
 		priceChangeStats,
+		configData,
 		sessionData,
 		marketData)
 
 }
 
+// Backfill Retrieve the final klines of the interval missed since the last kline loaded, as klines are not streamed while the websocket reconnects.
+// Klines are loaded for technical analysis and saved, and the 1m klines are returned for plotting.
+func Backfill(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session,
+	interval string) (backfilled []types.WsKline) {
+
+	series := marketData.Series
+
+	if interval != "1m" {
+
+		timeframe, ok := marketData.Timeframes[interval]
+
+		if !ok {
+
+			return nil
+
+		}
+
+		series = timeframe.Series
+
+	}
+
+	last := series.LastCandle()
+
+	if last == nil {
+
+		return nil
+
+	}
+
+	klines, err := exchange.GetKlinesSince(configData, sessionData, interval, last.Period.End.Unix()*1000)
+
+	if err != nil {
+
+		return nil
+
+	}
+
+	for _, kline := range klines {
+
+		if isOpen(sessionData, interval, kline) {
+
+			continue
+
+		}
+
+		if interval != "1m" {
+
+			loadTimeframeKline(configData, sessionData, marketData.Timeframes[interval], kline)
+			saveKline(sessionData, interval, kline)
+			continue
+
+		}
+
+		if !addCandle(series, interval, kline) {
+
+			continue
+
+		}
+
+		saveKline(sessionData, interval, kline)
+		backfilled = append(backfilled, toWsKline(interval, kline))
+
+	}
+
+	if len(backfilled) > 0 {
+
+		priceChangeStats, _ := exchange.GetPriceChangeStats(configData, sessionData, marketData)
+
+		calculate(
+			techan.NewClosePriceIndicator(marketData.Series),
+			priceChangeStats,
+			configData,
+			sessionData,
+			marketData)
+
+	}
+
+	return backfilled
+
+}
+
+// SaveKline Save a final kline streamed, so klines are kept beyond the technical analysis series and can be replayed by backtests
+func SaveKline(
+	sessionData *types.Session,
+	kline types.WsKline) {
+
+	if !kline.IsFinal || sessionData.Backtest {
+
+		return
+
+	}
+
+	_ = sessionData.Storage.SaveKline(sessionData, kline)
+
+}
+
+// LoadTimeframes Create the timeframes for the kline intervals of the configuration, seeded with the final klines retrieved via REST API.
+// Timeframes already loaded are kept, as they are updated by their own kline stream.
+func LoadTimeframes(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session) {
+
+	if marketData.Timeframes == nil {
+
+		marketData.Timeframes = make(map[string]*types.Timeframe)
+
+	}
+
+	for _, interval := range configData.Timeframes {
+
+		if _, ok := marketData.Timeframes[interval]; ok {
+
+			continue
+
+		}
+
+		/* The last kline retrieved is still open */
+		klines, err := exchange.GetKlines(configData, sessionData, interval, WarmupKlines(configData)+1)
+
+		if err != nil {
+
+			continue
+
+		}
+
+		timeframe := &types.Timeframe{
+			Interval: interval,
+			Series:   techan.NewTimeSeries(),
+		}
+
+		for _, kline := range klines {
+
+			/* Open klines are loaded once final from the kline stream */
+			if isOpen(sessionData, interval, kline) {
+
+				continue
+
+			}
+
+			loadTimeframeKline(configData, sessionData, timeframe, kline)
+			saveKline(sessionData, interval, kline)
+
+		}
+
+		marketData.Timeframes[interval] = timeframe
+
+	}
+
+}
+
+// GetIndicator Retrieve an indicator (rsi3, rsi7, rsi14 or macd) of a kline interval. Returns false until the interval indicators are warmed up.
+func GetIndicator(
+	marketData *types.Market,
+	interval string,
+	indicator string) (value float64, ok bool) {
+
+	if interval == "1m" && !marketData.Ready {
+
+		return 0, false
+
+	}
+
+	rsi3, rsi7, rsi14, macd := marketData.Rsi3, marketData.Rsi7, marketData.Rsi14, marketData.MACD
+
+	if interval != "1m" {
+
+		timeframe, ok := marketData.Timeframes[interval]
+
+		if !ok || !timeframe.Ready {
+
+			return 0, false
+
+		}
+
+		rsi3, rsi7, rsi14, macd = timeframe.Rsi3, timeframe.Rsi7, timeframe.Rsi14, timeframe.MACD
+
+	}
+
+	switch indicator {
+	case "rsi3":
+
+		return rsi3, true
+
+	case "rsi7":
+
+		return rsi7, true
+
+	case "rsi14":
+
+		return rsi14, true
+
+	case "macd":
+
+		return macd, true
+
+	}
+
+	return 0, false
+
+}
+
+/* Load a final kline to the timeframe and calculate its indicators on the last kline, as timeframes only hold final klines */
+func loadTimeframeKline(
+	configData *types.Config,
+	sessionData *types.Session,
+	timeframe *types.Timeframe,
+	kline *types.Kline) {
+
+	if !addCandle(timeframe.Series, timeframe.Interval, kline) {
+		return
+	}
+
+	closePrices := techan.NewClosePriceIndicator(timeframe.Series)
+	index := timeframe.Series.LastIndex()
+
+	timeframe.Rsi3 = techan.NewRelativeStrengthIndexIndicator(closePrices, 3).Calculate(index).Float()
+	timeframe.Rsi7 = techan.NewRelativeStrengthIndexIndicator(closePrices, 7).Calculate(index).Float()
+	timeframe.Rsi14 = techan.NewRelativeStrengthIndexIndicator(closePrices, rsiWindow).Calculate(index).Float()
+	timeframe.MACD = techan.NewMACDIndicator(closePrices, macdShortWindow, macdLongWindow).Calculate(index).Float()
+	timeframe.TimeStamp = functions.Now(sessionData)
+	timeframe.Ready = len(timeframe.Series.Candles) >= WarmupKlines(configData)-1
+
+}
+
+/* Add a kline of the interval to the series. Returns false if the kline is not newer than the last candle. */
+func addCandle(
+	series *techan.TimeSeries,
+	interval string,
+	kline *types.Kline) bool {
+
+	period := techan.NewTimePeriod(time.Unix((kline.OpenTime/1000), 0).UTC(), functions.IntervalDuration(interval))
+
+	candle := techan.NewCandle(period)
+	candle.OpenPrice = big.NewFromString(kline.Open)
+	candle.ClosePrice = big.NewFromString(kline.Close)
+	candle.MaxPrice = big.NewFromString(kline.High)
+	candle.MinPrice = big.NewFromString(kline.Low)
+	candle.Volume = big.NewFromString(kline.Volume)
+
+	return series.AddCandle(candle)
+
+}
+
+/* Save a final kline of the interval retrieved via REST API */
+func saveKline(
+	sessionData *types.Session,
+	interval string,
+	kline *types.Kline) {
+
+	SaveKline(sessionData, toWsKline(interval, kline))
+
+}
+
+/* Convert a REST API kline to a final websocket kline */
+func toWsKline(
+	interval string,
+	kline *types.Kline) types.WsKline {
+
+	return types.WsKline{
+		StartTime:            kline.OpenTime,
+		EndTime:              kline.CloseTime,
+		Interval:             interval,
+		Open:                 kline.Open,
+		Close:                kline.Close,
+		High:                 kline.High,
+		Low:                  kline.Low,
+		Volume:               kline.Volume,
+		QuoteVolume:          kline.QuoteVolume,
+		ActiveBuyVolume:      kline.ActiveBuyVolume,
+		ActiveBuyQuoteVolume: kline.ActiveBuyQuoteVolume,
+		IsFinal:              true,
+	}
+
+}
+
+/* Check if the kline of the interval is still open */
+func isOpen(
+	sessionData *types.Session,
+	interval string,
+	kline *types.Kline) bool {
+
+	return time.Unix(kline.OpenTime/1000, 0).Add(functions.IntervalDuration(interval)).After(functions.Now(sessionData))
+
+}
+
 /* Calculate Relative Strength Index */
 func calculateRSI(
 	closePrices techan.Indicator,
@@ -123,6 +475,40 @@ func calculateMACD(
 	return techan.NewMACDIndicator(closePrices, shortwindow, longwindow).Calculate(series.LastIndex() - 1).Float()
 }
 
+/* Calculate Bollinger Bands, %B and Bandwidth. Values are zero until the series is longer than the window */
+func calculateBollinger(
+	closePrices techan.Indicator,
+	series *techan.TimeSeries,
+	window int,
+	deviation float64) (upper float64, middle float64, lower float64, percentB float64, bandwidth float64) {
+
+	index := series.LastIndex() - 1
+
+	if window < 2 || index < window-1 {
+
+		return 0, 0, 0, 0, 0
+
+	}
+
+	upper = techan.NewBollingerUpperBandIndicator(closePrices, window, deviation).Calculate(index).Float()
+	middle = techan.NewSimpleMovingAverage(closePrices, window).Calculate(index).Float()
+	lower = techan.NewBollingerLowerBandIndicator(closePrices, window, deviation).Calculate(index).Float()
+
+	if upper > lower {
+
+		percentB = (closePrices.Calculate(index).Float() - lower) / (upper - lower)
+
+	}
+
+	if middle != 0 {
+
+		bandwidth = (upper - lower) / middle
+
+	}
+
+	return upper, middle, lower, percentB, bandwidth
+}
+
 /* Calculate High price for 1 period */
 func calculatePriceChangeStatsHighPrice(
 	priceChangeStats []*types.PriceChangeStats) float64 {
@@ -0,0 +1,1315 @@
+package memory
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"cryptopump/functions"
+	"cryptopump/types"
+
+	"github.com/shopspring/decimal"
+)
+
+/* order row, mirroring the orders table */
+type order struct {
+	types.Order
+	threadID        string
+	threadIDSession string
+	lastTradeID     int64 /* Last trade applied to the commission */
+}
+
+/* thread row, mirroring the thread table */
+type thread struct {
+	threadID                string
+	threadIDSession         string
+	orderID                 int64
+	cumulativeQuoteQuantity decimal.Decimal
+	price                   decimal.Decimal
+	executedQuantity        decimal.Decimal
+	highPrice               decimal.Decimal /* High-water mark of the market price since BUY */
+}
+
+/* session row, mirroring the session table */
+type session struct {
+	threadID        string
+	threadIDSession string
+	exchange        string
+	fiatSymbol      string
+	fiatFunds       float64
+}
+
+/* lot row, mirroring the lots table */
+type lot struct {
+	types.Lot
+	threadID string
+}
+
+/* fill row, mirroring the fills table */
+type fill struct {
+	types.Fill
+	threadID string
+}
+
+/* lease row, mirroring the lease table */
+type lease struct {
+	types.Lease
+	expires time.Time
+}
+
+// Storage in-memory implementation of types.Storage. It replicates the MySQL stored
+// procedures and is intended for backtesting, where no database is required.
+type Storage struct {
+	orders   []*order
+	threads  []*thread
+	lots     []*lot
+	fills    []*fill
+	sessions map[string]*session
+	leases   map[string]*lease
+	risk     types.Risk                 /* Risk state of the account */
+	klines   map[string][]types.WsKline /* Klines by symbol and interval, ordered by open time */
+	mutex    sync.Mutex
+}
+
+// New create an empty in-memory storage
+func New() *Storage {
+
+	return &Storage{
+		sessions: make(map[string]*session),
+		leases:   make(map[string]*lease),
+		klines:   make(map[string][]types.WsKline),
+	}
+
+}
+
+// SaveOrder Save order to storage
+func (s *Storage) SaveOrder(
+	sessionData *types.Session,
+	clientOrderID string,
+	cumulativeQuoteQuantity decimal.Decimal,
+	executedQuantity decimal.Decimal,
+	orderID int64,
+	price decimal.Decimal,
+	side string,
+	status string,
+	symbol string,
+	transactTime int64,
+	commissionAmount decimal.Decimal,
+	commissionAsset string,
+	commission decimal.Decimal) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, o := range s.orders {
+
+		if int64(o.OrderID) == orderID {
+
+			return errors.New("Duplicate entry for key 'orders.PRIMARY'")
+
+		}
+
+	}
+
+	s.orders = append(s.orders, &order{
+		Order: types.Order{
+			ClientOrderID:           clientOrderID,
+			CumulativeQuoteQuantity: cumulativeQuoteQuantity,
+			ExecutedQuantity:        executedQuantity,
+			OrderID:                 int(orderID),
+			Price:                   price,
+			Side:                    side,
+			Status:                  status,
+			Symbol:                  symbol,
+			TransactTime:            transactTime,
+			CommissionAmount:        commissionAmount,
+			CommissionAsset:         commissionAsset,
+			Commission:              commission,
+		},
+		threadID:        sessionData.ThreadID,
+		threadIDSession: sessionData.ThreadIDSession,
+	})
+
+	return nil
+
+}
+
+// UpdateOrder Update order
+func (s *Storage) UpdateOrder(
+	sessionData *types.Session,
+	orderID int64,
+	cumulativeQuoteQuantity decimal.Decimal,
+	executedQuantity decimal.Decimal,
+	price decimal.Decimal,
+	status string) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, o := range s.orders {
+
+		if int64(o.OrderID) == orderID {
+
+			o.CumulativeQuoteQuantity = cumulativeQuoteQuantity
+			o.ExecutedQuantity = executedQuantity
+			o.Price = price
+			o.Status = status
+
+		}
+
+	}
+
+	return nil
+
+}
+
+// UpdateOrderCommission Add the commission of a trade to an order waiting to be filled. Trades already applied are ignored
+func (s *Storage) UpdateOrderCommission(
+	sessionData *types.Session,
+	orderID int64,
+	tradeID int64,
+	commissionAmount decimal.Decimal,
+	commissionAsset string,
+	commission decimal.Decimal) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, o := range s.orders {
+
+		if int64(o.OrderID) == orderID &&
+			o.threadID == sessionData.ThreadID &&
+			(o.Status == "NEW" || o.Status == "PARTIALLY_FILLED") &&
+			o.lastTradeID < tradeID {
+
+			o.CommissionAmount = o.CommissionAmount.Add(commissionAmount)
+			o.CommissionAsset = commissionAsset
+			o.Commission = o.Commission.Add(commission)
+			o.lastTradeID = tradeID
+
+		}
+
+	}
+
+	return nil
+
+}
+
+// SaveSession Save new session to storage
+func (s *Storage) SaveSession(
+	configData *types.Config,
+	sessionData *types.Session) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.sessions[sessionData.ThreadID]; ok {
+
+		return errors.New("Duplicate entry for key 'session.ThreadID_UNIQUE'")
+
+	}
+
+	s.sessions[sessionData.ThreadID] = &session{
+		threadID:        sessionData.ThreadID,
+		threadIDSession: sessionData.ThreadIDSession,
+		exchange:        configData.ExchangeName,
+		fiatSymbol:      sessionData.SymbolFiat,
+		fiatFunds:       sessionData.SymbolFiatFunds,
+	}
+
+	return nil
+
+}
+
+// UpdateSession Update existing session
+func (s *Storage) UpdateSession(
+	configData *types.Config,
+	sessionData *types.Session) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if ss, ok := s.sessions[sessionData.ThreadID]; ok {
+
+		ss.fiatFunds = sessionData.SymbolFiatFunds
+
+	}
+
+	return nil
+
+}
+
+// DeleteSession Delete session
+func (s *Storage) DeleteSession(
+	sessionData *types.Session) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.sessions, sessionData.ThreadID)
+
+	return nil
+
+}
+
+// SaveThreadTransaction Save Thread cycle to storage
+func (s *Storage) SaveThreadTransaction(
+	sessionData *types.Session,
+	orderID int64,
+	cumulativeQuoteQuantity decimal.Decimal,
+	price decimal.Decimal,
+	executedQuantity decimal.Decimal) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.threads = append(s.threads, &thread{
+		threadID:                sessionData.ThreadID,
+		threadIDSession:         sessionData.ThreadIDSession,
+		orderID:                 orderID,
+		cumulativeQuoteQuantity: cumulativeQuoteQuantity,
+		price:                   price,
+		executedQuantity:        executedQuantity,
+		highPrice:               price,
+	})
+
+	return nil
+
+}
+
+// DeleteThreadTransactionByOrderID Remove thread transaction for the order
+func (s *Storage) DeleteThreadTransactionByOrderID(
+	sessionData *types.Session,
+	orderID int) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	threads := s.threads[:0]
+
+	for _, t := range s.threads {
+
+		if t.orderID != int64(orderID) {
+
+			threads = append(threads, t)
+
+		}
+
+	}
+
+	s.threads = threads
+
+	return nil
+
+}
+
+// UpdateThreadTransaction Update the quantity, amount and price of the thread transaction for the order
+func (s *Storage) UpdateThreadTransaction(
+	sessionData *types.Session,
+	orderID int,
+	cumulativeQuoteQuantity decimal.Decimal,
+	price decimal.Decimal,
+	executedQuantity decimal.Decimal) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, t := range s.threads {
+
+		if t.orderID == int64(orderID) {
+
+			t.cumulativeQuoteQuantity = cumulativeQuoteQuantity
+			t.price = price
+			t.executedQuantity = executedQuantity
+
+		}
+
+	}
+
+	return nil
+
+}
+
+// GetThreadTransactionByOrderID Return the price, quantity and amount of the thread transaction for the order, zero when none
+func (s *Storage) GetThreadTransactionByOrderID(
+	sessionData *types.Session,
+	orderID int) (price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, t := range s.threads {
+
+		if t.orderID == int64(orderID) {
+
+			return t.price, t.executedQuantity, t.cumulativeQuoteQuantity, nil
+
+		}
+
+	}
+
+	return decimal.Zero, decimal.Zero, decimal.Zero, nil
+
+}
+
+// GetThreadTransactionCount Get Thread count
+func (s *Storage) GetThreadTransactionCount(
+	sessionData *types.Session) (count int, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, t := range s.threads {
+
+		if t.threadID == sessionData.ThreadID {
+
+			count++
+
+		}
+
+	}
+
+	return count, nil
+
+}
+
+// GetLastOrderTransactionPrice Get price for last transaction the ThreadID
+func (s *Storage) GetLastOrderTransactionPrice(
+	sessionData *types.Session,
+	side string) (price decimal.Decimal, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, o := range s.lastOrders(sessionData.ThreadID) {
+
+		if o.Side == side && o.Status != "CANCELED" {
+
+			return o.Price, nil
+
+		}
+
+	}
+
+	return decimal.Zero, nil
+
+}
+
+// GetLastOrderTransactionSide Get Side for last transaction the ThreadID
+func (s *Storage) GetLastOrderTransactionSide(
+	sessionData *types.Session) (side string, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, o := range s.lastOrders(sessionData.ThreadID) {
+
+		if o.Status == "FILLED" {
+
+			return o.Side, nil
+
+		}
+
+	}
+
+	return "", nil
+
+}
+
+// GetOrderTransactionSideLastTwo Get Side for the last two transactions the ThreadID
+func (s *Storage) GetOrderTransactionSideLastTwo(
+	sessionData *types.Session) (side1 string, side2 string, err error) {
+
+	var sides []string
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, o := range s.lastOrders(sessionData.ThreadID) {
+
+		if o.Status != "CANCELED" {
+
+			sides = append(sides, o.Side)
+
+		}
+
+	}
+
+	/* The stored procedure cross joins both sides and returns no row if either is missing */
+	if len(sides) < 2 {
+
+		return "", "", nil
+
+	}
+
+	return sides[0], sides[1], nil
+
+}
+
+// GetOrderSymbol Get symbol for ThreadID
+func (s *Storage) GetOrderSymbol(
+	sessionData *types.Session) (symbol string, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if orders := s.lastOrders(sessionData.ThreadID); len(orders) > 0 {
+
+		return orders[0].Symbol, nil
+
+	}
+
+	return "", nil
+
+}
+
+// GetThreadTransactionDistinct Get Thread Distinct
+func (s *Storage) GetThreadTransactionDistinct(
+	sessionData *types.Session) (threadID string, threadIDSession string, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, t := range s.threads {
+
+		if functions.LockThreadID(t.threadID) { /* Create lock for threadID */
+
+			return t.threadID, t.threadIDSession, nil
+
+		}
+
+	}
+
+	return "", "", nil
+
+}
+
+// GetOrderTransactionPending Get 1 order with pending FILLED status
+func (s *Storage) GetOrderTransactionPending(
+	sessionData *types.Session) (orderID int64, symbol string, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	orders := s.lastOrders(sessionData.ThreadID)
+
+	for key := len(orders) - 1; key >= 0; key-- {
+
+		if orders[key].Status != "FILLED" &&
+			orders[key].Status != "CANCELED" &&
+			orders[key].Status != "EXPIRED" &&
+			orders[key].Status != "REJECTED" &&
+			orders[key].Status != "" {
+
+			return int64(orders[key].OrderID), orders[key].Symbol, nil
+
+		}
+
+	}
+
+	return 0, "", nil
+
+}
+
+// GetOrderByOrderID Return an order. Status is empty when the order is not found
+func (s *Storage) GetOrderByOrderID(
+	sessionData *types.Session,
+	orderID int64) (order types.Order, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, o := range s.orders {
+
+		if int64(o.OrderID) == orderID {
+
+			return o.Order, nil
+
+		}
+
+	}
+
+	return types.Order{}, nil
+
+}
+
+// GetOrdersBySymbol Return the orders for the session symbol since transactTime
+func (s *Storage) GetOrdersBySymbol(
+	sessionData *types.Session,
+	transactTime int64) (orders []types.Order, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, o := range s.orders {
+
+		if o.Symbol == sessionData.Symbol &&
+			o.TransactTime >= transactTime {
+
+			orders = append(orders, o.Order)
+
+		}
+
+	}
+
+	return orders, nil
+
+}
+
+// GetThreadTransactionByPrice Return the lowest price thread transaction below the market price
+func (s *Storage) GetThreadTransactionByPrice(
+	marketData *types.Market,
+	sessionData *types.Session) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if t := s.lowestThread(sessionData.ThreadID, marketData.Price); t != nil {
+
+		return int(t.orderID), t.price, t.executedQuantity, t.cumulativeQuoteQuantity, s.transactTime(t.orderID), nil
+
+	}
+
+	return 0, decimal.Zero, decimal.Zero, decimal.Zero, 0, nil
+
+}
+
+// GetThreadTransactionByStopLoss Return the highest price thread transaction the market price has fallen stopLoss below
+func (s *Storage) GetThreadTransactionByStopLoss(
+	marketData *types.Market,
+	sessionData *types.Session,
+	stopLoss float64) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var highest *thread
+
+	for _, t := range s.threads {
+
+		if t.threadID == sessionData.ThreadID &&
+			t.price.InexactFloat64()*(1-stopLoss) >= marketData.Price &&
+			(highest == nil || t.price.GreaterThan(highest.price)) {
+
+			highest = t
+
+		}
+
+	}
+
+	if highest != nil {
+
+		return int(highest.orderID), highest.price, highest.executedQuantity, highest.cumulativeQuoteQuantity, s.transactTime(highest.orderID), nil
+
+	}
+
+	return 0, decimal.Zero, decimal.Zero, decimal.Zero, 0, nil
+
+}
+
+// GetThreadTransactionByTrailingStop Return the lowest price thread transaction with a high-water mark above price plus trailingStop,
+// and the market price fallen trailingStop below the high-water mark
+func (s *Storage) GetThreadTransactionByTrailingStop(
+	marketData *types.Market,
+	sessionData *types.Session,
+	trailingStop float64) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var lowest *thread
+
+	for _, t := range s.threads {
+
+		if t.threadID == sessionData.ThreadID &&
+			t.highPrice.InexactFloat64() >= t.price.InexactFloat64()*(1+trailingStop) &&
+			t.highPrice.InexactFloat64()*(1-trailingStop) >= marketData.Price &&
+			(lowest == nil || t.price.LessThan(lowest.price)) {
+
+			lowest = t
+
+		}
+
+	}
+
+	if lowest != nil {
+
+		return int(lowest.orderID), lowest.price, lowest.executedQuantity, lowest.cumulativeQuoteQuantity, s.transactTime(lowest.orderID), nil
+
+	}
+
+	return 0, decimal.Zero, decimal.Zero, decimal.Zero, 0, nil
+
+}
+
+// UpdateThreadTransactionHighPrice Raise the high-water mark of thread transactions to the market price
+func (s *Storage) UpdateThreadTransactionHighPrice(
+	marketData *types.Market,
+	sessionData *types.Session) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, t := range s.threads {
+
+		if t.threadID == sessionData.ThreadID &&
+			t.highPrice.InexactFloat64() < marketData.Price {
+
+			t.highPrice = decimal.NewFromFloat(marketData.Price)
+
+		}
+
+	}
+
+	return nil
+
+}
+
+// GetThreadLastTransaction Return the last 'active' BUY transaction for a Thread
+func (s *Storage) GetThreadLastTransaction(
+	sessionData *types.Session) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if t := s.lowestThread(sessionData.ThreadID, math.Inf(1)); t != nil {
+
+		return int(t.orderID), t.price, t.executedQuantity, t.cumulativeQuoteQuantity, s.transactTime(t.orderID), nil
+
+	}
+
+	return 0, decimal.Zero, decimal.Zero, decimal.Zero, 0, nil
+
+}
+
+// GetThreadTransactiontUpmarketPriceCount Count thread transactions with price lower than price
+func (s *Storage) GetThreadTransactiontUpmarketPriceCount(
+	sessionData *types.Session,
+	price float64) (count int, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, t := range s.threads {
+
+		if t.threadID == sessionData.ThreadID && t.price.InexactFloat64() < price {
+
+			count++
+
+		}
+
+	}
+
+	return count, nil
+
+}
+
+// GetOrderTransactionCount Retrieve FILLED transaction count by Side in the last 60 minutes
+func (s *Storage) GetOrderTransactionCount(
+	sessionData *types.Session,
+	side string) (count float64, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	/* The stored procedure compares times truncated to the minute */
+	now := functions.Now(sessionData).Truncate(time.Minute)
+	start := now.Add(-60 * time.Minute)
+
+	for _, o := range s.orders {
+
+		transactTime := time.Unix(o.TransactTime/1000, 0).Truncate(time.Minute)
+
+		if o.threadID == sessionData.ThreadID &&
+			o.Side == side &&
+			o.Status == "FILLED" &&
+			!transactTime.Before(start) &&
+			!transactTime.After(now) {
+
+			count++
+
+		}
+
+	}
+
+	return count, nil
+
+}
+
+// GetThreadTransactionByThreadID Retrieve thread transactions ordered by price
+func (s *Storage) GetThreadTransactionByThreadID(
+	sessionData *types.Session) (orders []types.Order, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, t := range s.threads {
+
+		if t.threadID == sessionData.ThreadID {
+
+			orders = append(orders, types.Order{
+				OrderID:                 int(t.orderID),
+				CumulativeQuoteQuantity: t.cumulativeQuoteQuantity.Round(2),
+				Price:                   t.price.Round(3),
+			})
+
+		}
+
+	}
+
+	sort.SliceStable(orders, func(i, j int) bool {
+		return orders[i].Price.LessThan(orders[j].Price)
+	})
+
+	return orders, nil
+
+}
+
+// GetProfitByThreadID Retrieve thread profit
+func (s *Storage) GetProfitByThreadID(
+	sessionData *types.Session) (profit float64, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.profit(sessionData.ThreadID).Round(2).InexactFloat64(), nil
+
+}
+
+// GetProfit Retrieve total profit
+func (s *Storage) GetProfit(
+	sessionData *types.Session) (profit float64, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.profit("").Round(2).InexactFloat64(), nil
+
+}
+
+// GetThreadCount Retrieve Running Thread Count
+func (s *Storage) GetThreadCount(
+	sessionData *types.Session) (count int, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.sessions), nil
+
+}
+
+// GetThreadAmount Retrieve Thread Dollar Amount
+func (s *Storage) GetThreadAmount(
+	sessionData *types.Session) (amount float64, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sum := decimal.Zero
+
+	for _, t := range s.threads {
+
+		sum = sum.Add(t.cumulativeQuoteQuantity)
+
+	}
+
+	return sum.Round(2).InexactFloat64(), nil
+
+}
+
+// GetThreadPositions Retrieve Thread transactions count, quantity and amount by symbol across all threads
+func (s *Storage) GetThreadPositions(
+	sessionData *types.Session) (positions []types.Position, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	symbols := make(map[int64]string)
+
+	for _, o := range s.orders {
+
+		symbols[int64(o.OrderID)] = o.Symbol
+
+	}
+
+	index := make(map[string]int)
+	var quantities, amounts []decimal.Decimal
+
+	for _, t := range s.threads {
+
+		key, ok := index[symbols[t.orderID]]
+
+		if !ok {
+
+			key = len(positions)
+			index[symbols[t.orderID]] = key
+			positions = append(positions, types.Position{Symbol: symbols[t.orderID]})
+			quantities = append(quantities, decimal.Zero)
+			amounts = append(amounts, decimal.Zero)
+
+		}
+
+		positions[key].Count++
+		quantities[key] = quantities[key].Add(t.executedQuantity)
+		amounts[key] = amounts[key].Add(t.cumulativeQuoteQuantity)
+
+	}
+
+	for key := range positions {
+
+		positions[key].Quantity = quantities[key].InexactFloat64()
+		positions[key].Amount = amounts[key].Round(2).InexactFloat64()
+
+	}
+
+	return positions, nil
+
+}
+
+// GetThreadQuantity Retrieve the quantity held in Thread transactions for the ThreadID
+func (s *Storage) GetThreadQuantity(
+	sessionData *types.Session) (quantity float64, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sum := decimal.Zero
+
+	for _, t := range s.threads {
+
+		if t.threadID == sessionData.ThreadID {
+
+			sum = sum.Add(t.executedQuantity)
+
+		}
+
+	}
+
+	return sum.InexactFloat64(), nil
+
+}
+
+// GetOrders Return a copy of the orders saved for the ThreadID in transaction order
+func (s *Storage) GetOrders(
+	sessionData *types.Session) (orders []types.Order) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, o := range s.orders {
+
+		if o.threadID == sessionData.ThreadID {
+
+			orders = append(orders, o.Order)
+
+		}
+
+	}
+
+	return orders
+
+}
+
+/* Return the orders for the ThreadID, most recent TransactTime first. Must be called with mutex locked. */
+func (s *Storage) lastOrders(
+	threadID string) (orders []*order) {
+
+	for key := len(s.orders) - 1; key >= 0; key-- {
+
+		if s.orders[key].threadID == threadID {
+
+			orders = append(orders, s.orders[key])
+
+		}
+
+	}
+
+	sort.SliceStable(orders, func(i, j int) bool {
+		return orders[i].TransactTime > orders[j].TransactTime
+	})
+
+	return orders
+
+}
+
+/* Return the lowest price thread transaction below price. Must be called with mutex locked. */
+func (s *Storage) lowestThread(
+	threadID string,
+	price float64) (lowest *thread) {
+
+	for _, t := range s.threads {
+
+		if t.threadID == threadID &&
+			t.price.InexactFloat64() < price &&
+			(lowest == nil || t.price.LessThan(lowest.price)) {
+
+			lowest = t
+
+		}
+
+	}
+
+	return lowest
+
+}
+
+// AcquireLease Acquire or renew a lease for the ThreadID, unless held by another ThreadID and not expired. The current owner is returned.
+func (s *Storage) AcquireLease(
+	sessionData *types.Session,
+	name string,
+	duration time.Duration) (l types.Lease, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	row, ok := s.leases[name]
+
+	if !ok {
+
+		row = &lease{Lease: types.Lease{Name: name}}
+		s.leases[name] = row
+
+	}
+
+	if row.ThreadID == sessionData.ThreadID || !time.Now().Before(row.expires) {
+
+		if row.ThreadID != sessionData.ThreadID {
+
+			row.Token++
+
+		}
+
+		row.ThreadID = sessionData.ThreadID
+		row.expires = time.Now().Add(duration)
+
+	}
+
+	return row.Lease, nil
+
+}
+
+// CheckLease Check the lease is held by the ThreadID with the fencing token and not expired
+func (s *Storage) CheckLease(
+	sessionData *types.Session,
+	name string,
+	token int64) (held bool, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	row, ok := s.leases[name]
+
+	return ok &&
+		row.ThreadID == sessionData.ThreadID &&
+		row.Token == token &&
+		time.Now().Before(row.expires), nil
+
+}
+
+// ReleaseLease Expire the lease if held by the ThreadID
+func (s *Storage) ReleaseLease(
+	sessionData *types.Session,
+	name string) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if row, ok := s.leases[name]; ok && row.ThreadID == sessionData.ThreadID {
+
+		row.expires = time.Now()
+
+	}
+
+	return nil
+
+}
+
+// SaveKline Save a final kline for the session symbol, replacing the kline with the same interval and open time
+func (s *Storage) SaveKline(
+	sessionData *types.Session,
+	kline types.WsKline) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := sessionData.Symbol + " " + kline.Interval
+	klines := s.klines[key]
+
+	index := sort.Search(len(klines), func(i int) bool {
+		return klines[i].StartTime >= kline.StartTime
+	})
+
+	kline.Symbol = sessionData.Symbol
+	kline.IsFinal = true
+
+	if index < len(klines) && klines[index].StartTime == kline.StartTime {
+
+		klines[index] = kline
+		return nil
+
+	}
+
+	klines = append(klines, types.WsKline{})
+	copy(klines[index+1:], klines[index:])
+	klines[index] = kline
+
+	s.klines[key] = klines
+
+	return nil
+
+}
+
+// GetKlines Retrieve the klines saved for the session symbol and interval, opened from startTime to endTime and ordered by open time
+func (s *Storage) GetKlines(
+	sessionData *types.Session,
+	interval string,
+	startTime int64,
+	endTime int64) (klines []types.WsKline, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, kline := range s.klines[sessionData.Symbol+" "+interval] {
+
+		if kline.StartTime >= startTime && kline.StartTime <= endTime {
+
+			klines = append(klines, kline)
+
+		}
+
+	}
+
+	return klines, nil
+
+}
+
+// SaveLot Save the lot of a filled BUY order to the ledger
+func (s *Storage) SaveLot(
+	sessionData *types.Session,
+	l types.Lot) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, row := range s.lots {
+
+		if row.OrderID == l.OrderID {
+
+			return errors.New("Duplicate entry for key 'lots.PRIMARY'")
+
+		}
+
+	}
+
+	l.Remaining = l.Quantity
+	s.lots = append(s.lots, &lot{Lot: l, threadID: sessionData.ThreadID})
+
+	return nil
+
+}
+
+// GetOpenLots Retrieve the lots of the ThreadID with quantity not closed, oldest first
+func (s *Storage) GetOpenLots(
+	sessionData *types.Session) (lots []types.Lot, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, row := range s.lots {
+
+		if row.threadID == sessionData.ThreadID && row.Remaining.IsPositive() {
+
+			lots = append(lots, row.Lot)
+
+		}
+
+	}
+
+	sort.SliceStable(lots, func(i, j int) bool {
+		if lots[i].OpenTime != lots[j].OpenTime {
+			return lots[i].OpenTime < lots[j].OpenTime
+		}
+		return lots[i].OrderID < lots[j].OrderID
+	})
+
+	return lots, nil
+
+}
+
+// SaveFill Save the fill of a SELL order to the ledger and close the quantity of its lot
+func (s *Storage) SaveFill(
+	sessionData *types.Session,
+	f types.Fill) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, row := range s.fills {
+
+		if row.SellOrderID == f.SellOrderID && row.LotOrderID == f.LotOrderID {
+
+			return errors.New("Duplicate entry for key 'fills.SellOrderID_LotOrderID'")
+
+		}
+
+	}
+
+	s.fills = append(s.fills, &fill{Fill: f, threadID: sessionData.ThreadID})
+
+	for _, row := range s.lots {
+
+		if row.OrderID == f.LotOrderID {
+
+			row.Remaining = decimal.Max(row.Remaining.Sub(f.Quantity), decimal.Zero)
+
+		}
+
+	}
+
+	return nil
+
+}
+
+// GetTrades Retrieve the orders filled from startTime to endTime for the ThreadIDs, all when empty, with the cost and profit of the ledger fills of SELL orders
+func (s *Storage) GetTrades(
+	sessionData *types.Session,
+	startTime int64,
+	endTime int64,
+	threadIDs []string) (trades []types.Trade, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	selected := make(map[string]bool)
+
+	for _, threadID := range threadIDs {
+
+		selected[threadID] = true
+
+	}
+
+	for _, o := range s.orders {
+
+		if !o.ExecutedQuantity.IsPositive() ||
+			o.TransactTime < startTime ||
+			o.TransactTime > endTime ||
+			(len(selected) > 0 && !selected[o.threadID]) {
+
+			continue
+
+		}
+
+		trade := types.Trade{
+			OrderID:          o.OrderID,
+			ThreadID:         o.threadID,
+			Symbol:           o.Symbol,
+			Side:             o.Side,
+			Quantity:         o.ExecutedQuantity,
+			QuoteQuantity:    o.CumulativeQuoteQuantity,
+			CommissionAmount: o.CommissionAmount,
+			CommissionAsset:  o.CommissionAsset,
+			Commission:       o.Commission,
+			TransactTime:     o.TransactTime,
+		}
+
+		for _, f := range s.fills {
+
+			if f.SellOrderID == o.OrderID {
+
+				trade.Cost = decimal.NewNullDecimal(trade.Cost.Decimal.Add(f.Cost))
+				trade.Profit = decimal.NewNullDecimal(trade.Profit.Decimal.Add(f.Profit))
+
+			}
+
+		}
+
+		trades = append(trades, trade)
+
+	}
+
+	sort.SliceStable(trades, func(i, j int) bool {
+		if trades[i].TransactTime != trades[j].TransactTime {
+			return trades[i].TransactTime < trades[j].TransactTime
+		}
+		return trades[i].OrderID < trades[j].OrderID
+	})
+
+	return trades, nil
+
+}
+
+// GetRisk Retrieve the risk circuit breaker state of the account, shared by the threads using the storage. Day is 0 when no state is saved
+func (s *Storage) GetRisk(
+	sessionData *types.Session) (risk types.Risk, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.risk, nil
+
+}
+
+// SaveRisk Save the risk circuit breaker state of the account
+func (s *Storage) SaveRisk(
+	sessionData *types.Session,
+	risk types.Risk) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.risk = risk
+
+	return nil
+
+}
+
+// DeleteRisk Delete the risk circuit breaker state of the account
+func (s *Storage) DeleteRisk(
+	sessionData *types.Session) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.risk = types.Risk{}
+
+	return nil
+
+}
+
+/* Return the TransactTime for the order. Must be called with mutex locked. */
+func (s *Storage) transactTime(
+	orderID int64) int64 {
+
+	for _, o := range s.orders {
+
+		if int64(o.OrderID) == orderID {
+
+			return o.TransactTime
+
+		}
+
+	}
+
+	return 0
+
+}
+
+/* Sum the profit of the ledger fills, for all threads when threadID is empty. Must be called with mutex locked. */
+func (s *Storage) profit(
+	threadID string) (profit decimal.Decimal) {
+
+	for _, f := range s.fills {
+
+		if threadID == "" || f.threadID == threadID {
+
+			profit = profit.Add(f.Profit)
+
+		}
+
+	}
+
+	return profit
+
+}
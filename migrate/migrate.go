@@ -0,0 +1,302 @@
+package migrate
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* Applied migrations, created on the first migration */
+const versionTable = `CREATE TABLE IF NOT EXISTS schema_version (
+	Version int NOT NULL PRIMARY KEY,
+	Name varchar(100) NOT NULL,
+	Applied bigint NOT NULL
+)`
+
+/* Statements executed of the migrations not completed, created on the first migration */
+const progressTable = `CREATE TABLE IF NOT EXISTS schema_progress (
+	Version int NOT NULL PRIMARY KEY,
+	Statements int NOT NULL
+)`
+
+// Migration define a numbered schema change, read from a file named NNNN_name.sql
+type Migration struct {
+	Version    int
+	Name       string
+	Statements []string
+}
+
+// Load read the migrations of the directory, ordered by version.
+// Statements end with ; or with the delimiter set by a DELIMITER line, as in the mysql client.
+func Load(
+	fsys fs.FS,
+	dir string) (migrations []Migration, err error) {
+
+	var entries []fs.DirEntry
+
+	if entries, err = fs.ReadDir(fsys, dir); err != nil {
+
+		return nil, err
+
+	}
+
+	for _, entry := range entries {
+
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+
+			continue
+
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		separator := strings.Index(name, "_")
+
+		if separator < 0 {
+
+			return nil, fmt.Errorf("migration %s not named NNNN_name.sql", entry.Name())
+
+		}
+
+		migration := Migration{Name: name[separator+1:]}
+
+		if migration.Version, err = strconv.Atoi(name[:separator]); err != nil || migration.Version <= 0 {
+
+			return nil, fmt.Errorf("migration %s not named NNNN_name.sql", entry.Name())
+
+		}
+
+		var file []byte
+
+		if file, err = fs.ReadFile(fsys, path.Join(dir, entry.Name())); err != nil {
+
+			return nil, err
+
+		}
+
+		migration.Statements = split(string(file))
+		migrations = append(migrations, migration)
+
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	for key := 1; key < len(migrations); key++ {
+
+		if migrations[key].Version == migrations[key-1].Version {
+
+			return nil, fmt.Errorf("migration version %d is duplicated", migrations[key].Version)
+
+		}
+
+	}
+
+	return migrations, nil
+
+}
+
+// Version Retrieve the schema version of the database, 0 when no migration was applied
+func Version(
+	db *sql.DB) (version int, err error) {
+
+	if _, err = db.Exec(versionTable); err != nil {
+
+		return 0, err
+
+	}
+
+	if _, err = db.Exec(progressTable); err != nil {
+
+		return 0, err
+
+	}
+
+	err = db.QueryRow("SELECT COALESCE(MAX(Version), 0) FROM schema_version").Scan(&version)
+
+	return version, err
+
+}
+
+// Apply apply the migrations newer than the schema version of the database, in order.
+// A database with a schema version newer than the last migration is refused, as it was migrated by a newer release.
+func Apply(
+	db *sql.DB,
+	migrations []Migration) (from int, to int, err error) {
+
+	if from, err = Version(db); err != nil {
+
+		return 0, 0, err
+
+	}
+
+	latest := 0
+
+	if len(migrations) > 0 {
+
+		latest = migrations[len(migrations)-1].Version
+
+	}
+
+	if from > latest {
+
+		return from, from, fmt.Errorf("database schema version %d is newer than version %d supported by this release", from, latest)
+
+	}
+
+	to = from
+
+	for _, migration := range migrations {
+
+		if migration.Version <= from {
+
+			continue
+
+		}
+
+		if err = apply(db, migration); err != nil {
+
+			return from, to, fmt.Errorf("migration %04d_%s: %v", migration.Version, migration.Name, err)
+
+		}
+
+		to = migration.Version
+
+	}
+
+	return from, to, nil
+
+}
+
+/* Execute the statements of a migration and record its version. MySQL commits schema changes as they execute, so each statement is recorded in schema_progress as it completes, and a failed migration resumes from the failed statement once its cause is fixed. */
+func apply(
+	db *sql.DB,
+	migration Migration) (err error) {
+
+	var done int
+	var tx *sql.Tx
+
+	if err = db.QueryRow("SELECT COALESCE(MAX(Statements), 0) FROM schema_progress WHERE Version = ?", migration.Version).Scan(&done); err != nil {
+
+		return err
+
+	}
+
+	for key := done; key < len(migration.Statements); key++ {
+
+		if tx, err = db.Begin(); err != nil {
+
+			return err
+
+		}
+
+		if _, err = tx.Exec(migration.Statements[key]); err != nil {
+
+			tx.Rollback()
+			return fmt.Errorf("statement %d: %v", key+1, err)
+
+		}
+
+		if _, err = tx.Exec("REPLACE INTO schema_progress (Version, Statements) VALUES (?, ?)", migration.Version, key+1); err != nil {
+
+			tx.Rollback()
+			return err
+
+		}
+
+		if err = tx.Commit(); err != nil {
+
+			return err
+
+		}
+
+	}
+
+	if tx, err = db.Begin(); err != nil {
+
+		return err
+
+	}
+
+	if _, err = tx.Exec("INSERT INTO schema_version (Version, Name, Applied) VALUES (?, ?, ?)",
+		migration.Version,
+		migration.Name,
+		time.Now().UnixNano()/int64(time.Millisecond)); err != nil {
+
+		tx.Rollback()
+		return err
+
+	}
+
+	if _, err = tx.Exec("DELETE FROM schema_progress WHERE Version = ?", migration.Version); err != nil {
+
+		tx.Rollback()
+		return err
+
+	}
+
+	return tx.Commit()
+
+}
+
+/* Split a migration into statements, skipping comment lines */
+func split(
+	file string) (statements []string) {
+
+	delimiter := ";"
+	statement := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(file))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case statement == "" && (trimmed == "" || strings.HasPrefix(trimmed, "--")):
+
+			continue
+
+		case strings.HasPrefix(strings.ToUpper(trimmed), "DELIMITER "):
+
+			delimiter = strings.TrimSpace(trimmed[len("DELIMITER "):])
+			continue
+
+		}
+
+		statement += line + "\n"
+
+		if strings.HasSuffix(trimmed, delimiter) {
+
+			statement = strings.TrimSpace(statement)
+			statement = strings.TrimSpace(strings.TrimSuffix(statement, delimiter))
+
+			if statement != "" {
+
+				statements = append(statements, statement)
+
+			}
+
+			statement = ""
+
+		}
+
+	}
+
+	if statement = strings.TrimSpace(statement); statement != "" {
+
+		statements = append(statements, statement)
+
+	}
+
+	return statements
+
+}
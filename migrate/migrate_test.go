@@ -0,0 +1,254 @@
+package migrate
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSplit(t *testing.T) {
+
+	tests := []struct {
+		name string
+		file string
+		want []string
+	}{
+		{
+			name: "empty",
+			file: "",
+			want: nil,
+		},
+		{
+			name: "statements",
+			file: "CREATE TABLE a (ID int);\nCREATE TABLE b (ID int);\n",
+			want: []string{"CREATE TABLE a (ID int)", "CREATE TABLE b (ID int)"},
+		},
+		{
+			name: "multiline statement",
+			file: "CREATE TABLE a (\n\tID int,\n\tName text\n);\n",
+			want: []string{"CREATE TABLE a (\n\tID int,\n\tName text\n)"},
+		},
+		{
+			name: "comments and blank lines",
+			file: "-- Comment\n\n  -- Indented comment\nALTER TABLE a ADD COLUMN b int;\n\n-- Trailing comment\n",
+			want: []string{"ALTER TABLE a ADD COLUMN b int"},
+		},
+		{
+			name: "last statement without delimiter",
+			file: "DROP TABLE a;\nDROP TABLE b\n",
+			want: []string{"DROP TABLE a", "DROP TABLE b"},
+		},
+		{
+			name: "empty statements",
+			file: ";\nDROP TABLE a;\n;\n",
+			want: []string{"DROP TABLE a"},
+		},
+		{
+			name: "delimiter",
+			file: "DELIMITER ;;\nCREATE PROCEDURE p()\nBEGIN\nSELECT 1;\nSELECT 2;\nEND ;;\nDELIMITER ;\nDROP TABLE a;\n",
+			want: []string{"CREATE PROCEDURE p()\nBEGIN\nSELECT 1;\nSELECT 2;\nEND", "DROP TABLE a"},
+		},
+		{
+			name: "lowercase delimiter",
+			file: "delimiter $$\nCREATE TRIGGER t BEGIN SELECT 1; END$$\n",
+			want: []string{"CREATE TRIGGER t BEGIN SELECT 1; END"},
+		},
+	}
+
+	for _, test := range tests {
+
+		if got := split(test.file); !reflect.DeepEqual(got, test.want) {
+
+			t.Errorf("%s: split() = %q, want %q", test.name, got, test.want)
+
+		}
+
+	}
+
+}
+
+func TestLoad(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		versions []int
+		err      string
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"migrations/0010_ten.sql":      {Data: []byte("SELECT 10;")},
+				"migrations/0002_two.sql":      {Data: []byte("SELECT 2;")},
+				"migrations/0001_baseline.sql": {Data: []byte("SELECT 1;")},
+				"migrations/README.md":         {Data: []byte("Not a migration")},
+			},
+			versions: []int{1, 2, 10},
+		},
+		{
+			name: "name without version",
+			files: fstest.MapFS{
+				"migrations/baseline.sql": {Data: []byte("SELECT 1;")},
+			},
+			err: "not named NNNN_name.sql",
+		},
+		{
+			name: "version zero",
+			files: fstest.MapFS{
+				"migrations/0000_baseline.sql": {Data: []byte("SELECT 1;")},
+			},
+			err: "not named NNNN_name.sql",
+		},
+		{
+			name: "duplicated version",
+			files: fstest.MapFS{
+				"migrations/0001_baseline.sql": {Data: []byte("SELECT 1;")},
+				"migrations/0001_other.sql":    {Data: []byte("SELECT 1;")},
+			},
+			err: "migration version 1 is duplicated",
+		},
+	}
+
+	for _, test := range tests {
+
+		migrations, err := Load(test.files, "migrations")
+
+		if test.err != "" {
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+
+				t.Errorf("%s: Load() error = %v, want %q", test.name, err, test.err)
+
+			}
+
+			continue
+
+		}
+
+		if err != nil {
+
+			t.Errorf("%s: Load() error = %v", test.name, err)
+			continue
+
+		}
+
+		var versions []int
+
+		for _, migration := range migrations {
+
+			versions = append(versions, migration.Version)
+
+		}
+
+		if !reflect.DeepEqual(versions, test.versions) {
+
+			t.Errorf("%s: Load() versions = %v, want %v", test.name, versions, test.versions)
+
+		}
+
+	}
+
+}
+
+func TestApply(t *testing.T) {
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "migrate.db"))
+
+	if err != nil {
+
+		t.Fatal(err)
+
+	}
+
+	defer db.Close()
+
+	migrations := []Migration{
+		{Version: 1, Name: "baseline", Statements: []string{"CREATE TABLE a (ID int)"}},
+		{Version: 2, Name: "columns", Statements: []string{"ALTER TABLE a ADD COLUMN b int", "ALTER TABLE a ADD COLUMN c int"}},
+	}
+
+	tests := []struct {
+		name       string
+		migrations []Migration
+		from       int
+		to         int
+		err        string
+	}{
+		{
+			name:       "from empty database",
+			migrations: migrations,
+			from:       0,
+			to:         2,
+		},
+		{
+			name:       "up to date",
+			migrations: migrations,
+			from:       2,
+			to:         2,
+		},
+		{
+			name: "failed statement",
+			migrations: append(migrations, Migration{Version: 3, Name: "failed", Statements: []string{
+				"ALTER TABLE a ADD COLUMN d int",
+				"ALTER TABLE missing ADD COLUMN e int",
+			}}),
+			from: 2,
+			to:   2,
+			err:  "migration 0003_failed: statement 2",
+		},
+		{
+			name: "resumed from the failed statement",
+			migrations: append(migrations, Migration{Version: 3, Name: "failed", Statements: []string{
+				"ALTER TABLE a ADD COLUMN d int",
+				"ALTER TABLE a ADD COLUMN e int",
+			}}),
+			from: 2,
+			to:   3,
+		},
+		{
+			name:       "newer database",
+			migrations: migrations,
+			from:       3,
+			to:         3,
+			err:        "newer than version 2",
+		},
+	}
+
+	for _, test := range tests {
+
+		from, to, err := Apply(db, test.migrations)
+
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+
+			t.Errorf("%s: Apply() error = %v, want %q", test.name, err, test.err)
+
+		}
+
+		if from != test.from || to != test.to {
+
+			t.Errorf("%s: Apply() = %d, %d, want %d, %d", test.name, from, to, test.from, test.to)
+
+		}
+
+	}
+
+	var progress int
+
+	if err = db.QueryRow("SELECT COUNT(*) FROM schema_progress").Scan(&progress); err != nil || progress != 0 {
+
+		t.Errorf("schema_progress rows = %d, %v, want none once the migrations completed", progress, err)
+
+	}
+
+	if _, err = db.Exec("SELECT ID, b, c, d, e FROM a"); err != nil {
+
+		t.Errorf("columns of every migration statement: %v", err)
+
+	}
+
+}
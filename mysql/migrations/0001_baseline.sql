@@ -0,0 +1,365 @@
+-- Baseline schema: the tables and stored procedures of the former cryptopump.sql dump.
+-- Tables are created if missing and procedures replaced, so a database loaded from the dump is adopted as is
+-- and upgraded by the following migrations.
+
+CREATE TABLE IF NOT EXISTS `orders` (
+  `ClientOrderId` varchar(45) NOT NULL,
+  `CummulativeQuoteQty` float NOT NULL,
+  `ExecutedQuantity` float NOT NULL,
+  `OrderID` bigint NOT NULL,
+  `Price` float NOT NULL,
+  `Side` varchar(45) NOT NULL,
+  `Status` varchar(45) NOT NULL,
+  `Symbol` varchar(45) NOT NULL,
+  `TransactTime` bigint NOT NULL,
+  `ThreadID` varchar(45) NOT NULL,
+  `ThreadIDSession` varchar(45) NOT NULL,
+  PRIMARY KEY (`OrderID`),
+  UNIQUE KEY `OrderID_UNIQUE` (`OrderID`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `session` (
+  `ID` int NOT NULL AUTO_INCREMENT,
+  `ThreadID` varchar(45) NOT NULL,
+  `ThreadIDSession` varchar(45) NOT NULL,
+  `Exchange` varchar(45) NOT NULL,
+  `FiatSymbol` varchar(45) NOT NULL,
+  `FiatFunds` float NOT NULL,
+  PRIMARY KEY (`ID`),
+  UNIQUE KEY `ThreadID_UNIQUE` (`ThreadID`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `thread` (
+  `ID` int NOT NULL AUTO_INCREMENT,
+  `ThreadID` varchar(45) NOT NULL,
+  `ThreadIDSession` varchar(45) NOT NULL,
+  `OrderID` bigint DEFAULT NULL,
+  `CummulativeQuoteQty` float NOT NULL,
+  `Price` float NOT NULL,
+  `ExecutedQuantity` float NOT NULL,
+  PRIMARY KEY (`ID`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `DeleteSession` ;;
+CREATE PROCEDURE `DeleteSession`(IN in_ThreadID varchar(45))
+BEGIN
+	DECLARE ThreadID varchar(45);
+	SET SQL_SAFE_UPDATES = 0;
+	SET ThreadID = in_ThreadID;
+	DELETE FROM session ft
+	WHERE ft.ThreadID = in_ThreadID;
+	SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `DeleteThreadTransactionAll` ;;
+CREATE PROCEDURE `DeleteThreadTransactionAll`()
+BEGIN
+    SET SQL_SAFE_UPDATES = 0;
+    DELETE FROM thread ft;
+    SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `DeleteThreadTransactionByOrderID` ;;
+CREATE PROCEDURE `DeleteThreadTransactionByOrderID`(IN in_param_OrderID bigint)
+BEGIN
+	DECLARE declared_in_param_OrderID bigint;
+    SET SQL_SAFE_UPDATES = 0;
+    SET declared_in_param_OrderID = in_param_OrderID;
+    DELETE FROM thread ft
+    WHERE ft.OrderID = in_param_OrderID;
+    SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetLastOrderTransactionPrice` ;;
+CREATE PROCEDURE `GetLastOrderTransactionPrice`(IN in_param_ThreadID varchar(45), IN in_param_Side varchar(45))
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(45);
+    DECLARE declared_in_param_Side CHAR(45);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+    SET declared_in_param_Side = in_param_Side;
+    SELECT `orders`.`Price` AS `Price`
+	FROM `orders`
+	WHERE (`orders`.`ThreadID` = declared_in_param_ThreadID
+		AND `orders`.`Side` = declared_in_param_Side AND (`orders`.`Status` <> 'CANCELED'
+		OR `orders`.`Status` IS NULL))
+	ORDER BY from_unixtime((`orders`.`TransactTime` / 1000)) DESC
+	LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetLastOrderTransactionSide` ;;
+CREATE PROCEDURE `GetLastOrderTransactionSide`(IN in_param_ThreadID varchar(45))
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(45);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+	SELECT `orders`.`Side` AS `Side`
+	FROM `orders`
+	WHERE (`orders`.`ThreadID` = declared_in_param_ThreadID
+	   AND `orders`.`Status` = 'FILLED')
+	ORDER BY from_unixtime((`orders`.`TransactTime` / 1000)) DESC
+	LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetOrderSymbol` ;;
+CREATE PROCEDURE `GetOrderSymbol`(IN in_param varchar(45))
+BEGIN
+	DECLARE declared_in_param CHAR(45);
+    SET declared_in_param = in_param;
+	SELECT Symbol from orders ft 
+    WHERE ft.ThreadID = declared_in_param
+    ORDER BY TransactTime DESC LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetOrderTransactionCount` ;;
+CREATE PROCEDURE `GetOrderTransactionCount`(IN in_param_ThreadID varchar(45), IN in_param_Side varchar(45), IN in_param_Minutes int)
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(45);
+    DECLARE declared_in_param_Side CHAR(45);
+    DECLARE declared_in_param_Minutes int;
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+    SET declared_in_param_Side = in_param_Side;
+    SET declared_in_param_Minutes = in_param_Minutes;
+SELECT COALESCE(count(*),0) AS `count`
+FROM `orders`
+WHERE (`orders`.`Side` = declared_in_param_Side
+   AND `orders`.`Status` = 'FILLED' AND str_to_date(date_format(CAST(from_unixtime((`orders`.`TransactTime` / 1000)) AS DATETIME), '%Y-%m-%d %H:%i'), '%Y-%m-%d %H:%i') BETWEEN str_to_date(date_format(CAST(date_add(now(6), INTERVAL declared_in_param_Minutes minute) AS DATETIME), '%Y-%m-%d %H:%i'), '%Y-%m-%d %H:%i') AND str_to_date(date_format(CAST(now(6) AS DATETIME), '%Y-%m-%d %H:%i'), '%Y-%m-%d %H:%i') AND `orders`.`ThreadID` = declared_in_param_ThreadID);
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetOrderTransactionPending` ;;
+CREATE PROCEDURE `GetOrderTransactionPending`(IN in_param_ThreadID varchar(45))
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(45);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+SELECT `orders`.`OrderID` AS `OrderID`, `orders`.`Symbol` AS `Symbol`
+FROM `orders`
+WHERE (`orders`.`ThreadID` = declared_in_param_ThreadID
+   AND (`orders`.`Status` <> 'FILLED'
+    OR `orders`.`Status` IS NULL) AND (`orders`.`Status` <> 'CANCELED' OR `orders`.`Status` IS NULL) AND `orders`.`Status` IS NOT NULL AND (`orders`.`Status` <> '' OR `orders`.`Status` IS NULL))
+ORDER BY from_unixtime((`orders`.`TransactTime` / 1000)) ASC
+LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetOrderTransactionSideLastTwo` ;;
+CREATE PROCEDURE `GetOrderTransactionSideLastTwo`(IN in_param_ThreadID varchar(45))
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(45);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+	SELECT `A`.`Side` AS `Last`, `B`.`Side` AS `SecondLast` FROM (
+	(SELECT `orders`.`Side` AS `Side`
+	FROM `orders`
+	WHERE (`orders`.`ThreadID` = declared_in_param_ThreadID
+	   AND (`orders`.`Status` <> 'CANCELED'
+		OR `orders`.`Status` IS NULL))
+	ORDER BY from_unixtime((`orders`.`TransactTime` / 1000)) DESC
+	LIMIT 1) A
+	INNER JOIN
+	(SELECT `orders`.`Side` AS `Side`
+	FROM `orders`
+	WHERE (`orders`.`ThreadID` = declared_in_param_ThreadID
+	   AND (`orders`.`Status` <> 'CANCELED'
+		OR `orders`.`Status` IS NULL))
+	ORDER BY from_unixtime((`orders`.`TransactTime` / 1000)) DESC
+	LIMIT 1,1) B
+	);
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetOrderTransactionTimeByOrderID` ;;
+CREATE PROCEDURE `GetOrderTransactionTimeByOrderID`(IN in_param_OrderID bigint)
+BEGIN
+	DECLARE declared_in_param_OrderID bigint;
+    SET declared_in_param_OrderID = in_param_OrderID;
+	SELECT `orders`.`TransactTime` AS `TransactTime`
+	FROM `orders`
+	WHERE `orders`.`OrderID` = declared_in_param_OrderID
+	LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetProfit` ;;
+CREATE PROCEDURE `GetProfit`()
+BEGIN
+SELECT 
+    ((SELECT 
+            SUM(`orders`.`CummulativeQuoteQty`) AS `sum`
+        FROM
+            `orders`
+                LEFT JOIN
+            `thread` `Thread` ON `orders`.`OrderID` = `Thread`.`OrderID`
+        WHERE
+            (`Thread`.`OrderID` IS NULL
+                AND `orders`.`Side` = 'SELL')) - (SELECT 
+            SUM(`orders`.`CummulativeQuoteQty`) AS `sum`
+        FROM
+            `orders`
+                LEFT JOIN
+            `thread` `Thread` ON `orders`.`OrderID` = `Thread`.`OrderID`
+        WHERE
+            (`Thread`.`OrderID` IS NULL
+                AND `orders`.`Side` = 'BUY')));
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetProfitByThreadID` ;;
+CREATE PROCEDURE `GetProfitByThreadID`(IN in_param_ThreadID varchar(45))
+BEGIN
+DECLARE declared_in_param_ThreadID CHAR(50);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+SELECT 
+    ((SELECT 
+            SUM(`orders`.`CummulativeQuoteQty`) AS `sum`
+        FROM
+            `orders`
+                LEFT JOIN
+            `thread` `Thread` ON `orders`.`OrderID` = `Thread`.`OrderID`
+        WHERE
+            (`Thread`.`OrderID` IS NULL
+                AND `orders`.`Side` = 'SELL'
+                AND `orders`.`ThreadID` = declared_in_param_ThreadID)) - (SELECT 
+            SUM(`orders`.`CummulativeQuoteQty`) AS `sum`
+        FROM
+            `orders`
+                LEFT JOIN
+            `thread` `Thread` ON `orders`.`OrderID` = `Thread`.`OrderID`
+        WHERE
+            (`Thread`.`OrderID` IS NULL
+                AND `orders`.`Side` = 'BUY'
+                AND `orders`.`ThreadID` = declared_in_param_ThreadID)));
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadCount` ;;
+CREATE PROCEDURE `GetThreadCount`()
+BEGIN
+SELECT 
+    COUNT(DISTINCT `session`.`ThreadID`) AS `count`
+FROM
+    `cryptopump`.`session`;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadLastTransaction` ;;
+CREATE PROCEDURE `GetThreadLastTransaction`(IN in_param_ThreadID varchar(45))
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(50);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+	SELECT `thread`.`CummulativeQuoteQty` AS `CummulativeQuoteQty`, `thread`.`OrderID` AS `OrderID`, `thread`.`Price` AS `Price`, `thread`.`ExecutedQuantity` AS `ExecutedQuantity`, `Orders`.`TransactTime` AS `TransactTime`
+	FROM `thread`
+	LEFT JOIN `orders` `Orders` ON `thread`.`OrderID` = `Orders`.`OrderID`
+	WHERE (`thread`.`ThreadID` = declared_in_param_ThreadID)
+	ORDER BY `thread`.`Price` ASC
+	LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactionAmount` ;;
+CREATE PROCEDURE `GetThreadTransactionAmount`()
+BEGIN
+SELECT 
+    SUM(`thread`.`CummulativeQuoteQty`) AS `sum`
+FROM
+    `cryptopump`.`thread`;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactionByPrice` ;;
+CREATE PROCEDURE `GetThreadTransactionByPrice`(IN in_param_ThreadID varchar(45), IN in_param_Price float)
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(50);
+	DECLARE declared_in_param_Price FLOAT;
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+    SET declared_in_param_Price = in_param_Price;
+	SELECT `thread`.`CummulativeQuoteQty` AS `CummulativeQuoteQty`, `thread`.`OrderID` AS `OrderID`, `thread`.`Price` AS `Price`, `thread`.`ExecutedQuantity` AS `ExecutedQuantity`, `Orders`.`TransactTime` AS `TransactTime`
+	FROM `thread`
+	LEFT JOIN `orders` `Orders` ON `thread`.`OrderID` = `Orders`.`OrderID`
+	WHERE (`thread`.`ThreadID` = declared_in_param_ThreadID
+	   AND `thread`.`Price` < declared_in_param_Price)
+	ORDER BY `thread`.`Price` ASC
+	LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactionByThreadID` ;;
+CREATE PROCEDURE `GetThreadTransactionByThreadID`(IN in_param_ThreadID varchar(45))
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(50);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+SELECT 
+    `thread`.`OrderID` AS `OrderID`,
+    `thread`.`CummulativeQuoteQty` AS `CummulativeQuoteQty`,
+    `thread`.`Price` AS `Price`
+FROM
+    `thread`
+        LEFT JOIN
+    `orders` `Orders` ON `thread`.`OrderID` = `Orders`.`OrderID`
+WHERE
+    `thread`.`ThreadID` = declared_in_param_ThreadID
+ORDER BY `thread`.`Price` ASC;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactionCount` ;;
+CREATE PROCEDURE `GetThreadTransactionCount`(IN in_param varchar(45))
+BEGIN
+	DECLARE declared_in_param CHAR(50);
+    SET declared_in_param = in_param;
+    SELECT count(*) AS count FROM thread ft
+    WHERE ft.ThreadID = declared_in_param;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactionDistinct` ;;
+CREATE PROCEDURE `GetThreadTransactionDistinct`()
+BEGIN
+	SELECT DISTINCT ThreadID, ThreadIDSession FROM thread;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactiontUpmarketPriceCount` ;;
+CREATE PROCEDURE `GetThreadTransactiontUpmarketPriceCount`(IN in_param_ThreadID varchar(45), IN in_param_Price float)
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(45);
+	DECLARE declared_in_param_Price float;
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+    SET declared_in_param_Price = in_param_Price;
+	SELECT count(*) AS `count`
+	FROM `thread`
+	WHERE (`thread`.`Price` < declared_in_param_Price
+	   AND `thread`.`ThreadID` = declared_in_param_ThreadID);
+END ;;
+
+DROP PROCEDURE IF EXISTS `SaveOrder` ;;
+CREATE PROCEDURE `SaveOrder`(ClientOrderId varchar(45), CummulativeQuoteQty float, ExecutedQuantity float, OrderID bigint, Price float, Side varchar(45), Status varchar(45), Symbol varchar(45), TransactTime bigint, ThreadID varchar(45), ThreadIDSession varchar(45))
+BEGIN
+INSERT INTO orders (ClientOrderId, CummulativeQuoteQty, ExecutedQuantity, OrderID, Price, Side, Status, Symbol, TransactTime, ThreadID, ThreadIDSession)
+VALUES (ClientOrderId, CummulativeQuoteQty, ExecutedQuantity, OrderID, Price, Side, Status, Symbol, TransactTime, ThreadID, ThreadIDSession);
+END ;;
+
+DROP PROCEDURE IF EXISTS `SaveSession` ;;
+CREATE PROCEDURE `SaveSession`(in_ThreadID varchar(45), in_ThreadIDSession varchar(45), in_Exchange varchar(45), in_FiatSymbol varchar(45), in_FiatFunds float)
+BEGIN
+INSERT INTO session (ThreadID, ThreadIDSession, Exchange, FiatSymbol, FiatFunds)
+VALUES (in_ThreadID, in_ThreadIDSession, in_Exchange, in_FiatSymbol, in_FiatFunds);
+END ;;
+
+DROP PROCEDURE IF EXISTS `SaveThreadTransaction` ;;
+CREATE PROCEDURE `SaveThreadTransaction`(ThreadID varchar(45), ThreadIDSession varchar(45), OrderID bigint, CummulativeQuoteQty float, Price float, ExecutedQuantity float)
+BEGIN
+INSERT INTO thread (ThreadID, ThreadIDSession, OrderID, CummulativeQuoteQty, Price, ExecutedQuantity)
+VALUES (ThreadID, ThreadIDSession, OrderID, CummulativeQuoteQty, Price, ExecutedQuantity);
+END ;;
+
+DROP PROCEDURE IF EXISTS `UpdateOrder` ;;
+CREATE PROCEDURE `UpdateOrder`(in_OrderID bigint, CummulativeQuoteQty float, ExecutedQuantity float, Price float, Status varchar(45))
+BEGIN
+SET SQL_SAFE_UPDATES = 0;
+UPDATE orders
+SET  CummulativeQuoteQty = CummulativeQuoteQty,
+	ExecutedQuantity = ExecutedQuantity,
+    Price = Price,
+    Status = Status
+WHERE OrderID = in_OrderID;
+SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `UpdateSession` ;;
+CREATE PROCEDURE `UpdateSession`(in_ThreadID varchar(45), in_ThreadIDSession varchar(45), in_Exchange varchar(45), in_FiatSymbol varchar(45), in_FiatFunds float)
+BEGIN
+SET SQL_SAFE_UPDATES = 0;
+	UPDATE `session`
+	SET `session`.`FiatFunds` = in_FiatFunds
+	WHERE `session`.`ThreadID` = in_ThreadID;
+SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DELIMITER ;
@@ -0,0 +1,65 @@
+-- Trailing-Stop high-water mark of the thread transactions.
+
+ALTER TABLE `thread`
+  ADD COLUMN `HighPrice` float NOT NULL DEFAULT '0';
+
+UPDATE `thread` SET `HighPrice` = `Price`;
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactionByStopLoss` ;;
+CREATE PROCEDURE `GetThreadTransactionByStopLoss`(IN in_param_ThreadID varchar(45), IN in_param_Price float, IN in_param_StopLoss float)
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(50);
+	DECLARE declared_in_param_Price FLOAT;
+	DECLARE declared_in_param_StopLoss FLOAT;
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+    SET declared_in_param_Price = in_param_Price;
+    SET declared_in_param_StopLoss = in_param_StopLoss;
+	SELECT `thread`.`CummulativeQuoteQty` AS `CummulativeQuoteQty`, `thread`.`OrderID` AS `OrderID`, `thread`.`Price` AS `Price`, `thread`.`ExecutedQuantity` AS `ExecutedQuantity`, `Orders`.`TransactTime` AS `TransactTime`
+	FROM `thread`
+	LEFT JOIN `orders` `Orders` ON `thread`.`OrderID` = `Orders`.`OrderID`
+	WHERE (`thread`.`ThreadID` = declared_in_param_ThreadID
+	   AND `thread`.`Price` * (1 - declared_in_param_StopLoss) >= declared_in_param_Price)
+	ORDER BY `thread`.`Price` DESC
+	LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactionByTrailingStop` ;;
+CREATE PROCEDURE `GetThreadTransactionByTrailingStop`(IN in_param_ThreadID varchar(45), IN in_param_Price float, IN in_param_TrailingStop float)
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(50);
+	DECLARE declared_in_param_Price FLOAT;
+	DECLARE declared_in_param_TrailingStop FLOAT;
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+    SET declared_in_param_Price = in_param_Price;
+    SET declared_in_param_TrailingStop = in_param_TrailingStop;
+	SELECT `thread`.`CummulativeQuoteQty` AS `CummulativeQuoteQty`, `thread`.`OrderID` AS `OrderID`, `thread`.`Price` AS `Price`, `thread`.`ExecutedQuantity` AS `ExecutedQuantity`, `Orders`.`TransactTime` AS `TransactTime`
+	FROM `thread`
+	LEFT JOIN `orders` `Orders` ON `thread`.`OrderID` = `Orders`.`OrderID`
+	WHERE (`thread`.`ThreadID` = declared_in_param_ThreadID
+	   AND `thread`.`HighPrice` >= `thread`.`Price` * (1 + declared_in_param_TrailingStop)
+	   AND `thread`.`HighPrice` * (1 - declared_in_param_TrailingStop) >= declared_in_param_Price)
+	ORDER BY `thread`.`Price` ASC
+	LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `SaveThreadTransaction` ;;
+CREATE PROCEDURE `SaveThreadTransaction`(ThreadID varchar(45), ThreadIDSession varchar(45), OrderID bigint, CummulativeQuoteQty float, Price float, ExecutedQuantity float)
+BEGIN
+INSERT INTO thread (ThreadID, ThreadIDSession, OrderID, CummulativeQuoteQty, Price, ExecutedQuantity, HighPrice)
+VALUES (ThreadID, ThreadIDSession, OrderID, CummulativeQuoteQty, Price, ExecutedQuantity, Price);
+END ;;
+
+DROP PROCEDURE IF EXISTS `UpdateThreadTransactionHighPrice` ;;
+CREATE PROCEDURE `UpdateThreadTransactionHighPrice`(IN in_param_ThreadID varchar(45), IN in_param_Price float)
+BEGIN
+SET SQL_SAFE_UPDATES = 0;
+UPDATE thread
+SET HighPrice = in_param_Price
+WHERE ThreadID = in_param_ThreadID
+	AND HighPrice < in_param_Price;
+SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DELIMITER ;
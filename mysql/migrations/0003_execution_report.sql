@@ -0,0 +1,24 @@
+-- Commission amount and asset of the trades applied from executionReport events.
+
+ALTER TABLE `orders`
+  ADD COLUMN `CommissionAmount` float NOT NULL DEFAULT '0',
+  ADD COLUMN `CommissionAsset` varchar(45) NOT NULL DEFAULT '',
+  ADD COLUMN `LastTradeID` bigint NOT NULL DEFAULT '0';
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `UpdateOrderCommission` ;;
+CREATE PROCEDURE `UpdateOrderCommission`(IN in_param_ThreadID varchar(45), IN in_param_OrderID bigint, IN in_param_TradeID bigint, IN in_param_CommissionAmount float, IN in_param_CommissionAsset varchar(45))
+BEGIN
+SET SQL_SAFE_UPDATES = 0;
+UPDATE orders
+SET CommissionAmount = CommissionAmount + in_param_CommissionAmount,
+	CommissionAsset = in_param_CommissionAsset,
+	LastTradeID = in_param_TradeID
+WHERE ThreadID = in_param_ThreadID
+	AND OrderID = in_param_OrderID
+	AND LastTradeID < in_param_TradeID;
+SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DELIMITER ;
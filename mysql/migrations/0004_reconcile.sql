@@ -0,0 +1,53 @@
+-- Orders retrieved to reconcile with the exchange. Expired and rejected orders are not pending.
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `GetOrderByOrderID` ;;
+CREATE PROCEDURE `GetOrderByOrderID`(IN in_param_OrderID bigint)
+BEGIN
+SELECT `orders`.`ClientOrderId`,
+    `orders`.`CummulativeQuoteQty`,
+    `orders`.`ExecutedQuantity`,
+    `orders`.`OrderID`,
+    `orders`.`Price`,
+    `orders`.`Side`,
+    `orders`.`Status`,
+    `orders`.`Symbol`,
+    `orders`.`TransactTime`
+FROM `orders`
+WHERE `orders`.`OrderID` = in_param_OrderID;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetOrderTransactionPending` ;;
+CREATE PROCEDURE `GetOrderTransactionPending`(IN in_param_ThreadID varchar(45))
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(45);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+SELECT `orders`.`OrderID` AS `OrderID`, `orders`.`Symbol` AS `Symbol`
+FROM `orders`
+WHERE (`orders`.`ThreadID` = declared_in_param_ThreadID
+   AND (`orders`.`Status` <> 'FILLED'
+    OR `orders`.`Status` IS NULL) AND (`orders`.`Status` <> 'CANCELED' OR `orders`.`Status` IS NULL) AND `orders`.`Status` NOT IN ('EXPIRED', 'REJECTED') AND `orders`.`Status` IS NOT NULL AND (`orders`.`Status` <> '' OR `orders`.`Status` IS NULL))
+ORDER BY from_unixtime((`orders`.`TransactTime` / 1000)) ASC
+LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetOrdersBySymbol` ;;
+CREATE PROCEDURE `GetOrdersBySymbol`(IN in_param_Symbol varchar(45), IN in_param_TransactTime bigint)
+BEGIN
+SELECT `orders`.`ClientOrderId`,
+    `orders`.`CummulativeQuoteQty`,
+    `orders`.`ExecutedQuantity`,
+    `orders`.`OrderID`,
+    `orders`.`Price`,
+    `orders`.`Side`,
+    `orders`.`Status`,
+    `orders`.`Symbol`,
+    `orders`.`TransactTime`
+FROM `orders`
+WHERE `orders`.`Symbol` = in_param_Symbol
+	AND `orders`.`TransactTime` >= in_param_TransactTime
+ORDER BY `orders`.`TransactTime` ASC;
+END ;;
+
+DELIMITER ;
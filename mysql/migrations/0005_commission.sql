@@ -0,0 +1,115 @@
+-- Commission converted to the quote currency at fill time, deducted from profit.
+
+ALTER TABLE `orders`
+  ADD COLUMN `Commission` float NOT NULL DEFAULT '0';
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `GetOrderByOrderID` ;;
+CREATE PROCEDURE `GetOrderByOrderID`(IN in_param_OrderID bigint)
+BEGIN
+SELECT `orders`.`ClientOrderId`,
+    `orders`.`CummulativeQuoteQty`,
+    `orders`.`ExecutedQuantity`,
+    `orders`.`OrderID`,
+    `orders`.`Price`,
+    `orders`.`Side`,
+    `orders`.`Status`,
+    `orders`.`Symbol`,
+    `orders`.`TransactTime`,
+    `orders`.`CommissionAmount`,
+    `orders`.`CommissionAsset`,
+    `orders`.`Commission`
+FROM `orders`
+WHERE `orders`.`OrderID` = in_param_OrderID;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetProfit` ;;
+CREATE PROCEDURE `GetProfit`()
+BEGIN
+SELECT 
+    ((SELECT 
+            SUM(`orders`.`CummulativeQuoteQty`) AS `sum`
+        FROM
+            `orders`
+                LEFT JOIN
+            `thread` `Thread` ON `orders`.`OrderID` = `Thread`.`OrderID`
+        WHERE
+            (`Thread`.`OrderID` IS NULL
+                AND `orders`.`Side` = 'SELL')) - (SELECT 
+            SUM(`orders`.`CummulativeQuoteQty`) AS `sum`
+        FROM
+            `orders`
+                LEFT JOIN
+            `thread` `Thread` ON `orders`.`OrderID` = `Thread`.`OrderID`
+        WHERE
+            (`Thread`.`OrderID` IS NULL
+                AND `orders`.`Side` = 'BUY')) - (SELECT 
+            IFNULL(SUM(`orders`.`Commission`), 0) AS `sum`
+        FROM
+            `orders`
+                LEFT JOIN
+            `thread` `Thread` ON `orders`.`OrderID` = `Thread`.`OrderID`
+        WHERE
+            `Thread`.`OrderID` IS NULL));
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetProfitByThreadID` ;;
+CREATE PROCEDURE `GetProfitByThreadID`(IN in_param_ThreadID varchar(45))
+BEGIN
+DECLARE declared_in_param_ThreadID CHAR(50);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+SELECT 
+    ((SELECT 
+            SUM(`orders`.`CummulativeQuoteQty`) AS `sum`
+        FROM
+            `orders`
+                LEFT JOIN
+            `thread` `Thread` ON `orders`.`OrderID` = `Thread`.`OrderID`
+        WHERE
+            (`Thread`.`OrderID` IS NULL
+                AND `orders`.`Side` = 'SELL'
+                AND `orders`.`ThreadID` = declared_in_param_ThreadID)) - (SELECT 
+            SUM(`orders`.`CummulativeQuoteQty`) AS `sum`
+        FROM
+            `orders`
+                LEFT JOIN
+            `thread` `Thread` ON `orders`.`OrderID` = `Thread`.`OrderID`
+        WHERE
+            (`Thread`.`OrderID` IS NULL
+                AND `orders`.`Side` = 'BUY'
+                AND `orders`.`ThreadID` = declared_in_param_ThreadID)) - (SELECT 
+            IFNULL(SUM(`orders`.`Commission`), 0) AS `sum`
+        FROM
+            `orders`
+                LEFT JOIN
+            `thread` `Thread` ON `orders`.`OrderID` = `Thread`.`OrderID`
+        WHERE
+            (`Thread`.`OrderID` IS NULL
+                AND `orders`.`ThreadID` = declared_in_param_ThreadID)));
+END ;;
+
+DROP PROCEDURE IF EXISTS `SaveOrder` ;;
+CREATE PROCEDURE `SaveOrder`(ClientOrderId varchar(45), CummulativeQuoteQty float, ExecutedQuantity float, OrderID bigint, Price float, Side varchar(45), Status varchar(45), Symbol varchar(45), TransactTime bigint, ThreadID varchar(45), ThreadIDSession varchar(45), CommissionAmount float, CommissionAsset varchar(45), Commission float)
+BEGIN
+INSERT INTO orders (ClientOrderId, CummulativeQuoteQty, ExecutedQuantity, OrderID, Price, Side, Status, Symbol, TransactTime, ThreadID, ThreadIDSession, CommissionAmount, CommissionAsset, Commission)
+VALUES (ClientOrderId, CummulativeQuoteQty, ExecutedQuantity, OrderID, Price, Side, Status, Symbol, TransactTime, ThreadID, ThreadIDSession, CommissionAmount, CommissionAsset, Commission);
+END ;;
+
+DROP PROCEDURE IF EXISTS `UpdateOrderCommission` ;;
+CREATE PROCEDURE `UpdateOrderCommission`(IN in_param_ThreadID varchar(45), IN in_param_OrderID bigint, IN in_param_TradeID bigint, IN in_param_CommissionAmount float, IN in_param_CommissionAsset varchar(45), IN in_param_Commission float)
+BEGIN
+SET SQL_SAFE_UPDATES = 0;
+UPDATE orders
+SET CommissionAmount = CommissionAmount + in_param_CommissionAmount,
+	CommissionAsset = in_param_CommissionAsset,
+	Commission = Commission + in_param_Commission,
+	LastTradeID = in_param_TradeID
+WHERE ThreadID = in_param_ThreadID
+	AND OrderID = in_param_OrderID
+	AND Status IN ('NEW', 'PARTIALLY_FILLED')
+	AND LastTradeID < in_param_TradeID;
+SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DELIMITER ;
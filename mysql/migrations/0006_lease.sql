@@ -0,0 +1,45 @@
+-- Lease electing the Master Node, with a fencing token incremented on each change of master.
+
+CREATE TABLE IF NOT EXISTS `lease` (
+  `Name` varchar(45) NOT NULL,
+  `ThreadID` varchar(45) NOT NULL,
+  `Token` bigint NOT NULL,
+  `Expires` datetime(3) NOT NULL,
+  PRIMARY KEY (`Name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `AcquireLease` ;;
+CREATE PROCEDURE `AcquireLease`(IN in_param_Name varchar(45), IN in_param_ThreadID varchar(45), IN in_param_Duration int)
+BEGIN
+INSERT IGNORE INTO lease (Name, ThreadID, Token, Expires)
+VALUES (in_param_Name, '', 0, NOW(3));
+UPDATE lease
+SET Token = IF(ThreadID = in_param_ThreadID, Token, Token + 1),
+ThreadID = in_param_ThreadID,
+Expires = NOW(3) + INTERVAL in_param_Duration SECOND
+WHERE Name = in_param_Name
+AND (ThreadID = in_param_ThreadID OR Expires <= NOW(3));
+SELECT ThreadID, Token FROM lease WHERE Name = in_param_Name;
+END ;;
+
+DROP PROCEDURE IF EXISTS `CheckLease` ;;
+CREATE PROCEDURE `CheckLease`(IN in_param_Name varchar(45), IN in_param_ThreadID varchar(45), IN in_param_Token bigint)
+BEGIN
+SELECT COUNT(*) FROM lease
+WHERE Name = in_param_Name
+AND ThreadID = in_param_ThreadID
+AND Token = in_param_Token
+AND Expires > NOW(3);
+END ;;
+
+DROP PROCEDURE IF EXISTS `ReleaseLease` ;;
+CREATE PROCEDURE `ReleaseLease`(IN in_param_Name varchar(45), IN in_param_ThreadID varchar(45))
+BEGIN
+UPDATE lease SET Expires = NOW(3)
+WHERE Name = in_param_Name
+AND ThreadID = in_param_ThreadID;
+END ;;
+
+DELIMITER ;
@@ -0,0 +1,20 @@
+-- Thread transactions held by symbol across all threads, for the risk limits.
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactionPositions` ;;
+CREATE PROCEDURE `GetThreadTransactionPositions`()
+BEGIN
+SELECT 
+    IFNULL(`Orders`.`Symbol`, '') AS `Symbol`,
+    COUNT(`thread`.`ID`) AS `Count`,
+    SUM(`thread`.`ExecutedQuantity`) AS `ExecutedQuantity`,
+    SUM(`thread`.`CummulativeQuoteQty`) AS `CummulativeQuoteQty`
+FROM
+    `thread`
+        LEFT JOIN
+    `orders` `Orders` ON `thread`.`OrderID` = `Orders`.`OrderID`
+GROUP BY `Orders`.`Symbol`;
+END ;;
+
+DELIMITER ;
@@ -0,0 +1,53 @@
+-- Final klines by symbol, kline interval and open time.
+
+CREATE TABLE IF NOT EXISTS `klines` (
+  `Symbol` varchar(45) NOT NULL,
+  `Interval` varchar(10) NOT NULL,
+  `OpenTime` bigint NOT NULL,
+  `CloseTime` bigint NOT NULL,
+  `Open` decimal(30,8) NOT NULL,
+  `High` decimal(30,8) NOT NULL,
+  `Low` decimal(30,8) NOT NULL,
+  `Close` decimal(30,8) NOT NULL,
+  `Volume` decimal(30,8) NOT NULL,
+  `QuoteVolume` decimal(30,8) NOT NULL,
+  `ActiveBuyVolume` decimal(30,8) NOT NULL,
+  `ActiveBuyQuoteVolume` decimal(30,8) NOT NULL,
+  PRIMARY KEY (`Symbol`,`Interval`,`OpenTime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `GetKlines` ;;
+CREATE PROCEDURE `GetKlines`(IN in_param_Symbol varchar(45), IN in_param_Interval varchar(10), IN in_param_StartTime bigint, IN in_param_EndTime bigint)
+BEGIN
+SELECT 
+    `klines`.`OpenTime` AS `OpenTime`,
+    `klines`.`CloseTime` AS `CloseTime`,
+    `klines`.`Open` AS `Open`,
+    `klines`.`High` AS `High`,
+    `klines`.`Low` AS `Low`,
+    `klines`.`Close` AS `Close`,
+    `klines`.`Volume` AS `Volume`,
+    `klines`.`QuoteVolume` AS `QuoteVolume`,
+    `klines`.`ActiveBuyVolume` AS `ActiveBuyVolume`,
+    `klines`.`ActiveBuyQuoteVolume` AS `ActiveBuyQuoteVolume`
+FROM
+    `klines`
+WHERE
+    `klines`.`Symbol` = in_param_Symbol
+        AND `klines`.`Interval` = in_param_Interval
+        AND `klines`.`OpenTime` >= in_param_StartTime
+        AND `klines`.`OpenTime` <= in_param_EndTime
+ORDER BY `klines`.`OpenTime` ASC;
+END ;;
+
+DROP PROCEDURE IF EXISTS `SaveKline` ;;
+CREATE PROCEDURE `SaveKline`(in_Symbol varchar(45), in_Interval varchar(10), in_OpenTime bigint, in_CloseTime bigint, in_Open decimal(30,8), in_High decimal(30,8), in_Low decimal(30,8), in_Close decimal(30,8), in_Volume decimal(30,8), in_QuoteVolume decimal(30,8), in_ActiveBuyVolume decimal(30,8), in_ActiveBuyQuoteVolume decimal(30,8))
+BEGIN
+INSERT INTO klines (`Symbol`, `Interval`, `OpenTime`, `CloseTime`, `Open`, `High`, `Low`, `Close`, `Volume`, `QuoteVolume`, `ActiveBuyVolume`, `ActiveBuyQuoteVolume`)
+VALUES (in_Symbol, in_Interval, in_OpenTime, in_CloseTime, in_Open, in_High, in_Low, in_Close, in_Volume, in_QuoteVolume, in_ActiveBuyVolume, in_ActiveBuyQuoteVolume)
+ON DUPLICATE KEY UPDATE `CloseTime` = in_CloseTime, `Open` = in_Open, `High` = in_High, `Low` = in_Low, `Close` = in_Close, `Volume` = in_Volume, `QuoteVolume` = in_QuoteVolume, `ActiveBuyVolume` = in_ActiveBuyVolume, `ActiveBuyQuoteVolume` = in_ActiveBuyQuoteVolume;
+END ;;
+
+DELIMITER ;
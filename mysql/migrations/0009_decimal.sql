@@ -0,0 +1,158 @@
+-- Exact decimal prices, quantities and amounts. FLOAT columns and stored procedure parameters keep about 7 significant digits.
+
+ALTER TABLE `orders`
+  MODIFY `CummulativeQuoteQty` decimal(36,18) NOT NULL,
+  MODIFY `ExecutedQuantity` decimal(36,18) NOT NULL,
+  MODIFY `Price` decimal(36,18) NOT NULL,
+  MODIFY `CommissionAmount` decimal(36,18) NOT NULL DEFAULT '0',
+  MODIFY `Commission` decimal(36,18) NOT NULL DEFAULT '0';
+
+ALTER TABLE `session`
+  MODIFY `FiatFunds` decimal(36,18) NOT NULL;
+
+ALTER TABLE `thread`
+  MODIFY `CummulativeQuoteQty` decimal(36,18) NOT NULL,
+  MODIFY `Price` decimal(36,18) NOT NULL,
+  MODIFY `ExecutedQuantity` decimal(36,18) NOT NULL,
+  MODIFY `HighPrice` decimal(36,18) NOT NULL DEFAULT '0';
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactionByPrice` ;;
+CREATE PROCEDURE `GetThreadTransactionByPrice`(IN in_param_ThreadID varchar(45), IN in_param_Price decimal(36,18))
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(50);
+	DECLARE declared_in_param_Price decimal(36,18);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+    SET declared_in_param_Price = in_param_Price;
+	SELECT `thread`.`CummulativeQuoteQty` AS `CummulativeQuoteQty`, `thread`.`OrderID` AS `OrderID`, `thread`.`Price` AS `Price`, `thread`.`ExecutedQuantity` AS `ExecutedQuantity`, `Orders`.`TransactTime` AS `TransactTime`
+	FROM `thread`
+	LEFT JOIN `orders` `Orders` ON `thread`.`OrderID` = `Orders`.`OrderID`
+	WHERE (`thread`.`ThreadID` = declared_in_param_ThreadID
+	   AND `thread`.`Price` < declared_in_param_Price)
+	ORDER BY `thread`.`Price` ASC
+	LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactionByStopLoss` ;;
+CREATE PROCEDURE `GetThreadTransactionByStopLoss`(IN in_param_ThreadID varchar(45), IN in_param_Price decimal(36,18), IN in_param_StopLoss decimal(36,18))
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(50);
+	DECLARE declared_in_param_Price decimal(36,18);
+	DECLARE declared_in_param_StopLoss decimal(36,18);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+    SET declared_in_param_Price = in_param_Price;
+    SET declared_in_param_StopLoss = in_param_StopLoss;
+	SELECT `thread`.`CummulativeQuoteQty` AS `CummulativeQuoteQty`, `thread`.`OrderID` AS `OrderID`, `thread`.`Price` AS `Price`, `thread`.`ExecutedQuantity` AS `ExecutedQuantity`, `Orders`.`TransactTime` AS `TransactTime`
+	FROM `thread`
+	LEFT JOIN `orders` `Orders` ON `thread`.`OrderID` = `Orders`.`OrderID`
+	WHERE (`thread`.`ThreadID` = declared_in_param_ThreadID
+	   AND `thread`.`Price` * (1 - declared_in_param_StopLoss) >= declared_in_param_Price)
+	ORDER BY `thread`.`Price` DESC
+	LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactionByTrailingStop` ;;
+CREATE PROCEDURE `GetThreadTransactionByTrailingStop`(IN in_param_ThreadID varchar(45), IN in_param_Price decimal(36,18), IN in_param_TrailingStop decimal(36,18))
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(50);
+	DECLARE declared_in_param_Price decimal(36,18);
+	DECLARE declared_in_param_TrailingStop decimal(36,18);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+    SET declared_in_param_Price = in_param_Price;
+    SET declared_in_param_TrailingStop = in_param_TrailingStop;
+	SELECT `thread`.`CummulativeQuoteQty` AS `CummulativeQuoteQty`, `thread`.`OrderID` AS `OrderID`, `thread`.`Price` AS `Price`, `thread`.`ExecutedQuantity` AS `ExecutedQuantity`, `Orders`.`TransactTime` AS `TransactTime`
+	FROM `thread`
+	LEFT JOIN `orders` `Orders` ON `thread`.`OrderID` = `Orders`.`OrderID`
+	WHERE (`thread`.`ThreadID` = declared_in_param_ThreadID
+	   AND `thread`.`HighPrice` >= `thread`.`Price` * (1 + declared_in_param_TrailingStop)
+	   AND `thread`.`HighPrice` * (1 - declared_in_param_TrailingStop) >= declared_in_param_Price)
+	ORDER BY `thread`.`Price` ASC
+	LIMIT 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactiontUpmarketPriceCount` ;;
+CREATE PROCEDURE `GetThreadTransactiontUpmarketPriceCount`(IN in_param_ThreadID varchar(45), IN in_param_Price decimal(36,18))
+BEGIN
+	DECLARE declared_in_param_ThreadID CHAR(45);
+	DECLARE declared_in_param_Price decimal(36,18);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+    SET declared_in_param_Price = in_param_Price;
+	SELECT count(*) AS `count`
+	FROM `thread`
+	WHERE (`thread`.`Price` < declared_in_param_Price
+	   AND `thread`.`ThreadID` = declared_in_param_ThreadID);
+END ;;
+
+DROP PROCEDURE IF EXISTS `SaveOrder` ;;
+CREATE PROCEDURE `SaveOrder`(ClientOrderId varchar(45), CummulativeQuoteQty decimal(36,18), ExecutedQuantity decimal(36,18), OrderID bigint, Price decimal(36,18), Side varchar(45), Status varchar(45), Symbol varchar(45), TransactTime bigint, ThreadID varchar(45), ThreadIDSession varchar(45), CommissionAmount decimal(36,18), CommissionAsset varchar(45), Commission decimal(36,18))
+BEGIN
+INSERT INTO orders (ClientOrderId, CummulativeQuoteQty, ExecutedQuantity, OrderID, Price, Side, Status, Symbol, TransactTime, ThreadID, ThreadIDSession, CommissionAmount, CommissionAsset, Commission)
+VALUES (ClientOrderId, CummulativeQuoteQty, ExecutedQuantity, OrderID, Price, Side, Status, Symbol, TransactTime, ThreadID, ThreadIDSession, CommissionAmount, CommissionAsset, Commission);
+END ;;
+
+DROP PROCEDURE IF EXISTS `SaveSession` ;;
+CREATE PROCEDURE `SaveSession`(in_ThreadID varchar(45), in_ThreadIDSession varchar(45), in_Exchange varchar(45), in_FiatSymbol varchar(45), in_FiatFunds decimal(36,18))
+BEGIN
+INSERT INTO session (ThreadID, ThreadIDSession, Exchange, FiatSymbol, FiatFunds)
+VALUES (in_ThreadID, in_ThreadIDSession, in_Exchange, in_FiatSymbol, in_FiatFunds);
+END ;;
+
+DROP PROCEDURE IF EXISTS `SaveThreadTransaction` ;;
+CREATE PROCEDURE `SaveThreadTransaction`(ThreadID varchar(45), ThreadIDSession varchar(45), OrderID bigint, CummulativeQuoteQty decimal(36,18), Price decimal(36,18), ExecutedQuantity decimal(36,18))
+BEGIN
+INSERT INTO thread (ThreadID, ThreadIDSession, OrderID, CummulativeQuoteQty, Price, ExecutedQuantity, HighPrice)
+VALUES (ThreadID, ThreadIDSession, OrderID, CummulativeQuoteQty, Price, ExecutedQuantity, Price);
+END ;;
+
+DROP PROCEDURE IF EXISTS `UpdateOrder` ;;
+CREATE PROCEDURE `UpdateOrder`(in_OrderID bigint, CummulativeQuoteQty decimal(36,18), ExecutedQuantity decimal(36,18), Price decimal(36,18), Status varchar(45))
+BEGIN
+SET SQL_SAFE_UPDATES = 0;
+UPDATE orders
+SET  CummulativeQuoteQty = CummulativeQuoteQty,
+	ExecutedQuantity = ExecutedQuantity,
+    Price = Price,
+    Status = Status
+WHERE OrderID = in_OrderID;
+SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `UpdateOrderCommission` ;;
+CREATE PROCEDURE `UpdateOrderCommission`(IN in_param_ThreadID varchar(45), IN in_param_OrderID bigint, IN in_param_TradeID bigint, IN in_param_CommissionAmount decimal(36,18), IN in_param_CommissionAsset varchar(45), IN in_param_Commission decimal(36,18))
+BEGIN
+SET SQL_SAFE_UPDATES = 0;
+UPDATE orders
+SET CommissionAmount = CommissionAmount + in_param_CommissionAmount,
+	CommissionAsset = in_param_CommissionAsset,
+	Commission = Commission + in_param_Commission,
+	LastTradeID = in_param_TradeID
+WHERE ThreadID = in_param_ThreadID
+	AND OrderID = in_param_OrderID
+	AND Status IN ('NEW', 'PARTIALLY_FILLED')
+	AND LastTradeID < in_param_TradeID;
+SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `UpdateSession` ;;
+CREATE PROCEDURE `UpdateSession`(in_ThreadID varchar(45), in_ThreadIDSession varchar(45), in_Exchange varchar(45), in_FiatSymbol varchar(45), in_FiatFunds decimal(36,18))
+BEGIN
+SET SQL_SAFE_UPDATES = 0;
+	UPDATE `session`
+	SET `session`.`FiatFunds` = in_FiatFunds
+	WHERE `session`.`ThreadID` = in_ThreadID;
+SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `UpdateThreadTransactionHighPrice` ;;
+CREATE PROCEDURE `UpdateThreadTransactionHighPrice`(IN in_param_ThreadID varchar(45), IN in_param_Price decimal(36,18))
+BEGIN
+SET SQL_SAFE_UPDATES = 0;
+UPDATE thread
+SET HighPrice = in_param_Price
+WHERE ThreadID = in_param_ThreadID
+	AND HighPrice < in_param_Price;
+SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DELIMITER ;
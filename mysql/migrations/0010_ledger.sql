@@ -0,0 +1,121 @@
+-- Lot ledger: each filled BUY order is a lot, and each SELL order records the fills closing the lots it sold,
+-- with the profit realized net of commissions. Lots of the thread transactions held are created from the thread table.
+-- Profit is the sum of the fills plus, for the orders from before the ledger started that are not lots,
+-- the SELL amounts less the BUY amounts and commissions, as computed before the ledger.
+
+CREATE TABLE IF NOT EXISTS `lots` (
+  `OrderID` bigint NOT NULL,
+  `ThreadID` varchar(45) NOT NULL,
+  `ThreadIDSession` varchar(45) NOT NULL,
+  `Symbol` varchar(45) NOT NULL,
+  `Quantity` decimal(36,18) NOT NULL,
+  `Remaining` decimal(36,18) NOT NULL,
+  `Cost` decimal(36,18) NOT NULL,
+  `Commission` decimal(36,18) NOT NULL DEFAULT '0',
+  `OpenTime` bigint NOT NULL,
+  PRIMARY KEY (`OrderID`),
+  KEY `ThreadID_Remaining` (`ThreadID`,`Remaining`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `fills` (
+  `ID` int NOT NULL AUTO_INCREMENT,
+  `SellOrderID` bigint NOT NULL,
+  `LotOrderID` bigint NOT NULL,
+  `ThreadID` varchar(45) NOT NULL,
+  `ThreadIDSession` varchar(45) NOT NULL,
+  `Symbol` varchar(45) NOT NULL,
+  `Quantity` decimal(36,18) NOT NULL,
+  `Proceeds` decimal(36,18) NOT NULL,
+  `Cost` decimal(36,18) NOT NULL,
+  `Commission` decimal(36,18) NOT NULL,
+  `Profit` decimal(36,18) NOT NULL,
+  `HoldingTime` bigint NOT NULL,
+  `TransactTime` bigint NOT NULL,
+  PRIMARY KEY (`ID`),
+  UNIQUE KEY `SellOrderID_LotOrderID` (`SellOrderID`,`LotOrderID`),
+  KEY `ThreadID` (`ThreadID`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `ledger` (
+  `Started` bigint NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+INSERT INTO `ledger` (`Started`)
+SELECT CAST(UNIX_TIMESTAMP(NOW(3)) * 1000 AS UNSIGNED)
+FROM DUAL
+WHERE NOT EXISTS (SELECT 1 FROM `ledger`);
+
+INSERT IGNORE INTO `lots` (`OrderID`, `ThreadID`, `ThreadIDSession`, `Symbol`, `Quantity`, `Remaining`, `Cost`, `Commission`, `OpenTime`)
+SELECT `thread`.`OrderID`, `thread`.`ThreadID`, `thread`.`ThreadIDSession`, `Orders`.`Symbol`, `thread`.`ExecutedQuantity`, `thread`.`ExecutedQuantity`, `thread`.`CummulativeQuoteQty`, `Orders`.`Commission`, `Orders`.`TransactTime`
+FROM `thread`
+INNER JOIN `orders` `Orders` ON `thread`.`OrderID` = `Orders`.`OrderID`;
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `SaveLot` ;;
+CREATE PROCEDURE `SaveLot`(ThreadID varchar(45), ThreadIDSession varchar(45), OrderID bigint, Symbol varchar(45), Quantity decimal(36,18), Cost decimal(36,18), Commission decimal(36,18), OpenTime bigint)
+BEGIN
+INSERT INTO lots (OrderID, ThreadID, ThreadIDSession, Symbol, Quantity, Remaining, Cost, Commission, OpenTime)
+VALUES (OrderID, ThreadID, ThreadIDSession, Symbol, Quantity, Quantity, Cost, Commission, OpenTime);
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetOpenLots` ;;
+CREATE PROCEDURE `GetOpenLots`(IN in_param_ThreadID varchar(45))
+BEGIN
+DECLARE declared_in_param_ThreadID CHAR(50);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+SELECT `lots`.`OrderID`, `lots`.`Symbol`, `lots`.`Quantity`, `lots`.`Remaining`, `lots`.`Cost`, `lots`.`Commission`, `lots`.`OpenTime`
+FROM `lots`
+WHERE `lots`.`ThreadID` = declared_in_param_ThreadID
+AND `lots`.`Remaining` > 0
+ORDER BY `lots`.`OpenTime`, `lots`.`OrderID`;
+END ;;
+
+DROP PROCEDURE IF EXISTS `SaveFill` ;;
+CREATE PROCEDURE `SaveFill`(ThreadID varchar(45), ThreadIDSession varchar(45), SellOrderID bigint, LotOrderID bigint, Symbol varchar(45), Quantity decimal(36,18), Proceeds decimal(36,18), Cost decimal(36,18), Commission decimal(36,18), Profit decimal(36,18), HoldingTime bigint, TransactTime bigint)
+BEGIN
+INSERT INTO fills (SellOrderID, LotOrderID, ThreadID, ThreadIDSession, Symbol, Quantity, Proceeds, Cost, Commission, Profit, HoldingTime, TransactTime)
+VALUES (SellOrderID, LotOrderID, ThreadID, ThreadIDSession, Symbol, Quantity, Proceeds, Cost, Commission, Profit, HoldingTime, TransactTime);
+SET SQL_SAFE_UPDATES = 0;
+UPDATE lots
+SET lots.Remaining = GREATEST(lots.Remaining - Quantity, 0)
+WHERE lots.OrderID = LotOrderID;
+SET SQL_SAFE_UPDATES = 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetProfit` ;;
+CREATE PROCEDURE `GetProfit`()
+BEGIN
+SELECT 
+    (SELECT 
+            IFNULL(SUM(`fills`.`Profit`), 0)
+        FROM
+            `fills`) + (SELECT 
+            IFNULL(SUM(IF(`orders`.`Side` = 'SELL', `orders`.`CummulativeQuoteQty`, - `orders`.`CummulativeQuoteQty`) - `orders`.`Commission`), 0)
+        FROM
+            `orders`
+        WHERE
+            (`orders`.`TransactTime` < (SELECT `ledger`.`Started` FROM `ledger`)
+                AND `orders`.`OrderID` NOT IN (SELECT `lots`.`OrderID` FROM `lots`))) AS `Profit`;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetProfitByThreadID` ;;
+CREATE PROCEDURE `GetProfitByThreadID`(IN in_param_ThreadID varchar(45))
+BEGIN
+DECLARE declared_in_param_ThreadID CHAR(50);
+    SET declared_in_param_ThreadID = in_param_ThreadID;
+SELECT 
+    (SELECT 
+            IFNULL(SUM(`fills`.`Profit`), 0)
+        FROM
+            `fills`
+        WHERE
+            `fills`.`ThreadID` = declared_in_param_ThreadID) + (SELECT 
+            IFNULL(SUM(IF(`orders`.`Side` = 'SELL', `orders`.`CummulativeQuoteQty`, - `orders`.`CummulativeQuoteQty`) - `orders`.`Commission`), 0)
+        FROM
+            `orders`
+        WHERE
+            (`orders`.`ThreadID` = declared_in_param_ThreadID
+                AND `orders`.`TransactTime` < (SELECT `ledger`.`Started` FROM `ledger`)
+                AND `orders`.`OrderID` NOT IN (SELECT `lots`.`OrderID` FROM `lots`))) AS `Profit`;
+END ;;
@@ -0,0 +1,18 @@
+-- Filled orders by transaction time, with the cost and profit of the ledger fills of SELL orders, for exports.
+
+ALTER TABLE `orders`
+  ADD KEY `TransactTime` (`TransactTime`);
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `GetTrades` ;;
+CREATE PROCEDURE `GetTrades`(IN in_param_StartTime bigint, IN in_param_EndTime bigint, IN in_param_ThreadIDs varchar(4000))
+BEGIN
+SELECT `orders`.`OrderID`, `orders`.`ThreadID`, `orders`.`Symbol`, `orders`.`Side`, `orders`.`ExecutedQuantity`, `orders`.`CummulativeQuoteQty`, `orders`.`CommissionAmount`, `orders`.`CommissionAsset`, `orders`.`Commission`, `Fills`.`Cost`, `Fills`.`Profit`, `orders`.`TransactTime`
+FROM `orders`
+LEFT JOIN (SELECT `fills`.`SellOrderID`, SUM(`fills`.`Cost`) AS `Cost`, SUM(`fills`.`Profit`) AS `Profit` FROM `fills` GROUP BY `fills`.`SellOrderID`) `Fills` ON `orders`.`OrderID` = `Fills`.`SellOrderID`
+WHERE `orders`.`ExecutedQuantity` > 0
+AND `orders`.`TransactTime` BETWEEN in_param_StartTime AND in_param_EndTime
+AND (in_param_ThreadIDs = '' OR FIND_IN_SET(`orders`.`ThreadID`, in_param_ThreadIDs) > 0)
+ORDER BY `orders`.`TransactTime`, `orders`.`OrderID`;
+END ;;
@@ -0,0 +1,36 @@
+-- Risk circuit breaker and profit peak of each ThreadID, kept across restarts until reset.
+
+CREATE TABLE IF NOT EXISTS `risk` (
+  `ThreadID` varchar(45) NOT NULL,
+  `Paused` varchar(255) NOT NULL,
+  `Peak` decimal(36,18) NOT NULL,
+  `Day` bigint NOT NULL,
+  `DayStart` decimal(36,18) NOT NULL,
+  PRIMARY KEY (`ThreadID`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `GetRisk` ;;
+CREATE PROCEDURE `GetRisk`(IN in_param_ThreadID varchar(45))
+BEGIN
+SELECT Paused, Peak, Day, DayStart FROM risk
+WHERE ThreadID = in_param_ThreadID;
+END ;;
+
+DROP PROCEDURE IF EXISTS `SaveRisk` ;;
+CREATE PROCEDURE `SaveRisk`(IN in_param_ThreadID varchar(45), IN in_param_Paused varchar(255), IN in_param_Peak decimal(36,18), IN in_param_Day bigint, IN in_param_DayStart decimal(36,18))
+BEGIN
+INSERT INTO risk (ThreadID, Paused, Peak, Day, DayStart)
+VALUES (in_param_ThreadID, in_param_Paused, in_param_Peak, in_param_Day, in_param_DayStart)
+ON DUPLICATE KEY UPDATE Paused = in_param_Paused, Peak = in_param_Peak, Day = in_param_Day, DayStart = in_param_DayStart;
+END ;;
+
+DROP PROCEDURE IF EXISTS `DeleteRisk` ;;
+CREATE PROCEDURE `DeleteRisk`(IN in_param_ThreadID varchar(45))
+BEGIN
+DELETE FROM risk
+WHERE ThreadID = in_param_ThreadID;
+END ;;
+
+DELIMITER ;
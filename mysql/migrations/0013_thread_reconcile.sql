@@ -0,0 +1,22 @@
+-- Thread transactions fixed by reconciliation to the quantity and amount filled on the exchange.
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `UpdateThreadTransaction` ;;
+CREATE PROCEDURE `UpdateThreadTransaction`(IN in_param_OrderID bigint, IN in_param_CummulativeQuoteQty decimal(36,18), IN in_param_Price decimal(36,18), IN in_param_ExecutedQuantity decimal(36,18))
+BEGIN
+UPDATE thread
+SET CummulativeQuoteQty = in_param_CummulativeQuoteQty,
+	Price = in_param_Price,
+	ExecutedQuantity = in_param_ExecutedQuantity
+WHERE OrderID = in_param_OrderID;
+END ;;
+
+DROP PROCEDURE IF EXISTS `GetThreadTransactionByOrderID` ;;
+CREATE PROCEDURE `GetThreadTransactionByOrderID`(IN in_param_OrderID bigint)
+BEGIN
+SELECT Price, ExecutedQuantity, CummulativeQuoteQty FROM thread
+WHERE OrderID = in_param_OrderID;
+END ;;
+
+DELIMITER ;
@@ -0,0 +1,46 @@
+-- Risk circuit breaker, profit peak and day start of the account, shared by the threads using the database in a single row.
+-- A circuit breaker tripped by a ThreadID pauses the account, and daily loss and drawdown are measured again from the current profit.
+
+CREATE TABLE IF NOT EXISTS `risk_account` (
+  `ID` tinyint NOT NULL,
+  `Paused` varchar(255) NOT NULL,
+  `Peak` decimal(36,18) NOT NULL,
+  `Day` bigint NOT NULL,
+  `DayStart` decimal(36,18) NOT NULL,
+  PRIMARY KEY (`ID`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+INSERT INTO `risk_account` (ID, Paused, Peak, Day, DayStart)
+SELECT 1, Paused, 0, 0, 0 FROM `risk`
+WHERE Paused <> ''
+ORDER BY Day DESC
+LIMIT 1;
+
+DROP TABLE `risk`;
+
+ALTER TABLE `risk_account` RENAME TO `risk`;
+
+DELIMITER ;;
+
+DROP PROCEDURE IF EXISTS `GetRisk` ;;
+CREATE PROCEDURE `GetRisk`()
+BEGIN
+SELECT Paused, Peak, Day, DayStart FROM risk
+WHERE ID = 1;
+END ;;
+
+DROP PROCEDURE IF EXISTS `SaveRisk` ;;
+CREATE PROCEDURE `SaveRisk`(IN in_param_Paused varchar(255), IN in_param_Peak decimal(36,18), IN in_param_Day bigint, IN in_param_DayStart decimal(36,18))
+BEGIN
+INSERT INTO risk (ID, Paused, Peak, Day, DayStart)
+VALUES (1, in_param_Paused, in_param_Peak, in_param_Day, in_param_DayStart)
+ON DUPLICATE KEY UPDATE Paused = in_param_Paused, Peak = in_param_Peak, Day = in_param_Day, DayStart = in_param_DayStart;
+END ;;
+
+DROP PROCEDURE IF EXISTS `DeleteRisk` ;;
+CREATE PROCEDURE `DeleteRisk`()
+BEGIN
+DELETE FROM risk;
+END ;;
+
+DELIMITER ;
@@ -2,12 +2,16 @@ package mysql
 
 import (
 	"cryptopump/functions"
+	"cryptopump/migrate"
 	"cryptopump/types"
 	"database/sql"
+	"embed"
 	"fmt"
-	"math"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
 
 	_ "github.com/go-sql-driver/mysql" // This blank entry is required to enable mysql connectivity
@@ -15,10 +19,7 @@ import (
 
 // DBInit export
 /* This function initializes GCP mysql database connectivity */
-func DBInit() *sql.DB {
-
-	var db *sql.DB
-	var err error
+func DBInit() (db *sql.DB, err error) {
 
 	// If the optional DB_TCP_HOST environment variable is set, it contains
 	// the IP address and port number of a TCP connection pool to be created,
@@ -28,7 +29,7 @@ func DBInit() *sql.DB {
 
 		if db, err = InitTCPConnectionPool(); err != nil {
 
-			log.Fatalf("initTCPConnectionPool: unable to connect: %v", err)
+			return nil, fmt.Errorf("initTCPConnectionPool: unable to connect: %v", err)
 
 		}
 
@@ -36,13 +37,62 @@ func DBInit() *sql.DB {
 
 		if db, err = InitSocketConnectionPool(); err != nil {
 
-			log.Fatalf("initSocketConnectionPool: unable to connect: %v", err)
+			return nil, fmt.Errorf("initSocketConnectionPool: unable to connect: %v", err)
+
+		}
+
+	}
+
+	/* sql.Open defers connecting, so an unreachable database is only reported by the first query */
+	if err = db.Ping(); err != nil {
+
+		db.Close()
+		return nil, fmt.Errorf("unable to connect: %v", err)
+
+	}
+
+	return db, nil
+
+}
+
+/* Retrieve the environment variables, failing with the first missing one */
+func getenv(
+	keys ...string) (values []string, err error) {
+
+	for _, key := range keys {
+
+		value := os.Getenv(key)
+
+		if value == "" {
+
+			return nil, fmt.Errorf("%s environment variable not set", key)
 
 		}
 
+		values = append(values, strings.ToLower(value))
+
+	}
+
+	return values, nil
+
+}
+
+//go:embed migrations/*.sql
+var migrations embed.FS /* Numbered schema migrations from migrations/0001_baseline.sql, the tables and stored procedures of the former cryptopump.sql dump */
+
+// Migrate apply the schema migrations pending in the database, returning the schema version before and after
+func Migrate(
+	db *sql.DB) (from int, to int, err error) {
+
+	var list []migrate.Migration
+
+	if list, err = migrate.Load(migrations, "migrations"); err != nil {
+
+		return 0, 0, err
+
 	}
 
-	return db
+	return migrate.Apply(db, list)
 
 }
 
@@ -54,11 +104,19 @@ func InitSocketConnectionPool() (*sql.DB, error) {
 	var dbPool *sql.DB
 
 	// [START cloud_sql_mysql_databasesql_create_socket]
+	env, err := getenv("DB_USER", "DB_PASS", "INSTANCE_CONNECTION_NAME", "DB_NAME")
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
 	var (
-		dbUser                 = functions.MustGetenv("DB_USER")
-		dbPwd                  = functions.MustGetenv("DB_PASS")
-		instanceConnectionName = functions.MustGetenv("INSTANCE_CONNECTION_NAME")
-		dbName                 = functions.MustGetenv("DB_NAME")
+		dbUser                 = env[0]
+		dbPwd                  = env[1]
+		instanceConnectionName = env[2]
+		dbName                 = env[3]
 	)
 
 	socketDir, isSet := os.LookupEnv("DB_SOCKET_DIR")
@@ -112,12 +170,20 @@ func InitTCPConnectionPool() (*sql.DB, error) {
 	var dbPool *sql.DB
 
 	// [START cloud_sql_mysql_databasesql_create_tcp]
+	env, err := getenv("DB_USER", "DB_PASS", "DB_TCP_HOST", "DB_PORT", "DB_NAME")
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
 	var (
-		dbUser    = functions.MustGetenv("DB_USER")
-		dbPwd     = functions.MustGetenv("DB_PASS")
-		dbTCPHost = functions.MustGetenv("DB_TCP_HOST")
-		dbPort    = functions.MustGetenv("DB_PORT")
-		dbName    = functions.MustGetenv("DB_NAME")
+		dbUser    = env[0]
+		dbPwd     = env[1]
+		dbTCPHost = env[2]
+		dbPort    = env[3]
+		dbName    = env[4]
 	)
 
 	var dbURI = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", dbUser, dbPwd, dbTCPHost, dbPort, dbName)
@@ -138,22 +204,38 @@ func InitTCPConnectionPool() (*sql.DB, error) {
 	// [END cloud_sql_mysql_databasesql_create_tcp]
 }
 
+// Storage MySQL implementation of types.Storage using the cryptopump stored procedures
+type Storage struct {
+	db *sql.DB /* mySQL database connection */
+}
+
+// NewStorage create a MySQL storage for the database connection
+func NewStorage(
+	db *sql.DB) *Storage {
+
+	return &Storage{db: db}
+
+}
+
 // SaveOrder Save order to database
-func SaveOrder(
+func (s *Storage) SaveOrder(
 	sessionData *types.Session,
 	ClientOrderID string,
-	CumulativeQuoteQuantity float64,
-	ExecutedQuantity float64,
+	CumulativeQuoteQuantity decimal.Decimal,
+	ExecutedQuantity decimal.Decimal,
 	OrderID int64,
-	Price float64,
+	Price decimal.Decimal,
 	Side string,
 	Status string,
 	Symbol string,
-	TransactTime int64) (err error) {
+	TransactTime int64,
+	CommissionAmount decimal.Decimal,
+	CommissionAsset string,
+	Commission decimal.Decimal) (err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.SaveOrder(?,?,?,?,?,?,?,?,?,?,?)",
+	if rows, err = s.db.Query("call cryptopump.SaveOrder(?,?,?,?,?,?,?,?,?,?,?,?,?,?)",
 		ClientOrderID,
 		CumulativeQuoteQuantity,
 		ExecutedQuantity,
@@ -164,7 +246,10 @@ func SaveOrder(
 		Symbol,
 		TransactTime,
 		sessionData.ThreadID,
-		sessionData.ThreadIDSession); err != nil {
+		sessionData.ThreadIDSession,
+		CommissionAmount,
+		CommissionAsset,
+		Commission); err != nil {
 
 		functions.Logger(&types.LogEntry{
 			Config:  nil,
@@ -189,17 +274,17 @@ func SaveOrder(
 }
 
 // UpdateOrder Update order
-func UpdateOrder(
+func (s *Storage) UpdateOrder(
 	sessionData *types.Session,
 	OrderID int64,
-	CumulativeQuoteQuantity float64,
-	ExecutedQuantity float64,
-	Price float64,
+	CumulativeQuoteQuantity decimal.Decimal,
+	ExecutedQuantity decimal.Decimal,
+	Price decimal.Decimal,
 	Status string) (err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.UpdateOrder(?,?,?,?,?)",
+	if rows, err = s.db.Query("call cryptopump.UpdateOrder(?,?,?,?,?)",
 		OrderID,
 		CumulativeQuoteQuantity,
 		ExecutedQuantity,
@@ -228,14 +313,54 @@ func UpdateOrder(
 
 }
 
+// UpdateOrderCommission Add the commission of a trade to an order waiting to be filled. Trades already applied are ignored
+func (s *Storage) UpdateOrderCommission(
+	sessionData *types.Session,
+	OrderID int64,
+	TradeID int64,
+	CommissionAmount decimal.Decimal,
+	CommissionAsset string,
+	Commission decimal.Decimal) (err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.UpdateOrderCommission(?,?,?,?,?,?)",
+		sessionData.ThreadID,
+		OrderID,
+		TradeID,
+		CommissionAmount,
+		CommissionAsset,
+		Commission); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:  nil,
+			Market:  nil,
+			Session: sessionData,
+			Order: &types.Order{
+				OrderID: int(OrderID),
+			},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return err
+
+	}
+
+	rows.Close()
+
+	return nil
+
+}
+
 // UpdateSession Update existing session on Session table
-func UpdateSession(
+func (s *Storage) UpdateSession(
 	configData *types.Config,
 	sessionData *types.Session) (err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.UpdateSession(?,?,?,?,?)",
+	if rows, err = s.db.Query("call cryptopump.UpdateSession(?,?,?,?,?)",
 		sessionData.ThreadID,
 		sessionData.ThreadIDSession,
 		configData.ExchangeName,
@@ -262,13 +387,13 @@ func UpdateSession(
 }
 
 // SaveSession Save new session to Session table.
-func SaveSession(
+func (s *Storage) SaveSession(
 	configData *types.Config,
 	sessionData *types.Session) (err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.SaveSession(?,?,?,?,?)",
+	if rows, err = s.db.Query("call cryptopump.SaveSession(?,?,?,?,?)",
 		sessionData.ThreadID,
 		sessionData.ThreadIDSession,
 		configData.ExchangeName,
@@ -295,12 +420,12 @@ func SaveSession(
 }
 
 // DeleteSession Delete session from Session table
-func DeleteSession(
+func (s *Storage) DeleteSession(
 	sessionData *types.Session) (err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.DeleteSession(?)",
+	if rows, err = s.db.Query("call cryptopump.DeleteSession(?)",
 		sessionData.ThreadID); err != nil {
 
 		functions.Logger(&types.LogEntry{
@@ -323,16 +448,16 @@ func DeleteSession(
 }
 
 // SaveThreadTransaction Save Thread cycle to database
-func SaveThreadTransaction(
+func (s *Storage) SaveThreadTransaction(
 	sessionData *types.Session,
 	OrderID int64,
-	CumulativeQuoteQuantity float64,
-	Price float64,
-	ExecutedQuantity float64) (err error) {
+	CumulativeQuoteQuantity decimal.Decimal,
+	Price decimal.Decimal,
+	ExecutedQuantity decimal.Decimal) (err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.SaveThreadTransaction(?,?,?,?,?,?)",
+	if rows, err = s.db.Query("call cryptopump.SaveThreadTransaction(?,?,?,?,?,?)",
 		sessionData.ThreadID,
 		sessionData.ThreadIDSession,
 		OrderID,
@@ -363,13 +488,13 @@ func SaveThreadTransaction(
 }
 
 // DeleteThreadTransactionByOrderID function
-func DeleteThreadTransactionByOrderID(
+func (s *Storage) DeleteThreadTransactionByOrderID(
 	sessionData *types.Session,
 	orderID int) (err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.DeleteThreadTransactionByOrderID(?)",
+	if rows, err = s.db.Query("call cryptopump.DeleteThreadTransactionByOrderID(?)",
 		orderID); err != nil {
 
 		functions.Logger(&types.LogEntry{
@@ -393,13 +518,88 @@ func DeleteThreadTransactionByOrderID(
 
 }
 
+// UpdateThreadTransaction Update the quantity, amount and price of the thread transaction for the order
+func (s *Storage) UpdateThreadTransaction(
+	sessionData *types.Session,
+	orderID int,
+	cumulativeQuoteQuantity decimal.Decimal,
+	price decimal.Decimal,
+	executedQuantity decimal.Decimal) (err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.UpdateThreadTransaction(?,?,?,?)",
+		orderID,
+		cumulativeQuoteQuantity,
+		price,
+		executedQuantity); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:  nil,
+			Market:  nil,
+			Session: sessionData,
+			Order: &types.Order{
+				OrderID: orderID,
+			},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return err
+
+	}
+
+	rows.Close()
+
+	return nil
+
+}
+
+// GetThreadTransactionByOrderID Return the price, quantity and amount of the thread transaction for the order, zero when none
+func (s *Storage) GetThreadTransactionByOrderID(
+	sessionData *types.Session,
+	orderID int) (price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.GetThreadTransactionByOrderID(?)",
+		orderID); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:  nil,
+			Market:  nil,
+			Session: sessionData,
+			Order: &types.Order{
+				OrderID: orderID,
+			},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return decimal.Zero, decimal.Zero, decimal.Zero, err
+
+	}
+
+	for rows.Next() {
+		err = rows.Scan(
+			&price,
+			&executedQuantity,
+			&cumulativeQuoteQty)
+	}
+
+	rows.Close()
+
+	return price, executedQuantity, cumulativeQuoteQty, err
+
+}
+
 // GetThreadTransactionCount Get Thread count
-func GetThreadTransactionCount(
+func (s *Storage) GetThreadTransactionCount(
 	sessionData *types.Session) (count int, err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetThreadTransactionCount(?)",
+	if rows, err = s.db.Query("call cryptopump.GetThreadTransactionCount(?)",
 		sessionData.ThreadID); err != nil {
 
 		functions.Logger(&types.LogEntry{
@@ -426,13 +626,13 @@ func GetThreadTransactionCount(
 }
 
 // GetLastOrderTransactionPrice Get time for last transaction the ThreadID
-func GetLastOrderTransactionPrice(
+func (s *Storage) GetLastOrderTransactionPrice(
 	sessionData *types.Session,
-	Side string) (price float64, err error) {
+	Side string) (price decimal.Decimal, err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetLastOrderTransactionPrice(?,?)",
+	if rows, err = s.db.Query("call cryptopump.GetLastOrderTransactionPrice(?,?)",
 		sessionData.ThreadID,
 		Side); err != nil {
 
@@ -445,7 +645,7 @@ func GetLastOrderTransactionPrice(
 			LogLevel: log.DebugLevel,
 		})
 
-		return 0, err
+		return decimal.Zero, err
 
 	}
 
@@ -460,12 +660,12 @@ func GetLastOrderTransactionPrice(
 }
 
 // GetLastOrderTransactionSide Get Side for last transaction the ThreadID
-func GetLastOrderTransactionSide(
+func (s *Storage) GetLastOrderTransactionSide(
 	sessionData *types.Session) (side string, err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetLastOrderTransactionSide(?)",
+	if rows, err = s.db.Query("call cryptopump.GetLastOrderTransactionSide(?)",
 		sessionData.ThreadID); err != nil {
 
 		functions.Logger(&types.LogEntry{
@@ -492,12 +692,12 @@ func GetLastOrderTransactionSide(
 }
 
 // GetOrderTransactionSideLastTwo function
-func GetOrderTransactionSideLastTwo(
+func (s *Storage) GetOrderTransactionSideLastTwo(
 	sessionData *types.Session) (side1 string, side2 string, err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetOrderTransactionSideLastTwo(?)",
+	if rows, err = s.db.Query("call cryptopump.GetOrderTransactionSideLastTwo(?)",
 		sessionData.ThreadID); err != nil {
 
 		functions.Logger(&types.LogEntry{
@@ -524,12 +724,12 @@ func GetOrderTransactionSideLastTwo(
 }
 
 // GetOrderSymbol Get symbol for ThreadID
-func GetOrderSymbol(
+func (s *Storage) GetOrderSymbol(
 	sessionData *types.Session) (symbol string, err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetOrderSymbol(?)",
+	if rows, err = s.db.Query("call cryptopump.GetOrderSymbol(?)",
 		sessionData.ThreadID); err != nil {
 
 		functions.Logger(&types.LogEntry{
@@ -556,12 +756,12 @@ func GetOrderSymbol(
 }
 
 // GetThreadTransactionDistinct Get Thread Distinct
-func GetThreadTransactionDistinct(
+func (s *Storage) GetThreadTransactionDistinct(
 	sessionData *types.Session) (threadID string, threadIDSession string, err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetThreadTransactionDistinct()"); err != nil {
+	if rows, err = s.db.Query("call cryptopump.GetThreadTransactionDistinct()"); err != nil {
 
 		functions.Logger(&types.LogEntry{
 			Config:   nil,
@@ -600,12 +800,12 @@ func GetThreadTransactionDistinct(
 }
 
 // GetOrderTransactionPending Get 1 order with pending FILLED status
-func GetOrderTransactionPending(
+func (s *Storage) GetOrderTransactionPending(
 	sessionData *types.Session) (orderID int64, symbol string, err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetOrderTransactionPending(?)",
+	if rows, err = s.db.Query("call cryptopump.GetOrderTransactionPending(?)",
 		sessionData.ThreadID); err != nil {
 
 		functions.Logger(&types.LogEntry{
@@ -635,16 +835,15 @@ func GetOrderTransactionPending(
 
 }
 
-// GetThreadTransactionByPrice function
-func GetThreadTransactionByPrice(
-	marketData *types.Market,
-	sessionData *types.Session) (orderID int, price float64, executedQuantity float64, cumulativeQuoteQty float64, transactTime int64, err error) {
+// GetOrderByOrderID Return an order. Status is empty when the order is not found
+func (s *Storage) GetOrderByOrderID(
+	sessionData *types.Session,
+	orderID int64) (order types.Order, err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetThreadTransactionByPrice(?,?)",
-		sessionData.ThreadID,
-		marketData.Price); err != nil {
+	if rows, err = s.db.Query("call cryptopump.GetOrderByOrderID(?)",
+		orderID); err != nil {
 
 		functions.Logger(&types.LogEntry{
 			Config:  nil,
@@ -657,33 +856,95 @@ func GetThreadTransactionByPrice(
 			LogLevel: log.DebugLevel,
 		})
 
-		return 0, 0, 0, 0, 0, err
+		return types.Order{}, err
 
 	}
 
 	for rows.Next() {
 		err = rows.Scan(
-			&cumulativeQuoteQty,
-			&orderID,
-			&price,
-			&executedQuantity,
-			&transactTime)
+			&order.ClientOrderID,
+			&order.CumulativeQuoteQuantity,
+			&order.ExecutedQuantity,
+			&order.OrderID,
+			&order.Price,
+			&order.Side,
+			&order.Status,
+			&order.Symbol,
+			&order.TransactTime,
+			&order.CommissionAmount,
+			&order.CommissionAsset,
+			&order.Commission)
 	}
 
 	rows.Close()
 
-	return orderID, price, executedQuantity, cumulativeQuoteQty, transactTime, err
+	return order, err
 
 }
 
-// GetThreadLastTransaction Return the last 'active' BUY transaction for a Thread
-func GetThreadLastTransaction(
-	sessionData *types.Session) (orderID int, price float64, executedQuantity float64, cumulativeQuoteQty float64, transactTime int64, err error) {
+// GetOrdersBySymbol Return the orders for the session symbol since transactTime
+func (s *Storage) GetOrdersBySymbol(
+	sessionData *types.Session,
+	transactTime int64) (orders []types.Order, err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetThreadLastTransaction(?)",
-		sessionData.ThreadID); err != nil {
+	if rows, err = s.db.Query("call cryptopump.GetOrdersBySymbol(?,?)",
+		sessionData.Symbol,
+		transactTime); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return nil, err
+
+	}
+
+	for rows.Next() {
+
+		order := types.Order{}
+
+		if err = rows.Scan(
+			&order.ClientOrderID,
+			&order.CumulativeQuoteQuantity,
+			&order.ExecutedQuantity,
+			&order.OrderID,
+			&order.Price,
+			&order.Side,
+			&order.Status,
+			&order.Symbol,
+			&order.TransactTime); err != nil {
+
+			break
+
+		}
+
+		orders = append(orders, order)
+
+	}
+
+	rows.Close()
+
+	return orders, err
+
+}
+
+// GetThreadTransactionByPrice function
+func (s *Storage) GetThreadTransactionByPrice(
+	marketData *types.Market,
+	sessionData *types.Session) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.GetThreadTransactionByPrice(?,?)",
+		sessionData.ThreadID,
+		marketData.Price); err != nil {
 
 		functions.Logger(&types.LogEntry{
 			Config:  nil,
@@ -696,7 +957,7 @@ func GetThreadLastTransaction(
 			LogLevel: log.DebugLevel,
 		})
 
-		return 0, 0, 0, 0, 0, err
+		return 0, decimal.Zero, decimal.Zero, decimal.Zero, 0, err
 
 	}
 
@@ -715,85 +976,102 @@ func GetThreadLastTransaction(
 
 }
 
-// GetThreadTransactiontUpmarketPriceCount function
-func GetThreadTransactiontUpmarketPriceCount(
+// GetThreadTransactionByStopLoss Return the highest price thread transaction the market price has fallen stopLoss below
+func (s *Storage) GetThreadTransactionByStopLoss(
+	marketData *types.Market,
 	sessionData *types.Session,
-	price float64) (count int, err error) {
+	stopLoss float64) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetThreadTransactiontUpmarketPriceCount(?,?)",
+	if rows, err = s.db.Query("call cryptopump.GetThreadTransactionByStopLoss(?,?,?)",
 		sessionData.ThreadID,
-		price); err != nil {
+		marketData.Price,
+		stopLoss); err != nil {
 
 		functions.Logger(&types.LogEntry{
-			Config:   nil,
-			Market:   nil,
-			Session:  sessionData,
-			Order:    &types.Order{},
+			Config:  nil,
+			Market:  nil,
+			Session: sessionData,
+			Order: &types.Order{
+				OrderID: int(orderID),
+			},
 			Message:  functions.GetFunctionName() + " - " + err.Error(),
 			LogLevel: log.DebugLevel,
 		})
 
-		return 0, err
+		return 0, decimal.Zero, decimal.Zero, decimal.Zero, 0, err
 
 	}
 
 	for rows.Next() {
-		err = rows.Scan(&count)
+		err = rows.Scan(
+			&cumulativeQuoteQty,
+			&orderID,
+			&price,
+			&executedQuantity,
+			&transactTime)
 	}
 
 	rows.Close()
 
-	return count, err
+	return orderID, price, executedQuantity, cumulativeQuoteQty, transactTime, err
 
 }
 
-// GetOrderTransactionCount Retrieve transaction count by Side and minutes
-func GetOrderTransactionCount(
+// GetThreadTransactionByTrailingStop Return the lowest price thread transaction with a high-water mark above price plus trailingStop, and the market price fallen trailingStop below the high-water mark
+func (s *Storage) GetThreadTransactionByTrailingStop(
+	marketData *types.Market,
 	sessionData *types.Session,
-	side string) (count float64, err error) {
+	trailingStop float64) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetOrderTransactionCount(?,?,?)",
+	if rows, err = s.db.Query("call cryptopump.GetThreadTransactionByTrailingStop(?,?,?)",
 		sessionData.ThreadID,
-		side,
-		(60 * -1)); err != nil {
+		marketData.Price,
+		trailingStop); err != nil {
 
 		functions.Logger(&types.LogEntry{
-			Config:   nil,
-			Market:   nil,
-			Session:  sessionData,
-			Order:    &types.Order{},
+			Config:  nil,
+			Market:  nil,
+			Session: sessionData,
+			Order: &types.Order{
+				OrderID: int(orderID),
+			},
 			Message:  functions.GetFunctionName() + " - " + err.Error(),
 			LogLevel: log.DebugLevel,
 		})
 
-		return 0, err
+		return 0, decimal.Zero, decimal.Zero, decimal.Zero, 0, err
 
 	}
 
 	for rows.Next() {
-		err = rows.Scan(&count)
+		err = rows.Scan(
+			&cumulativeQuoteQty,
+			&orderID,
+			&price,
+			&executedQuantity,
+			&transactTime)
 	}
 
 	rows.Close()
 
-	return count, err
+	return orderID, price, executedQuantity, cumulativeQuoteQty, transactTime, err
 
 }
 
-// GetThreadTransactionByThreadID  Retrieve transaction count by Side and minutes
-func GetThreadTransactionByThreadID(
-	sessionData *types.Session) (orders []types.Order, err error) {
+// UpdateThreadTransactionHighPrice Raise the high-water mark of thread transactions to the market price
+func (s *Storage) UpdateThreadTransactionHighPrice(
+	marketData *types.Market,
+	sessionData *types.Session) (err error) {
 
 	var rows *sql.Rows
 
-	order := types.Order{}
-
-	if rows, err = sessionData.Db.Query("call cryptopump.GetThreadTransactionByThreadID(?)",
-		sessionData.ThreadID); err != nil {
+	if rows, err = s.db.Query("call cryptopump.UpdateThreadTransactionHighPrice(?,?)",
+		sessionData.ThreadID,
+		marketData.Price); err != nil {
 
 		functions.Logger(&types.LogEntry{
 			Config:   nil,
@@ -804,22 +1082,160 @@ func GetThreadTransactionByThreadID(
 			LogLevel: log.DebugLevel,
 		})
 
-		return nil, err
+		return err
 
 	}
 
-	for rows.Next() {
+	rows.Close()
 
-		var orderID int
-		var cumulativeQuoteQty, price string
-		err = rows.Scan(&orderID, &cumulativeQuoteQty, &price)
+	return nil
 
-		order.OrderID = orderID
-		order.CumulativeQuoteQuantity = math.Round(functions.StrToFloat64(cumulativeQuoteQty)*100) / 100
-		order.Price = math.Round(functions.StrToFloat64(price)*1000) / 1000
-		orders = append(orders, order)
+}
 
-	}
+// GetThreadLastTransaction Return the last 'active' BUY transaction for a Thread
+func (s *Storage) GetThreadLastTransaction(
+	sessionData *types.Session) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.GetThreadLastTransaction(?)",
+		sessionData.ThreadID); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:  nil,
+			Market:  nil,
+			Session: sessionData,
+			Order: &types.Order{
+				OrderID: int(orderID),
+			},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return 0, decimal.Zero, decimal.Zero, decimal.Zero, 0, err
+
+	}
+
+	for rows.Next() {
+		err = rows.Scan(
+			&cumulativeQuoteQty,
+			&orderID,
+			&price,
+			&executedQuantity,
+			&transactTime)
+	}
+
+	rows.Close()
+
+	return orderID, price, executedQuantity, cumulativeQuoteQty, transactTime, err
+
+}
+
+// GetThreadTransactiontUpmarketPriceCount function
+func (s *Storage) GetThreadTransactiontUpmarketPriceCount(
+	sessionData *types.Session,
+	price float64) (count int, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.GetThreadTransactiontUpmarketPriceCount(?,?)",
+		sessionData.ThreadID,
+		price); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return 0, err
+
+	}
+
+	for rows.Next() {
+		err = rows.Scan(&count)
+	}
+
+	rows.Close()
+
+	return count, err
+
+}
+
+// GetOrderTransactionCount Retrieve transaction count by Side and minutes
+func (s *Storage) GetOrderTransactionCount(
+	sessionData *types.Session,
+	side string) (count float64, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.GetOrderTransactionCount(?,?,?)",
+		sessionData.ThreadID,
+		side,
+		(60 * -1)); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return 0, err
+
+	}
+
+	for rows.Next() {
+		err = rows.Scan(&count)
+	}
+
+	rows.Close()
+
+	return count, err
+
+}
+
+// GetThreadTransactionByThreadID  Retrieve transaction count by Side and minutes
+func (s *Storage) GetThreadTransactionByThreadID(
+	sessionData *types.Session) (orders []types.Order, err error) {
+
+	var rows *sql.Rows
+
+	order := types.Order{}
+
+	if rows, err = s.db.Query("call cryptopump.GetThreadTransactionByThreadID(?)",
+		sessionData.ThreadID); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return nil, err
+
+	}
+
+	for rows.Next() {
+
+		var orderID int
+		var cumulativeQuoteQty, price decimal.Decimal
+		err = rows.Scan(&orderID, &cumulativeQuoteQty, &price)
+
+		order.OrderID = orderID
+		order.CumulativeQuoteQuantity = cumulativeQuoteQty.Round(2)
+		order.Price = price.Round(3)
+		orders = append(orders, order)
+
+	}
 
 	rows.Close()
 
@@ -827,13 +1243,14 @@ func GetThreadTransactionByThreadID(
 
 }
 
-// GetProfitByThreadID Retrieve thread profit
-func GetProfitByThreadID(
+// GetProfitByThreadID Retrieve thread profit, from the ledger fills and the orders from before the ledger
+func (s *Storage) GetProfitByThreadID(
 	sessionData *types.Session) (profit float64, err error) {
 
 	var rows *sql.Rows
+	var sum decimal.NullDecimal /* NULL without orders */
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetProfitByThreadID(?)",
+	if rows, err = s.db.Query("call cryptopump.GetProfitByThreadID(?)",
 		sessionData.ThreadID); err != nil {
 
 		functions.Logger(&types.LogEntry{
@@ -850,22 +1267,23 @@ func GetProfitByThreadID(
 	}
 
 	for rows.Next() {
-		err = rows.Scan(&profit)
+		err = rows.Scan(&sum)
 	}
 
 	rows.Close()
 
-	return math.Round(profit*100) / 100, err
+	return sum.Decimal.Round(2).InexactFloat64(), err
 
 }
 
-// GetProfit Retrieve total profit
-func GetProfit(
+// GetProfit Retrieve total profit, from the ledger fills and the orders from before the ledger
+func (s *Storage) GetProfit(
 	sessionData *types.Session) (profit float64, err error) {
 
 	var rows *sql.Rows
+	var sum decimal.NullDecimal /* NULL without orders */
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetProfit()"); err != nil {
+	if rows, err = s.db.Query("call cryptopump.GetProfit()"); err != nil {
 
 		functions.Logger(&types.LogEntry{
 			Config:   nil,
@@ -881,22 +1299,22 @@ func GetProfit(
 	}
 
 	for rows.Next() {
-		err = rows.Scan(&profit)
+		err = rows.Scan(&sum)
 	}
 
 	rows.Close()
 
-	return math.Round(profit*100) / 100, err
+	return sum.Decimal.Round(2).InexactFloat64(), err
 
 }
 
 // GetThreadCount Retrieve Running Thread Count
-func GetThreadCount(
+func (s *Storage) GetThreadCount(
 	sessionData *types.Session) (count int, err error) {
 
 	var rows *sql.Rows
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetThreadCount()"); err != nil {
+	if rows, err = s.db.Query("call cryptopump.GetThreadCount()"); err != nil {
 
 		functions.Logger(&types.LogEntry{
 			Config:   nil,
@@ -922,12 +1340,13 @@ func GetThreadCount(
 }
 
 // GetThreadAmount Retrieve Thread Dollar Amount
-func GetThreadAmount(
+func (s *Storage) GetThreadAmount(
 	sessionData *types.Session) (amount float64, err error) {
 
 	var rows *sql.Rows
+	var sum decimal.NullDecimal /* NULL without orders */
 
-	if rows, err = sessionData.Db.Query("call cryptopump.GetThreadTransactionAmount()"); err != nil {
+	if rows, err = s.db.Query("call cryptopump.GetThreadTransactionAmount()"); err != nil {
 
 		functions.Logger(&types.LogEntry{
 			Config:   nil,
@@ -943,11 +1362,536 @@ func GetThreadAmount(
 	}
 
 	for rows.Next() {
-		err = rows.Scan(&amount)
+		err = rows.Scan(&sum)
+	}
+
+	rows.Close()
+
+	return sum.Decimal.Round(2).InexactFloat64(), err
+
+}
+
+// GetThreadPositions Retrieve Thread transactions count, quantity and amount by symbol across all threads
+func (s *Storage) GetThreadPositions(
+	sessionData *types.Session) (positions []types.Position, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.GetThreadTransactionPositions()"); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return nil, err
+
+	}
+
+	for rows.Next() {
+
+		var position types.Position
+		var quantity, amount decimal.Decimal
+		err = rows.Scan(
+			&position.Symbol,
+			&position.Count,
+			&quantity,
+			&amount)
+
+		position.Quantity = quantity.InexactFloat64()
+		position.Amount = amount.Round(2).InexactFloat64()
+		positions = append(positions, position)
+
+	}
+
+	rows.Close()
+
+	return positions, err
+
+}
+
+// AcquireLease Acquire or renew a lease for the ThreadID, unless held by another ThreadID and not expired. The current owner is returned.
+func (s *Storage) AcquireLease(
+	sessionData *types.Session,
+	name string,
+	duration time.Duration) (lease types.Lease, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.AcquireLease(?,?,?)",
+		name,
+		sessionData.ThreadID,
+		int(duration.Seconds())); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return lease, err
+
+	}
+
+	lease.Name = name
+
+	for rows.Next() {
+		err = rows.Scan(
+			&lease.ThreadID,
+			&lease.Token)
+	}
+
+	rows.Close()
+
+	return lease, err
+
+}
+
+// CheckLease Check the lease is held by the ThreadID with the fencing token and not expired
+func (s *Storage) CheckLease(
+	sessionData *types.Session,
+	name string,
+	token int64) (held bool, err error) {
+
+	var rows *sql.Rows
+	var count int
+
+	if rows, err = s.db.Query("call cryptopump.CheckLease(?,?,?)",
+		name,
+		sessionData.ThreadID,
+		token); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return false, err
+
+	}
+
+	for rows.Next() {
+		err = rows.Scan(&count)
+	}
+
+	rows.Close()
+
+	return count > 0, err
+
+}
+
+// ReleaseLease Expire the lease if held by the ThreadID
+func (s *Storage) ReleaseLease(
+	sessionData *types.Session,
+	name string) (err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.ReleaseLease(?,?)",
+		name,
+		sessionData.ThreadID); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return err
+
+	}
+
+	rows.Close()
+
+	return nil
+
+}
+
+// SaveKline Save a final kline for the session symbol, replacing the kline with the same interval and open time
+func (s *Storage) SaveKline(
+	sessionData *types.Session,
+	kline types.WsKline) (err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.SaveKline(?,?,?,?,?,?,?,?,?,?,?,?)",
+		sessionData.Symbol,
+		kline.Interval,
+		kline.StartTime,
+		kline.EndTime,
+		kline.Open,
+		kline.High,
+		kline.Low,
+		kline.Close,
+		kline.Volume,
+		kline.QuoteVolume,
+		kline.ActiveBuyVolume,
+		kline.ActiveBuyQuoteVolume); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return err
+
+	}
+
+	rows.Close()
+
+	return nil
+
+}
+
+// GetKlines Retrieve the klines saved for the session symbol and interval, opened from startTime to endTime and ordered by open time
+func (s *Storage) GetKlines(
+	sessionData *types.Session,
+	interval string,
+	startTime int64,
+	endTime int64) (klines []types.WsKline, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.GetKlines(?,?,?,?)",
+		sessionData.Symbol,
+		interval,
+		startTime,
+		endTime); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return nil, err
+
+	}
+
+	for rows.Next() {
+
+		kline := types.WsKline{
+			Symbol:   sessionData.Symbol,
+			Interval: interval,
+			IsFinal:  true,
+		}
+
+		err = rows.Scan(
+			&kline.StartTime,
+			&kline.EndTime,
+			&kline.Open,
+			&kline.High,
+			&kline.Low,
+			&kline.Close,
+			&kline.Volume,
+			&kline.QuoteVolume,
+			&kline.ActiveBuyVolume,
+			&kline.ActiveBuyQuoteVolume)
+
+		klines = append(klines, kline)
+
 	}
 
 	rows.Close()
 
-	return math.Round(amount*100) / 100, err
+	return klines, err
+
+}
+
+// SaveLot Save the lot of a filled BUY order to the ledger
+func (s *Storage) SaveLot(
+	sessionData *types.Session,
+	lot types.Lot) (err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.SaveLot(?,?,?,?,?,?,?,?)",
+		sessionData.ThreadID,
+		sessionData.ThreadIDSession,
+		lot.OrderID,
+		lot.Symbol,
+		lot.Quantity,
+		lot.Cost,
+		lot.Commission,
+		lot.OpenTime); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:  nil,
+			Market:  nil,
+			Session: sessionData,
+			Order: &types.Order{
+				OrderID: lot.OrderID,
+			},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return err
+
+	}
+
+	rows.Close()
+
+	return nil
+
+}
+
+// GetOpenLots Retrieve the lots of the ThreadID with quantity not closed, oldest first
+func (s *Storage) GetOpenLots(
+	sessionData *types.Session) (lots []types.Lot, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.GetOpenLots(?)",
+		sessionData.ThreadID); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return nil, err
+
+	}
+
+	for rows.Next() {
+
+		var lot types.Lot
+		err = rows.Scan(
+			&lot.OrderID,
+			&lot.Symbol,
+			&lot.Quantity,
+			&lot.Remaining,
+			&lot.Cost,
+			&lot.Commission,
+			&lot.OpenTime)
+
+		lots = append(lots, lot)
+
+	}
+
+	rows.Close()
+
+	return lots, err
+
+}
+
+// SaveFill Save the fill of a SELL order to the ledger and close the quantity of its lot
+func (s *Storage) SaveFill(
+	sessionData *types.Session,
+	fill types.Fill) (err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.SaveFill(?,?,?,?,?,?,?,?,?,?,?,?)",
+		sessionData.ThreadID,
+		sessionData.ThreadIDSession,
+		fill.SellOrderID,
+		fill.LotOrderID,
+		fill.Symbol,
+		fill.Quantity,
+		fill.Proceeds,
+		fill.Cost,
+		fill.Commission,
+		fill.Profit,
+		fill.HoldingTime,
+		fill.TransactTime); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:  nil,
+			Market:  nil,
+			Session: sessionData,
+			Order: &types.Order{
+				OrderID:       fill.SellOrderID,
+				OrderIDSource: fill.LotOrderID,
+			},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return err
+
+	}
+
+	rows.Close()
+
+	return nil
+
+}
+
+// GetTrades Retrieve the orders filled from startTime to endTime for the ThreadIDs, all when empty, with the cost and profit of the ledger fills of SELL orders
+func (s *Storage) GetTrades(
+	sessionData *types.Session,
+	startTime int64,
+	endTime int64,
+	threadIDs []string) (trades []types.Trade, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.GetTrades(?,?,?)",
+		startTime,
+		endTime,
+		strings.Join(threadIDs, ",")); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return nil, err
+
+	}
+
+	for rows.Next() {
+
+		var trade types.Trade
+		err = rows.Scan(
+			&trade.OrderID,
+			&trade.ThreadID,
+			&trade.Symbol,
+			&trade.Side,
+			&trade.Quantity,
+			&trade.QuoteQuantity,
+			&trade.CommissionAmount,
+			&trade.CommissionAsset,
+			&trade.Commission,
+			&trade.Cost,
+			&trade.Profit,
+			&trade.TransactTime)
+
+		trades = append(trades, trade)
+
+	}
+
+	rows.Close()
+
+	return trades, err
+
+}
+
+// GetRisk Retrieve the risk circuit breaker state of the account, shared by the threads using the database. Day is 0 when no state is saved
+func (s *Storage) GetRisk(
+	sessionData *types.Session) (risk types.Risk, err error) {
+
+	var rows *sql.Rows
+	var peak, dayStart decimal.Decimal
+
+	if rows, err = s.db.Query("call cryptopump.GetRisk()"); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return risk, err
+
+	}
+
+	for rows.Next() {
+		err = rows.Scan(
+			&risk.Paused,
+			&peak,
+			&risk.Day,
+			&dayStart)
+	}
+
+	rows.Close()
+
+	risk.Peak = peak.InexactFloat64()
+	risk.DayStart = dayStart.InexactFloat64()
+
+	return risk, err
+
+}
+
+// SaveRisk Save the risk circuit breaker state of the account
+func (s *Storage) SaveRisk(
+	sessionData *types.Session,
+	risk types.Risk) (err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.SaveRisk(?,?,?,?)",
+		risk.Paused,
+		decimal.NewFromFloat(risk.Peak),
+		risk.Day,
+		decimal.NewFromFloat(risk.DayStart)); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return err
+
+	}
+
+	rows.Close()
+
+	return nil
+
+}
+
+// DeleteRisk Delete the risk circuit breaker state of the account
+func (s *Storage) DeleteRisk(
+	sessionData *types.Session) (err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query("call cryptopump.DeleteRisk()"); err != nil {
+
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
+
+		return err
+
+	}
+
+	rows.Close()
+
+	return nil
 
 }
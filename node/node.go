@@ -3,20 +3,23 @@ package node
 import (
 	"cryptopump/functions"
 	"cryptopump/types"
-	"os"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// GetRole Define node role Master or Slave
+const (
+	masterLease   = "master"          /* Lease name for the Master Node */
+	leaseDuration = 100 * time.Second /* Master lease duration, renewed by GetRole every 60 seconds */
+)
+
+// GetRole Define node role Master or Slave.
+// The Master Node holds the master lease in the database, so nodes on different hosts sharing the database elect one master.
 func GetRole(
 	configData *types.Config,
 	sessionData *types.Session) {
 
-	var filename string = "master.lock"
-
-	/* 	If TestNet is enabled will not check for "master.lock" to not affect production systems */
+	/* 	If TestNet is enabled will not acquire the master lease to not affect production systems */
 	if configData.TestNet {
 
 		sessionData.MasterNode = false
@@ -24,73 +27,72 @@ func GetRole(
 
 	}
 
-	/* If Master Node already set to True */
-	if sessionData.MasterNode {
-
-		/* Set access time and modified time of the file to the current time */
-		err := os.Chtimes(filename, time.Now().Local(), time.Now().Local())
+	/* The lease expiry is measured locally from before acquiring it, as node clocks may differ from the database */
+	start := time.Now()
 
-		if err != nil {
+	lease, err := sessionData.Storage.AcquireLease(sessionData, masterLease, leaseDuration)
 
-			functions.Logger(&types.LogEntry{
-				Config:   nil,
-				Market:   nil,
-				Session:  sessionData,
-				Order:    &types.Order{},
-				Message:  functions.GetFunctionName() + " - " + err.Error(),
-				LogLevel: log.DebugLevel,
-			})
+	if err != nil {
 
-		}
+		functions.Logger(&types.LogEntry{
+			Config:   nil,
+			Market:   nil,
+			Session:  sessionData,
+			Order:    &types.Order{},
+			Message:  functions.GetFunctionName() + " - " + err.Error(),
+			LogLevel: log.DebugLevel,
+		})
 
+		/* Master role can't be confirmed */
+		sessionData.MasterNode = false
 		return
 
 	}
 
-	/* If Master Node set to False */
-	if file, err := os.Stat(filename); err == nil { /* Check if "master.lock" is created and modified time */
+	if lease.ThreadID != sessionData.ThreadID {
 
 		sessionData.MasterNode = false
+		return
+
+	}
 
-		if time.Duration(time.Since(file.ModTime()).Seconds()) > 100 { /* Remove "master.lock" if old modified time */
+	sessionData.MasterNode = true
+	sessionData.MasterToken = lease.Token
+	sessionData.MasterExpires = start.Add(leaseDuration)
 
-			if err := os.Remove(filename); err != nil {
+}
 
-				functions.Logger(&types.LogEntry{
-					Config:   nil,
-					Market:   nil,
-					Session:  sessionData,
-					Order:    &types.Order{},
-					Message:  functions.GetFunctionName() + " - " + err.Error(),
-					LogLevel: log.DebugLevel,
-				})
+// IsMaster Check the node holds an unexpired master lease, according to the local clock.
+// A master paused beyond its lease is no longer Master Node when it resumes.
+func IsMaster(
+	sessionData *types.Session) bool {
 
-			}
+	return sessionData.MasterNode && time.Now().Before(sessionData.MasterExpires)
 
-		}
+}
 
-	} else if os.IsNotExist(err) { /* Check if "master.lock" is created and modified time */
+// CheckRole Check the node is still Master Node in the database before acting as master.
+// The fencing token prevents a former master from acting once another node acquired the lease.
+func CheckRole(
+	sessionData *types.Session) bool {
 
-		var file *os.File
-		if file, err = os.Create(filename); err != nil {
+	if !IsMaster(sessionData) {
 
-			functions.Logger(&types.LogEntry{
-				Config:   nil,
-				Market:   nil,
-				Session:  sessionData,
-				Order:    &types.Order{},
-				Message:  functions.GetFunctionName() + " - " + err.Error(),
-				LogLevel: log.DebugLevel,
-			})
+		return false
 
-		}
+	}
 
-		file.Close()
+	held, err := sessionData.Storage.CheckLease(sessionData, masterLease, sessionData.MasterToken)
 
-		sessionData.MasterNode = true
+	if err != nil || !held {
+
+		sessionData.MasterNode = false
+		return false
 
 	}
 
+	return true
+
 }
 
 // ReleaseRole Release node role if Master
@@ -100,20 +102,9 @@ func ReleaseRole(
 	/* Release node role if Master */
 	if sessionData.MasterNode {
 
-		var filename string = "master.lock"
-
-		if err := os.Remove(filename); err != nil {
+		_ = sessionData.Storage.ReleaseLease(sessionData, masterLease)
 
-			functions.Logger(&types.LogEntry{
-				Config:   nil,
-				Market:   nil,
-				Session:  sessionData,
-				Order:    &types.Order{},
-				Message:  functions.GetFunctionName() + " - " + err.Error(),
-				LogLevel: log.DebugLevel,
-			})
-
-		}
+		sessionData.MasterNode = false
 
 	}
 
@@ -0,0 +1,659 @@
+package optimizer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"cryptopump/backtest"
+	"cryptopump/functions"
+	"cryptopump/types"
+
+	"github.com/spf13/viper"
+)
+
+// Parameter define the values searched for a configuration key
+type Parameter struct {
+	Key    string   /* Configuration key as in config.yml, e.g. profit_min */
+	Values []string /* Values searched */
+}
+
+// Options define the parameter search
+type Options struct {
+	ConfigFile  string           /* Base configuration file */
+	Klines      []types.WsKline  /* Historical klines replayed for each candidate */
+	Filters     backtest.Filters /* Exchange filters for the symbol */
+	Funds       float64          /* Initial fiat funds. DryRun fiat funds from configuration when zero */
+	Parameters  []Parameter      /* Parameters searched */
+	Search      string           /* grid, random or genetic */
+	Samples     int              /* Candidates evaluated by random search and population of genetic search */
+	Generations int              /* Generations of genetic search */
+	Workers     int              /* Backtests running in parallel */
+	Rank        string           /* profit, drawdown or trades */
+	Seed        int64            /* Seed for random and genetic search */
+}
+
+// Result define the backtest result for a combination of parameter values
+type Result struct {
+	Values []string /* Parameter values in the order of Options.Parameters */
+	Report *backtest.Report
+}
+
+/* candidate is a combination of indexes into the parameter values */
+type candidate []int
+
+// ParseParameter Parse a parameter as key=min:max:step or key=value1,value2,...
+func ParseParameter(
+	value string) (parameter Parameter, err error) {
+
+	key := strings.SplitN(value, "=", 2)
+
+	if len(key) != 2 || key[0] == "" || key[1] == "" {
+
+		return parameter, errors.New("Optimizer - Invalid parameter " + value + ", expected key=min:max:step or key=value1,value2")
+
+	}
+
+	parameter.Key = strings.ToLower(key[0])
+
+	if !strings.Contains(key[1], ":") {
+
+		parameter.Values = strings.Split(key[1], ",")
+
+		return parameter, nil
+
+	}
+
+	bounds := strings.Split(key[1], ":")
+
+	if len(bounds) != 3 {
+
+		return parameter, errors.New("Optimizer - Invalid range " + key[1] + ", expected min:max:step")
+
+	}
+
+	var min, max, step float64
+
+	if min, err = strconv.ParseFloat(bounds[0], 64); err != nil {
+
+		return parameter, err
+
+	}
+
+	if max, err = strconv.ParseFloat(bounds[1], 64); err != nil {
+
+		return parameter, err
+
+	}
+
+	if step, err = strconv.ParseFloat(bounds[2], 64); err != nil {
+
+		return parameter, err
+
+	}
+
+	if step <= 0 || max < min {
+
+		return parameter, errors.New("Optimizer - Invalid range " + key[1])
+
+	}
+
+	/* Format values with the precision used to define the range */
+	precision := 0
+
+	for _, bound := range bounds {
+
+		if i := strings.Index(bound, "."); i >= 0 && len(bound)-i-1 > precision {
+
+			precision = len(bound) - i - 1
+
+		}
+
+	}
+
+	for i := 0; min+float64(i)*step <= max+step/1e6; i++ {
+
+		parameter.Values = append(parameter.Values, strconv.FormatFloat(min+float64(i)*step, 'f', precision, 64))
+
+	}
+
+	return parameter, nil
+
+}
+
+// Run Search parameter values backtesting each candidate, and return the results ranked best first
+func Run(
+	options *Options) (results []*Result, err error) {
+
+	var base *viper.Viper
+
+	if base, err = loadConfig(options.ConfigFile); err != nil {
+
+		return nil, err
+
+	}
+
+	if len(options.Parameters) == 0 {
+
+		return nil, errors.New("Optimizer - No parameters to search")
+
+	}
+
+	for _, parameter := range options.Parameters {
+
+		if !base.IsSet("config." + parameter.Key) {
+
+			return nil, errors.New("Optimizer - Unknown configuration key " + parameter.Key)
+
+		}
+
+	}
+
+	if options.Rank != "profit" && options.Rank != "drawdown" && options.Rank != "trades" {
+
+		return nil, errors.New("Optimizer - Unknown rank " + options.Rank + ", expected profit, drawdown or trades")
+
+	}
+
+	if options.Workers < 1 {
+
+		options.Workers = 1
+
+	}
+
+	if options.Samples < 2 {
+
+		options.Samples = 2
+
+	}
+
+	o := &optimizer{
+		options: options,
+		random:  rand.New(rand.NewSource(options.Seed)),
+		results: make(map[string]*Result),
+	}
+
+	switch options.Search {
+	case "grid":
+
+		err = o.evaluate(o.grid())
+
+	case "random":
+
+		err = o.evaluate(o.sample(options.Samples))
+
+	case "genetic":
+
+		err = o.genetic()
+
+	default:
+
+		return nil, errors.New("Optimizer - Unknown search " + options.Search + ", expected grid, random or genetic")
+
+	}
+
+	if err != nil {
+
+		return nil, err
+
+	}
+
+	for _, result := range o.results {
+
+		results = append(results, result)
+
+	}
+
+	o.rank(results)
+
+	return results, nil
+
+}
+
+// Write Save the results as configuration templates in path, and return the filenames.
+// Templates are named after the parameter values, e.g. config_0003-30.yml for profit_min 0.003 and buy_rsi7_entry 30.
+func Write(
+	options *Options,
+	results []*Result,
+	path string) (filenames []string, err error) {
+
+	for _, result := range results {
+
+		var v *viper.Viper
+
+		if v, err = loadConfig(options.ConfigFile); err != nil {
+
+			return filenames, err
+
+		}
+
+		setValues(v, options.Parameters, result.Values)
+
+		var name []string
+
+		for _, value := range result.Values {
+
+			name = append(name, strings.Map(func(r rune) rune {
+				switch {
+				case r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+					return r
+				}
+				return -1
+			}, value))
+
+		}
+
+		filename := filepath.Join(path, "config_"+strings.Join(name, "-")+".yml")
+
+		if err = v.WriteConfigAs(filename); err != nil {
+
+			return filenames, err
+
+		}
+
+		filenames = append(filenames, filename)
+
+	}
+
+	return filenames, nil
+
+}
+
+// Print Write the results table
+func Print(
+	w io.Writer,
+	options *Options,
+	results []*Result) {
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprint(tw, "Rank\t")
+
+	for _, parameter := range options.Parameters {
+
+		fmt.Fprint(tw, parameter.Key+"\t")
+
+	}
+
+	fmt.Fprintln(tw, "Net Profit\tDrawdown\tTrades\tMax Threads\t")
+
+	for key, result := range results {
+
+		fmt.Fprintf(tw, "%d\t", key+1)
+
+		for _, value := range result.Values {
+
+			fmt.Fprint(tw, value+"\t")
+
+		}
+
+		fmt.Fprintf(tw, "%.2f\t%.2f%%\t%d\t%d\t\n",
+			result.Report.NetProfit,
+			result.Report.MaxDrawdown*100,
+			len(result.Report.Trades),
+			result.Report.MaxThreads)
+
+	}
+
+	tw.Flush()
+
+}
+
+/* optimizer holds the state of a parameter search */
+type optimizer struct {
+	options *Options
+	random  *rand.Rand
+	results map[string]*Result /* Results by candidate, each candidate is evaluated once */
+}
+
+/* Load the base configuration file in a new viper instance */
+func loadConfig(
+	filename string) (v *viper.Viper, err error) {
+
+	if _, err = os.Stat(filename); err != nil {
+
+		return nil, err
+
+	}
+
+	v = viper.New()
+	v.SetConfigFile(filename)
+
+	if err = v.ReadInConfig(); err != nil {
+
+		return nil, err
+
+	}
+
+	return v, nil
+
+}
+
+/* Override configuration keys with the parameter values */
+func setValues(
+	v *viper.Viper,
+	parameters []Parameter,
+	values []string) {
+
+	for key, parameter := range parameters {
+
+		v.Set("config."+parameter.Key, values[key])
+
+	}
+
+}
+
+/* Parameter values for a candidate */
+func (o *optimizer) values(
+	c candidate) (values []string) {
+
+	for key, index := range c {
+
+		values = append(values, o.options.Parameters[key].Values[index])
+
+	}
+
+	return values
+
+}
+
+/* Number of combinations of parameter values */
+func (o *optimizer) size() float64 {
+
+	size := 1.0
+
+	for _, parameter := range o.options.Parameters {
+
+		size *= float64(len(parameter.Values))
+
+	}
+
+	return size
+
+}
+
+/* Every combination of parameter values */
+func (o *optimizer) grid() (candidates []candidate) {
+
+	c := make(candidate, len(o.options.Parameters))
+
+	for {
+
+		candidates = append(candidates, append(candidate{}, c...))
+
+		/* Increment indexes as a mixed radix number */
+		key := len(c) - 1
+
+		for ; key >= 0; key-- {
+
+			if c[key]++; c[key] < len(o.options.Parameters[key].Values) {
+
+				break
+
+			}
+
+			c[key] = 0
+
+		}
+
+		if key < 0 {
+
+			return candidates
+
+		}
+
+	}
+
+}
+
+/* Random distinct combinations of parameter values, limited by the number of combinations */
+func (o *optimizer) sample(
+	n int) (candidates []candidate) {
+
+	n = int(math.Min(float64(n), o.size()))
+	seen := make(map[string]bool)
+
+	for len(candidates) < n {
+
+		c := make(candidate, len(o.options.Parameters))
+
+		for key, parameter := range o.options.Parameters {
+
+			c[key] = o.random.Intn(len(parameter.Values))
+
+		}
+
+		if !seen[fmt.Sprint(c)] {
+
+			seen[fmt.Sprint(c)] = true
+			candidates = append(candidates, c)
+
+		}
+
+	}
+
+	return candidates
+
+}
+
+/* Evolve a population of candidates, keeping the best half of each generation and breeding it to refill the population */
+func (o *optimizer) genetic() (err error) {
+
+	population := o.sample(o.options.Samples)
+
+	for generation := 0; ; generation++ {
+
+		if err = o.evaluate(population); err != nil {
+
+			return err
+
+		}
+
+		if generation == o.options.Generations {
+
+			return nil
+
+		}
+
+		sort.SliceStable(population, func(i, j int) bool {
+			return o.better(o.results[fmt.Sprint(population[i])].Report, o.results[fmt.Sprint(population[j])].Report)
+		})
+
+		elite := population[:(len(population)+1)/2]
+		next := append([]candidate{}, elite...)
+
+		for len(next) < len(population) {
+
+			a := elite[o.random.Intn(len(elite))]
+			b := elite[o.random.Intn(len(elite))]
+			child := make(candidate, len(a))
+
+			for key := range child {
+
+				/* Uniform crossover */
+				child[key] = a[key]
+
+				if o.random.Intn(2) == 0 {
+
+					child[key] = b[key]
+
+				}
+
+				/* Mutate to a neighbour value */
+				if o.random.Intn(len(child)) == 0 {
+
+					child[key] += o.random.Intn(3) - 1
+
+					if child[key] < 0 {
+
+						child[key] = 0
+
+					} else if child[key] >= len(o.options.Parameters[key].Values) {
+
+						child[key] = len(o.options.Parameters[key].Values) - 1
+
+					}
+
+				}
+
+			}
+
+			next = append(next, child)
+
+		}
+
+		population = next
+
+	}
+
+}
+
+/* Backtest candidates not evaluated yet, in parallel */
+func (o *optimizer) evaluate(
+	candidates []candidate) (err error) {
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+
+	jobs := make(chan candidate)
+
+	for worker := 0; worker < o.options.Workers; worker++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			for c := range jobs {
+
+				result, e := o.backtest(c)
+
+				mutex.Lock()
+
+				if e != nil && err == nil {
+
+					err = e
+
+				} else if e == nil {
+
+					o.results[fmt.Sprint(c)] = result
+
+				}
+
+				mutex.Unlock()
+
+			}
+
+		}()
+
+	}
+
+	queued := make(map[string]bool)
+
+	for _, c := range candidates {
+
+		if o.results[fmt.Sprint(c)] == nil && !queued[fmt.Sprint(c)] {
+
+			queued[fmt.Sprint(c)] = true
+			jobs <- c
+
+		}
+
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return err
+
+}
+
+/* Backtest a candidate */
+func (o *optimizer) backtest(
+	c candidate) (result *Result, err error) {
+
+	var v *viper.Viper
+
+	if v, err = loadConfig(o.options.ConfigFile); err != nil {
+
+		return nil, err
+
+	}
+
+	values := o.values(c)
+	setValues(v, o.options.Parameters, values)
+
+	configData := functions.LoadConfig(v)
+
+	if o.options.Funds > 0 {
+
+		configData.DryRunFiatFunds = o.options.Funds
+
+	}
+
+	var report *backtest.Report
+
+	if report, err = backtest.Run(configData, o.options.Klines, o.options.Filters); err != nil {
+
+		return nil, err
+
+	}
+
+	return &Result{
+		Values: values,
+		Report: report,
+	}, nil
+
+}
+
+/* Sort results best first */
+func (o *optimizer) rank(
+	results []*Result) {
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return o.better(results[i].Report, results[j].Report)
+	})
+
+}
+
+/* Compare reports by the rank criteria, using the other criteria to break ties */
+func (o *optimizer) better(
+	a *backtest.Report,
+	b *backtest.Report) bool {
+
+	profit := func() (bool, bool) { return a.NetProfit > b.NetProfit, a.NetProfit != b.NetProfit }
+	drawdown := func() (bool, bool) { return a.MaxDrawdown < b.MaxDrawdown, a.MaxDrawdown != b.MaxDrawdown }
+	trades := func() (bool, bool) { return len(a.Trades) > len(b.Trades), len(a.Trades) != len(b.Trades) }
+
+	criteria := []func() (bool, bool){profit, drawdown, trades}
+
+	switch o.options.Rank {
+	case "drawdown":
+		criteria = []func() (bool, bool){drawdown, profit, trades}
+	case "trades":
+		criteria = []func() (bool, bool){trades, profit, drawdown}
+	}
+
+	for _, criterion := range criteria {
+
+		if better, differ := criterion(); differ {
+
+			return better
+
+		}
+
+	}
+
+	return false
+
+}
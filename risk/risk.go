@@ -0,0 +1,380 @@
+package risk
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"cryptopump/exchange"
+	"cryptopump/functions"
+	"cryptopump/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Breach define a risk limit breached, to be notified
+type Breach struct {
+	Message string /* Limit breached, empty when none */
+	Paused  bool   /* Circuit breaker tripped, BUY is paused until Reset. Otherwise BUY resumes once the exposure is back within the limit. */
+}
+
+/* Risk state of the account. The circuit breaker, peak and day start are shared by the threads using the storage and saved in it, so a breach pauses every thread until Reset. */
+type state struct {
+	mutex    sync.Mutex
+	paused   string            /* Circuit breaker breached, BUY is paused for every symbol until Reset */
+	blocked  map[string]string /* Exposure limit breached by symbol, BUY of the symbol is paused while breached */
+	day      time.Time         /* Day of dayStart */
+	dayStart float64           /* Realized and unrealized profit at the start of the day */
+	peak     float64           /* Realized and unrealized profit peak */
+	rebase   bool              /* Measure daily loss and drawdown from the next profit retrieved */
+}
+
+/* Risk state by storage, the account traded by the threads sharing it. Backtests use their own storage and keep their own state. */
+var states = struct {
+	sync.Mutex
+	m map[types.Storage]*state
+}{m: make(map[types.Storage]*state)}
+
+/* Retrieve the risk state of the session storage, loading the state saved in storage on first use */
+func getState(
+	sessionData *types.Session) *state {
+
+	states.Lock()
+	defer states.Unlock()
+
+	s, ok := states.m[sessionData.Storage]
+
+	if !ok {
+
+		s = &state{blocked: make(map[string]string), rebase: true}
+
+		if saved, err := sessionData.Storage.GetRisk(sessionData); err == nil {
+
+			s.paused = saved.Paused
+
+			if saved.Day != 0 {
+
+				s.peak = saved.Peak
+				s.day = time.Unix(0, saved.Day*int64(time.Millisecond)).UTC()
+				s.dayStart = saved.DayStart
+				s.rebase = false
+
+			}
+
+		}
+
+		states.m[sessionData.Storage] = s
+
+	}
+
+	return s
+
+}
+
+/* Save the circuit breaker, peak and day start. Must be called with the state mutex locked. */
+func (s *state) save(
+	sessionData *types.Session) {
+
+	_ = sessionData.Storage.SaveRisk(sessionData, types.Risk{
+		Paused:   s.paused,
+		Peak:     s.peak,
+		Day:      s.day.UnixNano() / int64(time.Millisecond),
+		DayStart: s.dayStart,
+	})
+
+}
+
+// Check Validate the risk limits before a BUY of buyQuantityFiat.
+// The BUY is refused while a limit is breached, and breach describes a limit breached since the last Check, to be notified.
+func Check(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session,
+	buyQuantityFiat float64) (allowed bool, breach Breach) {
+
+	s := getState(sessionData)
+
+	breach = Update(configData, marketData, sessionData)
+
+	s.mutex.Lock()
+	paused := s.paused
+	s.mutex.Unlock()
+
+	if paused != "" {
+
+		return false, breach
+
+	}
+
+	blocked := exposure(configData, sessionData, buyQuantityFiat)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if blocked != "" && blocked != s.blocked[sessionData.Symbol] {
+
+		logRisk(configData, marketData, sessionData, blocked)
+		breach = Breach{Message: blocked}
+
+	}
+
+	s.blocked[sessionData.Symbol] = blocked
+
+	return blocked == "", breach
+
+}
+
+// Update Track realized and unrealized profit across all threads, and trip the circuit breaker when the daily loss or drawdown limit is breached.
+// Returns the breach when the circuit breaker is tripped.
+func Update(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session) (breach Breach) {
+
+	if configData.RiskMaxDailyLoss == 0 && configData.RiskMaxDrawdown == 0 {
+
+		return Breach{}
+
+	}
+
+	profit, err := getProfit(configData, marketData, sessionData)
+
+	if err != nil {
+
+		return Breach{}
+
+	}
+
+	s := getState(sessionData)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	day := functions.Now(sessionData).UTC().Truncate(24 * time.Hour)
+	changed := s.rebase
+
+	if s.rebase || !day.Equal(s.day) {
+
+		s.day = day
+		s.dayStart = profit
+		changed = true
+
+	}
+
+	if s.rebase || profit > s.peak {
+
+		s.peak = profit
+		changed = true
+
+	}
+
+	s.rebase = false
+
+	if s.paused != "" {
+
+		if changed {
+
+			s.save(sessionData)
+
+		}
+
+		return Breach{}
+
+	}
+
+	switch {
+	case configData.RiskMaxDailyLoss > 0 && s.dayStart-profit >= configData.RiskMaxDailyLoss:
+
+		s.paused = fmt.Sprintf("Daily loss %.2f reached the limit %.2f, paused until reset", s.dayStart-profit, configData.RiskMaxDailyLoss)
+
+	case configData.RiskMaxDrawdown > 0 && s.peak-profit >= configData.RiskMaxDrawdown:
+
+		s.paused = fmt.Sprintf("Drawdown %.2f from peak %.2f reached the limit %.2f, paused until reset", s.peak-profit, s.peak, configData.RiskMaxDrawdown)
+
+	default:
+
+		if changed {
+
+			s.save(sessionData)
+
+		}
+
+		return Breach{}
+
+	}
+
+	s.save(sessionData)
+	logRisk(configData, marketData, sessionData, s.paused)
+
+	return Breach{Message: s.paused, Paused: true}
+
+}
+
+// Reset Resume buying on every thread sharing the storage after a circuit breaker breach. Daily loss and drawdown are measured again from the current profit.
+// The state saved in storage is deleted.
+func Reset(
+	sessionData *types.Session) {
+
+	s := getState(sessionData)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.paused = ""
+	s.blocked = make(map[string]string)
+	s.rebase = true
+
+	_ = sessionData.Storage.DeleteRisk(sessionData)
+
+}
+
+// Status Retrieve the limit breached pausing BUY of the session symbol, or empty when buying is allowed
+func Status(
+	sessionData *types.Session) string {
+
+	s := getState(sessionData)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.paused != "" {
+
+		return s.paused
+
+	}
+
+	return s.blocked[sessionData.Symbol]
+
+}
+
+/* Validate the exposure limits for a BUY of buyQuantityFiat. Returns the limit breached, or empty. */
+func exposure(
+	configData *types.Config,
+	sessionData *types.Session,
+	buyQuantityFiat float64) string {
+
+	if configData.RiskMaxThreads == 0 &&
+		configData.RiskMaxFiatSymbol == 0 &&
+		configData.RiskMaxFiatTotal == 0 {
+
+		return ""
+
+	}
+
+	positions, err := sessionData.Storage.GetThreadPositions(sessionData)
+
+	if err != nil {
+
+		return ""
+
+	}
+
+	var count int
+	var amountSymbol float64
+
+	for _, position := range positions {
+
+		count += position.Count
+
+		if position.Symbol == sessionData.Symbol {
+
+			amountSymbol = position.Amount
+
+		}
+
+	}
+
+	if configData.RiskMaxThreads > 0 && count >= configData.RiskMaxThreads {
+
+		return fmt.Sprintf("Thread transactions %d reached the limit %d", count, configData.RiskMaxThreads)
+
+	}
+
+	if configData.RiskMaxFiatSymbol > 0 && amountSymbol+buyQuantityFiat > configData.RiskMaxFiatSymbol {
+
+		return fmt.Sprintf("%s amount %.2f would exceed the limit %.2f", sessionData.Symbol, amountSymbol+buyQuantityFiat, configData.RiskMaxFiatSymbol)
+
+	}
+
+	if configData.RiskMaxFiatTotal > 0 {
+
+		amount, err := sessionData.Storage.GetThreadAmount(sessionData)
+
+		if err == nil && amount+buyQuantityFiat > configData.RiskMaxFiatTotal {
+
+			return fmt.Sprintf("Total amount %.2f would exceed the limit %.2f", amount+buyQuantityFiat, configData.RiskMaxFiatTotal)
+
+		}
+
+	}
+
+	return ""
+
+}
+
+/* Retrieve realized profit and the unrealized profit of the thread transactions across all threads, valued at market price */
+func getProfit(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session) (profit float64, err error) {
+
+	var positions []types.Position
+
+	if profit, err = sessionData.Storage.GetProfit(sessionData); err != nil {
+
+		return 0, err
+
+	}
+
+	if positions, err = sessionData.Storage.GetThreadPositions(sessionData); err != nil {
+
+		return 0, err
+
+	}
+
+	for _, position := range positions {
+
+		price := marketData.Price
+
+		if position.Symbol != sessionData.Symbol || price == 0 {
+
+			/* Positions without a price are valued at the amount paid */
+			if position.Symbol == "" {
+
+				continue
+
+			}
+
+			if price, err = exchange.GetPrice(configData, sessionData, position.Symbol); err != nil {
+
+				continue
+
+			}
+
+		}
+
+		profit += position.Quantity*price - position.Amount
+
+	}
+
+	return profit, nil
+
+}
+
+/* Log a risk limit breached */
+func logRisk(
+	configData *types.Config,
+	marketData *types.Market,
+	sessionData *types.Session,
+	message string) {
+
+	functions.Logger(&types.LogEntry{
+		Config:   configData,
+		Market:   marketData,
+		Session:  sessionData,
+		Order:    &types.Order{},
+		Message:  "RISK - " + message,
+		LogLevel: log.InfoLevel,
+	})
+
+}
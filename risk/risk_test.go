@@ -0,0 +1,122 @@
+package risk
+
+import (
+	"testing"
+
+	"cryptopump/memory"
+	"cryptopump/types"
+
+	"github.com/shopspring/decimal"
+)
+
+/* Exchange quoting every symbol at price */
+type priceExchange struct {
+	types.Exchange
+	price float64
+}
+
+func (e *priceExchange) GetPrice(
+	sessionData *types.Session,
+	symbol string) (price float64, err error) {
+
+	return e.price, nil
+
+}
+
+/* Sessions of two threads trading BTCUSDT and ETHUSDT on the same storage, holding 1 BTCUSDT bought for 100 */
+func newSessions() (btc *types.Session, eth *types.Session, quote *priceExchange) {
+
+	storage := memory.New()
+	quote = &priceExchange{price: 100}
+
+	btc = &types.Session{ThreadID: "btc", Symbol: "BTCUSDT", Backtest: true, Storage: storage, Exchange: quote}
+	eth = &types.Session{ThreadID: "eth", Symbol: "ETHUSDT", Backtest: true, Storage: storage, Exchange: quote}
+
+	_ = storage.SaveOrder(btc, "btc", decimal.NewFromInt(100), decimal.NewFromInt(1), 1, decimal.NewFromInt(100), "BUY", "FILLED", "BTCUSDT", 1000, decimal.Zero, "", decimal.Zero)
+	_ = storage.SaveThreadTransaction(btc, 1, decimal.NewFromInt(100), decimal.NewFromInt(100), decimal.NewFromInt(1))
+
+	return btc, eth, quote
+
+}
+
+func TestCircuitBreakerShared(t *testing.T) {
+
+	configData := &types.Config{RiskMaxDrawdown: 10}
+	btc, eth, quote := newSessions()
+
+	if breach := Update(configData, &types.Market{Price: 100}, btc); breach.Message != "" {
+
+		t.Fatalf("Update() at the peak = %q, want no breach", breach.Message)
+
+	}
+
+	quote.price = 85
+
+	if breach := Update(configData, &types.Market{Price: 85}, btc); !breach.Paused {
+
+		t.Fatalf("Update() 15 below the peak = %+v, want the circuit breaker tripped", breach)
+
+	}
+
+	/* The breach of the BTCUSDT thread pauses the ETHUSDT thread */
+	if allowed, _ := Check(configData, &types.Market{Price: 2000}, eth, 10); allowed {
+
+		t.Errorf("Check() of ETHUSDT after the BTCUSDT breach allowed, want refused")
+
+	}
+
+	if saved, _ := eth.Storage.GetRisk(eth); saved.Paused == "" {
+
+		t.Errorf("GetRisk() Paused empty, want the breach saved for the account")
+
+	}
+
+	/* Reset from any thread resumes every thread */
+	Reset(eth)
+
+	if status := Status(btc); status != "" {
+
+		t.Errorf("Status() of BTCUSDT after Reset = %q, want empty", status)
+
+	}
+
+	if allowed, _ := Check(configData, &types.Market{Price: 85}, btc, 10); !allowed {
+
+		t.Errorf("Check() of BTCUSDT after Reset refused, want allowed with drawdown measured from the current profit")
+
+	}
+
+}
+
+func TestExposureBySymbol(t *testing.T) {
+
+	configData := &types.Config{RiskMaxFiatSymbol: 150}
+	btc, eth, _ := newSessions()
+
+	allowed, breach := Check(configData, &types.Market{Price: 100}, btc, 100)
+
+	if allowed || breach.Paused || breach.Message == "" {
+
+		t.Errorf("Check() of BTCUSDT above the symbol limit = %v, %+v, want refused without the circuit breaker", allowed, breach)
+
+	}
+
+	if allowed, _ := Check(configData, &types.Market{Price: 2000}, eth, 100); !allowed {
+
+		t.Errorf("Check() of ETHUSDT refused by the BTCUSDT symbol limit, want allowed")
+
+	}
+
+	if status := Status(eth); status != "" {
+
+		t.Errorf("Status() of ETHUSDT = %q, want empty", status)
+
+	}
+
+	if status := Status(btc); status == "" {
+
+		t.Errorf("Status() of BTCUSDT empty, want the symbol limit")
+
+	}
+
+}
@@ -0,0 +1,59 @@
+-- Baseline schema: the tables of the cryptopump database, mirroring the MySQL schema up to mysql/migrations/0008_klines.sql.
+-- Lease expiry is stored in Unix milliseconds.
+
+CREATE TABLE IF NOT EXISTS klines (
+	Symbol TEXT NOT NULL,
+	Interval TEXT NOT NULL,
+	OpenTime INTEGER NOT NULL,
+	CloseTime INTEGER NOT NULL,
+	Open TEXT NOT NULL,
+	High TEXT NOT NULL,
+	Low TEXT NOT NULL,
+	Close TEXT NOT NULL,
+	Volume TEXT NOT NULL,
+	QuoteVolume TEXT NOT NULL,
+	ActiveBuyVolume TEXT NOT NULL,
+	ActiveBuyQuoteVolume TEXT NOT NULL,
+	PRIMARY KEY (Symbol, Interval, OpenTime)
+);
+CREATE TABLE IF NOT EXISTS lease (
+	Name TEXT NOT NULL PRIMARY KEY,
+	ThreadID TEXT NOT NULL,
+	Token INTEGER NOT NULL,
+	Expires INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS orders (
+	ClientOrderId TEXT NOT NULL,
+	CummulativeQuoteQty REAL NOT NULL,
+	ExecutedQuantity REAL NOT NULL,
+	OrderID INTEGER NOT NULL PRIMARY KEY,
+	Price REAL NOT NULL,
+	Side TEXT NOT NULL,
+	Status TEXT NOT NULL,
+	Symbol TEXT NOT NULL,
+	TransactTime INTEGER NOT NULL,
+	ThreadID TEXT NOT NULL,
+	ThreadIDSession TEXT NOT NULL,
+	CommissionAmount REAL NOT NULL DEFAULT 0,
+	CommissionAsset TEXT NOT NULL DEFAULT '',
+	LastTradeID INTEGER NOT NULL DEFAULT 0,
+	Commission REAL NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS session (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	ThreadID TEXT NOT NULL UNIQUE,
+	ThreadIDSession TEXT NOT NULL,
+	Exchange TEXT NOT NULL,
+	FiatSymbol TEXT NOT NULL,
+	FiatFunds REAL NOT NULL
+);
+CREATE TABLE IF NOT EXISTS thread (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	ThreadID TEXT NOT NULL,
+	ThreadIDSession TEXT NOT NULL,
+	OrderID INTEGER,
+	CummulativeQuoteQty REAL NOT NULL,
+	Price REAL NOT NULL,
+	ExecutedQuantity REAL NOT NULL,
+	HighPrice REAL NOT NULL DEFAULT 0
+);
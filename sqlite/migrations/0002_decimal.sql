@@ -0,0 +1,56 @@
+-- Exact decimal prices, quantities and amounts, mirroring mysql/migrations/0009_decimal.sql.
+-- SQLite has no decimal type and REAL columns keep about 15 significant digits, so amounts are stored as TEXT holding decimal strings.
+-- SQLite cannot change the type of a column, so the tables are rebuilt with TEXT columns and their rows copied.
+
+CREATE TABLE orders_decimal (
+	ClientOrderId TEXT NOT NULL,
+	CummulativeQuoteQty TEXT NOT NULL,
+	ExecutedQuantity TEXT NOT NULL,
+	OrderID INTEGER NOT NULL PRIMARY KEY,
+	Price TEXT NOT NULL,
+	Side TEXT NOT NULL,
+	Status TEXT NOT NULL,
+	Symbol TEXT NOT NULL,
+	TransactTime INTEGER NOT NULL,
+	ThreadID TEXT NOT NULL,
+	ThreadIDSession TEXT NOT NULL,
+	CommissionAmount TEXT NOT NULL DEFAULT '0',
+	CommissionAsset TEXT NOT NULL DEFAULT '',
+	LastTradeID INTEGER NOT NULL DEFAULT 0,
+	Commission TEXT NOT NULL DEFAULT '0'
+);
+INSERT INTO orders_decimal (ClientOrderId, CummulativeQuoteQty, ExecutedQuantity, OrderID, Price, Side, Status, Symbol, TransactTime, ThreadID, ThreadIDSession, CommissionAmount, CommissionAsset, LastTradeID, Commission)
+SELECT ClientOrderId, CAST(CummulativeQuoteQty AS TEXT), CAST(ExecutedQuantity AS TEXT), OrderID, CAST(Price AS TEXT), Side, Status, Symbol, TransactTime, ThreadID, ThreadIDSession, CAST(CommissionAmount AS TEXT), CommissionAsset, LastTradeID, CAST(Commission AS TEXT)
+FROM orders;
+DROP TABLE orders;
+ALTER TABLE orders_decimal RENAME TO orders;
+
+CREATE TABLE session_decimal (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	ThreadID TEXT NOT NULL UNIQUE,
+	ThreadIDSession TEXT NOT NULL,
+	Exchange TEXT NOT NULL,
+	FiatSymbol TEXT NOT NULL,
+	FiatFunds TEXT NOT NULL
+);
+INSERT INTO session_decimal (ID, ThreadID, ThreadIDSession, Exchange, FiatSymbol, FiatFunds)
+SELECT ID, ThreadID, ThreadIDSession, Exchange, FiatSymbol, CAST(FiatFunds AS TEXT)
+FROM session;
+DROP TABLE session;
+ALTER TABLE session_decimal RENAME TO session;
+
+CREATE TABLE thread_decimal (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	ThreadID TEXT NOT NULL,
+	ThreadIDSession TEXT NOT NULL,
+	OrderID INTEGER,
+	CummulativeQuoteQty TEXT NOT NULL,
+	Price TEXT NOT NULL,
+	ExecutedQuantity TEXT NOT NULL,
+	HighPrice TEXT NOT NULL DEFAULT '0'
+);
+INSERT INTO thread_decimal (ID, ThreadID, ThreadIDSession, OrderID, CummulativeQuoteQty, Price, ExecutedQuantity, HighPrice)
+SELECT ID, ThreadID, ThreadIDSession, OrderID, CAST(CummulativeQuoteQty AS TEXT), CAST(Price AS TEXT), CAST(ExecutedQuantity AS TEXT), CAST(HighPrice AS TEXT)
+FROM thread;
+DROP TABLE thread;
+ALTER TABLE thread_decimal RENAME TO thread;
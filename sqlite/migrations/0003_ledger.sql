@@ -0,0 +1,42 @@
+-- Lot ledger, mirroring mysql/migrations/0010_ledger.sql. Amounts are TEXT holding decimal strings, as in 0002_decimal.
+-- The ledger table records when the ledger started, for the profit of the orders from before it.
+
+CREATE TABLE IF NOT EXISTS lots (
+	OrderID INTEGER NOT NULL PRIMARY KEY,
+	ThreadID TEXT NOT NULL,
+	ThreadIDSession TEXT NOT NULL,
+	Symbol TEXT NOT NULL,
+	Quantity TEXT NOT NULL,
+	Remaining TEXT NOT NULL,
+	Cost TEXT NOT NULL,
+	Commission TEXT NOT NULL DEFAULT '0',
+	OpenTime INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS lots_ThreadID_Remaining ON lots (ThreadID, Remaining);
+CREATE TABLE IF NOT EXISTS fills (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	SellOrderID INTEGER NOT NULL,
+	LotOrderID INTEGER NOT NULL,
+	ThreadID TEXT NOT NULL,
+	ThreadIDSession TEXT NOT NULL,
+	Symbol TEXT NOT NULL,
+	Quantity TEXT NOT NULL,
+	Proceeds TEXT NOT NULL,
+	Cost TEXT NOT NULL,
+	Commission TEXT NOT NULL,
+	Profit TEXT NOT NULL,
+	HoldingTime INTEGER NOT NULL,
+	TransactTime INTEGER NOT NULL,
+	UNIQUE (SellOrderID, LotOrderID)
+);
+CREATE INDEX IF NOT EXISTS fills_ThreadID ON fills (ThreadID);
+CREATE TABLE IF NOT EXISTS ledger (
+	Started INTEGER NOT NULL
+);
+INSERT INTO ledger (Started)
+SELECT CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)
+WHERE NOT EXISTS (SELECT 1 FROM ledger);
+INSERT OR IGNORE INTO lots (OrderID, ThreadID, ThreadIDSession, Symbol, Quantity, Remaining, Cost, Commission, OpenTime)
+SELECT thread.OrderID, thread.ThreadID, thread.ThreadIDSession, orders.Symbol, thread.ExecutedQuantity, thread.ExecutedQuantity, thread.CummulativeQuoteQty, orders.Commission, orders.TransactTime
+FROM thread
+INNER JOIN orders ON thread.OrderID = orders.OrderID;
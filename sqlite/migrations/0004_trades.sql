@@ -0,0 +1,3 @@
+-- Filled orders by transaction time, mirroring mysql/migrations/0011_trades.sql.
+
+CREATE INDEX IF NOT EXISTS orders_TransactTime ON orders (TransactTime);
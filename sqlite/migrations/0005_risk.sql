@@ -0,0 +1,9 @@
+-- Risk circuit breaker and profit peak of each ThreadID, mirroring mysql/migrations/0012_risk.sql.
+
+CREATE TABLE IF NOT EXISTS risk (
+	ThreadID TEXT NOT NULL PRIMARY KEY,
+	Paused TEXT NOT NULL,
+	Peak TEXT NOT NULL,
+	Day INTEGER NOT NULL,
+	DayStart TEXT NOT NULL
+);
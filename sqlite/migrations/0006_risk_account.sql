@@ -0,0 +1,19 @@
+-- Risk circuit breaker, profit peak and day start of the account in a single row, mirroring mysql/migrations/0014_risk_account.sql.
+
+CREATE TABLE IF NOT EXISTS risk_account (
+	ID INTEGER NOT NULL PRIMARY KEY,
+	Paused TEXT NOT NULL,
+	Peak TEXT NOT NULL,
+	Day INTEGER NOT NULL,
+	DayStart TEXT NOT NULL
+);
+
+INSERT INTO risk_account (ID, Paused, Peak, Day, DayStart)
+SELECT 1, Paused, '0', 0, '0' FROM risk
+WHERE Paused <> ''
+ORDER BY Day DESC
+LIMIT 1;
+
+DROP TABLE risk;
+
+ALTER TABLE risk_account RENAME TO risk;
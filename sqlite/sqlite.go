@@ -0,0 +1,1366 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"cryptopump/functions"
+	"cryptopump/migrate"
+	"cryptopump/types"
+
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+
+	_ "modernc.org/sqlite" // This blank entry is required to enable sqlite connectivity
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS /* Numbered schema migrations, mirroring the MySQL migrations from the full baseline schema */
+
+/* Thread transaction columns returned by the thread queries */
+const threadColumns = `SELECT thread.CummulativeQuoteQty, thread.OrderID, thread.Price, thread.ExecutedQuantity, IFNULL(orders.TransactTime, 0)
+FROM thread
+LEFT JOIN orders ON thread.OrderID = orders.OrderID
+`
+
+/* Orders from before the ledger started that are not lots, whose profit is computed from the order amounts */
+const legacyOrders = `FROM orders
+WHERE TransactTime < (SELECT Started FROM ledger) AND OrderID NOT IN (SELECT OrderID FROM lots)
+`
+
+/* Lot columns returned by the lot queries */
+const lotColumns = `SELECT OrderID, Symbol, Quantity, Remaining, Cost, Commission, OpenTime
+FROM lots
+`
+
+// Storage SQLite implementation of types.Storage, embedding the database in a single file.
+// It replicates the MySQL stored procedures and requires no database server.
+type Storage struct {
+	db *sql.DB /* SQLite database connection */
+}
+
+// DBInit open or create the SQLite database file at path
+func DBInit(
+	path string) (db *sql.DB, err error) {
+
+	if db, err = sql.Open("sqlite", path); err != nil {
+
+		return nil, fmt.Errorf("sql.Open: %v", err)
+
+	}
+
+	/* SQLite allows a single writer, so threads share one connection instead of failing with SQLITE_BUSY */
+	db.SetMaxOpenConns(1)
+
+	if _, err = db.Exec("PRAGMA journal_mode = WAL; PRAGMA busy_timeout = 5000;"); err != nil {
+
+		db.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+
+	}
+
+	return db, nil
+
+}
+
+// Migrate apply the schema migrations pending in the database, returning the schema version before and after
+func Migrate(
+	db *sql.DB) (from int, to int, err error) {
+
+	var list []migrate.Migration
+
+	if list, err = migrate.Load(migrations, "migrations"); err != nil {
+
+		return 0, 0, err
+
+	}
+
+	return migrate.Apply(db, list)
+
+}
+
+// NewStorage create a SQLite storage for the database connection
+func NewStorage(
+	db *sql.DB) *Storage {
+
+	return &Storage{db: db}
+
+}
+
+// SaveOrder Save order to database
+func (s *Storage) SaveOrder(
+	sessionData *types.Session,
+	clientOrderID string,
+	cumulativeQuoteQuantity decimal.Decimal,
+	executedQuantity decimal.Decimal,
+	orderID int64,
+	price decimal.Decimal,
+	side string,
+	status string,
+	symbol string,
+	transactTime int64,
+	commissionAmount decimal.Decimal,
+	commissionAsset string,
+	commission decimal.Decimal) (err error) {
+
+	return s.exec(sessionData, `INSERT INTO orders (ClientOrderId, CummulativeQuoteQty, ExecutedQuantity, OrderID, Price, Side, Status, Symbol, TransactTime, ThreadID, ThreadIDSession, CommissionAmount, CommissionAsset, Commission)
+VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		clientOrderID,
+		cumulativeQuoteQuantity,
+		executedQuantity,
+		orderID,
+		price,
+		side,
+		status,
+		symbol,
+		transactTime,
+		sessionData.ThreadID,
+		sessionData.ThreadIDSession,
+		commissionAmount,
+		commissionAsset,
+		commission)
+
+}
+
+// UpdateOrder Update order
+func (s *Storage) UpdateOrder(
+	sessionData *types.Session,
+	orderID int64,
+	cumulativeQuoteQuantity decimal.Decimal,
+	executedQuantity decimal.Decimal,
+	price decimal.Decimal,
+	status string) (err error) {
+
+	return s.exec(sessionData, `UPDATE orders
+SET CummulativeQuoteQty = ?, ExecutedQuantity = ?, Price = ?, Status = ?
+WHERE OrderID = ?`,
+		cumulativeQuoteQuantity,
+		executedQuantity,
+		price,
+		status,
+		orderID)
+
+}
+
+// UpdateOrderCommission Add the commission of a trade to an order waiting to be filled. Trades already applied are ignored
+func (s *Storage) UpdateOrderCommission(
+	sessionData *types.Session,
+	orderID int64,
+	tradeID int64,
+	commissionAmount decimal.Decimal,
+	commissionAsset string,
+	commission decimal.Decimal) (err error) {
+
+	/* Decimal text columns are added in Go, since SQLite arithmetic converts them to floating point */
+	return s.transaction(sessionData, func(tx *sql.Tx) (err error) {
+
+		var amount, total decimal.Decimal
+
+		if err = tx.QueryRow(`SELECT CommissionAmount, Commission FROM orders
+WHERE ThreadID = ? AND OrderID = ? AND Status IN ('NEW', 'PARTIALLY_FILLED') AND LastTradeID < ?`,
+			sessionData.ThreadID,
+			orderID,
+			tradeID).Scan(
+			&amount,
+			&total); err != nil {
+
+			if err == sql.ErrNoRows {
+
+				return nil
+
+			}
+
+			return err
+
+		}
+
+		_, err = tx.Exec("UPDATE orders SET CommissionAmount = ?, CommissionAsset = ?, Commission = ?, LastTradeID = ? WHERE OrderID = ?",
+			amount.Add(commissionAmount),
+			commissionAsset,
+			total.Add(commission),
+			tradeID,
+			orderID)
+
+		return err
+
+	})
+
+}
+
+// SaveSession Save new session to Session table.
+func (s *Storage) SaveSession(
+	configData *types.Config,
+	sessionData *types.Session) (err error) {
+
+	return s.exec(sessionData, `INSERT INTO session (ThreadID, ThreadIDSession, Exchange, FiatSymbol, FiatFunds)
+VALUES (?,?,?,?,?)`,
+		sessionData.ThreadID,
+		sessionData.ThreadIDSession,
+		configData.ExchangeName,
+		sessionData.SymbolFiat,
+		decimal.NewFromFloat(sessionData.SymbolFiatFunds))
+
+}
+
+// UpdateSession Update existing session on Session table
+func (s *Storage) UpdateSession(
+	configData *types.Config,
+	sessionData *types.Session) (err error) {
+
+	return s.exec(sessionData, "UPDATE session SET FiatFunds = ? WHERE ThreadID = ?",
+		decimal.NewFromFloat(sessionData.SymbolFiatFunds),
+		sessionData.ThreadID)
+
+}
+
+// DeleteSession Delete session from Session table
+func (s *Storage) DeleteSession(
+	sessionData *types.Session) (err error) {
+
+	return s.exec(sessionData, "DELETE FROM session WHERE ThreadID = ?",
+		sessionData.ThreadID)
+
+}
+
+// SaveThreadTransaction Save Thread cycle to database
+func (s *Storage) SaveThreadTransaction(
+	sessionData *types.Session,
+	orderID int64,
+	cumulativeQuoteQuantity decimal.Decimal,
+	price decimal.Decimal,
+	executedQuantity decimal.Decimal) (err error) {
+
+	return s.exec(sessionData, `INSERT INTO thread (ThreadID, ThreadIDSession, OrderID, CummulativeQuoteQty, Price, ExecutedQuantity, HighPrice)
+VALUES (?,?,?,?,?,?,?)`,
+		sessionData.ThreadID,
+		sessionData.ThreadIDSession,
+		orderID,
+		cumulativeQuoteQuantity,
+		price,
+		executedQuantity,
+		price)
+
+}
+
+// DeleteThreadTransactionByOrderID Delete the thread transaction for the order
+func (s *Storage) DeleteThreadTransactionByOrderID(
+	sessionData *types.Session,
+	orderID int) (err error) {
+
+	return s.exec(sessionData, "DELETE FROM thread WHERE OrderID = ?",
+		orderID)
+
+}
+
+// UpdateThreadTransaction Update the quantity, amount and price of the thread transaction for the order
+func (s *Storage) UpdateThreadTransaction(
+	sessionData *types.Session,
+	orderID int,
+	cumulativeQuoteQuantity decimal.Decimal,
+	price decimal.Decimal,
+	executedQuantity decimal.Decimal) (err error) {
+
+	return s.exec(sessionData, "UPDATE thread SET CummulativeQuoteQty = ?, Price = ?, ExecutedQuantity = ? WHERE OrderID = ?",
+		cumulativeQuoteQuantity,
+		price,
+		executedQuantity,
+		orderID)
+
+}
+
+// GetThreadTransactionByOrderID Return the price, quantity and amount of the thread transaction for the order, zero when none
+func (s *Storage) GetThreadTransactionByOrderID(
+	sessionData *types.Session,
+	orderID int) (price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, err error) {
+
+	err = s.queryRow(sessionData, "SELECT Price, ExecutedQuantity, CummulativeQuoteQty FROM thread WHERE OrderID = ?",
+		[]interface{}{orderID},
+		&price,
+		&executedQuantity,
+		&cumulativeQuoteQty)
+
+	return price, executedQuantity, cumulativeQuoteQty, err
+
+}
+
+// GetThreadTransactionCount Get Thread count
+func (s *Storage) GetThreadTransactionCount(
+	sessionData *types.Session) (count int, err error) {
+
+	err = s.queryRow(sessionData, "SELECT COUNT(*) FROM thread WHERE ThreadID = ?",
+		[]interface{}{sessionData.ThreadID},
+		&count)
+
+	return count, err
+
+}
+
+// GetLastOrderTransactionPrice Get price for last transaction the ThreadID
+func (s *Storage) GetLastOrderTransactionPrice(
+	sessionData *types.Session,
+	side string) (price decimal.Decimal, err error) {
+
+	err = s.queryRow(sessionData, `SELECT Price FROM orders
+WHERE ThreadID = ? AND Side = ? AND Status <> 'CANCELED'
+ORDER BY TransactTime DESC LIMIT 1`,
+		[]interface{}{sessionData.ThreadID, side},
+		&price)
+
+	return price, err
+
+}
+
+// GetLastOrderTransactionSide Get Side for last transaction the ThreadID
+func (s *Storage) GetLastOrderTransactionSide(
+	sessionData *types.Session) (side string, err error) {
+
+	err = s.queryRow(sessionData, `SELECT Side FROM orders
+WHERE ThreadID = ? AND Status = 'FILLED'
+ORDER BY TransactTime DESC LIMIT 1`,
+		[]interface{}{sessionData.ThreadID},
+		&side)
+
+	return side, err
+
+}
+
+// GetOrderTransactionSideLastTwo Get Side for the last two transactions the ThreadID
+func (s *Storage) GetOrderTransactionSideLastTwo(
+	sessionData *types.Session) (side1 string, side2 string, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.query(sessionData, `SELECT Side FROM orders
+WHERE ThreadID = ? AND Status <> 'CANCELED'
+ORDER BY TransactTime DESC LIMIT 2`,
+		sessionData.ThreadID); err != nil {
+
+		return "", "", err
+
+	}
+
+	defer rows.Close()
+
+	var sides []string
+
+	for rows.Next() {
+
+		var side string
+
+		if err = rows.Scan(&side); err != nil {
+
+			return "", "", err
+
+		}
+
+		sides = append(sides, side)
+
+	}
+
+	/* The stored procedure joins the last two transactions, returning nothing unless both exist */
+	if len(sides) < 2 {
+
+		return "", "", rows.Err()
+
+	}
+
+	return sides[0], sides[1], rows.Err()
+
+}
+
+// GetOrderSymbol Get symbol for ThreadID
+func (s *Storage) GetOrderSymbol(
+	sessionData *types.Session) (symbol string, err error) {
+
+	err = s.queryRow(sessionData, "SELECT Symbol FROM orders WHERE ThreadID = ? ORDER BY TransactTime DESC LIMIT 1",
+		[]interface{}{sessionData.ThreadID},
+		&symbol)
+
+	return symbol, err
+
+}
+
+// GetThreadTransactionDistinct Get Thread Distinct
+func (s *Storage) GetThreadTransactionDistinct(
+	sessionData *types.Session) (threadID string, threadIDSession string, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.query(sessionData, "SELECT DISTINCT ThreadID, ThreadIDSession FROM thread"); err != nil {
+
+		return "", "", err
+
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		if err = rows.Scan(
+			&threadID,
+			&threadIDSession); err != nil {
+
+			return "", "", err
+
+		}
+
+		if functions.LockThreadID(threadID) { /* Create lock for threadID */
+
+			return threadID, threadIDSession, nil
+
+		}
+
+	}
+
+	return "", "", rows.Err()
+
+}
+
+// GetOrderTransactionPending Get 1 order with pending FILLED status
+func (s *Storage) GetOrderTransactionPending(
+	sessionData *types.Session) (orderID int64, symbol string, err error) {
+
+	err = s.queryRow(sessionData, `SELECT OrderID, Symbol FROM orders
+WHERE ThreadID = ? AND Status NOT IN ('FILLED', 'CANCELED', 'EXPIRED', 'REJECTED', '')
+ORDER BY TransactTime ASC LIMIT 1`,
+		[]interface{}{sessionData.ThreadID},
+		&orderID,
+		&symbol)
+
+	return orderID, symbol, err
+
+}
+
+// GetOrderByOrderID Return an order. Status is empty when the order is not found
+func (s *Storage) GetOrderByOrderID(
+	sessionData *types.Session,
+	orderID int64) (order types.Order, err error) {
+
+	err = s.queryRow(sessionData, `SELECT ClientOrderId, CummulativeQuoteQty, ExecutedQuantity, OrderID, Price, Side, Status, Symbol, TransactTime, CommissionAmount, CommissionAsset, Commission
+FROM orders WHERE OrderID = ?`,
+		[]interface{}{orderID},
+		&order.ClientOrderID,
+		&order.CumulativeQuoteQuantity,
+		&order.ExecutedQuantity,
+		&order.OrderID,
+		&order.Price,
+		&order.Side,
+		&order.Status,
+		&order.Symbol,
+		&order.TransactTime,
+		&order.CommissionAmount,
+		&order.CommissionAsset,
+		&order.Commission)
+
+	return order, err
+
+}
+
+// GetOrdersBySymbol Return the orders for the session symbol since transactTime
+func (s *Storage) GetOrdersBySymbol(
+	sessionData *types.Session,
+	transactTime int64) (orders []types.Order, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.query(sessionData, `SELECT ClientOrderId, CummulativeQuoteQty, ExecutedQuantity, OrderID, Price, Side, Status, Symbol, TransactTime
+FROM orders WHERE Symbol = ? AND TransactTime >= ?
+ORDER BY TransactTime ASC`,
+		sessionData.Symbol,
+		transactTime); err != nil {
+
+		return nil, err
+
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		order := types.Order{}
+
+		if err = rows.Scan(
+			&order.ClientOrderID,
+			&order.CumulativeQuoteQuantity,
+			&order.ExecutedQuantity,
+			&order.OrderID,
+			&order.Price,
+			&order.Side,
+			&order.Status,
+			&order.Symbol,
+			&order.TransactTime); err != nil {
+
+			return orders, err
+
+		}
+
+		orders = append(orders, order)
+
+	}
+
+	return orders, rows.Err()
+
+}
+
+// GetThreadTransactionByPrice Return the lowest price thread transaction below the market price
+func (s *Storage) GetThreadTransactionByPrice(
+	marketData *types.Market,
+	sessionData *types.Session) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error) {
+
+	err = s.queryRow(sessionData, threadColumns+`WHERE thread.ThreadID = ? AND CAST(thread.Price AS REAL) < ?
+ORDER BY CAST(thread.Price AS REAL) ASC LIMIT 1`,
+		[]interface{}{sessionData.ThreadID, marketData.Price},
+		&cumulativeQuoteQty,
+		&orderID,
+		&price,
+		&executedQuantity,
+		&transactTime)
+
+	return orderID, price, executedQuantity, cumulativeQuoteQty, transactTime, err
+
+}
+
+// GetThreadTransactionByStopLoss Return the highest price thread transaction the market price has fallen stopLoss below
+func (s *Storage) GetThreadTransactionByStopLoss(
+	marketData *types.Market,
+	sessionData *types.Session,
+	stopLoss float64) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error) {
+
+	err = s.queryRow(sessionData, threadColumns+`WHERE thread.ThreadID = ? AND CAST(thread.Price AS REAL) * (1 - ?) >= ?
+ORDER BY CAST(thread.Price AS REAL) DESC LIMIT 1`,
+		[]interface{}{sessionData.ThreadID, stopLoss, marketData.Price},
+		&cumulativeQuoteQty,
+		&orderID,
+		&price,
+		&executedQuantity,
+		&transactTime)
+
+	return orderID, price, executedQuantity, cumulativeQuoteQty, transactTime, err
+
+}
+
+// GetThreadTransactionByTrailingStop Return the lowest price thread transaction with a high-water mark above price plus trailingStop, and the market price fallen trailingStop below the high-water mark
+func (s *Storage) GetThreadTransactionByTrailingStop(
+	marketData *types.Market,
+	sessionData *types.Session,
+	trailingStop float64) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error) {
+
+	err = s.queryRow(sessionData, threadColumns+`WHERE thread.ThreadID = ? AND CAST(thread.HighPrice AS REAL) >= CAST(thread.Price AS REAL) * (1 + ?) AND CAST(thread.HighPrice AS REAL) * (1 - ?) >= ?
+ORDER BY CAST(thread.Price AS REAL) ASC LIMIT 1`,
+		[]interface{}{sessionData.ThreadID, trailingStop, trailingStop, marketData.Price},
+		&cumulativeQuoteQty,
+		&orderID,
+		&price,
+		&executedQuantity,
+		&transactTime)
+
+	return orderID, price, executedQuantity, cumulativeQuoteQty, transactTime, err
+
+}
+
+// UpdateThreadTransactionHighPrice Raise the high-water mark of thread transactions to the market price
+func (s *Storage) UpdateThreadTransactionHighPrice(
+	marketData *types.Market,
+	sessionData *types.Session) (err error) {
+
+	return s.exec(sessionData, "UPDATE thread SET HighPrice = ? WHERE ThreadID = ? AND CAST(HighPrice AS REAL) < ?",
+		decimal.NewFromFloat(marketData.Price),
+		sessionData.ThreadID,
+		marketData.Price)
+
+}
+
+// GetThreadLastTransaction Return the last 'active' BUY transaction for a Thread
+func (s *Storage) GetThreadLastTransaction(
+	sessionData *types.Session) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error) {
+
+	err = s.queryRow(sessionData, threadColumns+`WHERE thread.ThreadID = ?
+ORDER BY CAST(thread.Price AS REAL) ASC LIMIT 1`,
+		[]interface{}{sessionData.ThreadID},
+		&cumulativeQuoteQty,
+		&orderID,
+		&price,
+		&executedQuantity,
+		&transactTime)
+
+	return orderID, price, executedQuantity, cumulativeQuoteQty, transactTime, err
+
+}
+
+// GetThreadTransactiontUpmarketPriceCount Count the thread transactions below price
+func (s *Storage) GetThreadTransactiontUpmarketPriceCount(
+	sessionData *types.Session,
+	price float64) (count int, err error) {
+
+	err = s.queryRow(sessionData, "SELECT COUNT(*) FROM thread WHERE CAST(Price AS REAL) < ? AND ThreadID = ?",
+		[]interface{}{price, sessionData.ThreadID},
+		&count)
+
+	return count, err
+
+}
+
+// GetOrderTransactionCount Retrieve transaction count by Side and minutes
+func (s *Storage) GetOrderTransactionCount(
+	sessionData *types.Session,
+	side string) (count float64, err error) {
+
+	/* The stored procedure compares times truncated to the minute */
+	now := functions.Now(sessionData).Unix() / 60
+
+	err = s.queryRow(sessionData, `SELECT COUNT(*) FROM orders
+WHERE ThreadID = ? AND Side = ? AND Status = 'FILLED' AND TransactTime / 60000 BETWEEN ? AND ?`,
+		[]interface{}{sessionData.ThreadID, side, now - 60, now},
+		&count)
+
+	return count, err
+
+}
+
+// GetThreadTransactionByThreadID Retrieve thread transactions ordered by price
+func (s *Storage) GetThreadTransactionByThreadID(
+	sessionData *types.Session) (orders []types.Order, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.query(sessionData, "SELECT OrderID, CummulativeQuoteQty, Price FROM thread WHERE ThreadID = ? ORDER BY CAST(Price AS REAL) ASC",
+		sessionData.ThreadID); err != nil {
+
+		return nil, err
+
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		order := types.Order{}
+
+		if err = rows.Scan(
+			&order.OrderID,
+			&order.CumulativeQuoteQuantity,
+			&order.Price); err != nil {
+
+			return orders, err
+
+		}
+
+		order.CumulativeQuoteQuantity = order.CumulativeQuoteQuantity.Round(2)
+		order.Price = order.Price.Round(3)
+		orders = append(orders, order)
+
+	}
+
+	return orders, rows.Err()
+
+}
+
+// GetProfitByThreadID Retrieve thread profit, from the ledger fills and the orders from before the ledger
+func (s *Storage) GetProfitByThreadID(
+	sessionData *types.Session) (profit float64, err error) {
+
+	var fills, sells, buys, commissions decimal.Decimal
+
+	if fills, err = s.sum(sessionData, "SELECT Profit FROM fills WHERE ThreadID = ?",
+		sessionData.ThreadID); err != nil {
+
+		return 0, err
+
+	}
+
+	if sells, err = s.sum(sessionData, "SELECT CummulativeQuoteQty "+legacyOrders+"AND ThreadID = ? AND Side = 'SELL'",
+		sessionData.ThreadID); err != nil {
+
+		return 0, err
+
+	}
+
+	if buys, err = s.sum(sessionData, "SELECT CummulativeQuoteQty "+legacyOrders+"AND ThreadID = ? AND Side = 'BUY'",
+		sessionData.ThreadID); err != nil {
+
+		return 0, err
+
+	}
+
+	if commissions, err = s.sum(sessionData, "SELECT Commission "+legacyOrders+"AND ThreadID = ?",
+		sessionData.ThreadID); err != nil {
+
+		return 0, err
+
+	}
+
+	return fills.Add(sells).Sub(buys).Sub(commissions).Round(2).InexactFloat64(), nil
+
+}
+
+// GetProfit Retrieve total profit, from the ledger fills and the orders from before the ledger
+func (s *Storage) GetProfit(
+	sessionData *types.Session) (profit float64, err error) {
+
+	var fills, sells, buys, commissions decimal.Decimal
+
+	if fills, err = s.sum(sessionData, "SELECT Profit FROM fills"); err != nil {
+
+		return 0, err
+
+	}
+
+	if sells, err = s.sum(sessionData, "SELECT CummulativeQuoteQty "+legacyOrders+"AND Side = 'SELL'"); err != nil {
+
+		return 0, err
+
+	}
+
+	if buys, err = s.sum(sessionData, "SELECT CummulativeQuoteQty "+legacyOrders+"AND Side = 'BUY'"); err != nil {
+
+		return 0, err
+
+	}
+
+	if commissions, err = s.sum(sessionData, "SELECT Commission "+legacyOrders); err != nil {
+
+		return 0, err
+
+	}
+
+	return fills.Add(sells).Sub(buys).Sub(commissions).Round(2).InexactFloat64(), nil
+
+}
+
+// GetThreadCount Retrieve Running Thread Count
+func (s *Storage) GetThreadCount(
+	sessionData *types.Session) (count int, err error) {
+
+	err = s.queryRow(sessionData, "SELECT COUNT(DISTINCT ThreadID) FROM session",
+		nil,
+		&count)
+
+	return count, err
+
+}
+
+// GetThreadAmount Retrieve Thread Dollar Amount
+func (s *Storage) GetThreadAmount(
+	sessionData *types.Session) (amount float64, err error) {
+
+	var total decimal.Decimal
+
+	total, err = s.sum(sessionData, "SELECT CummulativeQuoteQty FROM thread")
+
+	return total.Round(2).InexactFloat64(), err
+
+}
+
+// GetThreadPositions Retrieve Thread transactions count, quantity and amount by symbol across all threads
+func (s *Storage) GetThreadPositions(
+	sessionData *types.Session) (positions []types.Position, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.query(sessionData, `SELECT IFNULL(orders.Symbol, ''), thread.ExecutedQuantity, thread.CummulativeQuoteQty
+FROM thread
+LEFT JOIN orders ON thread.OrderID = orders.OrderID
+ORDER BY orders.Symbol`); err != nil {
+
+		return nil, err
+
+	}
+
+	defer rows.Close()
+
+	var quantity, amount decimal.Decimal
+
+	/* Rows are grouped by symbol in Go, since SQLite SUM converts decimal text to floating point */
+	for rows.Next() {
+
+		var symbol string
+		var executedQuantity, cumulativeQuoteQty decimal.Decimal
+
+		if err = rows.Scan(
+			&symbol,
+			&executedQuantity,
+			&cumulativeQuoteQty); err != nil {
+
+			return positions, err
+
+		}
+
+		if len(positions) == 0 || positions[len(positions)-1].Symbol != symbol {
+
+			positions = append(positions, types.Position{Symbol: symbol})
+			quantity, amount = decimal.Zero, decimal.Zero
+
+		}
+
+		position := &positions[len(positions)-1]
+		quantity = quantity.Add(executedQuantity)
+		amount = amount.Add(cumulativeQuoteQty)
+		position.Count++
+		position.Quantity = quantity.InexactFloat64()
+		position.Amount = amount.Round(2).InexactFloat64()
+
+	}
+
+	return positions, rows.Err()
+
+}
+
+// AcquireLease Acquire or renew a lease for the ThreadID, unless held by another ThreadID and not expired. The current owner is returned.
+func (s *Storage) AcquireLease(
+	sessionData *types.Session,
+	name string,
+	duration time.Duration) (lease types.Lease, err error) {
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+
+	if err = s.exec(sessionData, "INSERT OR IGNORE INTO lease (Name, ThreadID, Token, Expires) VALUES (?, '', 0, ?)",
+		name,
+		now); err != nil {
+
+		return lease, err
+
+	}
+
+	if err = s.exec(sessionData, `UPDATE lease
+SET Token = CASE WHEN ThreadID = ? THEN Token ELSE Token + 1 END, ThreadID = ?, Expires = ?
+WHERE Name = ? AND (ThreadID = ? OR Expires <= ?)`,
+		sessionData.ThreadID,
+		sessionData.ThreadID,
+		now+duration.Milliseconds(),
+		name,
+		sessionData.ThreadID,
+		now); err != nil {
+
+		return lease, err
+
+	}
+
+	lease.Name = name
+
+	err = s.queryRow(sessionData, "SELECT ThreadID, Token FROM lease WHERE Name = ?",
+		[]interface{}{name},
+		&lease.ThreadID,
+		&lease.Token)
+
+	return lease, err
+
+}
+
+// CheckLease Check the lease is held by the ThreadID with the fencing token and not expired
+func (s *Storage) CheckLease(
+	sessionData *types.Session,
+	name string,
+	token int64) (held bool, err error) {
+
+	var count int
+
+	err = s.queryRow(sessionData, "SELECT COUNT(*) FROM lease WHERE Name = ? AND ThreadID = ? AND Token = ? AND Expires > ?",
+		[]interface{}{name, sessionData.ThreadID, token, time.Now().UnixNano() / int64(time.Millisecond)},
+		&count)
+
+	return count > 0, err
+
+}
+
+// ReleaseLease Expire the lease if held by the ThreadID
+func (s *Storage) ReleaseLease(
+	sessionData *types.Session,
+	name string) (err error) {
+
+	return s.exec(sessionData, "UPDATE lease SET Expires = ? WHERE Name = ? AND ThreadID = ?",
+		time.Now().UnixNano()/int64(time.Millisecond),
+		name,
+		sessionData.ThreadID)
+
+}
+
+// SaveKline Save a final kline for the session symbol, replacing the kline with the same interval and open time
+func (s *Storage) SaveKline(
+	sessionData *types.Session,
+	kline types.WsKline) (err error) {
+
+	return s.exec(sessionData, `INSERT OR REPLACE INTO klines (Symbol, Interval, OpenTime, CloseTime, Open, High, Low, Close, Volume, QuoteVolume, ActiveBuyVolume, ActiveBuyQuoteVolume)
+VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`,
+		sessionData.Symbol,
+		kline.Interval,
+		kline.StartTime,
+		kline.EndTime,
+		kline.Open,
+		kline.High,
+		kline.Low,
+		kline.Close,
+		kline.Volume,
+		kline.QuoteVolume,
+		kline.ActiveBuyVolume,
+		kline.ActiveBuyQuoteVolume)
+
+}
+
+// GetKlines Retrieve the klines saved for the session symbol and interval, opened from startTime to endTime and ordered by open time
+func (s *Storage) GetKlines(
+	sessionData *types.Session,
+	interval string,
+	startTime int64,
+	endTime int64) (klines []types.WsKline, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.query(sessionData, `SELECT OpenTime, CloseTime, Open, High, Low, Close, Volume, QuoteVolume, ActiveBuyVolume, ActiveBuyQuoteVolume
+FROM klines WHERE Symbol = ? AND Interval = ? AND OpenTime >= ? AND OpenTime <= ?
+ORDER BY OpenTime ASC`,
+		sessionData.Symbol,
+		interval,
+		startTime,
+		endTime); err != nil {
+
+		return nil, err
+
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		kline := types.WsKline{
+			Symbol:   sessionData.Symbol,
+			Interval: interval,
+			IsFinal:  true,
+		}
+
+		if err = rows.Scan(
+			&kline.StartTime,
+			&kline.EndTime,
+			&kline.Open,
+			&kline.High,
+			&kline.Low,
+			&kline.Close,
+			&kline.Volume,
+			&kline.QuoteVolume,
+			&kline.ActiveBuyVolume,
+			&kline.ActiveBuyQuoteVolume); err != nil {
+
+			return klines, err
+
+		}
+
+		klines = append(klines, kline)
+
+	}
+
+	return klines, rows.Err()
+
+}
+
+// SaveLot Save the lot of a filled BUY order to the ledger
+func (s *Storage) SaveLot(
+	sessionData *types.Session,
+	lot types.Lot) (err error) {
+
+	return s.exec(sessionData, `INSERT INTO lots (OrderID, ThreadID, ThreadIDSession, Symbol, Quantity, Remaining, Cost, Commission, OpenTime)
+VALUES (?,?,?,?,?,?,?,?,?)`,
+		lot.OrderID,
+		sessionData.ThreadID,
+		sessionData.ThreadIDSession,
+		lot.Symbol,
+		lot.Quantity,
+		lot.Quantity,
+		lot.Cost,
+		lot.Commission,
+		lot.OpenTime)
+
+}
+
+// GetOpenLots Retrieve the lots of the ThreadID with quantity not closed, oldest first
+func (s *Storage) GetOpenLots(
+	sessionData *types.Session) (lots []types.Lot, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.query(sessionData, lotColumns+"WHERE ThreadID = ? AND CAST(Remaining AS REAL) > 0 ORDER BY OpenTime, OrderID",
+		sessionData.ThreadID); err != nil {
+
+		return nil, err
+
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var lot types.Lot
+
+		if err = rows.Scan(
+			&lot.OrderID,
+			&lot.Symbol,
+			&lot.Quantity,
+			&lot.Remaining,
+			&lot.Cost,
+			&lot.Commission,
+			&lot.OpenTime); err != nil {
+
+			return lots, err
+
+		}
+
+		lots = append(lots, lot)
+
+	}
+
+	return lots, rows.Err()
+
+}
+
+// SaveFill Save the fill of a SELL order to the ledger and close the quantity of its lot
+func (s *Storage) SaveFill(
+	sessionData *types.Session,
+	fill types.Fill) (err error) {
+
+	return s.transaction(sessionData, func(tx *sql.Tx) (err error) {
+
+		var remaining decimal.Decimal
+
+		if _, err = tx.Exec(`INSERT INTO fills (SellOrderID, LotOrderID, ThreadID, ThreadIDSession, Symbol, Quantity, Proceeds, Cost, Commission, Profit, HoldingTime, TransactTime)
+VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`,
+			fill.SellOrderID,
+			fill.LotOrderID,
+			sessionData.ThreadID,
+			sessionData.ThreadIDSession,
+			fill.Symbol,
+			fill.Quantity,
+			fill.Proceeds,
+			fill.Cost,
+			fill.Commission,
+			fill.Profit,
+			fill.HoldingTime,
+			fill.TransactTime); err != nil {
+
+			return err
+
+		}
+
+		if err = tx.QueryRow("SELECT Remaining FROM lots WHERE OrderID = ?",
+			fill.LotOrderID).Scan(&remaining); err != nil {
+
+			if err == sql.ErrNoRows {
+
+				return nil
+
+			}
+
+			return err
+
+		}
+
+		_, err = tx.Exec("UPDATE lots SET Remaining = ? WHERE OrderID = ?",
+			decimal.Max(remaining.Sub(fill.Quantity), decimal.Zero),
+			fill.LotOrderID)
+
+		return err
+
+	})
+
+}
+
+// GetTrades Retrieve the orders filled from startTime to endTime for the ThreadIDs, all when empty, with the cost and profit of the ledger fills of SELL orders
+func (s *Storage) GetTrades(
+	sessionData *types.Session,
+	startTime int64,
+	endTime int64,
+	threadIDs []string) (trades []types.Trade, err error) {
+
+	var rows *sql.Rows
+
+	filter := "WHERE CAST(orders.ExecutedQuantity AS REAL) > 0 AND orders.TransactTime BETWEEN ? AND ?"
+	args := []interface{}{startTime, endTime}
+
+	if len(threadIDs) > 0 {
+
+		filter += " AND orders.ThreadID IN (?" + strings.Repeat(",?", len(threadIDs)-1) + ")"
+
+		for _, threadID := range threadIDs {
+
+			args = append(args, threadID)
+
+		}
+
+	}
+
+	/* Ledger fills are summed per SELL order in Go, since SQLite SUM converts decimal text to floating point */
+	if rows, err = s.query(sessionData, `SELECT fills.SellOrderID, fills.Cost, fills.Profit
+FROM fills
+INNER JOIN orders ON fills.SellOrderID = orders.OrderID
+`+filter, args...); err != nil {
+
+		return nil, err
+
+	}
+
+	defer rows.Close()
+
+	costs := make(map[int]decimal.Decimal)
+	profits := make(map[int]decimal.Decimal)
+
+	for rows.Next() {
+
+		var orderID int
+		var cost, profit decimal.Decimal
+
+		if err = rows.Scan(
+			&orderID,
+			&cost,
+			&profit); err != nil {
+
+			return nil, err
+
+		}
+
+		costs[orderID] = costs[orderID].Add(cost)
+		profits[orderID] = profits[orderID].Add(profit)
+
+	}
+
+	if err = rows.Err(); err != nil {
+
+		return nil, err
+
+	}
+
+	if rows, err = s.query(sessionData, `SELECT orders.OrderID, orders.ThreadID, orders.Symbol, orders.Side, orders.ExecutedQuantity, orders.CummulativeQuoteQty, orders.CommissionAmount, orders.CommissionAsset, orders.Commission, orders.TransactTime
+FROM orders
+`+filter+" ORDER BY orders.TransactTime, orders.OrderID", args...); err != nil {
+
+		return nil, err
+
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var trade types.Trade
+
+		if err = rows.Scan(
+			&trade.OrderID,
+			&trade.ThreadID,
+			&trade.Symbol,
+			&trade.Side,
+			&trade.Quantity,
+			&trade.QuoteQuantity,
+			&trade.CommissionAmount,
+			&trade.CommissionAsset,
+			&trade.Commission,
+			&trade.TransactTime); err != nil {
+
+			return trades, err
+
+		}
+
+		if cost, ok := costs[trade.OrderID]; ok {
+
+			trade.Cost = decimal.NullDecimal{Decimal: cost, Valid: true}
+			trade.Profit = decimal.NullDecimal{Decimal: profits[trade.OrderID], Valid: true}
+
+		}
+
+		trades = append(trades, trade)
+
+	}
+
+	return trades, rows.Err()
+
+}
+
+// GetRisk Retrieve the risk circuit breaker state of the account, shared by the threads using the database. Day is 0 when no state is saved
+func (s *Storage) GetRisk(
+	sessionData *types.Session) (risk types.Risk, err error) {
+
+	var peak, dayStart decimal.Decimal
+
+	err = s.queryRow(sessionData, "SELECT Paused, Peak, Day, DayStart FROM risk WHERE ID = 1",
+		nil,
+		&risk.Paused,
+		&peak,
+		&risk.Day,
+		&dayStart)
+
+	risk.Peak = peak.InexactFloat64()
+	risk.DayStart = dayStart.InexactFloat64()
+
+	return risk, err
+
+}
+
+// SaveRisk Save the risk circuit breaker state of the account
+func (s *Storage) SaveRisk(
+	sessionData *types.Session,
+	risk types.Risk) (err error) {
+
+	return s.exec(sessionData, `INSERT OR REPLACE INTO risk (ID, Paused, Peak, Day, DayStart)
+VALUES (1,?,?,?,?)`,
+		risk.Paused,
+		decimal.NewFromFloat(risk.Peak),
+		risk.Day,
+		decimal.NewFromFloat(risk.DayStart))
+
+}
+
+// DeleteRisk Delete the risk circuit breaker state of the account
+func (s *Storage) DeleteRisk(
+	sessionData *types.Session) (err error) {
+
+	return s.exec(sessionData, "DELETE FROM risk")
+
+}
+
+/* Execute a statement, logging the error */
+func (s *Storage) exec(
+	sessionData *types.Session,
+	statement string,
+	args ...interface{}) (err error) {
+
+	if _, err = s.db.Exec(statement, args...); err != nil {
+
+		logError(sessionData, err)
+
+	}
+
+	return err
+
+}
+
+/* Execute a query, logging the error */
+func (s *Storage) query(
+	sessionData *types.Session,
+	statement string,
+	args ...interface{}) (rows *sql.Rows, err error) {
+
+	if rows, err = s.db.Query(statement, args...); err != nil {
+
+		logError(sessionData, err)
+
+	}
+
+	return rows, err
+
+}
+
+/* Execute a query returning a single decimal column, summed exactly since SQLite SUM converts decimal text to floating point */
+func (s *Storage) sum(
+	sessionData *types.Session,
+	statement string,
+	args ...interface{}) (total decimal.Decimal, err error) {
+
+	var rows *sql.Rows
+
+	if rows, err = s.db.Query(statement, args...); err != nil {
+
+		logError(sessionData, err)
+		return total, err
+
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var value decimal.Decimal
+
+		if err = rows.Scan(&value); err != nil {
+
+			logError(sessionData, err)
+			return total, err
+
+		}
+
+		total = total.Add(value)
+
+	}
+
+	if err = rows.Err(); err != nil {
+
+		logError(sessionData, err)
+
+	}
+
+	return total, err
+
+}
+
+/* Execute a query returning at most one row, scanned into dest. Dest is left unchanged when no row is returned, as the stored procedures do. */
+func (s *Storage) queryRow(
+	sessionData *types.Session,
+	statement string,
+	args []interface{},
+	dest ...interface{}) (err error) {
+
+	if err = s.db.QueryRow(statement, args...).Scan(dest...); err != nil {
+
+		if err == sql.ErrNoRows {
+
+			return nil
+
+		}
+
+		logError(sessionData, err)
+
+	}
+
+	return err
+
+}
+
+/* Run fn in a transaction, committed when fn succeeds and rolled back otherwise, logging the error */
+func (s *Storage) transaction(
+	sessionData *types.Session,
+	fn func(tx *sql.Tx) error) (err error) {
+
+	var tx *sql.Tx
+
+	if tx, err = s.db.Begin(); err == nil {
+
+		if err = fn(tx); err == nil {
+
+			err = tx.Commit()
+
+		} else {
+
+			tx.Rollback()
+
+		}
+
+	}
+
+	if err != nil {
+
+		logError(sessionData, err)
+
+	}
+
+	return err
+
+}
+
+/* Log a database error with the name of the Storage method that failed */
+func logError(
+	sessionData *types.Session,
+	err error) {
+
+	/* Skip logError and the exec, query, queryRow, sum or transaction helper */
+	pc := make([]uintptr, 1)
+	runtime.Callers(3, pc)
+	frame, _ := runtime.CallersFrames(pc).Next()
+
+	functions.Logger(&types.LogEntry{
+		Config:   nil,
+		Market:   nil,
+		Session:  sessionData,
+		Order:    &types.Order{},
+		Message:  frame.Function + " - " + err.Error(),
+		LogLevel: log.DebugLevel,
+	})
+
+}
@@ -0,0 +1,112 @@
+/* Thread actions and configuration updates of the dashboard with the JSON API */
+
+/* Configuration keys of the dashboard form fields, with the type of their values */
+const configFields = {
+    bollingerWindow: ['bollinger_window', 'int'],
+    bollingerDeviation: ['bollinger_deviation', 'float'],
+    buy24hsHighpriceEntry: ['buy_24hs_highprice_entry', 'float'],
+    buyDirectionDown: ['buy_direction_down', 'int'],
+    buyDirectionUp: ['buy_direction_up', 'int'],
+    buyQuantityFiatUp: ['buy_quantity_fiat_up', 'float'],
+    buyQuantityFiatDown: ['buy_quantity_fiat_down', 'float'],
+    buyQuantityFiatInit: ['buy_quantity_fiat_init', 'float'],
+    buyRsi7Entry: ['buy_rsi7_entry', 'float'],
+    buyCondition: ['buy_condition', 'string'],
+    buyWait: ['buy_wait', 'int'],
+    buyBollinger: ['buy_bollinger', 'bool'],
+    buyBollingerPercentB: ['buy_bollinger_percentb', 'float'],
+    buyRepeatThresholdDown: ['buy_repeat_threshold_down', 'float'],
+    buyRepeatThresholdDownSecond: ['buy_repeat_threshold_down_second', 'float'],
+    buyRepeatThresholdDownSecondStartCount: ['buy_repeat_threshold_down_second_start_count', 'int'],
+    buyRepeatThresholdUp: ['buy_repeat_threshold_up', 'float'],
+    exchangeComission: ['exchange_comission', 'float'],
+    wsStaleTimeout: ['ws_stale_timeout', 'int'],
+    maxSpread: ['max_spread', 'float'],
+    maxSlippage: ['max_slippage', 'float'],
+    profitMin: ['profit_min', 'float'],
+    sellwaitbeforecancel: ['sellwaitbeforecancel', 'int'],
+    sellwaitaftercancel: ['sellwaitaftercancel', 'int'],
+    selltocover: ['selltocover', 'bool'],
+    sellholdonrsi3: ['sellholdonrsi3', 'float'],
+    sellholdonbollinger: ['sellholdonbollinger', 'float'],
+    riskMaxThreads: ['risk_max_threads', 'int'],
+    riskMaxFiatSymbol: ['risk_max_fiat_symbol', 'float'],
+    riskMaxFiatTotal: ['risk_max_fiat_total', 'float'],
+    riskMaxDailyLoss: ['risk_max_daily_loss', 'float'],
+    riskMaxDrawdown: ['risk_max_drawdown', 'float'],
+    stopLoss: ['stop_loss', 'float'],
+    trailingStop: ['trailing_stop', 'float'],
+    symbol: ['symbol', 'string'],
+    symbol_fiat: ['symbol_fiat', 'string'],
+    symbolFiatStash: ['symbol_fiat_stash', 'float'],
+    timeEnforce: ['time_enforce', 'bool'],
+    timeStart: ['time_start', 'string'],
+    timeStop: ['time_stop', 'string'],
+    debug: ['debug', 'bool'],
+    exit: ['exit', 'bool'],
+    dryrunFiatFunds: ['dryrun_fiat_funds', 'float'],
+    newsession: ['newsession', 'bool'],
+};
+
+/* Send a request to the JSON API, asking for the API token when the request is refused without it */
+async function apiFetch(path, options) {
+    options.cache = 'no-cache';
+    options.headers = Object.assign({}, options.headers);
+    let token = sessionStorage.getItem('apiToken');
+    if (token) {
+        options.headers['Authorization'] = 'Bearer ' + token;
+    }
+    let response = await fetch(path, options);
+    if (response.status === 401 && (token = prompt('API token'))) {
+        sessionStorage.setItem('apiToken', token);
+        options.headers['Authorization'] = 'Bearer ' + token;
+        response = await fetch(path, options);
+    }
+    return response;
+}
+
+/* Trigger a thread action, reloading the dashboard when done */
+async function apiAction(action, reload) {
+    const response = await apiFetch('/api/v1/actions/' + action, {method: 'POST'});
+    if (!response.ok) {
+        alert(action + ': ' + (await response.json()).error);
+        return;
+    }
+    if (reload) {
+        window.location.replace('/');
+    }
+}
+
+/* Save the configuration form, converting the field values to the types of the configuration keys. Disabled fields are read-only. */
+async function apiConfig(form) {
+    const config = {};
+    for (const [name, [key, kind]] of Object.entries(configFields)) {
+        const field = form.elements[name];
+        if (!field || field.disabled) {
+            continue;
+        }
+        const value = field.value.trim();
+        switch (kind) {
+            case 'int':
+            case 'float':
+                config[key] = (value === '' || isNaN(value)) ? value : Number(value);
+                break;
+            case 'bool':
+                config[key] = ['true', 'false'].includes(value.toLowerCase()) ? value.toLowerCase() === 'true' : value;
+                break;
+            default:
+                config[key] = field.value;
+        }
+    }
+    const response = await apiFetch('/api/v1/config', {
+        method: 'PUT',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify(config),
+    });
+    const json = await response.json();
+    if (!response.ok) {
+        alert('Configuration not saved\n' + (json.errors ? Object.entries(json.errors).map(([key, error]) => key + ': ' + error).join('\n') : json.error));
+        return;
+    }
+    window.location.replace('/');
+}
@@ -0,0 +1,353 @@
+package stream
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"cryptopump/functions"
+	"cryptopump/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	backoffMin = 1 * time.Second  /* Wait before the first reconnect */
+	backoffMax = 60 * time.Second /* Maximum wait between reconnects */
+	watchdog   = 1 * time.Second  /* Interval of the staleness and keep alive checks */
+)
+
+// Connect open a websocket, calling event for every event received and errHandler for connection errors.
+// The websocket is closed when stopC is signaled, and doneC is closed once the websocket is closed.
+type Connect func(event func(), errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error)
+
+// Stream define a websocket supervised by Serve
+type Stream struct {
+	Name              string        /* Stream name shown in the stream status */
+	Connect           Connect       /* Open the websocket */
+	Resume            func()        /* Recover the events missed while disconnected, called before reconnecting */
+	Stale             time.Duration /* Reconnect when no event is received for the duration, disabled when 0 */
+	KeepAlive         func() error  /* Called every KeepAliveInterval while connected, reconnect on error */
+	KeepAliveInterval time.Duration
+}
+
+/* Status of the streams of each session */
+var statuses = struct {
+	sync.Mutex
+	m map[*types.Session]map[string]*types.StreamStatus
+}{m: make(map[*types.Session]map[string]*types.StreamStatus)}
+
+// Serve run the stream until the thread is stopped.
+// A stream closed by an error, not receiving events for Stale or failing to keep alive is reconnected on its own, waiting an exponential backoff with jitter.
+func Serve(
+	configData *types.Config,
+	sessionData *types.Session,
+	stream *Stream) {
+
+	status := register(sessionData, stream.Name)
+	defer unregister(sessionData, stream.Name)
+
+	for attempt := 0; ; attempt++ {
+
+		if attempt > 0 {
+
+			/* Wait before reconnecting, returning if the thread is stopped meanwhile */
+			select {
+			case <-sessionData.Done:
+
+				return
+
+			case <-time.After(backoff(attempt)):
+
+			}
+
+			if stream.Resume != nil {
+
+				stream.Resume()
+
+			}
+
+		}
+
+		if healthy := serve(configData, sessionData, stream, status); healthy {
+
+			/* Backoff restarts once a connection received events for backoffMax */
+			attempt = 0
+
+		}
+
+		select {
+		case <-sessionData.Done:
+
+			return
+
+		default:
+
+		}
+
+		update(status, func(s *types.StreamStatus) {
+			s.Connected = false
+			s.Reconnects++
+		})
+
+	}
+
+}
+
+// Status Retrieve the status of the streams of the session, ordered by name
+func Status(
+	sessionData *types.Session) (status []types.StreamStatus) {
+
+	statuses.Lock()
+	defer statuses.Unlock()
+
+	for _, s := range statuses.m[sessionData] {
+
+		status = append(status, *s)
+
+	}
+
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Name < status[j].Name
+	})
+
+	return status
+
+}
+
+/* Connect the stream and supervise it until it is closed or the thread is stopped. Returns true if events were received for backoffMax. */
+func serve(
+	configData *types.Config,
+	sessionData *types.Session,
+	stream *Stream,
+	status *types.StreamStatus) (healthy bool) {
+
+	var mutex sync.Mutex
+	var lastEvent time.Time
+	var lastError error
+
+	event := func() {
+
+		mutex.Lock()
+		lastEvent = time.Now()
+		mutex.Unlock()
+
+	}
+
+	errHandler := func(err error) {
+
+		mutex.Lock()
+		lastError = err
+		mutex.Unlock()
+
+	}
+
+	doneC, stopC, err := stream.Connect(event, errHandler)
+
+	if err != nil {
+
+		failed(configData, sessionData, stream, status, err.Error())
+		return false
+
+	}
+
+	connected := time.Now()
+	keepAlive := connected
+
+	update(status, func(s *types.StreamStatus) {
+		s.Connected = true
+		s.Since = connected
+	})
+
+	/* Check if the connection received events for backoffMax */
+	isHealthy := func() bool {
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return !lastEvent.IsZero() && time.Since(connected) >= backoffMax
+
+	}
+
+	ticker := time.NewTicker(watchdog)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+		case <-doneC:
+
+			mutex.Lock()
+			last, err := lastEvent, lastError
+			mutex.Unlock()
+
+			if !last.IsZero() {
+
+				update(status, func(s *types.StreamStatus) { s.LastEvent = last })
+
+			}
+
+			if err == nil {
+
+				failed(configData, sessionData, stream, status, "Websocket closed")
+
+			} else {
+
+				failed(configData, sessionData, stream, status, err.Error())
+
+			}
+
+			return isHealthy()
+
+		case <-sessionData.Done:
+
+			close(stopC)
+			<-doneC
+
+			return false
+
+		case now := <-ticker.C:
+
+			mutex.Lock()
+			last := lastEvent
+			mutex.Unlock()
+
+			if last.IsZero() {
+
+				last = connected
+
+			} else {
+
+				update(status, func(s *types.StreamStatus) { s.LastEvent = last })
+
+			}
+
+			reason := ""
+
+			if stream.Stale > 0 && now.Sub(last) > stream.Stale {
+
+				reason = "No event for " + now.Sub(last).Round(time.Second).String()
+
+			} else if stream.KeepAlive != nil && now.Sub(keepAlive) >= stream.KeepAliveInterval {
+
+				keepAlive = now
+
+				if err := stream.KeepAlive(); err != nil {
+
+					reason = "Keep alive - " + err.Error()
+
+				}
+
+			}
+
+			if reason != "" {
+
+				close(stopC)
+				<-doneC
+
+				failed(configData, sessionData, stream, status, reason)
+
+				return isHealthy()
+
+			}
+
+		}
+
+	}
+
+}
+
+/* Log and record the reason a stream is reconnected */
+func failed(
+	configData *types.Config,
+	sessionData *types.Session,
+	stream *Stream,
+	status *types.StreamStatus,
+	reason string) {
+
+	update(status, func(s *types.StreamStatus) { s.LastError = reason })
+
+	functions.Logger(&types.LogEntry{
+		Config:   configData,
+		Market:   nil,
+		Session:  sessionData,
+		Order:    &types.Order{},
+		Message:  functions.GetFunctionName() + " - " + stream.Name + " - " + reason,
+		LogLevel: log.DebugLevel,
+	})
+
+}
+
+/* Wait before the reconnect attempt, doubled on every attempt up to backoffMax, with a random jitter of up to half the wait */
+func backoff(
+	attempt int) time.Duration {
+
+	wait := backoffMax
+
+	if attempt <= 6 {
+
+		wait = backoffMin << uint(attempt-1)
+
+		if wait > backoffMax {
+
+			wait = backoffMax
+
+		}
+
+	}
+
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+
+}
+
+/* Register the status of a stream of the session */
+func register(
+	sessionData *types.Session,
+	name string) *types.StreamStatus {
+
+	statuses.Lock()
+	defer statuses.Unlock()
+
+	if statuses.m[sessionData] == nil {
+
+		statuses.m[sessionData] = make(map[string]*types.StreamStatus)
+
+	}
+
+	status := &types.StreamStatus{Name: name}
+	statuses.m[sessionData][name] = status
+
+	return status
+
+}
+
+/* Remove the status of a stream once the thread is stopped */
+func unregister(
+	sessionData *types.Session,
+	name string) {
+
+	statuses.Lock()
+	defer statuses.Unlock()
+
+	delete(statuses.m[sessionData], name)
+
+	if len(statuses.m[sessionData]) == 0 {
+
+		delete(statuses.m, sessionData)
+
+	}
+
+}
+
+/* Update a stream status */
+func update(
+	status *types.StreamStatus,
+	apply func(s *types.StreamStatus)) {
+
+	statuses.Lock()
+	defer statuses.Unlock()
+
+	apply(status)
+
+}
@@ -1,8 +1,11 @@
 package telegram
 
 import (
+	"cryptopump/exchange"
 	"cryptopump/functions"
-	"cryptopump/mysql"
+	"cryptopump/ledger"
+	"cryptopump/node"
+	"cryptopump/risk"
 	"cryptopump/threads"
 	"cryptopump/types"
 	"strconv"
@@ -13,6 +16,14 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+/* Telegram bot of the Master Node and the chats it received messages from, for notifications */
+var notify = struct {
+	sync.Mutex
+	bot    *tgbotapi.BotAPI
+	sender *tgbotapi.BotAPI /* Bot sending notifications to the configured chat when the Master Node bot is not running in the process */
+	chats  map[int64]bool
+}{chats: make(map[int64]bool)}
+
 /* Establish connectivity to Telegram */
 func connect(
 	configData *types.Config,
@@ -76,10 +87,20 @@ func CheckUpdates(
 
 	}
 
-	/* Sleep until Master Node is True */
-	for !sessionData.MasterNode {
+	/* Thread stop signal, as restarted threads are given a new one */
+	done := sessionData.Done
+
+	/* Sleep until Master Node is True, or exit if the thread is stopped */
+	for !node.IsMaster(sessionData) {
+
+		select {
+		case <-done:
+
+			return
 
-		time.Sleep(30000 * time.Millisecond)
+		case <-time.After(30000 * time.Millisecond):
+
+		}
 
 	}
 
@@ -88,6 +109,19 @@ func CheckUpdates(
 		configData,
 		sessionData)
 
+	notify.Lock()
+	notify.bot = sessionData.TgBotAPI
+	notify.Unlock()
+
+	/* Stop notifications when no longer receiving updates */
+	defer func() {
+
+		notify.Lock()
+		notify.bot = nil
+		notify.Unlock()
+
+	}()
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
@@ -104,7 +138,34 @@ func CheckUpdates(
 
 	}
 
-	for update := range updates {
+	ticker := time.NewTicker(30000 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+
+		var update tgbotapi.Update
+
+		/* Stop receiving updates when the thread is stopped or no longer Master Node, as another node or thread becomes Master Node */
+		select {
+		case <-done:
+
+			sessionData.TgBotAPI.StopReceivingUpdates()
+			return
+
+		case <-ticker.C:
+
+			if !node.IsMaster(sessionData) {
+
+				sessionData.TgBotAPI.StopReceivingUpdates()
+				return
+
+			}
+
+			continue
+
+		case update = <-updates:
+
+		}
 
 		/* ignore any non-Message Updates */
 		if update.Message == nil {
@@ -113,6 +174,18 @@ func CheckUpdates(
 
 		}
 
+		/* Fence commands with the master lease, as a former master may have been paused beyond its lease */
+		if !node.CheckRole(sessionData) {
+
+			sessionData.TgBotAPI.StopReceivingUpdates()
+			return
+
+		}
+
+		notify.Lock()
+		notify.chats[update.Message.Chat.ID] = true
+		notify.Unlock()
+
 		switch update.Message.Text {
 		case "/stop":
 
@@ -156,25 +229,70 @@ func CheckUpdates(
 			msg.ReplyToMessageID = update.Message.MessageID
 			send(msg, sessionData)
 
+		case "/reconcile":
+
+			var tmp string
+
+			if reconciliation, err := exchange.Reconcile(configData, sessionData); err != nil {
+
+				tmp = "Reconcile failed: " + err.Error()
+
+			} else {
+
+				tmp = "\f" + reconciliation.String()
+
+			}
+
+			msg = tgbotapi.NewMessage(update.Message.Chat.ID, tmp)
+			msg.ReplyToMessageID = update.Message.MessageID
+			send(msg, sessionData)
+
+		case "/resetrisk":
+
+			risk.Reset(sessionData)
+
+			tmp := "Risk limits reset, buying resumed"
+			msg = tgbotapi.NewMessage(update.Message.Chat.ID, tmp)
+			msg.ReplyToMessageID = update.Message.MessageID
+			send(msg, sessionData)
+
 		case "/report":
 
 			var profit float64
 			var threadCount int
 			var err error
 
-			if profit, err = mysql.GetProfit(sessionData); err != nil {
+			if profit, err = sessionData.Storage.GetProfit(sessionData); err != nil {
 				return
 			}
 
-			if threadCount, err = mysql.GetThreadCount(sessionData); err != nil {
+			if threadCount, err = sessionData.Storage.GetThreadCount(sessionData); err != nil {
 				return
 			}
 
 			tmp := "\f" + "Funds: " + sessionData.SymbolFiat + " " + functions.Float64ToStr(sessionData.SymbolFiatFunds, 2) + "\n" +
-				"Profit: " + functions.Float64ToStr(profit, 2) + "\n" +
-				"Thread Count: " + strconv.Itoa(threadCount) + "\n" +
+				"Profit: " + functions.Float64ToStr(profit, 2) + "\n"
+
+			/* Open lots of the Master Node thread marked at the market price */
+			if price, err := exchange.GetPrice(configData, sessionData, sessionData.Symbol); err == nil {
+
+				if unrealized, err := ledger.Unrealized(configData, sessionData, price); err == nil {
+
+					tmp += "Unrealized " + sessionData.Symbol + ": " + unrealized.StringFixed(2) + "\n"
+
+				}
+
+			}
+
+			tmp += "Thread Count: " + strconv.Itoa(threadCount) + "\n" +
 				"Master: " + sessionData.ThreadID
 
+			if status := risk.Status(sessionData); status != "" {
+
+				tmp += "\n" + "Risk: " + status
+
+			}
+
 			msg = tgbotapi.NewMessage(update.Message.Chat.ID, tmp)
 			msg.ReplyToMessageID = update.Message.MessageID
 			send(msg, sessionData)
@@ -184,3 +302,89 @@ func CheckUpdates(
 	}
 
 }
+
+// Notify Send a message to the chat configured at TGBOTCHATID and the chats the Master Node bot received messages from.
+// When the Master Node bot is not running in the process, the message is sent to the configured chat only.
+// Messages that cannot be delivered are logged at warn level.
+func Notify(
+	configData *types.Config,
+	sessionData *types.Session,
+	message string) {
+
+	var err error
+
+	notify.Lock()
+	defer notify.Unlock()
+
+	bot := notify.bot
+	chats := make(map[int64]bool)
+
+	if bot != nil {
+
+		for chatID := range notify.chats {
+
+			chats[chatID] = true
+
+		}
+
+	}
+
+	if configData.TgBotApikey != "" && configData.TgBotChatID != 0 {
+
+		chats[configData.TgBotChatID] = true
+
+		if bot == nil && notify.sender == nil {
+
+			if notify.sender, err = tgbotapi.NewBotAPI(configData.TgBotApikey); err != nil {
+
+				notify.sender = nil
+				logNotify(sessionData, message, err.Error())
+				return
+
+			}
+
+		}
+
+		if bot == nil {
+
+			bot = notify.sender
+
+		}
+
+	}
+
+	if bot == nil || len(chats) == 0 {
+
+		logNotify(sessionData, message, "no Telegram chat to notify, configure TGBOTCHATID or message the Master Node bot")
+		return
+
+	}
+
+	for chatID := range chats {
+
+		if _, err = bot.Send(tgbotapi.NewMessage(chatID, message)); err != nil {
+
+			logNotify(sessionData, message, strconv.FormatInt(chatID, 10)+" "+err.Error())
+
+		}
+
+	}
+
+}
+
+/* Log a notification that could not be delivered */
+func logNotify(
+	sessionData *types.Session,
+	message string,
+	reason string) {
+
+	functions.Logger(&types.LogEntry{
+		Config:   nil,
+		Market:   nil,
+		Session:  sessionData,
+		Order:    &types.Order{},
+		Message:  "Telegram notification not delivered - " + reason + " - " + message,
+		LogLevel: log.WarnLevel,
+	})
+
+}
@@ -0,0 +1,190 @@
+package threads
+
+import (
+	"sync"
+
+	"cryptopump/functions"
+	"cryptopump/types"
+)
+
+// Runner run a trading thread until it is stopped
+type Runner func(configData *types.Config, sessionData *types.Session, marketData *types.Market)
+
+// Thread define a trading thread run by the Supervisor
+type Thread struct {
+	Session *types.Session /* Session of the thread */
+	Market  *types.Market  /* Market data of the thread */
+	exited  chan struct{}  /* Closed when the thread resources are released */
+}
+
+// Supervisor run independent trading threads in one process.
+// Threads share the database storage of the supervisor, and the exchange clients are shared by the exchange package.
+type Supervisor struct {
+	mutex   sync.Mutex
+	storage types.Storage
+	run     Runner
+	threads []*Thread
+}
+
+// NewSupervisor create a Supervisor running threads with run and sharing storage
+func NewSupervisor(
+	storage types.Storage,
+	run Runner) *Supervisor {
+
+	return &Supervisor{
+		storage: storage,
+		run:     run,
+	}
+
+}
+
+// Start run a thread for the session. Sessions already running are left unchanged.
+func (s *Supervisor) Start(
+	configData *types.Config,
+	sessionData *types.Session,
+	marketData *types.Market) *Thread {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if thread := s.get(sessionData); thread != nil {
+
+		return thread
+
+	}
+
+	sessionData.Storage = s.storage
+	sessionData.Done = make(chan struct{})
+
+	thread := &Thread{
+		Session: sessionData,
+		Market:  marketData,
+		exited:  make(chan struct{}),
+	}
+
+	s.threads = append(s.threads, thread)
+
+	go func() {
+
+		/* Release the thread resources when the thread returns or exits with ExitThreadID */
+		defer func() {
+
+			exitThreadID(sessionData)
+
+			s.mutex.Lock()
+			s.remove(thread)
+			s.mutex.Unlock()
+
+			close(thread.exited)
+
+		}()
+
+		s.run(configData, sessionData, marketData)
+
+	}()
+
+	return thread
+
+}
+
+// Stop signal the thread of the session to stop. The thread exits once buying/selling is over.
+func (s *Supervisor) Stop(
+	sessionData *types.Session) {
+
+	s.mutex.Lock()
+	thread := s.get(sessionData)
+	s.mutex.Unlock()
+
+	if thread != nil {
+
+		stop(sessionData)
+
+	}
+
+}
+
+// Restart stop the thread of the session and start it again with its ThreadID, symbol and reloaded configuration.
+// Restart waits for the thread to exit, which lasts while buying/selling.
+func (s *Supervisor) Restart(
+	sessionData *types.Session) *Thread {
+
+	s.mutex.Lock()
+	thread := s.get(sessionData)
+	s.mutex.Unlock()
+
+	if thread == nil {
+
+		return nil
+
+	}
+
+	stop(sessionData)
+
+	<-thread.exited
+
+	return s.Start(functions.GetConfigData(sessionData), sessionData, thread.Market)
+
+}
+
+// Threads Retrieve the threads running
+func (s *Supervisor) Threads() []*Thread {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]*Thread(nil), s.threads...)
+
+}
+
+// GetThread Retrieve a running thread by ThreadID
+func (s *Supervisor) GetThread(
+	threadID string) *Thread {
+
+	for _, thread := range s.Threads() {
+
+		if thread.Session.ThreadID == threadID {
+
+			return thread
+
+		}
+
+	}
+
+	return nil
+
+}
+
+/* Retrieve the running thread of the session */
+func (s *Supervisor) get(
+	sessionData *types.Session) *Thread {
+
+	for _, thread := range s.threads {
+
+		if thread.Session == sessionData {
+
+			return thread
+
+		}
+
+	}
+
+	return nil
+
+}
+
+/* Remove a thread from the running threads */
+func (s *Supervisor) remove(
+	thread *Thread) {
+
+	for key := range s.threads {
+
+		if s.threads[key] == thread {
+
+			s.threads = append(s.threads[:key], s.threads[key+1:]...)
+			return
+
+		}
+
+	}
+
+}
@@ -2,19 +2,115 @@ package threads
 
 import (
 	"cryptopump/functions"
-	"cryptopump/mysql"
 	"cryptopump/node"
 	"cryptopump/types"
 	"os"
+	"runtime"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// ExitThreadID Cleanly exit a Thread
+/* Serialize stopping threads, as the Done channel is closed once */
+var mutex sync.Mutex
+
+// ExitThreadID Cleanly exit a Thread.
+// Threads run by the Supervisor are stopped and the calling goroutine exits, while other threads exit the process.
 func ExitThreadID(
 	sessionData *types.Session) {
 
+	if sessionData.Done != nil {
+
+		stop(sessionData)
+		runtime.Goexit()
+
+	}
+
+	exitThreadID(sessionData)
+
+	os.Exit(1)
+
+}
+
+// IsStopped Check if the thread was stopped
+func IsStopped(
+	sessionData *types.Session) bool {
+
+	select {
+	case <-sessionData.Done:
+
+		return true
+
+	default:
+
+		return false
+
+	}
+
+}
+
+// RunTaskAtInterval Run task every interval after the start delay, until the thread is stopped
+func RunTaskAtInterval(
+	sessionData *types.Session,
+	task func(),
+	interval time.Duration,
+	startDelay time.Duration) {
+
+	done := sessionData.Done
+
+	go func() {
+
+		select {
+		case <-done:
+
+			return
+
+		case <-time.After(startDelay):
+
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+
+			select {
+			case <-done:
+
+				return
+
+			case <-ticker.C:
+
+				task()
+
+			}
+
+		}
+
+	}()
+
+}
+
+/* Signal the thread to stop, closing its websockets */
+func stop(
+	sessionData *types.Session) {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if !IsStopped(sessionData) {
+
+		close(sessionData.Done)
+
+	}
+
+}
+
+/* Release the thread resources once buying/selling is over */
+func exitThreadID(
+	sessionData *types.Session) {
+
 	/* Verify wether buying/selling to allow graceful session exit */
 	for sessionData.Busy {
 		time.Sleep(time.Millisecond * 200)
@@ -31,7 +127,7 @@ func ExitThreadID(
 	unlockThreadID(sessionData)
 
 	/* Delete session from Session table */
-	_ = mysql.DeleteSession(sessionData)
+	_ = sessionData.Storage.DeleteSession(sessionData)
 
 	functions.Logger(&types.LogEntry{
 		Config:   nil,
@@ -42,8 +138,6 @@ func ExitThreadID(
 		LogLevel: log.InfoLevel,
 	})
 
-	os.Exit(1)
-
 }
 
 /* Remove lock for threadID */
@@ -1,26 +1,28 @@
 package types
 
 import (
-	"database/sql"
 	"time"
 
-	"github.com/adshao/go-binance/v2"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/sdcoffey/techan"
+	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
 )
 
-// Order struct define an exchange order
+// Order struct define an exchange order. Prices, quantities and commissions are exact decimals, as in the exchange JSON strings
 type Order struct {
-	ClientOrderID           string  `json:"clientOrderId"`
-	CumulativeQuoteQuantity float64 `json:"cumulativeQuoteQty"`
-	ExecutedQuantity        float64 `json:"executedQty"`
-	OrderID                 int     `json:"orderId"`
-	Price                   float64 `json:"price"`
-	Side                    string  `json:"side"`
-	Status                  string  `json:"status"`
-	Symbol                  string  `json:"symbol"`
-	TransactTime            int64   `json:"transactTime"`
+	ClientOrderID           string          `json:"clientOrderId"`
+	CumulativeQuoteQuantity decimal.Decimal `json:"cumulativeQuoteQty"`
+	ExecutedQuantity        decimal.Decimal `json:"executedQty"`
+	OrderID                 int             `json:"orderId"`
+	Price                   decimal.Decimal `json:"price"`
+	Side                    string          `json:"side"`
+	Status                  string          `json:"status"`
+	Symbol                  string          `json:"symbol"`
+	TransactTime            int64           `json:"transactTime"`
+	CommissionAmount        decimal.Decimal `json:"commissionAmount"` /* Commission paid in CommissionAsset */
+	CommissionAsset         string          `json:"commissionAsset"`
+	Commission              decimal.Decimal `json:"commission"` /* Commission paid, converted to the quote currency at fill time */
 	ThreadID                int
 	ThreadIDSession         int
 	OrderIDSource           int /* Used for logging purposes to define source OrderID for a sale */
@@ -28,12 +30,16 @@ type Order struct {
 
 // Kline struct define a kline
 type Kline struct {
-	OpenTime int64  `json:"openTime"`
-	Open     string `json:"open"`
-	High     string `json:"high"`
-	Low      string `json:"low"`
-	Close    string `json:"close"`
-	Volume   string `json:"volume"`
+	OpenTime             int64  `json:"openTime"`
+	Open                 string `json:"open"`
+	High                 string `json:"high"`
+	Low                  string `json:"low"`
+	Close                string `json:"close"`
+	Volume               string `json:"volume"`
+	CloseTime            int64  `json:"closeTime"`
+	QuoteVolume          string `json:"quoteAssetVolume"`
+	ActiveBuyVolume      string `json:"takerBuyBaseAssetVolume"`
+	ActiveBuyQuoteVolume string `json:"takerBuyQuoteAssetVolume"`
 }
 
 // WsKline struct define websocket kline
@@ -56,17 +62,62 @@ type WsKline struct {
 	ActiveBuyQuoteVolume string `json:"Q"` /* Currently not in use */
 }
 
+// WsBookTicker struct define websocket best bid and ask prices
+type WsBookTicker struct {
+	UpdateID     int64  `json:"u"`
+	Symbol       string `json:"s"`
+	BestBidPrice string `json:"b"`
+	BestBidQty   string `json:"B"`
+	BestAskPrice string `json:"a"`
+	BestAskQty   string `json:"A"`
+}
+
+// WsDepth struct define websocket partial order book depth, best prices first
+type WsDepth struct {
+	LastUpdateID int64
+	Symbol       string
+	Bids         []DepthLevel
+	Asks         []DepthLevel
+}
+
+// DepthLevel struct define an order book price level
+type DepthLevel struct {
+	Price    string
+	Quantity string
+}
+
+// OrderBook define the local order book maintained from the depth stream, best prices first
+type OrderBook struct {
+	Bids      []PriceLevel
+	Asks      []PriceLevel
+	TimeStamp time.Time /* Time of last depth update */
+}
+
+// PriceLevel define an order book price level
+type PriceLevel struct {
+	Price    float64
+	Quantity float64
+}
+
 // PriceChangeStats define price change stats
 type PriceChangeStats struct {
 	HighPrice string `json:"highPrice"`
 	LowPrice  string `json:"lowPrice"`
 }
 
-// ExchangeInfo define exchange order size
+// ExchangeInfo define exchange symbol assets, status and order filters
 type ExchangeInfo struct {
-	MaxQuantity string `json:"maxQty"`
-	MinQuantity string `json:"minQty"`
-	StepSize    string `json:"stepSize"`
+	Symbol         string `json:"symbol"`
+	Status         string `json:"status"`
+	BaseAsset      string `json:"baseAsset"`
+	QuoteAsset     string `json:"quoteAsset"`
+	MaxQuantity    string `json:"maxQty"`
+	MinQuantity    string `json:"minQty"`
+	StepSize       string `json:"stepSize"`
+	TickSize       string `json:"tickSize"`
+	MinNotional    string `json:"minNotional"`
+	BasePrecision  int    `json:"baseAssetPrecision"`
+	QuotePrecision int    `json:"quotePrecision"`
 }
 
 // Session struct define session elements
@@ -85,27 +136,203 @@ type Session struct {
 	ForceSell            bool             /* This boolean when True force SELL transaction */
 	ListenKey            string           /* Listen key for user stream service */
 	MasterNode           bool             /* This boolean is true when Master Node is elected */
+	MasterToken          int64            /* Fencing token of the master lease */
+	MasterExpires        time.Time        /* Local time the master lease expires, measured from before it was acquired */
 	TgBotAPI             *tgbotapi.BotAPI /* This variable holds Telegram session bot */
-	Db                   *sql.DB          /* mySQL database connection */
-	Clients              Client           /* Binance client connection */
+	Storage              Storage          /* Database storage for orders, threads and sessions */
+	Exchange             Exchange         /* Exchange client connection */
+	Clock                Clock            /* Time source for the session. System time is used when nil */
+	Backtest             bool             /* Session replaying historical data, logging is disabled */
 	KlineData            []KlineData      /* kline data format for go-echart plotter */
-	StopWs               bool             /* Control when to stop Ws Channels */
 	Busy                 bool             /* Control wether buy/selling to allow graceful session exit */
 	MinQuantity          float64          /* Defines the minimum quantity allowed by exchange */
 	MaxQuantity          float64          /* Defines the maximum quantity allowed by exchange */
 	StepSize             float64          /* Defines the intervals that a quantity can be increased/decreased by exchange */
+	TickSize             float64          /* Defines the intervals that a price can be increased/decreased by exchange */
+	MinNotional          float64          /* Defines the minimum order value (price * quantity) allowed by exchange */
+	BasePrecision        int              /* Defines the decimals of the base asset */
+	QuotePrecision       int              /* Defines the decimals of the quote asset */
+	OrderUpdate          chan *Order      /* Order updates from the user data stream, waking order waits. Waits sleep when nil */
+	Done                 chan struct{}    /* Closed when the thread is stopped. Sessions not run by the thread supervisor exit the process when nil */
+}
+
+// Exchange define the operations an exchange adapter must implement to be used by CryptoPump
+type Exchange interface {
+	ExchangeOrders
+	ExchangeMarketData
+	ExchangeAccount
+	ExchangeUserData
+}
+
+// ExchangeOrders define exchange order operations
+type ExchangeOrders interface {
+	GetOrder(sessionData *Session, orderID int64) (order *Order, err error)
+	BuyOrder(sessionData *Session, quantity string) (order *Order, err error)
+	SellOrder(marketData *Market, sessionData *Session, quantity string) (order *Order, err error)
+	CancelOrder(sessionData *Session, orderID int64) (order *Order, err error)
+	GetOpenOrders(sessionData *Session) (orders []*Order, err error)
+	GetRecentOrders(sessionData *Session, limit int) (orders []*Order, err error)
+}
+
+// ExchangeMarketData define exchange market data operations
+type ExchangeMarketData interface {
+	GetSymbols(sessionData *Session) (symbols []*ExchangeInfo, err error)
+	GetKlines(sessionData *Session, interval string, startTime int64, endTime int64, limit int) (klines []*Kline, err error)
+	GetPriceChangeStats(sessionData *Session) (priceChangeStats []*PriceChangeStats, err error)
+	GetPrice(sessionData *Session, symbol string) (price float64, err error)
+	NewSetServerTimeService(sessionData *Session) (err error)
+	WsBookTickerServe(sessionData *Session, wsHandler *WsHandler, errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error)
+	WsDepthServe(sessionData *Session, wsHandler *WsHandler, errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error)
+	WsKlineServe(sessionData *Session, interval string, wsHandler *WsHandler, errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error)
+}
+
+// ExchangeAccount define exchange account operations
+type ExchangeAccount interface {
+	GetSymbolFunds(sessionData *Session) (balance float64, err error)
+}
+
+// ExchangeUserData define exchange user data stream operations
+type ExchangeUserData interface {
+	GetUserStreamServiceListenKey(sessionData *Session) (listenKey string, err error)
+	KeepAliveUserStreamServiceListenKey(sessionData *Session) (err error)
+	WsUserDataServe(sessionData *Session, wsHandler *WsHandler, errHandler func(err error)) (doneC chan struct{}, stopC chan struct{}, err error)
 }
 
-// Client struct for client libraries
-type Client struct {
-	Binance *binance.Client
+// Clock define the time source used by a session, so backtests can replay historical time
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// Storage define the persistence operations for orders, thread transactions, sessions, klines, the lot ledger and the risk state
+type Storage interface {
+	SaveOrder(sessionData *Session, clientOrderID string, cumulativeQuoteQuantity decimal.Decimal, executedQuantity decimal.Decimal, orderID int64, price decimal.Decimal, side string, status string, symbol string, transactTime int64, commissionAmount decimal.Decimal, commissionAsset string, commission decimal.Decimal) (err error)
+	UpdateOrder(sessionData *Session, orderID int64, cumulativeQuoteQuantity decimal.Decimal, executedQuantity decimal.Decimal, price decimal.Decimal, status string) (err error)
+	UpdateOrderCommission(sessionData *Session, orderID int64, tradeID int64, commissionAmount decimal.Decimal, commissionAsset string, commission decimal.Decimal) (err error)
+	SaveSession(configData *Config, sessionData *Session) (err error)
+	UpdateSession(configData *Config, sessionData *Session) (err error)
+	DeleteSession(sessionData *Session) (err error)
+	SaveThreadTransaction(sessionData *Session, orderID int64, cumulativeQuoteQuantity decimal.Decimal, price decimal.Decimal, executedQuantity decimal.Decimal) (err error)
+	DeleteThreadTransactionByOrderID(sessionData *Session, orderID int) (err error)
+	UpdateThreadTransaction(sessionData *Session, orderID int, cumulativeQuoteQuantity decimal.Decimal, price decimal.Decimal, executedQuantity decimal.Decimal) (err error)
+	GetThreadTransactionByOrderID(sessionData *Session, orderID int) (price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, err error)
+	GetThreadTransactionCount(sessionData *Session) (count int, err error)
+	GetLastOrderTransactionPrice(sessionData *Session, side string) (price decimal.Decimal, err error)
+	GetLastOrderTransactionSide(sessionData *Session) (side string, err error)
+	GetOrderTransactionSideLastTwo(sessionData *Session) (side1 string, side2 string, err error)
+	GetOrderSymbol(sessionData *Session) (symbol string, err error)
+	GetThreadTransactionDistinct(sessionData *Session) (threadID string, threadIDSession string, err error)
+	GetOrderTransactionPending(sessionData *Session) (orderID int64, symbol string, err error)
+	GetOrderByOrderID(sessionData *Session, orderID int64) (order Order, err error)
+	GetOrdersBySymbol(sessionData *Session, transactTime int64) (orders []Order, err error)
+	GetThreadTransactionByPrice(marketData *Market, sessionData *Session) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error)
+	GetThreadTransactionByStopLoss(marketData *Market, sessionData *Session, stopLoss float64) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error)
+	GetThreadTransactionByTrailingStop(marketData *Market, sessionData *Session, trailingStop float64) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error)
+	UpdateThreadTransactionHighPrice(marketData *Market, sessionData *Session) (err error)
+	GetThreadLastTransaction(sessionData *Session) (orderID int, price decimal.Decimal, executedQuantity decimal.Decimal, cumulativeQuoteQty decimal.Decimal, transactTime int64, err error)
+	GetThreadTransactiontUpmarketPriceCount(sessionData *Session, price float64) (count int, err error)
+	GetOrderTransactionCount(sessionData *Session, side string) (count float64, err error)
+	GetThreadTransactionByThreadID(sessionData *Session) (orders []Order, err error)
+	GetProfitByThreadID(sessionData *Session) (profit float64, err error)
+	GetProfit(sessionData *Session) (profit float64, err error)
+	GetThreadCount(sessionData *Session) (count int, err error)
+	GetThreadAmount(sessionData *Session) (amount float64, err error)
+	GetThreadPositions(sessionData *Session) (positions []Position, err error)
+	AcquireLease(sessionData *Session, name string, duration time.Duration) (lease Lease, err error)
+	CheckLease(sessionData *Session, name string, token int64) (held bool, err error)
+	ReleaseLease(sessionData *Session, name string) (err error)
+	SaveKline(sessionData *Session, kline WsKline) (err error)
+	GetKlines(sessionData *Session, interval string, startTime int64, endTime int64) (klines []WsKline, err error)
+	SaveLot(sessionData *Session, lot Lot) (err error)
+	GetOpenLots(sessionData *Session) (lots []Lot, err error)
+	SaveFill(sessionData *Session, fill Fill) (err error)
+	GetTrades(sessionData *Session, startTime int64, endTime int64, threadIDs []string) (trades []Trade, err error)
+	GetRisk(sessionData *Session) (risk Risk, err error)
+	SaveRisk(sessionData *Session, risk Risk) (err error)
+	DeleteRisk(sessionData *Session) (err error)
+}
+
+// Lot define a filled BUY order in the ledger, held until SELL orders close its quantity
+type Lot struct {
+	OrderID    int
+	Symbol     string
+	Quantity   decimal.Decimal /* Quantity bought */
+	Remaining  decimal.Decimal /* Quantity not closed by SELL orders */
+	Cost       decimal.Decimal /* Fiat amount paid */
+	Commission decimal.Decimal /* BUY commission in the quote currency */
+	OpenTime   int64           /* BUY TransactTime */
+}
+
+// Fill define the part of a SELL order closing a lot in the ledger, with the profit realized
+type Fill struct {
+	SellOrderID  int
+	LotOrderID   int
+	Symbol       string
+	Quantity     decimal.Decimal /* Lot quantity closed */
+	Proceeds     decimal.Decimal /* Fiat amount received for the quantity */
+	Cost         decimal.Decimal /* Fiat amount paid for the quantity */
+	Commission   decimal.Decimal /* BUY and SELL commissions for the quantity */
+	Profit       decimal.Decimal /* Proceeds less cost and commissions */
+	HoldingTime  int64           /* Milliseconds from BUY to SELL */
+	TransactTime int64           /* SELL TransactTime */
+}
+
+// Position define the Thread transactions held for a symbol across all threads
+type Position struct {
+	Symbol   string
+	Count    int     /* Thread transactions */
+	Quantity float64 /* Quantity bought */
+	Amount   float64 /* Fiat amount paid */
+}
+
+// Trade define a filled order for exports, with the cost and profit realized by the ledger fills of a SELL order
+type Trade struct {
+	OrderID          int
+	ThreadID         string
+	Symbol           string
+	Side             string
+	Quantity         decimal.Decimal /* Executed quantity */
+	QuoteQuantity    decimal.Decimal /* Fiat amount paid or received */
+	CommissionAmount decimal.Decimal /* Commission paid in CommissionAsset */
+	CommissionAsset  string
+	Commission       decimal.Decimal     /* Commission paid, converted to the quote currency at fill time */
+	Cost             decimal.NullDecimal /* Fiat amount paid for the lots closed by a SELL, NULL without ledger fills */
+	Profit           decimal.NullDecimal /* Profit realized by a SELL, NULL without ledger fills */
+	TransactTime     int64
+}
+
+// Risk define the circuit breaker state of the account, shared by the threads using the database and kept across restarts until reset
+type Risk struct {
+	Paused   string  /* Circuit breaker breached, empty when buying is allowed */
+	Peak     float64 /* Realized and unrealized profit peak */
+	Day      int64   /* Day of DayStart in Unix milliseconds, 0 when no state is saved */
+	DayStart float64 /* Realized and unrealized profit at the start of the day */
+}
+
+// Lease define the owner of a named lease shared by the nodes using the database.
+// Token is incremented when the owner changes, fencing former owners.
+type Lease struct {
+	Name     string
+	ThreadID string
+	Token    int64
+}
+
+// StreamStatus define the health of a supervised websocket stream
+type StreamStatus struct {
+	Name       string
+	Connected  bool
+	Since      time.Time /* Time of the last connection */
+	LastEvent  time.Time /* Time of the last event received */
+	LastError  string    /* Reason of the last reconnect */
+	Reconnects int
 }
 
 // WsHandler struct for websocket handlers for exchanges
 type WsHandler struct {
-	BinanceWsKline         func(event *binance.WsKlineEvent)      /* WsKlineServe serve websocket kline handler */
-	BinanceWsBookTicker    func(event *binance.WsBookTickerEvent) /* WsBookTicker serve websocket kline handler */
-	BinanceWsUserDataServe func(message []byte)                   /* WsUserDataServe serve user data handler with listen key */
+	WsKline         func(kline WsKline)       /* WsKlineServe serve websocket kline handler */
+	WsBookTicker    func(event *WsBookTicker) /* WsBookTicker serve websocket book ticker handler */
+	WsDepth         func(event *WsDepth)      /* WsDepth serve websocket partial depth handler */
+	WsUserDataServe func(message []byte)      /* WsUserDataServe serve user data handler with listen key */
 }
 
 // KlineData struct define kline retention for e-charts plotting
@@ -117,25 +344,56 @@ type KlineData struct {
 
 // Market struct define realtime market data
 type Market struct {
-	Rsi3                      float64            /* Relative Strength Index for 3 periods */
-	Rsi7                      float64            /* Relative Strength Index for 7 periods */
-	Rsi14                     float64            /* Relative Strength Index for 14 periods */
-	MACD                      float64            /* Moving average convergence divergence */
-	Price                     float64            /* Market Price */
-	PriceChangeStatsHighPrice float64            /* High price for 1 period */
-	PriceChangeStatsLowPrice  float64            /* Low price for 1 period */
-	Direction                 int                /* Market Direction */
-	TimeStamp                 time.Time          /* Time of last retrieved market Data */
-	Series                    *techan.TimeSeries /* kline data format for technical analysis */
+	Rsi3                      float64               /* Relative Strength Index for 3 periods */
+	Rsi7                      float64               /* Relative Strength Index for 7 periods */
+	Rsi14                     float64               /* Relative Strength Index for 14 periods */
+	MACD                      float64               /* Moving average convergence divergence */
+	Price                     float64               /* Market Price */
+	PriceChangeStatsHighPrice float64               /* High price for 1 period */
+	PriceChangeStatsLowPrice  float64               /* Low price for 1 period */
+	Direction                 int                   /* Market Direction */
+	Imbalance                 float64               /* Order book imbalance of the quantities bid and asked. -1 = only asks / 1 = only bids */
+	Book                      *OrderBook            /* Local order book, replaced on every depth update */
+	BollingerUpper            float64               /* Bollinger upper band */
+	BollingerMiddle           float64               /* Bollinger middle band, the moving average */
+	BollingerLower            float64               /* Bollinger lower band */
+	BollingerPercentB         float64               /* Bollinger %B, close price position relative to the bands. 0 = lower band / 1 = upper band */
+	BollingerBandwidth        float64               /* Bollinger bandwidth, distance between the bands relative to the middle band */
+	TimeStamp                 time.Time             /* Time of last retrieved market Data */
+	Series                    *techan.TimeSeries    /* kline data format for technical analysis */
+	Timeframes                map[string]*Timeframe /* Kline data and indicators by kline interval other than 1m */
+	Ready                     bool                  /* Indicators warmed up with enough klines */
+}
+
+// Timeframe define the kline data and indicators of a kline interval other than 1m
+type Timeframe struct {
+	Interval  string             /* Kline interval, e.g. 5m, 1h */
+	Rsi3      float64            /* Relative Strength Index for 3 periods */
+	Rsi7      float64            /* Relative Strength Index for 7 periods */
+	Rsi14     float64            /* Relative Strength Index for 14 periods */
+	MACD      float64            /* Moving average convergence divergence */
+	TimeStamp time.Time          /* Time of last retrieved kline */
+	Ready     bool               /* Indicators warmed up with enough klines */
+	Series    *techan.TimeSeries /* kline data format for technical analysis */
+}
+
+// Condition define a comparison of a kline interval indicator with a value
+type Condition struct {
+	Interval  string  /* Kline interval, e.g. 1m, 1h */
+	Indicator string  /* rsi3, rsi7, rsi14 or macd */
+	Operator  string  /* <, <=, > or >= */
+	Value     float64 /* Value compared, from a number or a configuration key */
 }
 
 // Config struct for configuration
 type Config struct {
-	ThreadID                               string /* For index.html population */
-	Apikey                                 string /* Exchange API Key */
-	Secretkey                              string /* Exchange Secret Key */
-	ApikeyTestNet                          string /* API key for exchange test network, used with launch.json */
-	SecretkeyTestNet                       string /* Secret key for exchange test network, used with launch.json */
+	ThreadID                               string  /* For index.html population */
+	Apikey                                 string  /* Exchange API Key */
+	Secretkey                              string  /* Exchange Secret Key */
+	ApikeyTestNet                          string  /* API key for exchange test network, used with launch.json */
+	SecretkeyTestNet                       string  /* Secret key for exchange test network, used with launch.json */
+	BollingerWindow                        int     /* Number of klines for Bollinger Bands */
+	BollingerDeviation                     float64 /* Standard deviations for Bollinger upper and lower bands */
 	Buy24hsHighpriceEntry                  float64
 	BuyDirectionDown                       int
 	BuyDirectionUp                         int
@@ -147,13 +405,26 @@ type Config struct {
 	BuyRepeatThresholdDownSecondStartCount int
 	BuyRepeatThresholdUp                   float64
 	BuyRsi7Entry                           float64
-	BuyWait                                int /* Wait time between BUY transactions in seconds */
+	BuyWait                                int     /* Wait time between BUY transactions in seconds */
+	BuyBollinger                           bool    /* Define if INIT and DOWN buys require Bollinger %B lower than BuyBollingerPercentB */
+	BuyBollingerPercentB                   float64 /* Bollinger %B threshold to buy. 0 = lower band */
 	ExchangeComission                      float64
+	WsStaleTimeout                         int     /* Reconnect a market data websocket receiving no event for the seconds, disabled when 0 */
+	MaxSpread                              float64 /* Hold BUY and market SELL while the bid-ask spread exceeds the percentage of the mid price, disabled when 0 */
+	MaxSlippage                            float64 /* Downsize BUY and hold market SELL while the expected fill price slips the percentage from the best price, disabled when 0 */
 	ProfitMin                              float64
 	SellWaitBeforeCancel                   int     /* Wait time before cancelling a sale in seconds */
 	SellWaitAfterCancel                    int     /* Wait time before selling after a cancel in seconds */
 	SellToCover                            bool    /* Define if will sell to cover low funds */
 	SellHoldOnRSI3                         float64 /* Hold sale if RSI3 above defined threshold */
+	SellHoldOnBollinger                    float64 /* Hold sale if Bollinger %B above defined threshold, disabled when 0. 1 = upper band */
+	RiskMaxThreads                         int     /* Pause BUY while the thread transactions across all threads reach the count, disabled when 0 */
+	RiskMaxFiatSymbol                      float64 /* Pause BUY while the fiat amount held in the symbol across all threads would exceed the amount, disabled when 0 */
+	RiskMaxFiatTotal                       float64 /* Pause BUY while the fiat amount held across all threads would exceed the amount, disabled when 0 */
+	RiskMaxDailyLoss                       float64 /* Pause BUY until reset when realized and unrealized profit falls the fiat amount within a day, disabled when 0 */
+	RiskMaxDrawdown                        float64 /* Pause BUY until reset when realized and unrealized profit falls the fiat amount below its peak, disabled when 0 */
+	StopLoss                               float64 /* Sell at market when price falls the percentage below the BUY price, disabled when 0 */
+	TrailingStop                           float64 /* Sell at market when price falls the percentage below the high-water mark since BUY, disabled when 0 */
 	SymbolFiat                             string
 	SymbolFiatStash                        float64
 	Symbol                                 string
@@ -163,11 +434,18 @@ type Config struct {
 	Debug                                  bool
 	Exit                                   bool
 	DryRun                                 bool        /* Dry Run mode */
+	DryRunFiatFunds                        float64     /* Virtual fiat funds for Dry Run mode. Exchange funds are used when zero */
 	NewSession                             bool        /* Force a new session instead of resume */
+	BuyCondition                           string      /* Conditions on kline interval indicators required to BUY, e.g. "1h rsi14 < 50 and 1m rsi7 < buy_rsi7_entry" */
+	BuyConditions                          []Condition /* Conditions parsed from BuyCondition */
+	BuyConditionError                      error       /* Error parsing BuyCondition, BUY is refused while set */
+	Timeframes                             []string    /* Kline intervals other than 1m referenced by BuyConditions */
 	ConfigTemplateList                     interface{} /* List of configuration templates available in ./config folder */
+	ThreadList                             interface{} /* List of threads running in the process, indexed by ThreadID */
 	ExchangeName                           string      /* Exchange name */
 	TestNet                                bool        /* Use Exchange TestNet */
 	TgBotApikey                            string      /* Telegram bot API key */
+	TgBotChatID                            int64       /* Telegram chat receiving notifications, in addition to the chats messaging the Master Node bot, disabled when 0 */
 	HTMLSnippet                            interface{} /* Store kline plotter graph for html output */
 }
 